@@ -0,0 +1,118 @@
+package accounts
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryValidate(t *testing.T) {
+	tests := []struct {
+		testName    string
+		entry       Entry
+		expectedErr bool
+	}{
+		{testName: "Earn", entry: Entry{Type: EntryEarn, Points: 10}},
+		{testName: "EarnNothing", entry: Entry{Type: EntryEarn, Points: 0}},
+		{testName: "NegativeEarn", entry: Entry{Type: EntryEarn, Points: -1}, expectedErr: true},
+		{testName: "NegativeSpend", entry: Entry{Type: EntryEarn, Points: 1, Spend: -1}, expectedErr: true},
+		{testName: "AdjustDown", entry: Entry{Type: EntryAdjust, Points: -5}},
+		{testName: "EmptyAdjust", entry: Entry{Type: EntryAdjust, Points: 0}, expectedErr: true},
+		{testName: "AdjustSpend", entry: Entry{Type: EntryAdjust, Points: 0, Spend: -12.5}},
+		{testName: "Redeem", entry: Entry{Type: EntryRedeem, Points: -5}},
+		{testName: "PositiveRedeem", entry: Entry{Type: EntryRedeem, Points: 5}, expectedErr: true},
+		{testName: "Refund", entry: Entry{Type: EntryRefund, Points: 5}},
+		{testName: "NegativeRefund", entry: Entry{Type: EntryRefund, Points: -5}, expectedErr: true},
+		{testName: "PositiveExpire", entry: Entry{Type: EntryExpire, Points: 5}, expectedErr: true},
+		{testName: "UnknownType", entry: Entry{Type: "gift", Points: 5}, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Equal(t, test.expectedErr, test.entry.Validate() != nil)
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore()
+	account := store.CreateAccount(Account{Name: "Jane"})
+	assert.NotEmpty(t, account.Id)
+	assert.False(t, account.CreatedAt.IsZero())
+
+	earned, err := store.Append(Entry{AccountId: account.Id, Type: EntryEarn, Points: 100, ReceiptId: "receipt", Spend: 12.5})
+	require.NoError(t, err)
+	assert.Equal(t, 100, earned.Balance)
+
+	_, err = store.Append(Entry{AccountId: account.Id, Type: EntryRedeem, Points: -101})
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	_, err = store.Append(Entry{AccountId: "unknown", Type: EntryEarn, Points: 1})
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	_, err = store.Append(Entry{AccountId: account.Id, Type: EntryRedeem, Points: -60})
+	require.NoError(t, err)
+	adjusted, err := store.Append(Entry{AccountId: account.Id, Type: EntryAdjust, Points: -50, Reason: "rescored"})
+	require.NoError(t, err)
+	assert.Equal(t, -10, adjusted.Balance, "adjustments may take the balance below zero")
+
+	balance, exists := store.Balance(account.Id)
+	assert.True(t, exists)
+	assert.Equal(t, -10, balance)
+	ledger, exists := store.Ledger(account.Id)
+	assert.True(t, exists)
+	require.Len(t, ledger, 3)
+	assert.Equal(t, []EntryType{EntryEarn, EntryRedeem, EntryAdjust}, []EntryType{ledger[0].Type, ledger[1].Type, ledger[2].Type})
+
+	accountId, exists := store.ReceiptAccount("receipt")
+	assert.True(t, exists)
+	assert.Equal(t, account.Id, accountId)
+	_, exists = store.ReceiptAccount("unknown")
+	assert.False(t, exists)
+
+	points, spend, exists := store.Activity(account.Id, earned.CreatedAt)
+	assert.True(t, exists)
+	assert.Equal(t, 100, points, "only receipts' earn and adjust entries count as activity")
+	assert.Equal(t, 12.5, spend)
+	points, _, _ = store.Activity(account.Id, earned.CreatedAt.Add(time.Nanosecond))
+	assert.Zero(t, points)
+	_, _, exists = store.Activity("unknown", earned.CreatedAt)
+	assert.False(t, exists)
+	assert.Equal(t, []string{account.Id}, store.AccountIds())
+	_, exists = store.Balance("unknown")
+	assert.False(t, exists)
+	_, exists = store.Ledger("unknown")
+	assert.False(t, exists)
+}
+
+func TestStoreConcurrentWrites(t *testing.T) {
+	store := NewStore()
+	account := store.CreateAccount(Account{Name: "Jane"})
+
+	// Half the goroutines earn 10 points and half try to redeem 15, so some redemptions must fail
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				store.Append(Entry{AccountId: account.Id, Type: EntryEarn, Points: 10})
+			} else {
+				store.Append(Entry{AccountId: account.Id, Type: EntryRedeem, Points: -15})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ledger, _ := store.Ledger(account.Id)
+	sum := 0
+	for _, entry := range ledger {
+		sum += entry.Points
+		assert.Equal(t, sum, entry.Balance, "every entry's balance must be the sum of the ledger up to it")
+		assert.GreaterOrEqual(t, entry.Balance, 0)
+	}
+	balance, _ := store.Balance(account.Id)
+	assert.Equal(t, sum, balance)
+}
@@ -0,0 +1,283 @@
+package accounts
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How earned points expire
+type ExpirationKind string
+
+const (
+	// Points never expire
+	ExpireNever ExpirationKind = "never"
+	// Points expire a number of days after they were earned
+	ExpireAfterDays ExpirationKind = "days"
+	// Points expire at the end of the year, in UTC, they were earned in
+	ExpireEndOfYear ExpirationKind = "end-of-year"
+	// Every point of an account expires a number of days after its last earn or redeem entry
+	ExpireAfterInactivity ExpirationKind = "inactivity"
+)
+
+// When points expire, the zero value never expires them
+type ExpirationPolicy struct {
+	Kind ExpirationKind
+	// Days until points expire for ExpireAfterDays and ExpireAfterInactivity
+	Days int
+}
+
+/*
+Parses a policy written as never, end-of-year, days:N or inactivity:N, where N is
+a positive number of days.
+*/
+func ParseExpirationPolicy(value string) (ExpirationPolicy, error) {
+	kind, days, hasDays := strings.Cut(value, ":")
+	policy := ExpirationPolicy{Kind: ExpirationKind(kind)}
+	switch policy.Kind {
+	case ExpireNever, ExpireEndOfYear:
+		if hasDays {
+			return ExpirationPolicy{}, fmt.Errorf("expiration policy %s doesn't take a number of days", kind)
+		}
+	case ExpireAfterDays, ExpireAfterInactivity:
+		parsed, err := strconv.Atoi(days)
+		if err != nil || parsed <= 0 {
+			return ExpirationPolicy{}, fmt.Errorf("expiration policy %s must be followed by a positive number of days, such as %s:365", kind, kind)
+		}
+		policy.Days = parsed
+	default:
+		return ExpirationPolicy{}, fmt.Errorf("expiration policy must be one of never, end-of-year, days:N or inactivity:N")
+	}
+	return policy, nil
+}
+
+func (p ExpirationPolicy) String() string {
+	switch p.Kind {
+	case ExpireAfterDays, ExpireAfterInactivity:
+		return fmt.Sprintf("%s:%d", p.Kind, p.Days)
+	case "":
+		return string(ExpireNever)
+	default:
+		return string(p.Kind)
+	}
+}
+
+/*
+Points added to an account by one entry, and how many of them are left. Points taken
+away are consumed from the oldest lots first, so the oldest points are spent or expire first.
+*/
+type Lot struct {
+	EntryId   string    `json:"entryId"`
+	EarnedAt  time.Time `json:"earnedAt"`
+	Points    int       `json:"points"`
+	Remaining int       `json:"remaining"`
+	// When the remaining points expire under the policy, nil if they never do
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+/*
+Replays a ledger into its lots with points remaining, oldest first. Points taken away
+beyond every lot, which adjustments can do, are owed and taken from the next lots added.
+A refund gives points back to the lots its redemption consumed, so they keep the time
+they were earned at, and only the rest of it is added as a new lot.
+*/
+func lots(ledger []Entry, policy ExpirationPolicy) []Lot {
+	var open []Lot
+	owed := 0
+	var lastActivity time.Time
+	// Points each redemption took from each lot, with the lot they were taken from
+	redeemed := make(map[string][]Lot)
+	for _, entry := range ledger {
+		if entry.Type == EntryEarn || entry.Type == EntryRedeem {
+			lastActivity = entry.CreatedAt
+		}
+		if entry.Points > 0 {
+			points := entry.Points
+			if entry.Type == EntryRefund && entry.RedemptionId != "" {
+				for _, taken := range redeemed[entry.RedemptionId] {
+					restored := min(points, taken.Remaining)
+					open = restore(open, taken, restored)
+					points -= restored
+				}
+				delete(redeemed, entry.RedemptionId)
+			}
+			lot := Lot{EntryId: entry.Id, EarnedAt: entry.CreatedAt, Points: points, Remaining: points}
+			repaid := min(owed, lot.Remaining)
+			lot.Remaining -= repaid
+			owed -= repaid
+			if lot.Remaining > 0 {
+				open = append(open, lot)
+			}
+			continue
+		}
+
+		taken := -entry.Points
+		for taken > 0 && len(open) > 0 {
+			consumed := min(taken, open[0].Remaining)
+			if entry.Type == EntryRedeem && entry.RedemptionId != "" {
+				redeemed[entry.RedemptionId] = append(redeemed[entry.RedemptionId], Lot{EntryId: open[0].EntryId, EarnedAt: open[0].EarnedAt, Points: open[0].Points, Remaining: consumed})
+			}
+			open[0].Remaining -= consumed
+			taken -= consumed
+			if open[0].Remaining == 0 {
+				open = open[1:]
+			}
+		}
+		owed += taken
+	}
+
+	for i := range open {
+		open[i].ExpiresAt = policy.expiresAt(open[i].EarnedAt, lastActivity)
+	}
+	return open
+}
+
+// Gives points back to the lot, putting it back in order of when it was earned if it was used up
+func restore(open []Lot, lot Lot, points int) []Lot {
+	if points <= 0 {
+		return open
+	}
+	for i := range open {
+		if open[i].EntryId == lot.EntryId {
+			open[i].Remaining += points
+			return open
+		}
+	}
+	lot.Remaining = points
+	at := len(open)
+	for i := range open {
+		if open[i].EarnedAt.After(lot.EarnedAt) {
+			at = i
+			break
+		}
+	}
+	return slices.Insert(open, at, lot)
+}
+
+// When points earned at earnedAt expire, nil if they never do
+func (p ExpirationPolicy) expiresAt(earnedAt time.Time, lastActivity time.Time) *time.Time {
+	var expiresAt time.Time
+	switch p.Kind {
+	case ExpireAfterDays:
+		expiresAt = earnedAt.AddDate(0, 0, p.Days)
+	case ExpireEndOfYear:
+		expiresAt = time.Date(earnedAt.UTC().Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	case ExpireAfterInactivity:
+		// Accounts that only ever had adjustments are inactive since the points were added
+		if lastActivity.IsZero() {
+			lastActivity = earnedAt
+		}
+		expiresAt = lastActivity.AddDate(0, 0, p.Days)
+	default:
+		return nil
+	}
+	return &expiresAt
+}
+
+/*
+Returns the account's lots with points remaining that expire after now and no later
+than now plus within, oldest first, or false if there is no such account. Waits for the read lock.
+*/
+func (s *Store) ExpiringLots(accountId string, policy ExpirationPolicy, now time.Time, within time.Duration) ([]Lot, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	if _, exists := s.idToAccount[accountId]; !exists {
+		return nil, false
+	}
+	return s.expiringLots(accountId, policy, now, within), true
+}
+
+// Points expiring soon in one account
+type ExpiringPoints struct {
+	AccountId string `json:"accountId"`
+	Points    int    `json:"points"`
+	Lots      []Lot  `json:"lots"`
+}
+
+/*
+Returns every account with points that expire after now and no later than now plus
+within, in the order the accounts were created. Waits for the read lock.
+*/
+func (s *Store) ExpiringPoints(policy ExpirationPolicy, now time.Time, within time.Duration) []ExpiringPoints {
+	s.RLock()
+	defer s.RUnlock()
+	expiring := []ExpiringPoints{}
+	for _, id := range s.ids {
+		lots := s.expiringLots(id, policy, now, within)
+		if len(lots) == 0 {
+			continue
+		}
+		account := ExpiringPoints{AccountId: id, Lots: lots}
+		for _, lot := range lots {
+			account.Points += lot.Remaining
+		}
+		expiring = append(expiring, account)
+	}
+	return expiring
+}
+
+// Caller must hold the read lock
+func (s *Store) expiringLots(accountId string, policy ExpirationPolicy, now time.Time, within time.Duration) []Lot {
+	expiring := []Lot{}
+	until := now.Add(within)
+	for _, lot := range lots(s.idToLedger[accountId], policy) {
+		if lot.ExpiresAt != nil && lot.ExpiresAt.After(now) && !lot.ExpiresAt.After(until) {
+			expiring = append(expiring, lot)
+		}
+	}
+	return expiring
+}
+
+/*
+Appends an expire entry to every account with points that expired under the policy by
+now, and returns the appended entries. Holds the read / write lock for the whole run,
+so no entry can be appended between finding an account's expired points and expiring them.
+*/
+func (s *Store) ExpirePoints(policy ExpirationPolicy, now time.Time) []Entry {
+	s.Lock()
+	defer s.Unlock()
+	expired := []Entry{}
+	for _, id := range s.ids {
+		points := 0
+		for _, lot := range lots(s.idToLedger[id], policy) {
+			if lot.ExpiresAt != nil && !lot.ExpiresAt.After(now) {
+				points += lot.Remaining
+			}
+		}
+		// Owed points can leave the balance below the expired lots, and it never expires below 0
+		points = min(points, s.idToBalance[id])
+		if points <= 0 {
+			continue
+		}
+		entry, err := s.append(Entry{AccountId: id, Type: EntryExpire, Points: -points, Reason: "expired under policy " + policy.String()})
+		if err != nil {
+			log.Printf("failed to expire %d points of account %s: %v", points, id, err)
+			continue
+		}
+		expired = append(expired, entry)
+	}
+	return expired
+}
+
+/*
+Expires points under the policy every interval until stop is closed, logging how many
+were expired. Blocks, so it is usually run in its own goroutine.
+*/
+func (s *Store) RunExpiration(policy ExpirationPolicy, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			expired := s.ExpirePoints(policy, now)
+			if len(expired) > 0 {
+				log.Printf("expired points of %d accounts under policy %s", len(expired), policy)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package accounts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpirationPolicy(t *testing.T) {
+	tests := []struct {
+		value          string
+		expectedPolicy ExpirationPolicy
+		expectedErr    bool
+	}{
+		{value: "never", expectedPolicy: ExpirationPolicy{Kind: ExpireNever}},
+		{value: "end-of-year", expectedPolicy: ExpirationPolicy{Kind: ExpireEndOfYear}},
+		{value: "days:365", expectedPolicy: ExpirationPolicy{Kind: ExpireAfterDays, Days: 365}},
+		{value: "inactivity:180", expectedPolicy: ExpirationPolicy{Kind: ExpireAfterInactivity, Days: 180}},
+		{value: "days", expectedErr: true},
+		{value: "days:0", expectedErr: true},
+		{value: "inactivity:soon", expectedErr: true},
+		{value: "end-of-year:1", expectedErr: true},
+		{value: "monthly", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			policy, err := ParseExpirationPolicy(test.value)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPolicy, policy)
+			assert.Equal(t, test.value, policy.String())
+		})
+	}
+}
+
+func TestLots(t *testing.T) {
+	day := func(n int) time.Time {
+		return time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n)
+	}
+	ledger := []Entry{
+		{Id: "1", Type: EntryEarn, Points: 10, CreatedAt: day(0)},
+		{Id: "2", Type: EntryEarn, Points: 20, CreatedAt: day(10)},
+		{Id: "3", Type: EntryRedeem, Points: -15, CreatedAt: day(20)},
+		{Id: "4", Type: EntryAdjust, Points: 5, CreatedAt: day(400)},
+	}
+
+	tests := []struct {
+		testName     string
+		policy       ExpirationPolicy
+		expectedLots []Lot
+	}{
+		{
+			testName: "Never",
+			policy:   ExpirationPolicy{},
+			expectedLots: []Lot{
+				{EntryId: "2", EarnedAt: day(10), Points: 20, Remaining: 15},
+				{EntryId: "4", EarnedAt: day(400), Points: 5, Remaining: 5},
+			},
+		},
+		{
+			testName: "Days",
+			policy:   ExpirationPolicy{Kind: ExpireAfterDays, Days: 30},
+			expectedLots: []Lot{
+				{EntryId: "2", EarnedAt: day(10), Points: 20, Remaining: 15, ExpiresAt: ptr(day(40))},
+				{EntryId: "4", EarnedAt: day(400), Points: 5, Remaining: 5, ExpiresAt: ptr(day(430))},
+			},
+		},
+		{
+			testName: "EndOfYear",
+			policy:   ExpirationPolicy{Kind: ExpireEndOfYear},
+			expectedLots: []Lot{
+				{EntryId: "2", EarnedAt: day(10), Points: 20, Remaining: 15, ExpiresAt: ptr(day(366))},
+				{EntryId: "4", EarnedAt: day(400), Points: 5, Remaining: 5, ExpiresAt: ptr(day(731))},
+			},
+		},
+		{
+			testName: "Inactivity",
+			policy:   ExpirationPolicy{Kind: ExpireAfterInactivity, Days: 90},
+			expectedLots: []Lot{
+				{EntryId: "2", EarnedAt: day(10), Points: 20, Remaining: 15, ExpiresAt: ptr(day(110))},
+				{EntryId: "4", EarnedAt: day(400), Points: 5, Remaining: 5, ExpiresAt: ptr(day(110))},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Equal(t, test.expectedLots, lots(ledger, test.policy))
+		})
+	}
+
+	t.Run("Owed", func(t *testing.T) {
+		owing := []Entry{
+			{Id: "1", Type: EntryAdjust, Points: -10, CreatedAt: day(0)},
+			{Id: "2", Type: EntryEarn, Points: 15, CreatedAt: day(1)},
+		}
+		assert.Equal(t, []Lot{{EntryId: "2", EarnedAt: day(1), Points: 15, Remaining: 5}}, lots(owing, ExpirationPolicy{}))
+	})
+
+	t.Run("RedeemThenCancel", func(t *testing.T) {
+		cancelled := []Entry{
+			{Id: "1", Type: EntryEarn, Points: 10, CreatedAt: day(0)},
+			{Id: "2", Type: EntryEarn, Points: 20, CreatedAt: day(10)},
+			{Id: "3", Type: EntryRedeem, Points: -15, RedemptionId: "r", CreatedAt: day(20)},
+			{Id: "4", Type: EntryRefund, Points: 15, RedemptionId: "r", CreatedAt: day(25)},
+		}
+		// The refund puts the points back in the lots they came from, keeping their expiry
+		assert.Equal(t, []Lot{
+			{EntryId: "1", EarnedAt: day(0), Points: 10, Remaining: 10, ExpiresAt: ptr(day(30))},
+			{EntryId: "2", EarnedAt: day(10), Points: 20, Remaining: 20, ExpiresAt: ptr(day(40))},
+		}, lots(cancelled, ExpirationPolicy{Kind: ExpireAfterDays, Days: 30}))
+
+		// Refunds of redemptions not in the ledger can only be added as new lots
+		unknown := []Entry{{Id: "1", Type: EntryRefund, Points: 5, RedemptionId: "other", CreatedAt: day(0)}}
+		assert.Equal(t, []Lot{{EntryId: "1", EarnedAt: day(0), Points: 5, Remaining: 5}}, lots(unknown, ExpirationPolicy{}))
+	})
+}
+
+func ptr(value time.Time) *time.Time {
+	return &value
+}
+
+func TestExpirePoints(t *testing.T) {
+	store := NewStore()
+	policy := ExpirationPolicy{Kind: ExpireAfterDays, Days: 30}
+	spender := store.CreateAccount(Account{Name: "Spender"})
+	saver := store.CreateAccount(Account{Name: "Saver"})
+	for _, entry := range []Entry{
+		{AccountId: spender.Id, Type: EntryEarn, Points: 100},
+		{AccountId: spender.Id, Type: EntryRedeem, Points: -100},
+		{AccountId: saver.Id, Type: EntryEarn, Points: 40},
+		{AccountId: saver.Id, Type: EntryRedeem, Points: -15},
+	} {
+		_, err := store.Append(entry)
+		require.NoError(t, err)
+	}
+	now := time.Now()
+
+	t.Run("Expiring", func(t *testing.T) {
+		expiring := store.ExpiringPoints(policy, now, 31*24*time.Hour)
+		require.Len(t, expiring, 1)
+		assert.Equal(t, saver.Id, expiring[0].AccountId)
+		assert.Equal(t, 25, expiring[0].Points)
+
+		assert.Empty(t, store.ExpiringPoints(policy, now, 7*24*time.Hour))
+		lots, exists := store.ExpiringLots(saver.Id, policy, now, 31*24*time.Hour)
+		assert.True(t, exists)
+		assert.Len(t, lots, 1)
+		_, exists = store.ExpiringLots("unknown", policy, now, time.Hour)
+		assert.False(t, exists)
+	})
+
+	t.Run("NotYet", func(t *testing.T) {
+		assert.Empty(t, store.ExpirePoints(policy, now.AddDate(0, 0, 29)))
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		expired := store.ExpirePoints(policy, now.AddDate(0, 0, 31))
+		require.Len(t, expired, 1)
+		assert.Equal(t, EntryExpire, expired[0].Type)
+		assert.Equal(t, -25, expired[0].Points)
+		balance, _ := store.Balance(saver.Id)
+		assert.Equal(t, 0, balance)
+
+		assert.Empty(t, store.ExpirePoints(policy, now.AddDate(0, 0, 31)), "expired points must only expire once")
+	})
+}
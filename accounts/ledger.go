@@ -0,0 +1,92 @@
+package accounts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Longest name an account can have
+const MaxNameLength int = 256
+
+// A loyalty account that receipts can be submitted for, earning it points
+type Account struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Checks that the account has a name of reasonable length
+func (a Account) Validate() error {
+	if strings.TrimSpace(a.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(a.Name) > MaxNameLength {
+		return fmt.Errorf("name must be at most %d characters", MaxNameLength)
+	}
+	return nil
+}
+
+// Kind of change a ledger entry makes to an account's balance
+type EntryType string
+
+const (
+	// Points awarded for a receipt, never negative
+	EntryEarn EntryType = "earn"
+	// A manual correction, or the change in a receipt's points when it is rescored, in either direction
+	EntryAdjust EntryType = "adjust"
+	// Points that expired unused, always negative
+	EntryExpire EntryType = "expire"
+	// Points spent on a reward, always negative
+	EntryRedeem EntryType = "redeem"
+	// Points given back when a redemption is cancelled, always positive
+	EntryRefund EntryType = "refund"
+)
+
+// One change to an account's balance. Entries are only ever appended, never edited or removed.
+type Entry struct {
+	Id        string    `json:"id"`
+	AccountId string    `json:"accountId"`
+	Type      EntryType `json:"type"`
+	// Change to the balance, negative for points taken away
+	Points int `json:"points"`
+	// Balance of the account after this entry
+	Balance int `json:"balance"`
+	// Receipt the entry is for, if any
+	ReceiptId string `json:"receiptId,omitempty"`
+	// Total of the receipt an earn entry is for, counted towards spend based tiers. Adjust
+	// entries for a receipt carry the change to it, such as minus the total when it's deleted.
+	Spend float64 `json:"spend,omitempty"`
+	// Redemption a redeem or refund entry is for, if any
+	RedemptionId string    `json:"redemptionId,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Checks the sign of the entry's points is allowed for its type
+func (e Entry) Validate() error {
+	switch e.Type {
+	case EntryEarn:
+		if e.Points < 0 {
+			return fmt.Errorf("earn entries must not have negative points")
+		}
+		if e.Spend < 0 {
+			return fmt.Errorf("earn entries must not have negative spend")
+		}
+	case EntryAdjust:
+		if e.Points == 0 && e.Spend == 0 {
+			return fmt.Errorf("adjust entries must change the balance or spend")
+		}
+	case EntryExpire, EntryRedeem:
+		if e.Points >= 0 {
+			return fmt.Errorf("%s entries must have negative points", e.Type)
+		}
+	case EntryRefund:
+		if e.Points <= 0 {
+			return fmt.Errorf("refund entries must have positive points")
+		}
+	default:
+		return fmt.Errorf("entry type must be one of earn, adjust, expire, redeem or refund")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	// Returned when an expire or redeem entry would take more points than the account has
+	ErrInsufficientBalance = errors.New("insufficient balance")
+)
+
+/*
+Thread safe store of accounts and their ledgers. Every entry is appended while
+holding the write lock, and each account's balance is kept with its ledger, so
+concurrent writes always see, and leave, a balance equal to the sum of the ledger.
+*/
+type Store struct {
+	*sync.RWMutex
+	idToAccount map[string]Account
+	// Account ids in the order they were created
+	ids         []string
+	idToLedger  map[string][]Entry
+	idToBalance map[string]int
+	// Account each receipt was submitted for, from the receipt's earn entry
+	receiptToAccount map[string]string
+}
+
+func NewStore() *Store {
+	return &Store{
+		RWMutex:          &sync.RWMutex{},
+		idToAccount:      make(map[string]Account),
+		idToLedger:       make(map[string][]Entry),
+		idToBalance:      make(map[string]int),
+		receiptToAccount: make(map[string]string),
+	}
+}
+
+/*
+Saves the account under a new id with an empty ledger and returns it with its id and
+creation time set, after waiting for the read / write lock. The account must already be valid.
+*/
+func (s *Store) CreateAccount(account Account) Account {
+	s.Lock()
+	defer s.Unlock()
+	account.Id = uuid.New().String()
+	account.CreatedAt = time.Now().UTC()
+	s.idToAccount[account.Id] = account
+	s.ids = append(s.ids, account.Id)
+	return account
+}
+
+// Returns the account with the id, or false if there is none, after waiting for the read lock.
+func (s *Store) GetAccount(id string) (Account, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	account, exists := s.idToAccount[id]
+	return account, exists
+}
+
+// Returns the account's balance, or false if there is no such account, after waiting for the read lock.
+func (s *Store) Balance(id string) (int, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	if _, exists := s.idToAccount[id]; !exists {
+		return 0, false
+	}
+	return s.idToBalance[id], true
+}
+
+// Returns the account's ledger oldest entry first, or false if there is no such account, after waiting for the read lock.
+func (s *Store) Ledger(id string) ([]Entry, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	if _, exists := s.idToAccount[id]; !exists {
+		return nil, false
+	}
+	ledger := make([]Entry, len(s.idToLedger[id]))
+	copy(ledger, s.idToLedger[id])
+	return ledger, true
+}
+
+// Returns the ids of every account in the order they were created, after waiting for the read lock.
+func (s *Store) AccountIds() []string {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]string(nil), s.ids...)
+}
+
+/*
+Returns the points and spend the account earned with receipts submitted since the given
+time, or false if there is no such account, after waiting for the read lock. Adjust
+entries for a receipt, from rescoring or deleting it, count along with its earn entry,
+so a deleted receipt no longer counts and a rescored one counts its new points.
+*/
+func (s *Store) Activity(id string, since time.Time) (int, float64, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	if _, exists := s.idToAccount[id]; !exists {
+		return 0, 0, false
+	}
+	points, spend := 0, 0.0
+	// Receipts earned since the given time, whose adjustments count with them
+	counted := make(map[string]bool)
+	for _, entry := range s.idToLedger[id] {
+		if entry.Type == EntryEarn && !entry.CreatedAt.Before(since) {
+			if entry.ReceiptId != "" {
+				counted[entry.ReceiptId] = true
+			}
+		} else if entry.Type != EntryAdjust || !counted[entry.ReceiptId] {
+			continue
+		}
+		points += entry.Points
+		spend += entry.Spend
+	}
+	return points, spend, true
+}
+
+// Returns the id of the account the receipt was submitted for, or false if it wasn't, after waiting for the read lock.
+func (s *Store) ReceiptAccount(receiptId string) (string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	accountId, exists := s.receiptToAccount[receiptId]
+	return accountId, exists
+}
+
+/*
+Appends the entry to its account's ledger and returns it with its id, creation time
+and the resulting balance set, after waiting for the read / write lock. Returns
+ErrAccountNotFound for unknown accounts, ErrInsufficientBalance when an expire or
+redeem entry takes more points than the account has, or an error for invalid entries.
+An earn entry with a receipt id associates the receipt with the account.
+*/
+func (s *Store) Append(entry Entry) (Entry, error) {
+	if err := entry.Validate(); err != nil {
+		return Entry{}, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	return s.append(entry)
+}
+
+// Same as Append for an already validated entry, caller must hold the write lock
+func (s *Store) append(entry Entry) (Entry, error) {
+	if _, exists := s.idToAccount[entry.AccountId]; !exists {
+		return Entry{}, fmt.Errorf("%w: %s", ErrAccountNotFound, entry.AccountId)
+	}
+	balance := s.idToBalance[entry.AccountId]
+	if (entry.Type == EntryExpire || entry.Type == EntryRedeem) && balance+entry.Points < 0 {
+		return Entry{}, fmt.Errorf("%w: %s of %d points needs more than the balance of %d", ErrInsufficientBalance, entry.Type, -entry.Points, balance)
+	}
+
+	entry.Id = uuid.New().String()
+	entry.CreatedAt = time.Now().UTC()
+	entry.Balance = balance + entry.Points
+	s.idToBalance[entry.AccountId] = entry.Balance
+	s.idToLedger[entry.AccountId] = append(s.idToLedger[entry.AccountId], entry)
+	if entry.Type == EntryEarn && entry.ReceiptId != "" {
+		s.receiptToAccount[entry.ReceiptId] = entry.AccountId
+	}
+	return entry, nil
+}
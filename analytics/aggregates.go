@@ -0,0 +1,113 @@
+package analytics
+
+import (
+	"receipts/models"
+	"receipts/storage"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Sums over a set of receipts
+type totals struct {
+	receipts int
+	// Money is summed in cents so totals stay exact however many receipts are added and removed
+	spendCents int64
+	// Units bought, counting an item sold by weight as one
+	items  int
+	points int
+}
+
+func (t *totals) add(other totals, sign int) {
+	t.receipts += sign * other.receipts
+	t.spendCents += int64(sign) * other.spendCents
+	t.items += sign * other.items
+	t.points += sign * other.points
+}
+
+// Receipts purchased in the same hour of a day at the same retailer
+type cellKey struct {
+	hour     int
+	retailer string
+}
+
+/*
+Thread safe aggregates over stored receipts, kept as totals per purchase day, hour and
+retailer. As a storage.Observer they are updated with every change to the stored
+receipts, so a query only reads the totals of the days in its range rather than
+every receipt.
+*/
+type Aggregates struct {
+	*sync.RWMutex
+	dayToCells map[time.Time]map[cellKey]*totals
+	// Days with receipts in ascending order, so a range of days can be found without scanning every day
+	days []time.Time
+	// Name a retailer was first stored with, by its canonical id
+	retailerNames map[string]string
+}
+
+func NewAggregates() *Aggregates {
+	return &Aggregates{
+		RWMutex:       &sync.RWMutex{},
+		dayToCells:    make(map[time.Time]map[cellKey]*totals),
+		retailerNames: make(map[string]string),
+	}
+}
+
+// Returns the receipt's price in cents, or 0 when it isn't a valid price
+func cents(price string) int64 {
+	dollars, cents := models.GetDollars(price), models.GetCents(price)
+	if dollars < 0 || cents < 0 {
+		return 0
+	}
+	return int64(dollars)*100 + int64(cents)
+}
+
+// Takes the previous version of a changed receipt out of the aggregates and adds the current one
+func (a *Aggregates) ReceiptChanged(previous *storage.StoredReceipt, current *storage.StoredReceipt) {
+	a.Lock()
+	defer a.Unlock()
+	if previous != nil {
+		a.add(*previous, -1)
+	}
+	if current != nil {
+		a.add(*current, 1)
+	}
+}
+
+// Adds the receipt to the totals of its cell, or takes it out when sign is -1. Caller must hold the write lock.
+func (a *Aggregates) add(stored storage.StoredReceipt, sign int) {
+	receipt := stored.Receipt
+	day := receipt.PurchaseDate.Date.UTC().Truncate(24 * time.Hour)
+	key := cellKey{hour: receipt.PurchaseTime.Time.Hour(), retailer: receipt.RetailerId()}
+	if _, exists := a.retailerNames[key.retailer]; !exists {
+		a.retailerNames[key.retailer] = receipt.RetailerName()
+	}
+
+	cells, exists := a.dayToCells[day]
+	if !exists {
+		cells = make(map[cellKey]*totals)
+		a.dayToCells[day] = cells
+		i, _ := slices.BinarySearchFunc(a.days, day, time.Time.Compare)
+		a.days = slices.Insert(a.days, i, day)
+	}
+	cell, exists := cells[key]
+	if !exists {
+		cell = &totals{}
+		cells[key] = cell
+	}
+	items := 0
+	for _, item := range receipt.Items {
+		items += item.Units()
+	}
+	cell.add(totals{receipts: 1, spendCents: cents(receipt.Total), items: items, points: stored.Score().Points}, sign)
+
+	if cell.receipts == 0 {
+		delete(cells, key)
+	}
+	if len(cells) == 0 {
+		delete(a.dayToCells, day)
+		i, _ := slices.BinarySearchFunc(a.days, day, time.Time.Compare)
+		a.days = slices.Delete(a.days, i, i+1)
+	}
+}
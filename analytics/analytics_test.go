@@ -0,0 +1,160 @@
+package analytics
+
+import (
+	"receipts/models"
+	"receipts/points"
+	"receipts/storage"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a receipt purchased on the date at the hour, with one item per price
+func receiptAt(retailer string, date string, hour int, total string, prices ...string) *models.Receipt {
+	purchaseDate, _ := time.Parse(models.DateLayout, date)
+	receipt := &models.Receipt{
+		Retailer:     retailer,
+		PurchaseDate: models.PurchaseDate{Date: purchaseDate},
+		PurchaseTime: models.PurchaseTime{Time: time.Date(0, 1, 1, hour, 30, 0, 0, time.UTC)},
+		Total:        total,
+	}
+	for _, price := range prices {
+		receipt.Items = append(receipt.Items, models.Item{ShortDescription: "Item", Price: price})
+	}
+	return receipt
+}
+
+func TestAggregates(t *testing.T) {
+	receiptStorage := storage.NewReceiptStorage()
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	// Monday, Monday, Wednesday and the next Monday
+	receiptStorage.SetScoredReceipt(ids[0], receiptAt("Target", "2024-06-10", 9, "10.00", "4.00", "6.00"), points.Score{Points: 10})
+	receiptStorage.SetScoredReceipt(ids[1], receiptAt("target ", "2024-06-10", 17, "5.25", "5.25"), points.Score{Points: 20})
+
+	// Receipts stored before the aggregates observe the storage are included
+	aggregates := NewAggregates()
+	receiptStorage.Observe(aggregates)
+	receiptStorage.SetScoredReceipt(ids[2], receiptAt("Walgreens", "2024-06-12", 9, "3.00", "1.00", "1.00", "1.00"), points.Score{Points: 5})
+	receiptStorage.SetScoredReceipt(ids[3], receiptAt("Walgreens", "2024-06-17", 9, "1.99", "1.99"), points.Score{Points: 7})
+
+	report := aggregates.Query(GroupByRetailer, nil, nil)
+	assert.Equal(t, Group{Receipts: 4, Spend: 20.24, AverageSpend: 5.06, AverageItems: 1.75, Points: 42}, report.Totals)
+	assert.Equal(t, []Group{
+		{Key: "Target", Receipts: 2, Spend: 15.25, AverageSpend: 7.63, AverageItems: 1.5, Points: 30},
+		{Key: "Walgreens", Receipts: 2, Spend: 4.99, AverageSpend: 2.5, AverageItems: 2, Points: 12},
+	}, report.Groups)
+
+	tests := []struct {
+		dimension    Dimension
+		from         string
+		to           string
+		expectedKeys []string
+		expectedSums []int
+	}{
+		{dimension: GroupByDay, expectedKeys: []string{"2024-06-10", "2024-06-12", "2024-06-17"}, expectedSums: []int{2, 1, 1}},
+		{dimension: GroupByDay, from: "2024-06-11", to: "2024-06-12", expectedKeys: []string{"2024-06-12"}, expectedSums: []int{1}},
+		{dimension: GroupByDay, from: "2024-06-13", to: "2024-06-16", expectedKeys: []string{}, expectedSums: []int{}},
+		{dimension: GroupByWeek, expectedKeys: []string{"2024-06-10", "2024-06-17"}, expectedSums: []int{3, 1}},
+		{dimension: GroupByHour, to: "2024-06-12", expectedKeys: []string{"09", "17"}, expectedSums: []int{2, 1}},
+		{dimension: GroupByWeekday, expectedKeys: []string{"Monday", "Wednesday"}, expectedSums: []int{3, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.dimension)+test.from+test.to, func(t *testing.T) {
+			var from, to *time.Time
+			if test.from != "" {
+				parsed, _ := time.Parse(models.DateLayout, test.from)
+				from = &parsed
+			}
+			if test.to != "" {
+				parsed, _ := time.Parse(models.DateLayout, test.to)
+				to = &parsed
+			}
+			report := aggregates.Query(test.dimension, from, to)
+			keys, sums := []string{}, []int{}
+			for _, group := range report.Groups {
+				keys = append(keys, group.Key)
+				sums = append(sums, group.Receipts)
+			}
+			assert.Equal(t, test.expectedKeys, keys)
+			assert.Equal(t, test.expectedSums, sums)
+		})
+	}
+
+	// Rescored, replaced and deleted receipts update the aggregates
+	receiptStorage.RescoreReceipts(func(stored storage.StoredReceipt) points.Score { return points.Score{Points: 1} }, false)
+	receiptStorage.SetReceipt(ids[1], receiptAt("Costco", "2024-06-10", 17, "100.00", "100.00"))
+	receiptStorage.DeleteReceipt(ids[3])
+	report = aggregates.Query(GroupByRetailer, nil, nil)
+	require.Len(t, report.Groups, 3)
+	assert.Equal(t, Group{Key: "Costco", Receipts: 1, Spend: 100, AverageSpend: 100, AverageItems: 1, Points: points.CalculatePoints(receiptAt("Costco", "2024-06-10", 17, "100.00", "100.00"))}, report.Groups[0])
+	assert.Equal(t, Group{Key: "Target", Receipts: 1, Spend: 10, AverageSpend: 10, AverageItems: 2, Points: 1}, report.Groups[1])
+	assert.Equal(t, 1, report.Groups[2].Receipts)
+
+	receiptStorage.DeleteReceipt(ids[0])
+	receiptStorage.DeleteReceipt(ids[1])
+	receiptStorage.DeleteReceipt(ids[2])
+	report = aggregates.Query(GroupByDay, nil, nil)
+	assert.Empty(t, report.Groups)
+	assert.Empty(t, aggregates.days, "days without receipts are dropped")
+
+	// Items count their units, and items sold by weight count once
+	weighed := receiptAt("Costco", "2024-06-10", 17, "9.00", "6.00", "3.00")
+	weighed.Items[0].Quantity, weighed.Items[1].Quantity = 3, 1.5
+	receiptStorage.SetReceipt(ids[0], weighed)
+	report = aggregates.Query(GroupByRetailer, nil, nil)
+	assert.Equal(t, 4.0, report.Totals.AverageItems)
+}
+
+func TestParseDimension(t *testing.T) {
+	dimension, err := ParseDimension("weekday")
+	require.NoError(t, err)
+	assert.Equal(t, GroupByWeekday, dimension)
+	_, err = ParseDimension("year")
+	assert.Error(t, err)
+}
+
+func TestProducts(t *testing.T) {
+	receiptStorage := storage.NewReceiptStorage()
+	topProducts := NewProducts()
+	receiptStorage.Observe(topProducts)
+
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	first := receiptAt("Target", "2024-06-10", 9, "20.50")
+	first.Items = []models.Item{{ShortDescription: "Klarbrunn 12-PK 12 FL OZ", Price: "12.00"}, {ShortDescription: "Gatorade", Price: "2.25"}, {ShortDescription: "gatorade ", Price: "2.25"}}
+	receiptStorage.SetReceipt(ids[0], first)
+	second := receiptAt("Walgreens", "2024-06-11", 9, "14.25")
+	second.Items = []models.Item{{ShortDescription: "KLARBRUNN 12 pack 12oz", Price: "12.00"}, {ShortDescription: "GATORADE", Price: "2.25"}}
+	receiptStorage.SetReceipt(ids[1], second)
+
+	byCount, products := topProducts.Top(RankByCount, 10)
+	assert.Equal(t, 3, products)
+	require.Len(t, byCount, 3)
+	assert.Equal(t, "gatorade", byCount[0].Id)
+	assert.Equal(t, 3, byCount[0].Count)
+	assert.Equal(t, 6.75, byCount[0].Spend)
+
+	bySpend, _ := topProducts.Top(RankBySpend, 1)
+	require.Len(t, bySpend, 1)
+	assert.Equal(t, "klarbrunn sparkling water 12 fl oz 12 pk", bySpend[0].Id, "12 FL OZ and 12oz are different sizes")
+	assert.Equal(t, 12.0, bySpend[0].Spend)
+
+	receiptStorage.DeleteReceipt(ids[0])
+	byCount, products = topProducts.Top(RankByCount, 10)
+	assert.Equal(t, 2, products)
+	assert.Equal(t, 1, byCount[0].Count)
+
+	// Items printed with a quantity count every unit
+	third := receiptAt("Walgreens", "2024-06-12", 9, "9.00")
+	third.Items = []models.Item{{ShortDescription: "Gatorade", Price: "9.00", Quantity: 4, UnitPrice: "2.25"}}
+	receiptStorage.SetReceipt(uuid.New(), third)
+	byCount, _ = topProducts.Top(RankByCount, 1)
+	assert.Equal(t, "gatorade", byCount[0].Id)
+	assert.Equal(t, 5, byCount[0].Count)
+
+	_, err := ParseRanking("price")
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"fmt"
+	"receipts/models"
+	"receipts/storage"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// What products are ranked by in a top products report
+type Ranking string
+
+const (
+	// Number of units bought
+	RankByCount Ranking = "count"
+	// Sum of the items' prices
+	RankBySpend Ranking = "spend"
+)
+
+// Parses a ranking name, returning an error for unknown names
+func ParseRanking(name string) (Ranking, error) {
+	switch ranking := Ranking(name); ranking {
+	case RankByCount, RankBySpend:
+		return ranking, nil
+	default:
+		return "", fmt.Errorf("by must be one of count or spend")
+	}
+}
+
+// How often a product was bought and how much was spent on it
+type ProductTotals struct {
+	models.Product
+	// Units bought of the items normalized to the product, counting an item sold by weight as one
+	Count int `json:"count"`
+	// Sum of the prices of those items, in dollars
+	Spend float64 `json:"spend"`
+}
+
+// Sums of the items of one product
+type productSums struct {
+	product    models.Product
+	count      int
+	spendCents int64
+}
+
+/*
+Thread safe totals of every canonical product bought, kept up to date as a
+storage.Observer like Aggregates, so top products are read without scanning receipts.
+*/
+type Products struct {
+	*sync.RWMutex
+	idToSums map[string]*productSums
+}
+
+func NewProducts() *Products {
+	return &Products{
+		RWMutex:  &sync.RWMutex{},
+		idToSums: make(map[string]*productSums),
+	}
+}
+
+// Takes the items of the previous version of a changed receipt out of the totals and adds the current ones
+func (p *Products) ReceiptChanged(previous *storage.StoredReceipt, current *storage.StoredReceipt) {
+	p.Lock()
+	defer p.Unlock()
+	if previous != nil {
+		p.add(previous.Receipt, -1)
+	}
+	if current != nil {
+		p.add(current.Receipt, 1)
+	}
+}
+
+// Adds the receipt's items to their products' totals, or takes them out when sign is -1. Caller must hold the write lock.
+func (p *Products) add(receipt *models.Receipt, sign int) {
+	for _, item := range receipt.Items {
+		if item.Product == nil {
+			continue
+		}
+		sums, exists := p.idToSums[item.Product.Id]
+		if !exists {
+			sums = &productSums{product: *item.Product}
+			p.idToSums[item.Product.Id] = sums
+		}
+		sums.count += sign * item.Units()
+		sums.spendCents += int64(sign) * cents(item.Price)
+		if sums.count == 0 {
+			delete(p.idToSums, item.Product.Id)
+		}
+	}
+}
+
+/*
+Returns the limit products bought most often or spent most on, depending on ranking,
+highest first, and how many products were bought in total. Waits for the read lock.
+*/
+func (p *Products) Top(ranking Ranking, limit int) ([]ProductTotals, int) {
+	p.RLock()
+	defer p.RUnlock()
+	top := make([]ProductTotals, 0, len(p.idToSums))
+	for _, sums := range p.idToSums {
+		top = append(top, ProductTotals{Product: sums.product, Count: sums.count, Spend: float64(sums.spendCents) / 100})
+	}
+	// Ties are listed by product id so the order is stable between reads
+	slices.SortFunc(top, func(a, b ProductTotals) int {
+		if ranking == RankBySpend && a.Spend != b.Spend {
+			if a.Spend > b.Spend {
+				return -1
+			}
+			return 1
+		}
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+	return top[:min(limit, len(top))], len(top)
+}
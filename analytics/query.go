@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"receipts/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// What receipts are grouped by in a report
+type Dimension string
+
+const (
+	GroupByRetailer Dimension = "retailer"
+	// The purchaseDate
+	GroupByDay Dimension = "day"
+	// The week, starting on Monday, of the purchaseDate
+	GroupByWeek Dimension = "week"
+	// The hour of the purchaseTime
+	GroupByHour Dimension = "hour"
+	// The day of the week of the purchaseDate
+	GroupByWeekday Dimension = "weekday"
+)
+
+// Parses a dimension name, returning an error for unknown names
+func ParseDimension(name string) (Dimension, error) {
+	switch dimension := Dimension(name); dimension {
+	case GroupByRetailer, GroupByDay, GroupByWeek, GroupByHour, GroupByWeekday:
+		return dimension, nil
+	default:
+		return "", fmt.Errorf("groupBy must be one of retailer, day, week, hour or weekday")
+	}
+}
+
+// Aggregates of the receipts in one group of a report
+type Group struct {
+	// The retailer, the date a day or week starts on, the hour from 00 to 23, or the weekday's name
+	Key      string `json:"key"`
+	Receipts int    `json:"receipts"`
+	// Sum of the receipts' totals, in dollars
+	Spend float64 `json:"spend"`
+	// Spend and units bought per receipt, rounded to 2 decimals
+	AverageSpend float64 `json:"averageSpend"`
+	AverageItems float64 `json:"averageItems"`
+	Points       int     `json:"points"`
+}
+
+// Builds a group from its totals
+func newGroup(key string, t totals) Group {
+	group := Group{Key: key, Receipts: t.receipts, Spend: float64(t.spendCents) / 100, Points: t.points}
+	if t.receipts > 0 {
+		group.AverageSpend = math.Round(float64(t.spendCents)/float64(t.receipts)) / 100
+		group.AverageItems = math.Round(float64(t.items)*100/float64(t.receipts)) / 100
+	}
+	return group
+}
+
+// Aggregates of receipts purchased in a range of dates, overall and per group
+type Report struct {
+	GroupBy Dimension `json:"groupBy"`
+	// Inclusive range of purchase dates, nil when the range is open on that side
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+	Totals Group      `json:"totals"`
+	// Groups with receipts, in order of their key, weekdays starting on Monday
+	Groups []Group `json:"groups"`
+}
+
+// Returns the key of the group a cell of the given day falls in, and the key's sort position
+func groupKey(dimension Dimension, day time.Time, cell cellKey, retailerNames map[string]string) (string, int) {
+	switch dimension {
+	case GroupByRetailer:
+		return retailerNames[cell.retailer], 0
+	case GroupByWeek:
+		// Weekday counts from Sunday, so Sunday is 6 days after the Monday starting its week
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7).Format(models.DateLayout), 0
+	case GroupByHour:
+		return fmt.Sprintf("%02d", cell.hour), 0
+	case GroupByWeekday:
+		return day.Weekday().String(), (int(day.Weekday()) + 6) % 7
+	default:
+		return day.Format(models.DateLayout), 0
+	}
+}
+
+/*
+Returns the aggregates of receipts purchased from from to to, both inclusive and either
+nil to leave that side of the range open, grouped by the dimension. Waits for the read lock.
+*/
+func (a *Aggregates) Query(dimension Dimension, from *time.Time, to *time.Time) Report {
+	a.RLock()
+	defer a.RUnlock()
+
+	first, last := 0, len(a.days)
+	if from != nil {
+		first, _ = slices.BinarySearchFunc(a.days, from.UTC().Truncate(24*time.Hour), time.Time.Compare)
+	}
+	if to != nil {
+		last, _ = slices.BinarySearchFunc(a.days, to.UTC().Truncate(24*time.Hour).Add(time.Nanosecond), time.Time.Compare)
+	}
+
+	type groupTotals struct {
+		order  int
+		totals totals
+	}
+	keyToGroup := make(map[string]*groupTotals)
+	var overall totals
+	for _, day := range a.days[first:max(first, last)] {
+		for cell, cellTotals := range a.dayToCells[day] {
+			key, order := groupKey(dimension, day, cell, a.retailerNames)
+			group, exists := keyToGroup[key]
+			if !exists {
+				group = &groupTotals{order: order}
+				keyToGroup[key] = group
+			}
+			group.totals.add(*cellTotals, 1)
+			overall.add(*cellTotals, 1)
+		}
+	}
+
+	report := Report{GroupBy: dimension, From: from, To: to, Totals: newGroup("", overall), Groups: make([]Group, 0, len(keyToGroup))}
+	for key, group := range keyToGroup {
+		report.Groups = append(report.Groups, newGroup(key, group.totals))
+	}
+	slices.SortFunc(report.Groups, func(x, y Group) int {
+		if order := keyToGroup[x.Key].order - keyToGroup[y.Key].order; order != 0 {
+			return order
+		}
+		return strings.Compare(x.Key, y.Key)
+	})
+	return report
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"receipts/points"
+	"text/tabwriter"
+)
+
+func runValidate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	strict := flags.Bool("strict", false, "reject json receipts with unknown fields or trailing data")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	inputs, err := readInputs(flags.Args(), stdin, *strict)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	exitCode := exitOk
+	for _, in := range inputs {
+		if err := validate(in); err != nil {
+			fmt.Fprintf(stdout, "%s: invalid: %v\n", in.Name, err)
+			exitCode = exitInvalid
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok\n", in.Name)
+	}
+	return exitCode
+}
+
+// Score of a single input as printed by the score command in json format
+type scoreResult struct {
+	File      string              `json:"file"`
+	Valid     bool                `json:"valid"`
+	Error     string              `json:"error,omitempty"`
+	Points    int                 `json:"points"`
+	Breakdown []points.RulePoints `json:"breakdown,omitempty"`
+}
+
+func runScore(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("score", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	strict := flags.Bool("strict", false, "reject json receipts with unknown fields or trailing data")
+	format := flags.String("format", "table", "output format, table or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(stderr, "unknown format %q, must be table or json\n", *format)
+		return exitUsage
+	}
+
+	inputs, err := readInputs(flags.Args(), stdin, *strict)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	exitCode := exitOk
+	results := make([]scoreResult, 0, len(inputs))
+	for _, in := range inputs {
+		result := scoreResult{File: in.Name}
+		if err := validate(in); err != nil {
+			result.Error = err.Error()
+			exitCode = exitInvalid
+		} else {
+			result.Valid = true
+			result.Points = points.CalculatePoints(in.Receipt)
+			result.Breakdown = points.CalculateBreakdown(in.Receipt)
+		}
+		results = append(results, result)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+	} else {
+		writeScoreTable(stdout, results)
+	}
+	return exitCode
+}
+
+// Prints one table of rule points per input, separated by blank lines
+func writeScoreTable(stdout io.Writer, results []scoreResult) {
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintln(stdout, result.File)
+		if !result.Valid {
+			fmt.Fprintf(stdout, "invalid: %s\n", result.Error)
+			continue
+		}
+
+		table := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "RULE\tPOINTS")
+		for _, rulePoints := range result.Breakdown {
+			fmt.Fprintf(table, "%s\t%d\n", rulePoints.Rule, rulePoints.Points)
+		}
+		fmt.Fprintf(table, "TOTAL\t%d\n", result.Points)
+		table.Flush()
+	}
+}
+
+// Returns the error reading or validating the input, if any
+func validate(in input) error {
+	if in.Err != nil {
+		return in.Err
+	}
+	return in.Receipt.Validate()
+}
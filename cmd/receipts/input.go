@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"receipts/models"
+	"sort"
+	"strings"
+)
+
+// A receipt read from one input, or the error that prevented reading it
+type input struct {
+	Name    string
+	Receipt *models.Receipt
+	Err     error
+}
+
+// Extensions of the files picked up when a directory is given
+var receiptExtensions = map[string]bool{".json": true, ".xml": true, ".csv": true}
+
+/*
+Expands the paths given on the command line into the files to read. Directories
+are replaced by the receipt files directly inside them, and no paths means stdin.
+*/
+func expandPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return []string{"-"}, nil
+	}
+
+	var expanded []string
+	for _, path := range paths {
+		if path == "-" {
+			expanded = append(expanded, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			expanded = append(expanded, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, entry := range entries {
+			if !entry.IsDir() && receiptExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+		expanded = append(expanded, files...)
+	}
+	return expanded, nil
+}
+
+// Reads every path, decoding receipts by file extension
+func readInputs(paths []string, stdin io.Reader, strict bool) ([]input, error) {
+	expanded, err := expandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]input, 0, len(expanded))
+	for _, path := range expanded {
+		var data []byte
+		var err error
+		name := path
+		if path == "-" {
+			name = "stdin"
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		receipt, err := decodeReceipt(path, data, strict)
+		inputs = append(inputs, input{Name: name, Receipt: receipt, Err: err})
+	}
+	return inputs, nil
+}
+
+func decodeReceipt(path string, data []byte, strict bool) (*models.Receipt, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		var receipt models.Receipt
+		if err := xml.Unmarshal(data, &receipt); err != nil {
+			return nil, err
+		}
+		return &receipt, nil
+	case ".csv":
+		return models.ReceiptFromCSV(bytes.NewReader(data))
+	default:
+		var receipt models.Receipt
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+		if err := decoder.Decode(&receipt); err != nil {
+			return nil, err
+		}
+		if strict {
+			// More() is false before a stray ] or }, so only reaching the end proves there's nothing after the value
+			if _, err := decoder.Token(); err != io.EOF {
+				return nil, fmt.Errorf("file must contain a single JSON value")
+			}
+		}
+		return &receipt, nil
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes of the cli
+const (
+	exitOk      int = 0
+	exitInvalid int = 1
+	exitUsage   int = 2
+)
+
+const usage = `Usage: receipts <command> [flags] [files...]
+
+Commands:
+  validate   Checks receipts against the api's validation rules
+  score      Prints the points, and points per rule, each receipt is worth
+  export     Downloads every receipt stored in a running server
+  import     Uploads receipts from an export file to a running server
+  simulate   Compares the points receipts earn under a candidate rule set with the current rules
+  replay     Replays recorded requests from a JSONL file and reports differences
+
+For validate, score and simulate, files may be json, xml or csv receipts, picked by extension,
+or directories of them. With no files, or a file named -, a json receipt is read from stdin.
+
+Run receipts <command> -h for the flags of a command.
+`
+
+// Scores and validates receipt files without running the server
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Runs the command in args, returning the exit code. Split from main so tests can call it.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "validate":
+		return runValidate(args[1:], stdin, stdout, stderr)
+	case "score":
+		return runScore(args[1:], stdin, stdout, stderr)
+	case "export":
+		return runExport(args[1:], stdout, stderr)
+	case "import":
+		return runImport(args[1:], stdin, stdout, stderr)
+	case "simulate":
+		return runSimulate(args[1:], stdin, stdout, stderr)
+	case "replay":
+		return runReplay(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOk
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validReceipt = `{
+					"retailer": "Target",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "13:13",
+					"total": "1.25",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}
+					]
+				}`
+
+// Runs the cli and returns its exit code, stdout and stderr
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunUsage(t *testing.T) {
+	code, _, stderr := runCLI(t, "")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "Usage")
+
+	code, _, stderr = runCLI(t, "", "unknown")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "unknown command")
+
+	code, stdout, _ := runCLI(t, "", "help")
+	assert.Equal(t, exitOk, code)
+	assert.Contains(t, stdout, "Usage")
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("ExampleReceipts", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, "", "validate", "../../example-receipts")
+		assert.Equal(t, exitOk, code)
+		assert.Equal(t, filepath.Join("../../example-receipts", "morning-receipt.json")+": ok\n"+
+			filepath.Join("../../example-receipts", "simple-receipt.json")+": ok\n", stdout)
+	})
+
+	t.Run("Stdin", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, validReceipt, "validate")
+		assert.Equal(t, exitOk, code)
+		assert.Equal(t, "stdin: ok\n", stdout)
+	})
+
+	t.Run("InvalidReceipt", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, strings.Replace(validReceipt, `"1.25"`, `"1.255"`, 1), "validate", "-")
+		assert.Equal(t, exitInvalid, code)
+		assert.Contains(t, stdout, "stdin: invalid: invalid total format")
+	})
+
+	t.Run("StrictRejectsUnknownFields", func(t *testing.T) {
+		withUnknownField := strings.Replace(validReceipt, `"retailer"`, `"store": "x", "retailer"`, 1)
+		code, _, _ := runCLI(t, withUnknownField, "validate")
+		assert.Equal(t, exitOk, code)
+		code, _, _ = runCLI(t, withUnknownField, "validate", "-strict")
+		assert.Equal(t, exitInvalid, code)
+	})
+
+	t.Run("StrictRejectsTrailingData", func(t *testing.T) {
+		for _, trailing := range []string{"]", "}", "{}", "\n1"} {
+			code, _, _ := runCLI(t, validReceipt+trailing, "validate", "-strict")
+			assert.Equal(t, exitInvalid, code, "trailing %q", trailing)
+		}
+		code, _, _ := runCLI(t, validReceipt+"\n", "validate", "-strict")
+		assert.Equal(t, exitOk, code)
+	})
+
+	t.Run("OtherFormats", func(t *testing.T) {
+		dir := t.TempDir()
+		csvPath := filepath.Join(dir, "receipt.csv")
+		xmlPath := filepath.Join(dir, "receipt.xml")
+		require.NoError(t, os.WriteFile(csvPath, []byte("retailer,purchaseDate,purchaseTime,total,shortDescription,price\nTarget,2022-01-02,13:13,1.25,Pepsi - 12-oz,1.25\n"), 0o644))
+		require.NoError(t, os.WriteFile(xmlPath, []byte("<receipt><retailer>Target</retailer><purchaseDate>2022-01-02</purchaseDate><purchaseTime>13:13</purchaseTime><total>1.25</total><items><item><shortDescription>Pepsi - 12-oz</shortDescription><price>1.25</price></item></items></receipt>"), 0o644))
+		code, stdout, _ := runCLI(t, "", "validate", csvPath, xmlPath)
+		assert.Equal(t, exitOk, code)
+		assert.Equal(t, csvPath+": ok\n"+xmlPath+": ok\n", stdout)
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		code, _, stderr := runCLI(t, "", "validate", "does-not-exist.json")
+		assert.Equal(t, exitUsage, code)
+		assert.NotEmpty(t, stderr)
+	})
+}
+
+func TestScore(t *testing.T) {
+	t.Run("Table", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, validReceipt, "score")
+		assert.Equal(t, exitOk, code)
+		assert.Equal(t, `stdin
+RULE                 POINTS
+RetailerRule         6
+TotalRoundRule       0
+TotalMultipleRule    25
+NumItemsRule         0
+ItemDescriptionRule  0
+PurchaseDayRule      0
+PurchaseTimeRule     0
+TOTAL                31
+`, stdout)
+	})
+
+	t.Run("Json", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, "", "score", "-format", "json", "../../example-receipts/morning-receipt.json")
+		assert.Equal(t, exitOk, code)
+		var results []scoreResult
+		require.NoError(t, json.Unmarshal([]byte(stdout), &results))
+		require.Len(t, results, 1)
+		assert.True(t, results[0].Valid)
+		assert.Equal(t, 15, results[0].Points)
+		assert.Len(t, results[0].Breakdown, 7)
+	})
+
+	t.Run("InvalidReceiptStillPrintsOthers", func(t *testing.T) {
+		dir := t.TempDir()
+		invalidPath := filepath.Join(dir, "invalid.json")
+		require.NoError(t, os.WriteFile(invalidPath, []byte(`{"retailer": "Target"}`), 0o644))
+		code, stdout, _ := runCLI(t, "", "score", "-format", "json", invalidPath, "../../example-receipts/simple-receipt.json")
+		assert.Equal(t, exitInvalid, code)
+		var results []scoreResult
+		require.NoError(t, json.Unmarshal([]byte(stdout), &results))
+		require.Len(t, results, 2)
+		assert.False(t, results[0].Valid)
+		assert.NotEmpty(t, results[0].Error)
+		assert.True(t, results[1].Valid)
+		assert.Equal(t, 31, results[1].Points)
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		code, _, _ := runCLI(t, validReceipt, "score", "-format", "yaml")
+		assert.Equal(t, exitUsage, code)
+	})
+}
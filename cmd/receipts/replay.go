@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"receipts/handlers"
+	"receipts/replay"
+	"time"
+)
+
+func runReplay(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	target := flags.String("target", "", "base url of a running server, replays against an in-process router when empty")
+	concurrency := flags.Int("concurrency", 1, "number of requests in flight at once")
+	rate := flags.Float64("rate", 0, "maximum requests started per second, 0 for no limit")
+	format := flags.String("format", "text", "report format, text or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "unknown format %q, must be text or json\n", *format)
+		return exitUsage
+	}
+	if flags.NArg() > 1 {
+		fmt.Fprintln(stderr, "replay takes at most one file")
+		return exitUsage
+	}
+
+	source := stdin
+	if flags.NArg() == 1 && flags.Arg(0) != "-" {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		defer file.Close()
+		source = file
+	}
+	records, err := replay.ReadRecords(source)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	var replayTarget replay.Target = replay.HandlerTarget{Handler: handlers.CreateRouter()}
+	if *target != "" {
+		replayTarget = replay.RemoteTarget{URL: *target}
+	}
+
+	start := time.Now()
+	results := replay.Run(context.Background(), replayTarget, records, replay.Options{Concurrency: *concurrency, Rate: *rate})
+	report := replay.NewReport(results, time.Since(start))
+
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.WriteText(stdout)
+	}
+
+	if !report.Ok() {
+		return exitInvalid
+	}
+	return exitOk
+}
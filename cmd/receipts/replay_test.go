@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"receipts/handlers"
+	"receipts/replay"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recording = `{"method": "POST", "path": "/receipts/process", "body": "{\"retailer\": \"Target\", \"purchaseDate\": \"2022-01-02\", \"purchaseTime\": \"13:13\", \"total\": \"1.25\", \"items\": [{\"shortDescription\": \"Pepsi - 12-oz\", \"price\": \"1.25\"}]}", "status": 200, "response": "{\"id\": \"recorded\"}"}
+{"method": "GET", "path": "/receipts/recorded/points", "status": 200, "response": "{\"points\": 31}"}
+`
+
+func TestReplay(t *testing.T) {
+	t.Run("InProcess", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, recording, "replay", "-concurrency", "2")
+		assert.Equal(t, exitOk, code)
+		assert.Contains(t, stdout, "requests: 2, errors: 0, status mismatches: 0, points diffs: 0")
+	})
+
+	t.Run("RemoteJson", func(t *testing.T) {
+		server := httptest.NewServer(handlers.CreateRouter())
+		defer server.Close()
+		code, stdout, _ := runCLI(t, recording, "replay", "-target", server.URL, "-format", "json")
+		assert.Equal(t, exitOk, code)
+		var report replay.Report
+		require.NoError(t, json.Unmarshal([]byte(stdout), &report))
+		assert.Equal(t, 2, report.Total)
+	})
+
+	t.Run("Differences", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, `{"method": "GET", "path": "/receipts/missing/points", "status": 200}`, "replay")
+		assert.Equal(t, exitInvalid, code)
+		assert.Contains(t, stdout, "status 404, recorded 200")
+	})
+
+	t.Run("NotARecording", func(t *testing.T) {
+		code, _, _ := runCLI(t, `{"request_id": "user-034"}`, "replay")
+		assert.Equal(t, exitUsage, code)
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"receipts/points"
+	"receipts/simulation"
+	"time"
+)
+
+func runSimulate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	candidatePath := flags.String("candidate", "", "json file with the candidate rule set, required")
+	baselineVersion := flags.String("baseline", "", "rule set version to compare against, the one in effect now when empty")
+	sampleSize := flags.Int("sample", 0, "score a random sample of this many receipts, 0 for all of them")
+	seed := flags.Int64("seed", 0, "seed of the random sample")
+	top := flags.Int("top", simulation.DefaultTop, "number of most affected receipts to print")
+	strict := flags.Bool("strict", false, "reject json receipts with unknown fields or trailing data")
+	format := flags.String("format", "text", "report format, text or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "unknown format %q, must be text or json\n", *format)
+		return exitUsage
+	}
+	if *candidatePath == "" {
+		fmt.Fprintln(stderr, "-candidate is required")
+		return exitUsage
+	}
+	if *sampleSize < 0 || *top < 0 {
+		fmt.Fprintln(stderr, "-sample and -top must not be negative")
+		return exitUsage
+	}
+
+	candidate, err := readCandidate(*candidatePath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	baseline := points.EffectiveRuleSet(time.Now())
+	if *baselineVersion != "" {
+		var ok bool
+		if baseline, ok = points.RuleSetVersion(*baselineVersion); !ok {
+			fmt.Fprintf(stderr, "rule set version %s not found\n", *baselineVersion)
+			return exitUsage
+		}
+	}
+
+	inputs, err := readInputs(flags.Args(), stdin, *strict)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	// Invalid receipts are left out of the simulation rather than stopping it
+	exitCode := exitOk
+	simulationInputs := make([]simulation.Input, 0, len(inputs))
+	for _, in := range inputs {
+		if err := validate(in); err != nil {
+			fmt.Fprintf(stderr, "%s: invalid: %v\n", in.Name, err)
+			exitCode = exitInvalid
+			continue
+		}
+		simulationInputs = append(simulationInputs, simulation.Input{Id: in.Name, Receipt: in.Receipt})
+	}
+
+	options := simulation.Options{Sample: *sampleSize, Seed: *seed, Top: *top}
+	report := simulation.Simulate(simulationInputs, baseline, candidate, options)
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.WriteText(stdout)
+	}
+	return exitCode
+}
+
+// Reads a simulation.Candidate from a json file and builds its rule set
+func readCandidate(path string) (points.RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return points.RuleSet{}, err
+	}
+	var candidate simulation.Candidate
+	if err := json.Unmarshal(data, &candidate); err != nil {
+		return points.RuleSet{}, fmt.Errorf("%s: %w", path, err)
+	}
+	ruleSet, err := candidate.RuleSet()
+	if err != nil {
+		return points.RuleSet{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return ruleSet, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"receipts/simulation"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+	directory := t.TempDir()
+	candidatePath := filepath.Join(directory, "candidate.json")
+	require.NoError(t, os.WriteFile(candidatePath, []byte(`{"rules": [{"rule": "RetailerRule", "multiplier": 2}]}`), 0o644))
+
+	t.Run("Text", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, "", "simulate", "-candidate", candidatePath, "../../example-receipts")
+		assert.Equal(t, exitOk, code)
+		assert.Contains(t, stdout, "2 receipts, v1 -> candidate")
+		assert.Contains(t, stdout, "RetailerRule")
+	})
+
+	t.Run("Json", func(t *testing.T) {
+		code, stdout, _ := runCLI(t, validReceipt, "simulate", "-candidate", candidatePath, "-format", "json")
+		assert.Equal(t, exitOk, code)
+		var report simulation.Report
+		require.NoError(t, json.Unmarshal([]byte(stdout), &report))
+		assert.Equal(t, 1, report.Receipts)
+		// The candidate only has RetailerRule, doubled to 12 points for the 6 characters of Target
+		assert.Equal(t, 12, report.CandidatePoints)
+		assert.Equal(t, []simulation.ReceiptDelta{
+			{Id: "stdin", Retailer: "Target", BaselinePoints: report.BaselinePoints, CandidatePoints: 12, Delta: 12 - report.BaselinePoints},
+		}, report.MostAffected)
+	})
+
+	t.Run("InvalidReceiptSkipped", func(t *testing.T) {
+		code, stdout, stderr := runCLI(t, `{"retailer": "Target"}`, "simulate", "-candidate", candidatePath)
+		assert.Equal(t, exitInvalid, code)
+		assert.Contains(t, stderr, "stdin: invalid")
+		assert.Contains(t, stdout, "0 receipts")
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		code, _, _ := runCLI(t, validReceipt, "simulate")
+		assert.Equal(t, exitUsage, code)
+		code, _, _ = runCLI(t, validReceipt, "simulate", "-candidate", filepath.Join(directory, "missing.json"))
+		assert.Equal(t, exitUsage, code)
+		code, _, _ = runCLI(t, validReceipt, "simulate", "-candidate", candidatePath, "-baseline", "v0")
+		assert.Equal(t, exitUsage, code)
+	})
+}
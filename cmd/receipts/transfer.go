@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"receipts/transfer"
+	"strings"
+)
+
+// Flags shared by commands that talk to a running server's admin endpoints
+type serverFlags struct {
+	server string
+	token  string
+}
+
+func (s *serverFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&s.server, "server", "http://localhost:8080", "base url of the receipt processor server")
+	flags.StringVar(&s.token, "token", os.Getenv("RECEIPTS_ADMIN_TOKEN"), "admin token of the server, defaults to $RECEIPTS_ADMIN_TOKEN")
+}
+
+// Sends a request to an admin endpoint, returning an error for any non 200 response
+func (s *serverFlags) do(method string, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(s.server, "/")+path+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		message, _ := io.ReadAll(response.Body)
+		return nil, fmt.Errorf("server responded %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+	return response, nil
+}
+
+// Picks the transfer format from a file name, ndjson when the extension is not recognized
+func formatFromPath(path string) transfer.Format {
+	for _, format := range []transfer.Format{transfer.FormatArchive, transfer.FormatCSV} {
+		if strings.HasSuffix(strings.ToLower(path), format.Extension()) {
+			return format
+		}
+	}
+	return transfer.FormatNDJSON
+}
+
+func runExport(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var server serverFlags
+	server.register(flags)
+	formatName := flags.String("format", "", "ndjson, csv or archive, defaults to the output file's extension or ndjson")
+	output := flags.String("o", "-", "file to write the export to, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	format := formatFromPath(*output)
+	if *formatName != "" {
+		parsed, err := transfer.ParseFormat(*formatName)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		format = parsed
+	}
+
+	response, err := server.do("GET", "/admin/receipts/export", url.Values{"format": {string(format)}}, nil)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+	defer response.Body.Close()
+
+	destination := stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		defer file.Close()
+		destination = file
+	}
+	if _, err := io.Copy(destination, response.Body); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+	return exitOk
+}
+
+func runImport(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var server serverFlags
+	server.register(flags)
+	formatName := flags.String("format", "", "ndjson, csv or archive, defaults to the file's extension or ndjson")
+	conflict := flags.String("conflict", "fail", "what to do with ids already stored: skip, overwrite or fail")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 1 {
+		fmt.Fprintln(stderr, "import takes at most one file")
+		return exitUsage
+	}
+
+	path := "-"
+	if flags.NArg() == 1 {
+		path = flags.Arg(0)
+	}
+	format := formatFromPath(path)
+	if *formatName != "" {
+		parsed, err := transfer.ParseFormat(*formatName)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		format = parsed
+	}
+
+	source := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		defer file.Close()
+		source = file
+	}
+
+	response, err := server.do("POST", "/admin/receipts/import", url.Values{"format": {string(format)}, "conflict": {*conflict}}, source)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+	defer response.Body.Close()
+	io.Copy(stdout, response.Body)
+	return exitOk
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"receipts/handlers"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	source := httptest.NewServer(handlers.CreateRouterWithConfig(handlers.Config{AdminToken: "secret"}))
+	defer source.Close()
+	destination := httptest.NewServer(handlers.CreateRouter())
+	defer destination.Close()
+
+	// Seed the source server with one receipt
+	response, err := source.Client().Post(source.URL+"/receipts/process", "application/json", strings.NewReader(validReceipt))
+	require.NoError(t, err)
+	response.Body.Close()
+
+	for _, extension := range []string{".ndjson", ".csv", ".tar.gz"} {
+		t.Run(extension, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "receipts"+extension)
+
+			code, _, stderr := runCLI(t, "", "export", "-server", source.URL, "-o", path)
+			assert.Equal(t, exitInvalid, code)
+			assert.Contains(t, stderr, "401")
+
+			code, _, stderr = runCLI(t, "", "export", "-server", source.URL, "-token", "secret", "-o", path)
+			require.Equal(t, exitOk, code, stderr)
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.NotZero(t, info.Size())
+
+			code, stdout, stderr := runCLI(t, "", "import", "-server", destination.URL, "-conflict", "overwrite", path)
+			require.Equal(t, exitOk, code, stderr)
+			assert.Contains(t, stdout, `"imported"`)
+		})
+	}
+
+	t.Run("ConflictFails", func(t *testing.T) {
+		code, exported, _ := runCLI(t, "", "export", "-server", source.URL, "-token", "secret")
+		require.Equal(t, exitOk, code)
+		code, _, stderr := runCLI(t, exported, "import", "-server", destination.URL)
+		assert.Equal(t, exitInvalid, code)
+		assert.Contains(t, stderr, "409")
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		code, _, _ := runCLI(t, "", "export", "-server", source.URL, "-format", "xml")
+		assert.Equal(t, exitUsage, code)
+	})
+}
@@ -0,0 +1,616 @@
+package gql
+
+import (
+	"fmt"
+	"receipts/ingest"
+	"receipts/models"
+	"receipts/points"
+	"receipts/storage"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	DefaultPageLimit int = 20
+	MaxPageLimit     int = 100
+)
+
+/*
+Creates the GraphQL schema over receipts in storage.
+
+Receipt fields resolve from storage.StoredReceipt values, and points come from the
+score cached when each receipt was stored. Receipts processed through the schema
+are stored by the ingester.
+*/
+func NewSchema(receiptStorage *storage.ReceiptStorage, ingester *ingest.Ingester) (graphql.Schema, error) {
+	canonicalRetailerType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CanonicalRetailer",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*models.CanonicalRetailer).Id, nil
+				},
+			},
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*models.CanonicalRetailer).Name, nil
+				},
+			},
+		},
+	})
+
+	productType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Product",
+		Description: "Canonical product an item's description normalizes to",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*models.Product).Id, nil
+				},
+			},
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*models.Product).Name, nil
+				},
+			},
+			"size": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Size of one unit with its unit, null when the description has none",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if size := p.Source.(*models.Product).Size; size != "" {
+						return size, nil
+					}
+					return nil, nil
+				},
+			},
+			"pack": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Number of units in a pack, null when the description has none",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if pack := p.Source.(*models.Product).Pack; pack != 0 {
+						return pack, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	itemType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Item",
+		Fields: graphql.Fields{
+			"shortDescription": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(models.Item).ShortDescription, nil
+				},
+			},
+			"price": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(models.Item).Price, nil
+				},
+			},
+			"quantity": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Number of units bought, null when not printed",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if quantity := p.Source.(models.Item).Quantity; quantity != 0 {
+						return quantity, nil
+					}
+					return nil, nil
+				},
+			},
+			"unitPrice": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return optionalString(p.Source.(models.Item).UnitPrice), nil
+				},
+			},
+			"product": &graphql.Field{
+				Type: productType,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if product := p.Source.(models.Item).Product; product != nil {
+						return product, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	rulePointsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "RulePoints",
+		Fields: graphql.Fields{
+			"rule": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(points.RulePoints).Rule, nil
+				},
+			},
+			"points": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(points.RulePoints).Points, nil
+				},
+			},
+			"campaign": &graphql.Field{
+				Type:        graphql.ID,
+				Description: "Id of the promotion campaign that awarded the points, null for rules of a rule set",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if campaign := p.Source.(points.RulePoints).Campaign; campaign != "" {
+						return campaign, nil
+					}
+					return nil, nil
+				},
+			},
+			"tier": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Membership tier whose multiplier awarded the points, null for rules of a rule set",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if tier := p.Source.(points.RulePoints).Tier; tier != "" {
+						return tier, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	taxType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Tax",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return optionalString(p.Source.(models.Tax).Name), nil
+				},
+			},
+			"amount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(models.Tax).Amount, nil
+				},
+			},
+		},
+	})
+
+	discountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Discount",
+		Fields: graphql.Fields{
+			"description": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return optionalString(p.Source.(models.Discount).Description), nil
+				},
+			},
+			"code": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return optionalString(p.Source.(models.Discount).Code), nil
+				},
+			},
+			"amount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(models.Discount).Amount, nil
+				},
+			},
+		},
+	})
+
+	receiptType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Receipt",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Id.String(), nil
+				},
+			},
+			"retailer": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Receipt.Retailer, nil
+				},
+			},
+			"canonicalRetailer": &graphql.Field{
+				Type:        canonicalRetailerType,
+				Description: "Canonical retailer the retailer was matched to when the receipt was processed",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if canonical := p.Source.(storage.StoredReceipt).Receipt.CanonicalRetailer; canonical != nil {
+						return canonical, nil
+					}
+					return nil, nil
+				},
+			},
+			"purchaseDate": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Receipt.PurchaseDate.String(), nil
+				},
+			},
+			"purchaseTime": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Receipt.PurchaseTime.String(), nil
+				},
+			},
+			"total": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Receipt.Total, nil
+				},
+			},
+			"subtotal": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return optionalString(p.Source.(storage.StoredReceipt).Receipt.Subtotal), nil
+				},
+			},
+			"taxes": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(taxType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Receipt.Taxes, nil
+				},
+			},
+			"discounts": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(discountType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Receipt.Discounts, nil
+				},
+			},
+			"tip": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return optionalString(p.Source.(storage.StoredReceipt).Receipt.Tip), nil
+				},
+			},
+			"paymentMethod": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return optionalString(string(p.Source.(storage.StoredReceipt).Receipt.PaymentMethod)), nil
+				},
+			},
+			"items": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(itemType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Receipt.Items, nil
+				},
+			},
+			"points": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Score().Points, nil
+				},
+			},
+			"breakdown": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(rulePointsType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Score().Breakdown, nil
+				},
+			},
+			"ruleVersion": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(storage.StoredReceipt).Score().RuleVersion, nil
+				},
+			},
+		},
+	})
+
+	receiptPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ReceiptPage",
+		Fields: graphql.Fields{
+			"totalCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(receiptPage).TotalCount, nil
+				},
+			},
+			"receipts": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(receiptType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(receiptPage).Receipts, nil
+				},
+			},
+		},
+	})
+
+	itemInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ItemInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"shortDescription": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"price":            &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"quantity":         &graphql.InputObjectFieldConfig{Type: graphql.Float},
+			"unitPrice":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	taxInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "TaxInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"amount": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	discountInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "DiscountInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"code":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"amount":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	receiptInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ReceiptInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"retailer":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"purchaseDate":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"purchaseTime":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"items":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(itemInputType)))},
+			"total":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"subtotal":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"taxes":         &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(taxInputType))},
+			"discounts":     &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(discountInputType))},
+			"tip":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"paymentMethod": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"receipt": &graphql.Field{
+				Type: receiptType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := uuid.Parse(p.Args["id"].(string))
+					if err != nil {
+						return nil, nil
+					}
+					stored, exists := receiptStorage.GetStoredReceipt(id)
+					if !exists {
+						return nil, nil
+					}
+					return stored, nil
+				},
+			},
+			"receipts": &graphql.Field{
+				Type: graphql.NewNonNull(receiptPageType),
+				Args: graphql.FieldConfigArgument{
+					"retailer": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Only receipts whose retailer contains this string, ignoring case",
+					},
+					"purchaseDateFrom": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Only receipts purchased on or after this date, format YYYY-MM-DD",
+					},
+					"purchaseDateTo": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Only receipts purchased on or before this date, format YYYY-MM-DD",
+					},
+					"minPoints": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: "Only receipts awarded at least this many points",
+					},
+					"limit": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: DefaultPageLimit,
+					},
+					"offset": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: 0,
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					filter, err := receiptFilterFromArgs(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return listReceipts(receiptStorage, filter), nil
+				},
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"processReceipt": &graphql.Field{
+				Type: graphql.NewNonNull(receiptType),
+				Args: graphql.FieldConfigArgument{
+					"receipt": &graphql.ArgumentConfig{Type: graphql.NewNonNull(receiptInputType)},
+					"accountId": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Loyalty account that earns the receipt's points, which must exist",
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					receipt, err := receiptFromInput(p.Args["receipt"].(map[string]any))
+					if err != nil {
+						return nil, err
+					}
+					if err := receipt.Validate(); err != nil {
+						return nil, err
+					}
+
+					accountId, _ := p.Args["accountId"].(string)
+					id, err := ingester.Ingest(receipt, accountId)
+					if err != nil {
+						return nil, err
+					}
+					stored, _ := receiptStorage.GetStoredReceipt(id)
+					return stored, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+// Converts a ReceiptInput argument into models.Receipt
+func receiptFromInput(input map[string]any) (*models.Receipt, error) {
+	purchaseDate, err := models.ParsePurchaseDate(input["purchaseDate"].(string))
+	if err != nil {
+		return nil, err
+	}
+	purchaseTime, err := models.ParsePurchaseTime(input["purchaseTime"].(string))
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &models.Receipt{
+		Retailer:      input["retailer"].(string),
+		PurchaseDate:  purchaseDate,
+		PurchaseTime:  purchaseTime,
+		Total:         input["total"].(string),
+		Subtotal:      stringArg(input, "subtotal"),
+		Tip:           stringArg(input, "tip"),
+		PaymentMethod: models.PaymentMethod(stringArg(input, "paymentMethod")),
+	}
+	for _, rawItem := range input["items"].([]any) {
+		item := rawItem.(map[string]any)
+		quantity, _ := item["quantity"].(float64)
+		receipt.Items = append(receipt.Items, models.Item{
+			ShortDescription: item["shortDescription"].(string),
+			Price:            item["price"].(string),
+			Quantity:         quantity,
+			UnitPrice:        stringArg(item, "unitPrice"),
+		})
+	}
+	for _, rawTax := range anyList(input["taxes"]) {
+		tax := rawTax.(map[string]any)
+		receipt.Taxes = append(receipt.Taxes, models.Tax{Name: stringArg(tax, "name"), Amount: tax["amount"].(string)})
+	}
+	for _, rawDiscount := range anyList(input["discounts"]) {
+		discount := rawDiscount.(map[string]any)
+		receipt.Discounts = append(receipt.Discounts, models.Discount{
+			Description: stringArg(discount, "description"),
+			Code:        stringArg(discount, "code"),
+			Amount:      discount["amount"].(string),
+		})
+	}
+	return receipt, nil
+}
+
+// Returns the optional string argument, or an empty string when it is missing or null
+func stringArg(args map[string]any, name string) string {
+	value, _ := args[name].(string)
+	return value
+}
+
+// Returns the optional list argument, or nil when it is missing or null
+func anyList(value any) []any {
+	list, _ := value.([]any)
+	return list
+}
+
+// Returns nil for an empty string, so optional fields resolve to null
+func optionalString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// Filtering and pagination arguments of the receipts query
+type receiptFilter struct {
+	retailer         string
+	purchaseDateFrom *models.PurchaseDate
+	purchaseDateTo   *models.PurchaseDate
+	minPoints        *int
+	limit            int
+	offset           int
+}
+
+// Result of the receipts query
+type receiptPage struct {
+	TotalCount int
+	Receipts   []storage.StoredReceipt
+}
+
+func receiptFilterFromArgs(args map[string]any) (receiptFilter, error) {
+	filter := receiptFilter{
+		limit:  args["limit"].(int),
+		offset: args["offset"].(int),
+	}
+	if filter.limit < 0 || filter.limit > MaxPageLimit {
+		return filter, fmt.Errorf("limit must be between 0 and %d", MaxPageLimit)
+	}
+	if filter.offset < 0 {
+		return filter, fmt.Errorf("offset must not be negative")
+	}
+
+	if retailer, ok := args["retailer"].(string); ok {
+		filter.retailer = strings.ToLower(retailer)
+	}
+	if rawDate, ok := args["purchaseDateFrom"].(string); ok {
+		purchaseDate, err := models.ParsePurchaseDate(rawDate)
+		if err != nil {
+			return filter, err
+		}
+		filter.purchaseDateFrom = &purchaseDate
+	}
+	if rawDate, ok := args["purchaseDateTo"].(string); ok {
+		purchaseDate, err := models.ParsePurchaseDate(rawDate)
+		if err != nil {
+			return filter, err
+		}
+		filter.purchaseDateTo = &purchaseDate
+	}
+	if minPoints, ok := args["minPoints"].(int); ok {
+		filter.minPoints = &minPoints
+	}
+	return filter, nil
+}
+
+func (f receiptFilter) matches(stored storage.StoredReceipt) bool {
+	receipt := stored.Receipt
+	if f.retailer != "" && !strings.Contains(strings.ToLower(receipt.Retailer), f.retailer) {
+		return false
+	}
+	if f.purchaseDateFrom != nil && receipt.PurchaseDate.Date.Before(f.purchaseDateFrom.Date) {
+		return false
+	}
+	if f.purchaseDateTo != nil && receipt.PurchaseDate.Date.After(f.purchaseDateTo.Date) {
+		return false
+	}
+	if f.minPoints != nil && stored.Score().Points < *f.minPoints {
+		return false
+	}
+	return true
+}
+
+// Returns the page of stored receipts matching the filter, in the order they were stored
+func listReceipts(receiptStorage *storage.ReceiptStorage, filter receiptFilter) receiptPage {
+	var matching []storage.StoredReceipt
+	for _, stored := range receiptStorage.ListReceipts() {
+		if filter.matches(stored) {
+			matching = append(matching, stored)
+		}
+	}
+
+	page := receiptPage{TotalCount: len(matching), Receipts: []storage.StoredReceipt{}}
+	if filter.offset >= len(matching) {
+		return page
+	}
+	end := min(filter.offset+filter.limit, len(matching))
+	page.Receipts = matching[filter.offset:end]
+	return page
+}
@@ -0,0 +1,312 @@
+package gql
+
+import (
+	"encoding/json"
+	"receipts/accounts"
+	"receipts/ingest"
+	"receipts/leaderboards"
+	"receipts/models"
+	"receipts/points"
+	"receipts/storage"
+	"receipts/tiers"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Stores the receipts in order and returns a schema over them along with their ids
+func newTestSchema(t *testing.T, rawReceipts ...string) (graphql.Schema, []uuid.UUID) {
+	receiptStorage := storage.NewReceiptStorage()
+	var ids []uuid.UUID
+	for _, rawReceipt := range rawReceipts {
+		var receipt models.Receipt
+		require.NoError(t, json.Unmarshal([]byte(rawReceipt), &receipt))
+		id := uuid.New()
+		receiptStorage.SetReceipt(id, &receipt)
+		ids = append(ids, id)
+	}
+
+	ingester, _ := newTestIngester(receiptStorage)
+	schema, err := NewSchema(receiptStorage, ingester)
+	require.NoError(t, err)
+	return schema, ids
+}
+
+// Returns an ingester over the storage along with the accounts it credits
+func newTestIngester(receiptStorage *storage.ReceiptStorage) (*ingest.Ingester, *accounts.Store) {
+	accountStore := accounts.NewStore()
+	scorer := points.Scorer{Selection: points.SelectByPurchaseDate}
+	return ingest.NewIngester(receiptStorage, accountStore, tiers.NewStore(accountStore), leaderboards.NewBoards(), scorer), accountStore
+}
+
+// Runs the query and returns its data re-encoded as json, failing on any graphql error
+func execute(t *testing.T, schema graphql.Schema, query string, variables map[string]any) string {
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: query, VariableValues: variables})
+	require.Empty(t, result.Errors)
+	data, err := json.Marshal(result.Data)
+	require.NoError(t, err)
+	return string(data)
+}
+
+const (
+	targetReceipt = `{
+						"retailer": "Target",
+						"purchaseDate": "2022-01-01",
+						"purchaseTime": "13:01",
+						"items": [
+							{"shortDescription": "Mountain Dew 12PK", "price": "6.49"},
+							{"shortDescription": "Emils Cheese Pizza", "price": "12.25"},
+							{"shortDescription": "Knorr Creamy Chicken", "price": "1.26"},
+							{"shortDescription": "Doritos Nacho Cheese", "price": "3.35"},
+							{"shortDescription": "   Klarbrunn 12-PK 12 FL OZ  ", "price": "12.00"}
+						],
+						"total": "35.35"
+					}`
+	cornerMarketReceipt = `{
+						"retailer": "M&M Corner Market",
+						"purchaseDate": "2022-03-20",
+						"purchaseTime": "14:33",
+						"items": [
+							{"shortDescription": "Gatorade", "price": "2.25"},
+							{"shortDescription": "Gatorade", "price": "2.25"},
+							{"shortDescription": "Gatorade", "price": "2.25"},
+							{"shortDescription": "Gatorade", "price": "2.25"}
+						],
+						"total": "9.00"
+					}`
+	walgreensReceipt = `{
+						"retailer": "Walgreens",
+						"purchaseDate": "2022-01-02",
+						"purchaseTime": "08:13",
+						"total": "2.65",
+						"items": [
+							{"shortDescription": "Pepsi - 12-oz", "price": "1.25"},
+							{"shortDescription": "Dasani", "price": "1.40"}
+						]
+					}`
+)
+
+func TestReceiptQuery(t *testing.T) {
+	schema, ids := newTestSchema(t, cornerMarketReceipt)
+
+	t.Run("ExistingReceipt", func(t *testing.T) {
+		data := execute(t, schema, `query($id: ID!) {
+			receipt(id: $id) {
+				id retailer purchaseDate purchaseTime total
+				items { shortDescription price }
+				points
+				breakdown { rule points }
+			}
+		}`, map[string]any{"id": ids[0].String()})
+
+		assert.JSONEq(t, `{"receipt": {
+			"id": "`+ids[0].String()+`",
+			"retailer": "M&M Corner Market",
+			"purchaseDate": "2022-03-20",
+			"purchaseTime": "14:33",
+			"total": "9.00",
+			"items": [
+				{"shortDescription": "Gatorade", "price": "2.25"},
+				{"shortDescription": "Gatorade", "price": "2.25"},
+				{"shortDescription": "Gatorade", "price": "2.25"},
+				{"shortDescription": "Gatorade", "price": "2.25"}
+			],
+			"points": 109,
+			"breakdown": [
+				{"rule": "RetailerRule", "points": 14},
+				{"rule": "TotalRoundRule", "points": 50},
+				{"rule": "TotalMultipleRule", "points": 25},
+				{"rule": "NumItemsRule", "points": 10},
+				{"rule": "ItemDescriptionRule", "points": 0},
+				{"rule": "PurchaseDayRule", "points": 0},
+				{"rule": "PurchaseTimeRule", "points": 10}
+			]
+		}}`, data)
+	})
+
+	t.Run("ItemProducts", func(t *testing.T) {
+		schema, ids := newTestSchema(t, walgreensReceipt)
+		data := execute(t, schema, `query($id: ID!) {
+			receipt(id: $id) { items { product { id name size pack } } }
+		}`, map[string]any{"id": ids[0].String()})
+
+		assert.JSONEq(t, `{"receipt": {"items": [
+			{"product": {"id": "pepsi 12 oz", "name": "Pepsi", "size": "12 oz", "pack": null}},
+			{"product": {"id": "dasani water", "name": "Dasani Water", "size": null, "pack": null}}
+		]}}`, data)
+	})
+
+	t.Run("CanonicalRetailer", func(t *testing.T) {
+		data := execute(t, schema, `query($id: ID!) {
+			receipt(id: $id) { retailer canonicalRetailer { id name } }
+		}`, map[string]any{"id": ids[0].String()})
+
+		assert.JSONEq(t, `{"receipt": {"retailer": "M&M Corner Market", "canonicalRetailer": {"id": "m-and-m-corner-market", "name": "M&M Corner Market"}}}`, data)
+	})
+
+	t.Run("NonExistentReceipt", func(t *testing.T) {
+		data := execute(t, schema, `{ receipt(id: "`+uuid.New().String()+`") { id } }`, nil)
+		assert.JSONEq(t, `{"receipt": null}`, data)
+	})
+
+	t.Run("InvalidIdFormat", func(t *testing.T) {
+		data := execute(t, schema, `{ receipt(id: "1234") { id } }`, nil)
+		assert.JSONEq(t, `{"receipt": null}`, data)
+	})
+}
+
+func TestReceiptsQuery(t *testing.T) {
+	schema, ids := newTestSchema(t, targetReceipt, cornerMarketReceipt, walgreensReceipt)
+
+	tests := []struct {
+		testName     string
+		arguments    string
+		expectedData string
+	}{
+		{
+			testName:     "NoArguments",
+			arguments:    "",
+			expectedData: `{"receipts": {"totalCount": 3, "receipts": [{"id": "` + ids[0].String() + `"}, {"id": "` + ids[1].String() + `"}, {"id": "` + ids[2].String() + `"}]}}`,
+		},
+		{
+			testName:     "RetailerIgnoresCase",
+			arguments:    `(retailer: "corner")`,
+			expectedData: `{"receipts": {"totalCount": 1, "receipts": [{"id": "` + ids[1].String() + `"}]}}`,
+		},
+		{
+			testName:     "PurchaseDateRange",
+			arguments:    `(purchaseDateFrom: "2022-01-02", purchaseDateTo: "2022-03-20")`,
+			expectedData: `{"receipts": {"totalCount": 2, "receipts": [{"id": "` + ids[1].String() + `"}, {"id": "` + ids[2].String() + `"}]}}`,
+		},
+		{
+			testName:     "MinPoints",
+			arguments:    `(minPoints: 28)`,
+			expectedData: `{"receipts": {"totalCount": 2, "receipts": [{"id": "` + ids[0].String() + `"}, {"id": "` + ids[1].String() + `"}]}}`,
+		},
+		{
+			testName:     "LimitAndOffset",
+			arguments:    `(limit: 1, offset: 1)`,
+			expectedData: `{"receipts": {"totalCount": 3, "receipts": [{"id": "` + ids[1].String() + `"}]}}`,
+		},
+		{
+			testName:     "OffsetPastEnd",
+			arguments:    `(offset: 10)`,
+			expectedData: `{"receipts": {"totalCount": 3, "receipts": []}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			data := execute(t, schema, `{ receipts`+test.arguments+` { totalCount receipts { id } } }`, nil)
+			assert.JSONEq(t, test.expectedData, data)
+		})
+	}
+
+	t.Run("InvalidArguments", func(t *testing.T) {
+		for _, arguments := range []string{`(limit: 1000)`, `(offset: -1)`, `(purchaseDateFrom: "2022-1-1")`} {
+			result := graphql.Do(graphql.Params{Schema: schema, RequestString: `{ receipts` + arguments + ` { totalCount } }`})
+			assert.NotEmpty(t, result.Errors, arguments)
+		}
+	})
+}
+
+func TestProcessReceiptMutation(t *testing.T) {
+	schema, _ := newTestSchema(t)
+	mutation := `mutation($receipt: ReceiptInput!) { processReceipt(receipt: $receipt) { id retailer points ruleVersion } }`
+
+	t.Run("ValidReceipt", func(t *testing.T) {
+		var receipt map[string]any
+		require.NoError(t, json.Unmarshal([]byte(targetReceipt), &receipt))
+		data := execute(t, schema, mutation, map[string]any{"receipt": receipt})
+
+		var response struct {
+			ProcessReceipt struct {
+				Id          string
+				Retailer    string
+				Points      int
+				RuleVersion string
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(data), &response))
+		_, err := uuid.Parse(response.ProcessReceipt.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Target", response.ProcessReceipt.Retailer)
+		assert.Equal(t, 28, response.ProcessReceipt.Points)
+		assert.Equal(t, points.EffectiveRuleSet(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)).Version, response.ProcessReceipt.RuleVersion)
+
+		// Stored receipt can be queried afterwards
+		data = execute(t, schema, `{ receipts { totalCount } }`, nil)
+		assert.JSONEq(t, `{"receipts": {"totalCount": 1}}`, data)
+	})
+
+	t.Run("ExtendedReceipt", func(t *testing.T) {
+		var receipt map[string]any
+		require.NoError(t, json.Unmarshal([]byte(walgreensReceipt), &receipt))
+		receipt["items"].([]any)[0].(map[string]any)["quantity"] = 1
+		receipt["items"].([]any)[0].(map[string]any)["unitPrice"] = "1.25"
+		receipt["taxes"] = []any{map[string]any{"name": "Sales Tax", "amount": "0.15"}}
+		receipt["discounts"] = []any{map[string]any{"code": "SAVE", "amount": "0.15"}}
+		receipt["paymentMethod"] = "cash"
+		data := execute(t, schema, `mutation($receipt: ReceiptInput!) {
+			processReceipt(receipt: $receipt) {
+				points subtotal tip paymentMethod
+				items { quantity unitPrice }
+				taxes { name amount }
+				discounts { description code amount }
+			}
+		}`, map[string]any{"receipt": receipt})
+
+		assert.JSONEq(t, `{"processReceipt": {
+			"points": 15, "subtotal": null, "tip": null, "paymentMethod": "cash",
+			"items": [{"quantity": 1, "unitPrice": "1.25"}, {"quantity": null, "unitPrice": null}],
+			"taxes": [{"name": "Sales Tax", "amount": "0.15"}],
+			"discounts": [{"description": null, "code": "SAVE", "amount": "0.15"}]
+		}}`, data)
+
+		receipt["total"] = "2.80"
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: mutation, VariableValues: map[string]any{"receipt": receipt}})
+		assert.NotEmpty(t, result.Errors, "taxes and discounts must reconcile with the total")
+	})
+
+	t.Run("InvalidReceipt", func(t *testing.T) {
+		var receipt map[string]any
+		require.NoError(t, json.Unmarshal([]byte(walgreensReceipt), &receipt))
+		receipt["total"] = "2.652"
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: mutation, VariableValues: map[string]any{"receipt": receipt}})
+		assert.NotEmpty(t, result.Errors)
+	})
+
+	t.Run("Account", func(t *testing.T) {
+		receiptStorage := storage.NewReceiptStorage()
+		ingester, accountStore := newTestIngester(receiptStorage)
+		schema, err := NewSchema(receiptStorage, ingester)
+		require.NoError(t, err)
+		account := accountStore.CreateAccount(accounts.Account{Name: "Jane"})
+		mutation := `mutation($receipt: ReceiptInput!, $accountId: String) { processReceipt(receipt: $receipt, accountId: $accountId) { id points } }`
+
+		var receipt map[string]any
+		require.NoError(t, json.Unmarshal([]byte(targetReceipt), &receipt))
+		data := execute(t, schema, mutation, map[string]any{"receipt": receipt, "accountId": account.Id})
+		var response struct {
+			ProcessReceipt struct {
+				Id     string
+				Points int
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(data), &response))
+
+		balance, _ := accountStore.Balance(account.Id)
+		assert.Equal(t, 28, balance)
+		receiptAccount, _ := accountStore.ReceiptAccount(response.ProcessReceipt.Id)
+		assert.Equal(t, account.Id, receiptAccount)
+
+		// Unknown accounts are an error and store nothing
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: mutation, VariableValues: map[string]any{"receipt": receipt, "accountId": "missing"}})
+		assert.NotEmpty(t, result.Errors)
+		assert.Equal(t, 1, receiptStorage.Count())
+	})
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"receipts/accounts"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Response of GetBalance
+type balanceResponse struct {
+	AccountId string `json:"accountId"`
+	Balance   int    `json:"balance"`
+}
+
+// Response of GetLedger
+type ledgerResponse struct {
+	AccountId string           `json:"accountId"`
+	Entries   []accounts.Entry `json:"entries"`
+}
+
+// Request body of AdjustBalance
+type adjustmentRequest struct {
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+}
+
+// Creates a loyalty account from the json request body, which receipts can then be submitted for
+func (h *Handlers) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	var account accounts.Account
+	if status, err := h.decodeJSONBody(w, r, &account); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err := account.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, h.accounts.CreateAccount(account))
+}
+
+func (h *Handlers) GetAccount(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	account, exists := h.accounts.GetAccount(id)
+	if !exists {
+		http.Error(w, "account with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+// Returns the account's points balance, the sum of its ledger
+func (h *Handlers) GetBalance(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	balance, exists := h.accounts.Balance(id)
+	if !exists {
+		http.Error(w, "account with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, balanceResponse{AccountId: id, Balance: balance})
+}
+
+// Returns every entry of the account's ledger, oldest first
+func (h *Handlers) GetLedger(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ledger, exists := h.accounts.Ledger(id)
+	if !exists {
+		http.Error(w, "account with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, ledgerResponse{AccountId: id, Entries: ledger})
+}
+
+// Appends a manual adjust entry from the json request body to the account's ledger
+func (h *Handlers) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var request adjustmentRequest
+	if status, err := h.decodeJSONBody(w, r, &request); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if request.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := h.accounts.Append(accounts.Entry{AccountId: id, Type: accounts.EntryAdjust, Points: request.Points, Reason: request.Reason})
+	if errors.Is(err, accounts.ErrAccountNotFound) {
+		http.Error(w, "account with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+// Default number of days ahead GetExpiringPoints and ListExpiringPoints look
+const DefaultExpiringDays int = 30
+
+// Response of GetExpiringPoints
+type expiringResponse struct {
+	AccountId string         `json:"accountId"`
+	Policy    string         `json:"policy"`
+	Days      int            `json:"days"`
+	Points    int            `json:"points"`
+	Lots      []accounts.Lot `json:"lots"`
+}
+
+// Response of ListExpiringPoints
+type expiringAccountsResponse struct {
+	Policy   string                    `json:"policy"`
+	Days     int                       `json:"days"`
+	Accounts []accounts.ExpiringPoints `json:"accounts"`
+}
+
+// Response of ExpirePoints
+type expireResponse struct {
+	Policy  string           `json:"policy"`
+	Entries []accounts.Entry `json:"entries"`
+}
+
+/*
+Returns the account's points that expire under the configured policy within the
+number of days given by the days query parameter, oldest first.
+*/
+func (h *Handlers) GetExpiringPoints(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	days, err := queryDays(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lots, exists := h.accounts.ExpiringLots(id, h.config.Expiration, time.Now(), time.Duration(days)*24*time.Hour)
+	if !exists {
+		http.Error(w, "account with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+
+	response := expiringResponse{AccountId: id, Policy: h.config.Expiration.String(), Days: days, Lots: lots}
+	for _, lot := range lots {
+		response.Points += lot.Remaining
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Returns every account with points that expire within the number of days given by the days query parameter
+func (h *Handlers) ListExpiringPoints(w http.ResponseWriter, r *http.Request) {
+	days, err := queryDays(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, expiringAccountsResponse{
+		Policy:   h.config.Expiration.String(),
+		Days:     days,
+		Accounts: h.accounts.ExpiringPoints(h.config.Expiration, time.Now(), time.Duration(days)*24*time.Hour),
+	})
+}
+
+// Expires every point that has expired under the configured policy now, instead of waiting for the scheduler
+func (h *Handlers) ExpirePoints(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, expireResponse{
+		Policy:  h.config.Expiration.String(),
+		Entries: h.accounts.ExpirePoints(h.config.Expiration, time.Now()),
+	})
+}
+
+// Parses the days query parameter, DefaultExpiringDays when it is missing
+func queryDays(r *http.Request) (int, error) {
+	days, err := strconv.Atoi(queryOrDefault(r, "days", strconv.Itoa(DefaultExpiringDays)))
+	if err != nil || days < 1 || days > 3660 {
+		return 0, fmt.Errorf("days must be a number from 1 to 3660")
+	}
+	return days, nil
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/accounts"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccounts(t *testing.T) {
+	router := CreateRouter()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+	getBalance := func(accountId string) int {
+		responseRecorder := serve("GET", "/accounts/"+accountId+"/balance", "")
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var balance balanceResponse
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &balance))
+		return balance.Balance
+	}
+
+	responseRecorder := serve("POST", "/accounts", `{"name": "Jane Doe"}`)
+	require.Equal(t, http.StatusCreated, responseRecorder.Code)
+	var account accounts.Account
+	require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &account))
+	assert.Equal(t, "Jane Doe", account.Name)
+
+	t.Run("InvalidAccount", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/accounts", `{"name": " "}`).Code)
+		assert.Equal(t, http.StatusNotFound, serve("GET", "/accounts/unknown", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve("GET", "/accounts/unknown/balance", "").Code)
+	})
+
+	t.Run("Earn", func(t *testing.T) {
+		// The morning receipt earns 15 points
+		processReceipt(t, router, morningReceipt)
+		assert.Equal(t, 0, getBalance(account.Id), "receipts submitted without an account earn nothing")
+
+		responseRecorder := serve("POST", "/receipts/process?accountId="+account.Id, morningReceipt)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.Equal(t, 15, getBalance(account.Id))
+
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/receipts/process?accountId=unknown", morningReceipt).Code)
+	})
+
+	t.Run("Adjust", func(t *testing.T) {
+		responseRecorder := serve("POST", "/admin/accounts/"+account.Id+"/adjustments", `{"points": -20, "reason": "Returned items"}`)
+		require.Equal(t, http.StatusCreated, responseRecorder.Code)
+		var entry accounts.Entry
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &entry))
+		assert.Equal(t, accounts.EntryAdjust, entry.Type)
+		assert.Equal(t, -5, entry.Balance)
+
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/admin/accounts/"+account.Id+"/adjustments", `{"points": 0, "reason": "Nothing"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/admin/accounts/"+account.Id+"/adjustments", `{"points": 5}`).Code)
+		assert.Equal(t, http.StatusNotFound, serve("POST", "/admin/accounts/unknown/adjustments", `{"points": 5, "reason": "Goodwill"}`).Code)
+	})
+
+	t.Run("RescoreAdjusts", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, serve("POST", "/admin/campaigns", `{"name": "Walgreens bonus", "retailer": "Walgreens", "bonus": 100}`).Code)
+		require.Equal(t, http.StatusOK, serve("POST", "/admin/receipts/rescore?version=v1&dryRun=true", "").Code)
+		assert.Equal(t, -5, getBalance(account.Id), "dry runs must not touch the ledger")
+
+		require.Equal(t, http.StatusOK, serve("POST", "/admin/receipts/rescore?version=v1", "").Code)
+		assert.Equal(t, 95, getBalance(account.Id))
+	})
+
+	t.Run("Ledger", func(t *testing.T) {
+		responseRecorder := serve("GET", "/accounts/"+account.Id+"/ledger", "")
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var ledger ledgerResponse
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &ledger))
+		require.Len(t, ledger.Entries, 3)
+		assert.Equal(t, accounts.EntryEarn, ledger.Entries[0].Type)
+		assert.Equal(t, 15, ledger.Entries[0].Points)
+		assert.NotEmpty(t, ledger.Entries[0].ReceiptId)
+		assert.Equal(t, ledger.Entries[0].ReceiptId, ledger.Entries[2].ReceiptId)
+		assert.Equal(t, 100, ledger.Entries[2].Points)
+		assert.Equal(t, 95, ledger.Entries[2].Balance)
+	})
+
+	t.Run("ConcurrentEarn", func(t *testing.T) {
+		before := getBalance(account.Id)
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest("POST", "/receipts/process?accountId="+account.Id, bytes.NewBufferString(morningReceipt))
+				router.ServeHTTP(httptest.NewRecorder(), req)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, before+50*115, getBalance(account.Id))
+	})
+}
+
+func TestExpiringPoints(t *testing.T) {
+	config := DefaultConfig()
+	config.Expiration = accounts.ExpirationPolicy{Kind: accounts.ExpireAfterDays, Days: 30}
+	router := CreateRouterWithConfig(config)
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	var account accounts.Account
+	require.NoError(t, json.Unmarshal(serve("POST", "/accounts", `{"name": "Jane Doe"}`).Body.Bytes(), &account))
+	require.Equal(t, http.StatusOK, serve("POST", "/receipts/process?accountId="+account.Id, morningReceipt).Code)
+
+	tests := []struct {
+		testName       string
+		days           string
+		expectedStatus int
+		expectedPoints int
+	}{
+		{testName: "WithinWindow", days: "?days=31", expectedStatus: http.StatusOK, expectedPoints: 15},
+		{testName: "DefaultWindow", days: "", expectedStatus: http.StatusOK, expectedPoints: 15},
+		{testName: "BeforeWindow", days: "?days=7", expectedStatus: http.StatusOK, expectedPoints: 0},
+		{testName: "InvalidDays", days: "?days=soon", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			responseRecorder := serve("GET", "/accounts/"+account.Id+"/expiring"+test.days, "")
+			require.Equal(t, test.expectedStatus, responseRecorder.Code)
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			var expiring expiringResponse
+			require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &expiring))
+			assert.Equal(t, "days:30", expiring.Policy)
+			assert.Equal(t, test.expectedPoints, expiring.Points)
+		})
+	}
+
+	t.Run("AllAccounts", func(t *testing.T) {
+		var expiring expiringAccountsResponse
+		require.NoError(t, json.Unmarshal(serve("GET", "/admin/accounts/expiring?days=31", "").Body.Bytes(), &expiring))
+		require.Len(t, expiring.Accounts, 1)
+		assert.Equal(t, account.Id, expiring.Accounts[0].AccountId)
+		assert.Equal(t, 15, expiring.Accounts[0].Points)
+	})
+
+	t.Run("NothingExpiredYet", func(t *testing.T) {
+		var expired expireResponse
+		require.NoError(t, json.Unmarshal(serve("POST", "/admin/accounts/expire", "").Body.Bytes(), &expired))
+		assert.Empty(t, expired.Entries)
+	})
+
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/accounts/unknown/expiring", "").Code)
+}
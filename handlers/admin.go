@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"receipts/accounts"
+	"receipts/points"
+	"receipts/storage"
+	"receipts/transfer"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+/*
+Wraps admin handlers so they require the configured admin token as a bearer token.
+When no token is configured the admin endpoints are open, as the rest of the api is,
+which only routers built in tests do since main refuses to start without a token.
+*/
+func (h *Handlers) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.config.AdminToken != "" {
+			token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.AdminToken)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "admin token required", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Writes every stored receipt, with ids and metadata, in the format given by the format query parameter.
+func (h *Handlers) ExportReceipts(w http.ResponseWriter, r *http.Request) {
+	format, err := transfer.ParseFormat(queryOrDefault(r, "format", string(transfer.FormatNDJSON)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filename := "receipts-" + time.Now().UTC().Format("20060102T150405Z") + format.Extension()
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	// Headers are already sent, so a failure part way through can only cut the body short
+	transfer.Export(w, format, h.storage.ListReceipts())
+}
+
+/*
+Reads receipts in the format given by the format query parameter from the request
+body and stores them under their original ids. The conflict query parameter decides
+what happens to ids that are already stored. If any receipt in the body is invalid, or
+would overwrite a receipt submitted for an account, whose points are in the account's
+ledger and leaderboards, nothing is imported.
+*/
+func (h *Handlers) ImportReceipts(w http.ResponseWriter, r *http.Request) {
+	format, err := transfer.ParseFormat(queryOrDefault(r, "format", string(transfer.FormatNDJSON)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy, err := storage.ParseConflictPolicy(queryOrDefault(r, "conflict", string(storage.ConflictFail)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := r.Body
+	if h.config.MaxImportBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, h.config.MaxImportBytes)
+	}
+	// Read fully first so an oversized body is reported as such rather than as a truncated record
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
+		return
+	}
+	receipts, err := transfer.Import(bytes.NewReader(data), format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if policy == storage.ConflictOverwrite {
+		for _, stored := range receipts {
+			_, linked := h.accounts.ReceiptAccount(stored.Id.String())
+			if _, exists := h.storage.GetStoredReceipt(stored.Id); linked && exists {
+				http.Error(w, "receipt with id "+stored.Id.String()+" was submitted for an account and can't be overwritten", http.StatusConflict)
+				return
+			}
+		}
+	}
+
+	// Scored with the version each receipt was exported with, so imported points match the source
+	for i, stored := range receipts {
+		score := h.scorer.ScoreWith(points.StoredRuleSet(stored.Receipt, stored.Metadata.RuleVersion), stored.Receipt)
+		receipts[i].Metadata.RuleVersion = score.RuleVersion
+		receipts[i].CachedScore = &score
+	}
+
+	summary, err := h.storage.ImportReceipts(receipts, policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// How points of stored receipts changed when they were rescored
+type rescoreReport struct {
+	RuleVersion  string        `json:"ruleVersion"`
+	DryRun       bool          `json:"dryRun"`
+	Receipts     int           `json:"receipts"`
+	Changed      int           `json:"changed"`
+	PointsBefore int           `json:"pointsBefore"`
+	PointsAfter  int           `json:"pointsAfter"`
+	Changes      []scoreChange `json:"changes"`
+}
+
+// A receipt whose points changed when it was rescored
+type scoreChange struct {
+	Id                  string `json:"id"`
+	PreviousRuleVersion string `json:"previousRuleVersion"`
+	PreviousPoints      int    `json:"previousPoints"`
+	Points              int    `json:"points"`
+	// Why the change couldn't be applied to the receipt's account, the new points are saved regardless
+	AccountError string `json:"accountError,omitempty"`
+}
+
+/*
+Recalculates the points of every stored receipt with the rule set given by the version
+query parameter, the current campaigns and the tiers accounts were in, and reports the
+receipts whose points changed. With dryRun=true the report is returned without saving
+the new points. Otherwise each change is also recorded as an adjust entry in the
+ledger of the account the receipt was submitted for.
+*/
+func (h *Handlers) RescoreReceipts(w http.ResponseWriter, r *http.Request) {
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		http.Error(w, "version query parameter is required", http.StatusBadRequest)
+		return
+	}
+	ruleSet, ok := points.RuleSetVersion(version)
+	if !ok {
+		http.Error(w, "rule set version "+version+" not found", http.StatusBadRequest)
+		return
+	}
+	dryRun, err := strconv.ParseBool(queryOrDefault(r, "dryRun", "false"))
+	if err != nil {
+		http.Error(w, "dryRun must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	report := rescoreReport{RuleVersion: ruleSet.Version, DryRun: dryRun, Changes: []scoreChange{}}
+	score := func(stored storage.StoredReceipt) points.Score {
+		scorer := h.scorer
+		// Receipts keep the multiplier of the tier their account was in when they were submitted
+		if accountId, exists := h.accounts.ReceiptAccount(stored.Id.String()); exists {
+			scorer = scorer.WithBonus(h.tiers.TierAt(accountId, stored.Metadata.CreatedAt))
+		}
+		return scorer.ScoreWith(ruleSet, stored.Receipt)
+	}
+	for _, update := range h.storage.RescoreReceipts(score, dryRun) {
+		report.Receipts++
+		report.PointsBefore += update.Previous.Points
+		report.PointsAfter += update.Current.Points
+		if update.Previous.Points != update.Current.Points {
+			report.Changed++
+			change := scoreChange{
+				Id:                  update.Id.String(),
+				PreviousRuleVersion: update.Previous.RuleVersion,
+				PreviousPoints:      update.Previous.Points,
+				Points:              update.Current.Points,
+			}
+			if !dryRun {
+				if err := h.adjustRescoredAccount(update, ruleSet.Version); err != nil {
+					log.Printf("failed to adjust account for rescored receipt %s: %v", change.Id, err)
+					change.AccountError = err.Error()
+				}
+			}
+			report.Changes = append(report.Changes, change)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+/*
+Adjusts the balance of the account a rescored receipt was submitted for by the change
+in its points, and replaces the receipt's points on the account's leaderboards. Returns
+the error appending the adjust entry, if any.
+*/
+func (h *Handlers) adjustRescoredAccount(update storage.ScoreUpdate, version string) error {
+	accountId, exists := h.accounts.ReceiptAccount(update.Id.String())
+	if !exists {
+		return nil
+	}
+	h.leaderboards.Rescore(update.Id.String(), max(update.Current.Points, 0))
+	// Negative points earn nothing, so a change between negative scores leaves the balance as is
+	change := max(update.Current.Points, 0) - max(update.Previous.Points, 0)
+	if change == 0 {
+		return nil
+	}
+	_, err := h.accounts.Append(accounts.Entry{
+		AccountId: accountId,
+		Type:      accounts.EntryAdjust,
+		Points:    change,
+		ReceiptId: update.Id.String(),
+		Reason:    "receipt rescored with rule set " + version,
+	})
+	return err
+}
+
+/*
+Deletes a stored receipt. When it was submitted for an account, the points and spend
+it earned are taken back with an adjust entry and taken off the account's leaderboards.
+The receipt is only deleted once the adjust entry is appended, so when it can't be the
+receipt, ledger and leaderboards are left as they were.
+*/
+func (h *Handlers) DeleteReceipt(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		http.Error(w, "receipt with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	_, exists, err := h.storage.DeleteReceiptIf(parsedId, h.takeBackReceipt)
+	if !exists {
+		http.Error(w, "receipt with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to take back points of receipt %s before deleting it: %v", id, err)
+		http.Error(w, "receipt not deleted, its points couldn't be taken back: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+Takes the points and spend a receipt earned back from its account and off the account's
+leaderboards, doing nothing for receipts not submitted for an account. Called by the
+storage while it holds the lock, so the receipt can't be deleted twice.
+*/
+func (h *Handlers) takeBackReceipt(stored storage.StoredReceipt) error {
+	id := stored.Id.String()
+	accountId, exists := h.accounts.ReceiptAccount(id)
+	if !exists {
+		return nil
+	}
+	// Stored receipts have a valid total, as when the receipt was stored
+	spend, _ := strconv.ParseFloat(stored.Receipt.Total, 64)
+	if earned := max(stored.Score().Points, 0); earned > 0 || spend > 0 {
+		_, err := h.accounts.Append(accounts.Entry{AccountId: accountId, Type: accounts.EntryAdjust, Points: -earned, ReceiptId: id, Spend: -spend, Reason: "receipt deleted"})
+		if err != nil {
+			return fmt.Errorf("account %s: %w", accountId, err)
+		}
+	}
+	h.leaderboards.Remove(id)
+	return nil
+}
+
+// Returns the query parameter, or fallback when it is missing or empty
+func queryOrDefault(r *http.Request, name string, fallback string) string {
+	if value := r.URL.Query().Get(name); value != "" {
+		return value
+	}
+	return fallback
+}
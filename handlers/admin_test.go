@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"receipts/accounts"
+	"receipts/models"
+	"receipts/points"
+	"receipts/storage"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Processes the receipt through the router and returns its id
+func processReceipt(t *testing.T, router http.Handler, rawReceipt string) string {
+	req, err := http.NewRequest("POST", "/receipts/process", bytes.NewBufferString(rawReceipt))
+	require.NoError(t, err)
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, req)
+	require.Equal(t, http.StatusOK, responseRecorder.Code)
+	var id models.Id
+	require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&id))
+	return id.Id
+}
+
+const morningReceipt = `{
+					"retailer": "Walgreens",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "08:13",
+					"total": "2.65",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "1.25"},
+						{"shortDescription": "Dasani", "price": "1.40"}
+					]
+				}`
+
+func TestAdminToken(t *testing.T) {
+	router := CreateRouterWithConfig(Config{AdminToken: "secret"})
+
+	tests := []struct {
+		testName       string
+		authorization  string
+		expectedStatus int
+	}{
+		{testName: "MissingToken", authorization: "", expectedStatus: http.StatusUnauthorized},
+		{testName: "WrongToken", authorization: "Bearer wrong", expectedStatus: http.StatusUnauthorized},
+		{testName: "WrongScheme", authorization: "Basic secret", expectedStatus: http.StatusUnauthorized},
+		{testName: "CorrectToken", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/admin/receipts/export", nil)
+			require.NoError(t, err)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, req)
+			assert.Equal(t, test.expectedStatus, responseRecorder.Code)
+		})
+	}
+
+	// Non admin endpoints never need the token
+	processReceipt(t, router, morningReceipt)
+}
+
+func TestExportImportReceipts(t *testing.T) {
+	for _, format := range []string{"ndjson", "csv", "archive"} {
+		t.Run(format, func(t *testing.T) {
+			source := CreateRouter()
+			id := processReceipt(t, source, morningReceipt)
+
+			req, err := http.NewRequest("GET", "/admin/receipts/export?format="+format, nil)
+			require.NoError(t, err)
+			responseRecorder := httptest.NewRecorder()
+			source.ServeHTTP(responseRecorder, req)
+			require.Equal(t, http.StatusOK, responseRecorder.Code)
+			assert.Contains(t, responseRecorder.Header().Get("Content-Disposition"), "attachment")
+			exported := responseRecorder.Body.Bytes()
+
+			destinationStorage := storage.NewReceiptStorage()
+			destination := NewRouter(NewHandlers(destinationStorage, DefaultConfig()))
+			importReceipts := func(conflict string) *httptest.ResponseRecorder {
+				req, err := http.NewRequest("POST", "/admin/receipts/import?format="+format+"&conflict="+conflict, bytes.NewReader(exported))
+				require.NoError(t, err)
+				responseRecorder := httptest.NewRecorder()
+				destination.ServeHTTP(responseRecorder, req)
+				return responseRecorder
+			}
+
+			responseRecorder = importReceipts("fail")
+			require.Equal(t, http.StatusOK, responseRecorder.Code)
+			assert.JSONEq(t, `{"imported": 1, "skipped": 0, "overwritten": 0}`, responseRecorder.Body.String())
+
+			// Imported receipt keeps its id and points
+			req, err = http.NewRequest("GET", "/receipts/"+id+"/points", nil)
+			require.NoError(t, err)
+			responseRecorder = httptest.NewRecorder()
+			destination.ServeHTTP(responseRecorder, req)
+			assert.JSONEq(t, `{"points": 15}`, responseRecorder.Body.String())
+			stored, _ := destinationStorage.GetStoredReceipt(uuid.MustParse(id))
+			assert.Equal(t, "v1", stored.Metadata.RuleVersion)
+
+			// Importing again conflicts with the stored id
+			assert.Equal(t, http.StatusConflict, importReceipts("fail").Code)
+			responseRecorder = importReceipts("skip")
+			assert.JSONEq(t, `{"imported": 0, "skipped": 1, "overwritten": 0}`, responseRecorder.Body.String())
+			responseRecorder = importReceipts("overwrite")
+			assert.JSONEq(t, `{"imported": 0, "skipped": 0, "overwritten": 1}`, responseRecorder.Body.String())
+			assert.Equal(t, 1, destinationStorage.Count())
+		})
+	}
+}
+
+func TestImportReceiptsOverwriteAccountReceipt(t *testing.T) {
+	router := CreateRouter()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+	var account accounts.Account
+	require.NoError(t, json.Unmarshal(serve("POST", "/accounts", `{"name": "Jane"}`).Body.Bytes(), &account))
+	processReceipt(t, router, morningReceipt)
+	accountReceipt := serve("POST", "/receipts/process?accountId="+account.Id, morningReceipt)
+	require.Equal(t, http.StatusOK, accountReceipt.Code)
+	exported := serve("GET", "/admin/receipts/export", "").Body.String()
+
+	// Overwriting the account's receipt would change points its ledger and leaderboards already count
+	responseRecorder := serve("POST", "/admin/receipts/import?conflict=overwrite", exported)
+	assert.Equal(t, http.StatusConflict, responseRecorder.Code)
+	assert.Contains(t, responseRecorder.Body.String(), "submitted for an account")
+	assert.JSONEq(t, `{"imported": 0, "skipped": 2, "overwritten": 0}`, serve("POST", "/admin/receipts/import?conflict=skip", exported).Body.String())
+}
+
+func TestImportReceiptsBadRequest(t *testing.T) {
+	router := CreateRouter()
+	tests := []struct {
+		testName       string
+		query          string
+		body           string
+		expectedStatus int
+	}{
+		{testName: "UnknownFormat", query: "?format=xml", body: "", expectedStatus: http.StatusBadRequest},
+		{testName: "UnknownConflictPolicy", query: "?conflict=replace", body: "", expectedStatus: http.StatusBadRequest},
+		{testName: "InvalidRecord", query: "", body: `{"id": "1234"}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/admin/receipts/import"+test.query, bytes.NewBufferString(test.body))
+			require.NoError(t, err)
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, req)
+			assert.Equal(t, test.expectedStatus, responseRecorder.Code)
+		})
+	}
+
+	t.Run("OverMaxImportBytes", func(t *testing.T) {
+		router := CreateRouterWithConfig(Config{MaxImportBytes: 5})
+		req, err := http.NewRequest("POST", "/admin/receipts/import", bytes.NewBufferString(`{"id": "1234"}`))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, responseRecorder.Code)
+	})
+}
+
+func TestRescoreReceipts(t *testing.T) {
+	receiptStorage := storage.NewReceiptStorage()
+	router := NewRouter(NewHandlers(receiptStorage, DefaultConfig()))
+	processedId := processReceipt(t, router, morningReceipt)
+
+	// A receipt whose cached points came from a rule set that awarded more
+	var receipt models.Receipt
+	require.NoError(t, json.Unmarshal([]byte(morningReceipt), &receipt))
+	staleId := uuid.New()
+	receiptStorage.SetScoredReceipt(staleId, &receipt, points.Score{RuleVersion: "v0", Points: 100})
+
+	getPoints := func(id string) string {
+		req, err := http.NewRequest("GET", "/receipts/"+id+"/points", nil)
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder.Body.String()
+	}
+	rescore := func(query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/admin/receipts/rescore"+query, nil)
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	assert.JSONEq(t, `{"points": 15}`, getPoints(processedId))
+	assert.JSONEq(t, `{"points": 100}`, getPoints(staleId.String()))
+
+	expectedReport := `{
+		"ruleVersion": "v1",
+		"dryRun": %t,
+		"receipts": 2,
+		"changed": 1,
+		"pointsBefore": 115,
+		"pointsAfter": 30,
+		"changes": [{"id": "%s", "previousRuleVersion": "v0", "previousPoints": 100, "points": 15}]
+	}`
+
+	responseRecorder := rescore("?version=v1&dryRun=true")
+	require.Equal(t, http.StatusOK, responseRecorder.Code)
+	assert.JSONEq(t, fmt.Sprintf(expectedReport, true, staleId), responseRecorder.Body.String())
+	assert.JSONEq(t, `{"points": 100}`, getPoints(staleId.String()))
+
+	responseRecorder = rescore("?version=v1")
+	require.Equal(t, http.StatusOK, responseRecorder.Code)
+	assert.JSONEq(t, fmt.Sprintf(expectedReport, false, staleId), responseRecorder.Body.String())
+	assert.JSONEq(t, `{"points": 15}`, getPoints(staleId.String()))
+
+	assert.Equal(t, http.StatusBadRequest, rescore("").Code)
+	assert.Equal(t, http.StatusBadRequest, rescore("?version=v9").Code)
+	assert.Equal(t, http.StatusBadRequest, rescore("?version=v1&dryRun=maybe").Code)
+}
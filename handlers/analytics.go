@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"receipts/analytics"
+	"receipts/models"
+	"strconv"
+	"time"
+)
+
+// Default and largest number of products GetTopProducts returns
+const (
+	DefaultTopProductsLimit int = 10
+	MaxTopProductsLimit     int = 100
+)
+
+// Response of GetTopProducts
+type topProductsResponse struct {
+	By analytics.Ranking `json:"by"`
+	// Number of distinct products bought
+	Products int                       `json:"products"`
+	Top      []analytics.ProductTotals `json:"top"`
+}
+
+/*
+Returns receipt count, spend, average basket and points of receipts purchased between
+the from and to query parameters, grouped by the groupBy query parameter. Reads
+aggregates kept up to date as receipts are stored, so it doesn't scan every receipt.
+*/
+func (h *Handlers) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	dimension, err := analytics.ParseDimension(queryOrDefault(r, "groupBy", string(analytics.GroupByRetailer)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := queryDate(r, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := queryDate(r, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.analytics.Query(dimension, from, to))
+}
+
+/*
+Returns the products bought most often, or spent most on with by=spend, across every
+stored receipt. Items are grouped by the canonical product their description normalizes to.
+*/
+func (h *Handlers) GetTopProducts(w http.ResponseWriter, r *http.Request) {
+	ranking, err := analytics.ParseRanking(queryOrDefault(r, "by", string(analytics.RankByCount)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(queryOrDefault(r, "limit", strconv.Itoa(DefaultTopProductsLimit)))
+	if err != nil || limit < 1 || limit > MaxTopProductsLimit {
+		http.Error(w, fmt.Sprintf("limit must be a number from 1 to %d", MaxTopProductsLimit), http.StatusBadRequest)
+		return
+	}
+
+	top, products := h.products.Top(ranking, limit)
+	writeJSON(w, http.StatusOK, topProductsResponse{By: ranking, Products: products, Top: top})
+}
+
+// Returns the query parameter parsed as a date, or nil when it is missing
+func queryDate(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(models.DateLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be formatted as %s", name, models.DateLayout)
+	}
+	return &date, nil
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/analytics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAnalytics(t *testing.T) {
+	router := CreateRouter()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	// Two morning receipts from Sunday, January 2 2022, earning 15 points each
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, serve("POST", "/receipts/process", morningReceipt).Code)
+	}
+
+	tests := []struct {
+		target         string
+		expectedStatus int
+		expectedGroups []analytics.Group
+	}{
+		{
+			target:         "/admin/analytics",
+			expectedStatus: http.StatusOK,
+			expectedGroups: []analytics.Group{{Key: "Walgreens", Receipts: 2, Spend: 5.3, AverageSpend: 2.65, AverageItems: 2, Points: 30}},
+		},
+		{
+			target:         "/admin/analytics?groupBy=hour&from=2022-01-02&to=2022-01-02",
+			expectedStatus: http.StatusOK,
+			expectedGroups: []analytics.Group{{Key: "08", Receipts: 2, Spend: 5.3, AverageSpend: 2.65, AverageItems: 2, Points: 30}},
+		},
+		{target: "/admin/analytics?groupBy=weekday&from=2022-01-03", expectedStatus: http.StatusOK, expectedGroups: []analytics.Group{}},
+		{target: "/admin/analytics?groupBy=year", expectedStatus: http.StatusBadRequest},
+		{target: "/admin/analytics?from=January", expectedStatus: http.StatusBadRequest},
+		{target: "/admin/analytics?from=2022-01-03&to=2022-01-02", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			responseRecorder := serve("GET", test.target, "")
+			require.Equal(t, test.expectedStatus, responseRecorder.Code, responseRecorder.Body.String())
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			var report analytics.Report
+			require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &report))
+			assert.Equal(t, test.expectedGroups, report.Groups)
+		})
+	}
+}
+
+func TestGetTopProducts(t *testing.T) {
+	router := CreateRouter()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, serve("POST", "/receipts/process", morningReceipt).Code)
+	}
+
+	tests := []struct {
+		target         string
+		expectedStatus int
+		expectedIds    []string
+		expectedSpends []float64
+	}{
+		{target: "/admin/products/top", expectedStatus: http.StatusOK, expectedIds: []string{"dasani water", "pepsi 12 oz"}, expectedSpends: []float64{2.8, 2.5}},
+		{target: "/admin/products/top?by=spend&limit=1", expectedStatus: http.StatusOK, expectedIds: []string{"dasani water"}, expectedSpends: []float64{2.8}},
+		{target: "/admin/products/top?by=price", expectedStatus: http.StatusBadRequest},
+		{target: "/admin/products/top?limit=101", expectedStatus: http.StatusBadRequest},
+		{target: "/admin/products/top?limit=ten", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			responseRecorder := serve("GET", test.target, "")
+			require.Equal(t, test.expectedStatus, responseRecorder.Code, responseRecorder.Body.String())
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			var response topProductsResponse
+			require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &response))
+			assert.Equal(t, 2, response.Products)
+			ids, spends := []string{}, []float64{}
+			for _, product := range response.Top {
+				ids = append(ids, product.Id)
+				spends = append(spends, product.Spend)
+			}
+			assert.Equal(t, test.expectedIds, ids)
+			assert.Equal(t, test.expectedSpends, spends)
+		})
+	}
+}
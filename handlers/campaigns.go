@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"receipts/promotions"
+
+	"github.com/gorilla/mux"
+)
+
+// Returns every promotion campaign in the order they were created
+func (h *Handlers) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.campaigns.ListCampaigns())
+}
+
+/*
+Creates a campaign from the json request body. Receipts processed from then on
+earn its points when they match it, receipts already stored are not rescored.
+*/
+func (h *Handlers) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := h.decodeCampaign(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusCreated, h.campaigns.CreateCampaign(campaign))
+}
+
+func (h *Handlers) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	campaign, exists := h.campaigns.GetCampaign(id)
+	if !exists {
+		http.Error(w, "campaign with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, campaign)
+}
+
+// Replaces a campaign with the json request body, keeping its id
+func (h *Handlers) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	campaign, ok := h.decodeCampaign(w, r)
+	if !ok {
+		return
+	}
+	updated, exists := h.campaigns.UpdateCampaign(id, campaign)
+	if !exists {
+		http.Error(w, "campaign with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handlers) DeleteCampaign(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !h.campaigns.DeleteCampaign(id) {
+		http.Error(w, "campaign with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+Decodes and validates a campaign from the request body, responding with an error when it can't,
+and matches its retailer to a canonical retailer as receipts' retailers are
+*/
+func (h *Handlers) decodeCampaign(w http.ResponseWriter, r *http.Request) (promotions.Campaign, bool) {
+	var campaign promotions.Campaign
+	if status, err := h.decodeJSONBody(w, r, &campaign); err != nil {
+		http.Error(w, err.Error(), status)
+		return campaign, false
+	}
+	if err := campaign.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return campaign, false
+	}
+	campaign.RetailerId = ""
+	if canonical := h.storage.Retailers().Resolve(campaign.Retailer); canonical != nil {
+		campaign.RetailerId = canonical.Id
+	}
+	return campaign, true
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/promotions"
+	"receipts/storage"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCampaigns(t *testing.T) {
+	receiptStorage := storage.NewReceiptStorage()
+	router := NewRouter(NewHandlers(receiptStorage, DefaultConfig()))
+	send := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	responseRecorder := send("POST", "/admin/campaigns", `{
+		"name": "Double points at Walgreens",
+		"retailer": "walgreens",
+		"start": "2022-01-01T00:00:00Z",
+		"end": "2022-01-03T00:00:00Z",
+		"multiplier": 2
+	}`)
+	require.Equal(t, http.StatusCreated, responseRecorder.Code)
+	var double promotions.Campaign
+	require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &double))
+	assert.NotEmpty(t, double.Id)
+
+	responseRecorder = send("POST", "/admin/campaigns", `{"name": "Dasani bonus", "itemDescription": "dasani", "bonus": 100}`)
+	require.Equal(t, http.StatusCreated, responseRecorder.Code)
+	var dasani promotions.Campaign
+	require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &dasani))
+
+	// The morning receipt earns 15 points from the rules, doubled by one campaign plus 100 from the other
+	id := processReceipt(t, router, morningReceipt)
+	responseRecorder = send("GET", "/receipts/"+id+"/points", "")
+	assert.JSONEq(t, `{"points": 130}`, responseRecorder.Body.String())
+	stored, _ := receiptStorage.GetStoredReceipt(uuid.MustParse(id))
+	breakdown := stored.Score().Breakdown
+	assert.Equal(t, "Double points at Walgreens", breakdown[len(breakdown)-2].Rule)
+	assert.Equal(t, double.Id, breakdown[len(breakdown)-2].Campaign)
+	assert.Equal(t, 15, breakdown[len(breakdown)-2].Points)
+	assert.Equal(t, dasani.Id, breakdown[len(breakdown)-1].Campaign)
+
+	responseRecorder = send("PUT", "/admin/campaigns/"+dasani.Id, `{"name": "Dasani bonus", "itemDescription": "dasani", "bonus": 10}`)
+	require.Equal(t, http.StatusOK, responseRecorder.Code)
+	responseRecorder = send("GET", "/admin/campaigns/"+dasani.Id, "")
+	assert.JSONEq(t, `{"id": "`+dasani.Id+`", "name": "Dasani bonus", "itemDescription": "dasani", "bonus": 10}`, responseRecorder.Body.String())
+
+	assert.Equal(t, http.StatusNoContent, send("DELETE", "/admin/campaigns/"+double.Id, "").Code)
+	responseRecorder = send("GET", "/admin/campaigns", "")
+	var campaigns []promotions.Campaign
+	require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &campaigns))
+	assert.Len(t, campaigns, 1)
+
+	// Stored receipts keep their points until they are rescored with the current campaigns
+	responseRecorder = send("GET", "/receipts/"+id+"/points", "")
+	assert.JSONEq(t, `{"points": 130}`, responseRecorder.Body.String())
+	send("POST", "/admin/receipts/rescore?version=v1", "")
+	responseRecorder = send("GET", "/receipts/"+id+"/points", "")
+	assert.JSONEq(t, `{"points": 25}`, responseRecorder.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, send("GET", "/admin/campaigns/"+double.Id, "").Code)
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/admin/campaigns/"+double.Id, `{"name": "Gone", "bonus": 1}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/admin/campaigns/"+double.Id, "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/admin/campaigns", `{"name": "Nothing"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/admin/campaigns", `{"name": "Bad window", "bonus": 1, "start": "soon"}`).Code)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"receipts/accounts"
+	"receipts/points"
+	"receipts/rulesfile"
+)
+
+const (
+	// Default maximum size of a request body, 1 MiB
+	DefaultMaxBodyBytes int64 = 1 << 20
+	// Default maximum size of a bulk import body, 64 MiB
+	DefaultMaxImportBytes int64 = 64 << 20
+)
+
+// Settings that control how handlers read and decode requests.
+type Config struct {
+	// Maximum number of bytes read from a request body before responding with 413
+	MaxBodyBytes int64
+	// When true, unknown fields and trailing data after the JSON value are rejected
+	StrictDecoding bool
+	// Maximum number of bytes read from a bulk import body, which is usually much larger than a single receipt
+	MaxImportBytes int64
+	// Bearer token required by /admin endpoints, when empty they require no token, so main won't start without one
+	AdminToken string
+	// Which date picks the points rule set a processed receipt is scored with, purchase date when empty
+	RuleSelection points.Selection
+	// Reloads the points rules from a rules file through /admin/rules/reload, nil when the built in rules are used
+	Rules *rulesfile.Reloader
+	// When points earned by accounts expire, never when it is the zero value
+	Expiration accounts.ExpirationPolicy
+}
+
+// Returns the config used by CreateRouter.
+func DefaultConfig() Config {
+	return Config{
+		MaxBodyBytes:   DefaultMaxBodyBytes,
+		StrictDecoding: false,
+		MaxImportBytes: DefaultMaxImportBytes,
+		RuleSelection:  points.SelectByPurchaseDate,
+	}
+}
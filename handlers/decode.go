@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"receipts/models"
+)
+
+/*
+Decodes a receipt from the request body based on its Content-Type. Returns the
+http status code that should be used when an error is returned.
+
+A missing Content-Type, or text/plain, is decoded as json since that is what
+clients of the original api send.
+*/
+func (h *Handlers) decodeReceipt(w http.ResponseWriter, r *http.Request) (*models.Receipt, int, error) {
+	mediaType := "application/json"
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("invalid Content-Type %s", contentType)
+		}
+		mediaType = parsed
+	}
+
+	switch mediaType {
+	case "application/json", "text/plain":
+		var receipt models.Receipt
+		if status, err := h.decodeJSONBody(w, r, &receipt); err != nil {
+			return nil, status, err
+		}
+		return &receipt, http.StatusOK, nil
+	case "application/xml", "text/xml":
+		var receipt models.Receipt
+		if err := xml.NewDecoder(h.limitBody(w, r)).Decode(&receipt); err != nil {
+			return nil, decodeErrorStatus(err), err
+		}
+		return &receipt, http.StatusOK, nil
+	case "text/csv":
+		receipt, err := models.ReceiptFromCSV(h.limitBody(w, r))
+		if err != nil {
+			return nil, decodeErrorStatus(err), err
+		}
+		return receipt, http.StatusOK, nil
+	case "application/x-www-form-urlencoded":
+		r.Body = h.limitBody(w, r)
+		if err := r.ParseForm(); err != nil {
+			return nil, decodeErrorStatus(err), err
+		}
+		receipt, err := models.ReceiptFromForm(r.PostForm)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return receipt, http.StatusOK, nil
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported Content-Type %s", mediaType)
+	}
+}
+
+// Wraps the request body so reading past the configured maximum fails
+func (h *Handlers) limitBody(w http.ResponseWriter, r *http.Request) io.ReadCloser {
+	if h.config.MaxBodyBytes > 0 {
+		return http.MaxBytesReader(w, r.Body, h.config.MaxBodyBytes)
+	}
+	return r.Body
+}
+
+/*
+Decodes a single JSON value from the request body into v, honoring the
+handler's Config. Returns the http status code that should be used when
+an error is returned.
+*/
+func (h *Handlers) decodeJSONBody(w http.ResponseWriter, r *http.Request, v any) (int, error) {
+	decoder := json.NewDecoder(h.limitBody(w, r))
+	if h.config.StrictDecoding {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(v); err != nil {
+		return decodeErrorStatus(err), err
+	}
+
+	if h.config.StrictDecoding {
+		// Anything other than EOF here means there is more data after the first value
+		var extra json.RawMessage
+		err := decoder.Decode(&extra)
+		if err == nil {
+			return http.StatusBadRequest, fmt.Errorf("request body must contain a single JSON value")
+		}
+		if err != io.EOF {
+			return decodeErrorStatus(err), err
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// Maps an error from reading the request body to the http status to respond with
+func decodeErrorStatus(err error) int {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
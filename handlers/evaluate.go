@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"receipts/models"
+	"receipts/ruleexpr"
+)
+
+// Request body of EvaluateRule
+type evaluateRequest struct {
+	Expression string          `json:"expression"`
+	Receipt    *models.Receipt `json:"receipt"`
+}
+
+/*
+Compiles a rule expression and returns the points it awards the sample receipt in the
+request body, so rules can be tried out before they are used. Nothing is saved.
+*/
+func (h *Handlers) EvaluateRule(w http.ResponseWriter, r *http.Request) {
+	var request evaluateRequest
+	if status, err := h.decodeJSONBody(w, r, &request); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if request.Receipt == nil {
+		http.Error(w, "receipt is required", http.StatusBadRequest)
+		return
+	}
+	if err := request.Receipt.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	program, err := ruleexpr.Compile(request.Expression)
+	if err != nil {
+		http.Error(w, "invalid expression: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	points, err := program.Evaluate(request.Receipt)
+	if err != nil {
+		http.Error(w, "evaluating expression: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Points{Points: points})
+}
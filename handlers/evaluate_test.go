@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateRule(t *testing.T) {
+	router := CreateRouter()
+
+	tests := []struct {
+		testName         string
+		expression       string
+		receipt          string
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			testName:         "Points",
+			expression:       `len(items) >= 2 && retailer matches \"^Wal\" ? 20 : 0`,
+			receipt:          morningReceipt,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: "{\"points\":20}\n",
+		},
+		{
+			testName:         "ItemAggregate",
+			expression:       `sum(items, price) * 2`,
+			receipt:          morningReceipt,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: "{\"points\":5}\n",
+		},
+		{
+			testName:         "CompileError",
+			expression:       `retailer`,
+			receipt:          morningReceipt,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: "invalid expression: expression must evaluate to a number, not string\n",
+		},
+		{
+			testName:         "RuntimeError",
+			expression:       `1 / (len(items) - 2)`,
+			receipt:          morningReceipt,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: "evaluating expression: division by zero\n",
+		},
+		{
+			testName:       "InvalidReceipt",
+			expression:     `1`,
+			receipt:        `{"retailer": "Walgreens"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			body := `{"expression": "` + test.expression + `", "receipt": ` + test.receipt + `}`
+			req, err := http.NewRequest("POST", "/admin/rules/evaluate", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, req)
+
+			assert.Equal(t, test.expectedStatus, responseRecorder.Code)
+			if test.expectedResponse != "" {
+				assert.Equal(t, test.expectedResponse, responseRecorder.Body.String())
+			}
+		})
+	}
+
+	t.Run("MissingReceipt", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/admin/rules/evaluate", bytes.NewBufferString(`{"expression": "1"}`))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		assert.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+	})
+}
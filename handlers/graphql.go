@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Body of a GraphQL request, as sent by common GraphQL clients
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+/*
+Executes a GraphQL query or mutation against the receipts schema.
+
+Accepts POST with a json body, or GET with query, operationName and variables
+url parameters. Query errors are returned in the errors field of a 200 response,
+as is conventional for GraphQL, while malformed requests get a 400.
+*/
+func (h *Handlers) GraphQL(w http.ResponseWriter, r *http.Request) {
+	var request graphQLRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				http.Error(w, "variables must be a json object", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if status, err := h.decodeJSONBody(w, r, &request); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if request.Query == "" {
+		http.Error(w, "query is required", http.StatusBadRequest)
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         h.graphQLSchema,
+		RequestString:  request.Query,
+		OperationName:  request.OperationName,
+		VariableValues: request.Variables,
+		Context:        r.Context(),
+	})
+
+	writeJSON(w, http.StatusOK, result)
+}
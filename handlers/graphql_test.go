@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"receipts/accounts"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+Only worried about the http side of the endpoint here, the schema itself is
+tested in the gql package.
+*/
+func TestGraphQL(t *testing.T) {
+	router := CreateRouter()
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	t.Run("PostMutationThenGetQuery", func(t *testing.T) {
+		body := `{
+			"query": "mutation($receipt: ReceiptInput!) { processReceipt(receipt: $receipt) { id } }",
+			"variables": {"receipt": {
+				"retailer": "Target",
+				"purchaseDate": "2022-01-02",
+				"purchaseTime": "13:13",
+				"total": "1.25",
+				"items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]
+			}}
+		}`
+		req, err := http.NewRequest("POST", "/graphql", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		responseRecorder := serve(req)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.Equal(t, "application/json", responseRecorder.Header().Get("Content-Type"))
+
+		var mutationResponse struct {
+			Data struct {
+				ProcessReceipt struct{ Id string }
+			}
+		}
+		assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&mutationResponse))
+		id := mutationResponse.Data.ProcessReceipt.Id
+		assert.NotEmpty(t, id)
+
+		query := url.Values{}
+		query.Set("query", `query($id: ID!) { receipt(id: $id) { points } }`)
+		query.Set("variables", `{"id": "`+id+`"}`)
+		req, err = http.NewRequest("GET", "/graphql?"+query.Encode(), nil)
+		assert.NoError(t, err)
+		responseRecorder = serve(req)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.JSONEq(t, `{"data": {"receipt": {"points": 31}}}`, responseRecorder.Body.String())
+	})
+
+	t.Run("MutationForAccount", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/accounts", bytes.NewBufferString(`{"name": "Jane Doe"}`))
+		require.NoError(t, err)
+		var account accounts.Account
+		require.NoError(t, json.NewDecoder(serve(req).Body).Decode(&account))
+
+		body := `{
+			"query": "mutation($receipt: ReceiptInput!, $accountId: String) { processReceipt(receipt: $receipt, accountId: $accountId) { id } }",
+			"variables": {"accountId": "` + account.Id + `", "receipt": ` + morningReceipt + `}
+		}`
+		req, err = http.NewRequest("POST", "/graphql", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, serve(req).Code)
+
+		req, err = http.NewRequest("GET", "/accounts/"+account.Id+"/balance", nil)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"accountId": "`+account.Id+`", "balance": 15}`, serve(req).Body.String())
+	})
+
+	t.Run("QueryError", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/graphql", bytes.NewBufferString(`{"query": "{ unknownField }"}`))
+		assert.NoError(t, err)
+		responseRecorder := serve(req)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		var response struct{ Errors []any }
+		assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+		assert.NotEmpty(t, response.Errors)
+	})
+
+	t.Run("MissingQuery", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/graphql", bytes.NewBufferString(`{}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, serve(req).Code)
+	})
+
+	t.Run("InvalidJson", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/graphql", bytes.NewBufferString(`{"query": `))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, serve(req).Code)
+	})
+
+	t.Run("InvalidVariables", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/graphql?query=%7Breceipts%7BtotalCount%7D%7D&variables=nope", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, serve(req).Code)
+	})
+}
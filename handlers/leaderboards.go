@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"receipts/leaderboards"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Default and largest number of accounts GetLeaderboard returns
+const (
+	DefaultLeaderboardLimit int = 10
+	MaxLeaderboardLimit     int = 100
+)
+
+// The period and retailer a leaderboard ranks points over
+type leaderboardWindow struct {
+	Period   leaderboards.Period `json:"period"`
+	Start    time.Time           `json:"start"`
+	End      time.Time           `json:"end"`
+	Retailer string              `json:"retailer,omitempty"`
+	// Id of the canonical retailer the retailer query parameter matched, or the parameter as given when it matched none
+	retailerId string
+	// Number of accounts with points on the leaderboard
+	Accounts int `json:"accounts"`
+}
+
+// An account's standing along with its name
+type leaderboardStanding struct {
+	leaderboards.Standing
+	Name string `json:"name"`
+}
+
+// Response of GetLeaderboard
+type leaderboardResponse struct {
+	leaderboardWindow
+	Standings []leaderboardStanding `json:"standings"`
+}
+
+// Response of GetLeaderboardRank
+type leaderboardRankResponse struct {
+	leaderboardWindow
+	AccountId string `json:"accountId"`
+	Points    int    `json:"points"`
+	// Nil when the account earned no points in the period
+	Rank *int `json:"rank,omitempty"`
+}
+
+/*
+Returns the accounts that earned the most points in the period containing the date
+query parameter, today when missing, at the retailer query parameter or at every
+retailer when missing. The limit query parameter caps how many accounts are listed.
+*/
+func (h *Handlers) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	window, at, err := h.queryLeaderboard(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(queryOrDefault(r, "limit", strconv.Itoa(DefaultLeaderboardLimit)))
+	if err != nil || limit < 1 || limit > MaxLeaderboardLimit {
+		http.Error(w, fmt.Sprintf("limit must be a number from 1 to %d", MaxLeaderboardLimit), http.StatusBadRequest)
+		return
+	}
+
+	top, ranked := h.leaderboards.Top(window.Period, at, window.retailerId, limit)
+	window.Accounts = ranked
+	response := leaderboardResponse{leaderboardWindow: window, Standings: make([]leaderboardStanding, 0, len(top))}
+	for _, standing := range top {
+		account, _ := h.accounts.GetAccount(standing.AccountId)
+		response.Standings = append(response.Standings, leaderboardStanding{Standing: standing, Name: account.Name})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Returns the account's rank and points on the leaderboard GetLeaderboard would return for the same query
+func (h *Handlers) GetLeaderboardRank(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	window, at, err := h.queryLeaderboard(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, exists := h.accounts.GetAccount(id); !exists {
+		http.Error(w, "account with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+
+	standing, ranked, exists := h.leaderboards.Rank(id, window.Period, at, window.retailerId)
+	window.Accounts = ranked
+	response := leaderboardRankResponse{leaderboardWindow: window, AccountId: id, Points: standing.Points}
+	if exists {
+		response.Rank = &standing.Rank
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+/*
+Reads the leaderboard's period from the path and its date and retailer from the query.
+The retailer is matched to a canonical retailer, as receipts' retailers are when processed.
+*/
+func (h *Handlers) queryLeaderboard(r *http.Request) (leaderboardWindow, time.Time, error) {
+	period, err := leaderboards.ParsePeriod(mux.Vars(r)["period"])
+	if err != nil {
+		return leaderboardWindow{}, time.Time{}, err
+	}
+	at := time.Now().UTC()
+	date, err := queryDate(r, "date")
+	if err != nil {
+		return leaderboardWindow{}, time.Time{}, err
+	}
+	if date != nil {
+		at = *date
+	}
+	start := period.Start(at)
+	window := leaderboardWindow{Period: period, Start: start, End: period.End(start), Retailer: r.URL.Query().Get("retailer")}
+	window.retailerId = window.Retailer
+	if match, exists := h.storage.Retailers().Match(window.Retailer); exists {
+		window.Retailer, window.retailerId = match.Retailer.Name, match.Retailer.Id
+	}
+	return window, at, nil
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/accounts"
+	"receipts/leaderboards"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderboards(t *testing.T) {
+	router := CreateRouter()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+	decode := func(responseRecorder *httptest.ResponseRecorder, v any) {
+		require.Less(t, responseRecorder.Code, 300, responseRecorder.Body.String())
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), v))
+	}
+	process := func(accountId string) string {
+		var id struct{ Id string }
+		decode(serve("POST", "/receipts/process?accountId="+accountId, morningReceipt), &id)
+		return id.Id
+	}
+
+	// Jane earns 30 points from two morning receipts and John 15 from one
+	var jane, john accounts.Account
+	decode(serve("POST", "/accounts", `{"name": "Jane"}`), &jane)
+	decode(serve("POST", "/accounts", `{"name": "John"}`), &john)
+	process(jane.Id)
+	janeReceipt := process(jane.Id)
+	process(john.Id)
+
+	var leaderboard leaderboardResponse
+	decode(serve("GET", "/leaderboards/week", ""), &leaderboard)
+	assert.Equal(t, 2, leaderboard.Accounts)
+	require.Len(t, leaderboard.Standings, 2)
+	assert.Equal(t, leaderboardStanding{Standing: leaderboards.Standing{Rank: 1, AccountId: jane.Id, Points: 30}, Name: "Jane"}, leaderboard.Standings[0])
+	assert.Equal(t, leaderboardStanding{Standing: leaderboards.Standing{Rank: 2, AccountId: john.Id, Points: 15}, Name: "John"}, leaderboard.Standings[1])
+
+	var limited leaderboardResponse
+	decode(serve("GET", "/leaderboards/day?retailer=walgreens%20inc&limit=1", ""), &limited)
+	assert.Equal(t, "Walgreens", limited.Retailer, "the retailer is matched to its canonical retailer")
+	assert.Equal(t, 2, limited.Accounts)
+	assert.Len(t, limited.Standings, 1)
+
+	var elsewhere leaderboardResponse
+	decode(serve("GET", "/leaderboards/month?retailer=Target", ""), &elsewhere)
+	assert.Empty(t, elsewhere.Standings)
+	var past leaderboardResponse
+	decode(serve("GET", "/leaderboards/day?date=2020-01-01", ""), &past)
+	assert.Empty(t, past.Standings)
+
+	// A campaign doubling Walgreens points, applied by rescoring, doubles both accounts' points
+	require.Equal(t, http.StatusCreated, serve("POST", "/admin/campaigns", `{"name": "Double", "retailer": "Walgreens", "multiplier": 2}`).Code)
+	require.Equal(t, http.StatusOK, serve("POST", "/admin/receipts/rescore?version=v1", "").Code)
+	var rank leaderboardRankResponse
+	decode(serve("GET", "/leaderboards/month/accounts/"+jane.Id, ""), &rank)
+	assert.Equal(t, 60, rank.Points)
+	require.NotNil(t, rank.Rank)
+	assert.Equal(t, 1, *rank.Rank)
+
+	// Deleting one of Jane's receipts ties her with John and takes its points back
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/admin/receipts/"+janeReceipt, "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", "/admin/receipts/"+janeReceipt, "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/receipts/"+janeReceipt+"/points", "").Code)
+	var tied leaderboardRankResponse
+	decode(serve("GET", "/leaderboards/month/accounts/"+john.Id, ""), &tied)
+	assert.Equal(t, 30, tied.Points)
+	assert.Equal(t, 1, *tied.Rank)
+	var balance balanceResponse
+	decode(serve("GET", "/accounts/"+jane.Id+"/balance", ""), &balance)
+	assert.Equal(t, 30, balance.Balance)
+
+	var unranked leaderboardRankResponse
+	decode(serve("GET", "/leaderboards/day/accounts/"+jane.Id+"?date=2020-01-01", ""), &unranked)
+	assert.Nil(t, unranked.Rank)
+	assert.Zero(t, unranked.Points)
+
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/leaderboards/year", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/leaderboards/day?limit=0", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/leaderboards/day?date=yesterday", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/leaderboards/day/accounts/unknown", "").Code)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types responses can be encoded in, the first one is used when the client has no preference
+var responseMediaTypes = []string{"application/json", "application/xml"}
+
+/*
+Picks the response media type from the request's Accept header. Returns false
+if the client only accepts types that none of responseMediaTypes match.
+*/
+func negotiateMediaType(r *http.Request) (string, bool) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return responseMediaTypes[0], true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if rawQuality, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(rawQuality, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	for _, accepted := range ranges {
+		switch accepted.mediaType {
+		case "*/*", "application/*":
+			return responseMediaTypes[0], true
+		case "text/xml":
+			return "application/xml", true
+		}
+		for _, mediaType := range responseMediaTypes {
+			if accepted.mediaType == mediaType {
+				return mediaType, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Encodes v as json or xml depending on the request's Accept header
+func writeNegotiated(w http.ResponseWriter, r *http.Request, status int, v any) {
+	mediaType, ok := negotiateMediaType(r)
+	if !ok {
+		http.Error(w, "response can only be application/json or application/xml", http.StatusNotAcceptable)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	if mediaType == "application/xml" {
+		xml.NewEncoder(w).Encode(v)
+		return
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+// Encodes v as json regardless of Accept, for responses that have no xml form
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
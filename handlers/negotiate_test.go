@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"receipts/models"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessReceiptContentTypes(t *testing.T) {
+	router := CreateRouter()
+	form := url.Values{
+		"retailer":         {"Walgreens"},
+		"purchaseDate":     {"2022-01-02"},
+		"purchaseTime":     {"08:13"},
+		"total":            {"2.65"},
+		"shortDescription": {"Pepsi - 12-oz", "Dasani"},
+		"price":            {"1.25", "1.40"},
+	}
+
+	tests := []struct {
+		testName       string
+		contentType    string
+		inputBody      string
+		expectedStatus int
+	}{
+		{
+			testName:       "NoContentType",
+			contentType:    "",
+			inputBody:      `{"retailer": "Walgreens", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "2.65", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}, {"shortDescription": "Dasani", "price": "1.40"}]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "TextPlainIsJson",
+			contentType:    "text/plain",
+			inputBody:      `{"retailer": "Walgreens", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "2.65", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}, {"shortDescription": "Dasani", "price": "1.40"}]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "Json",
+			contentType:    "application/json; charset=utf-8",
+			inputBody:      `{"retailer": "Walgreens", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "2.65", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}, {"shortDescription": "Dasani", "price": "1.40"}]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "Xml",
+			contentType:    "application/xml",
+			inputBody:      `<receipt><retailer>Walgreens</retailer><purchaseDate>2022-01-02</purchaseDate><purchaseTime>08:13</purchaseTime><total>2.65</total><items><item><shortDescription>Pepsi - 12-oz</shortDescription><price>1.25</price></item><item><shortDescription>Dasani</shortDescription><price>1.40</price></item></items></receipt>`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "InvalidXml",
+			contentType:    "text/xml",
+			inputBody:      `<receipt><retailer>Walgreens</retailer>`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testName:       "Csv",
+			contentType:    "text/csv",
+			inputBody:      "retailer,purchaseDate,purchaseTime,total,shortDescription,price\nWalgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25\nWalgreens,2022-01-02,08:13,2.65,Dasani,1.40\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "CsvFailsValidation",
+			contentType:    "text/csv",
+			inputBody:      "retailer,purchaseDate,purchaseTime,total,shortDescription,price\nWalgreens!,2022-01-02,08:13,2.65,Dasani,1.40\n",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testName:       "Form",
+			contentType:    "application/x-www-form-urlencoded",
+			inputBody:      form.Encode(),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "UnsupportedContentType",
+			contentType:    "application/pdf",
+			inputBody:      "%PDF",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/receipts/process", bytes.NewBufferString(test.inputBody))
+			assert.NoError(t, err)
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, req)
+			assert.Equal(t, test.expectedStatus, responseRecorder.Code)
+
+			// Every format of this receipt is worth the same points
+			if test.expectedStatus == http.StatusOK {
+				var response models.Id
+				assert.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+				responseRecorder = httptest.NewRecorder()
+				req, err = http.NewRequest("GET", "/receipts/"+response.Id+"/points", nil)
+				assert.NoError(t, err)
+				router.ServeHTTP(responseRecorder, req)
+				assert.JSONEq(t, `{"points": 15}`, responseRecorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetPointsAccept(t *testing.T) {
+	router := CreateRouter()
+	req, err := http.NewRequest("POST", "/receipts/process", bytes.NewBufferString(`{"retailer": "Walgreens", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "2.65", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}, {"shortDescription": "Dasani", "price": "1.40"}]}`))
+	assert.NoError(t, err)
+	req.Header.Set("Accept", "application/xml")
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, req)
+	assert.Equal(t, http.StatusOK, responseRecorder.Code)
+	assert.Equal(t, "application/xml", responseRecorder.Header().Get("Content-Type"))
+	var id models.Id
+	assert.NoError(t, xml.NewDecoder(responseRecorder.Body).Decode(&id))
+	_, err = uuid.Parse(id.Id)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		testName            string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			testName:            "NoAccept",
+			accept:              "",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"points":15}`,
+		},
+		{
+			testName:            "Wildcard",
+			accept:              "*/*",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"points":15}`,
+		},
+		{
+			testName:            "Xml",
+			accept:              "application/xml",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedBody:        `<points>15</points>`,
+		},
+		{
+			testName:            "TextXml",
+			accept:              "text/xml",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedBody:        `<points>15</points>`,
+		},
+		{
+			testName:            "QualityPrefersXml",
+			accept:              "application/json;q=0.5, application/xml",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedBody:        `<points>15</points>`,
+		},
+		{
+			testName:            "QualityPrefersJson",
+			accept:              "application/xml;q=0.1, application/json;q=0.9",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"points":15}`,
+		},
+		{
+			testName:       "NotAcceptable",
+			accept:         "text/html",
+			expectedStatus: http.StatusNotAcceptable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/receipts/"+id.Id+"/points", nil)
+			assert.NoError(t, err)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, req)
+
+			assert.Equal(t, test.expectedStatus, responseRecorder.Code)
+			if test.expectedStatus == http.StatusOK {
+				assert.Equal(t, test.expectedContentType, responseRecorder.Header().Get("Content-Type"))
+				assert.Equal(t, test.expectedBody, strings.TrimSpace(responseRecorder.Body.String()))
+			}
+		})
+	}
+}
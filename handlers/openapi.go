@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPI 3 document describing every route registered in CreateRouter
+//
+//go:embed static/openapi.json
+var OpenAPISpec []byte
+
+// Page that renders the OpenAPI document in a browser
+//
+//go:embed static/docs.html
+var docsPage []byte
+
+// Returns the OpenAPI document for this server.
+func (h *Handlers) GetOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(OpenAPISpec)
+}
+
+// Returns the html page that renders the OpenAPI document.
+func (h *Handlers) GetDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(docsPage)
+}
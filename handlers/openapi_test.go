@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"receipts/models"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// kin-openapi only decodes a few content types out of the box, html is validated as a plain string
+	openapi3filter.RegisterBodyDecoder("text/html", func(body io.Reader, header http.Header, schema *openapi3.SchemaRef, encFn openapi3filter.EncodingFn) (any, error) {
+		data, err := io.ReadAll(body)
+		return string(data), err
+	})
+}
+
+func loadOpenAPISpec(t *testing.T) *openapi3.T {
+	doc, err := openapi3.NewLoader().LoadFromData(OpenAPISpec)
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+	return doc
+}
+
+// Every route registered in CreateRouter must be described in the spec
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+	router := CreateRouter()
+
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		pathItem := doc.Paths.Find(path)
+		if !assert.NotNil(t, pathItem, "path %s missing from openapi spec", path) {
+			return nil
+		}
+		for _, method := range methods {
+			assert.NotNil(t, pathItem.GetOperation(method), "%s %s missing from openapi spec", method, path)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+/*
+Sends requests through the router and validates both the request and the
+handler's response against the spec.
+*/
+func TestOpenAPISpecResponses(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+	specRouter, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+	router := CreateRouter()
+
+	validReceipt := `{
+					"retailer": "Target",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "13:13",
+					"total": "1.25",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}
+					]
+				}`
+
+	contentType := func(path string) string {
+		if path == "/receipts/parse-text" {
+			return "text/plain"
+		}
+		return "application/json"
+	}
+
+	serve := func(method string, path string, body string) *http.Response {
+		var bodyReader io.Reader
+		if body != "" {
+			bodyReader = bytes.NewBufferString(body)
+		}
+		req := httptest.NewRequest(method, path, bodyReader)
+		if body != "" {
+			req.Header.Set("Content-Type", contentType(path))
+		}
+
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		response := responseRecorder.Result()
+		responseBody, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		// The request was consumed by the handler, so build a fresh one to validate
+		validateReq := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if body != "" {
+			validateReq.Header.Set("Content-Type", contentType(path))
+		}
+		route, pathParams, err := specRouter.FindRoute(validateReq)
+		require.NoError(t, err)
+
+		requestInput := &openapi3filter.RequestValidationInput{
+			Request:    validateReq,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    &openapi3filter.Options{MultiError: true},
+		}
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: requestInput,
+			Status:                 response.StatusCode,
+			Header:                 response.Header,
+			Body:                   io.NopCloser(bytes.NewReader(responseBody)),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		}
+		assert.NoError(t, openapi3filter.ValidateResponse(context.Background(), responseInput), "%s %s", method, path)
+
+		response.Body = io.NopCloser(bytes.NewReader(responseBody))
+		return response
+	}
+
+	var id models.Id
+	t.Run("ProcessReceiptOk", func(t *testing.T) {
+		response := serve("POST", "/receipts/process", validReceipt)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&id))
+	})
+
+	t.Run("ProcessReceiptBadRequest", func(t *testing.T) {
+		response := serve("POST", "/receipts/process", `{"retailer": "Target"}`)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("ParseTextReceiptOk", func(t *testing.T) {
+		response := serve("POST", "/receipts/parse-text", "Target\n2022-01-02 13:13\nPepsi 1.25\nTotal 1.25")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetPointsOk", func(t *testing.T) {
+		response := serve("GET", "/receipts/"+id.Id+"/points", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetPointsNotFound", func(t *testing.T) {
+		response := serve("GET", "/receipts/"+uuid.New().String()+"/points", "")
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("GraphQLOk", func(t *testing.T) {
+		response := serve("POST", "/graphql", `{"query": "{ receipts { totalCount } }"}`)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GraphQLBadRequest", func(t *testing.T) {
+		response := serve("POST", "/graphql", `{"variables": {}}`)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("RescoreReceiptsOk", func(t *testing.T) {
+		response := serve("POST", "/admin/receipts/rescore?version=v1&dryRun=true", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("RescoreReceiptsBadRequest", func(t *testing.T) {
+		response := serve("POST", "/admin/receipts/rescore?version=unknown", "")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("GetRulesOk", func(t *testing.T) {
+		response := serve("GET", "/admin/rules", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("ReloadRulesConflict", func(t *testing.T) {
+		response := serve("POST", "/admin/rules/reload", "")
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+	})
+
+	t.Run("SimulateRulesOk", func(t *testing.T) {
+		response := serve("POST", "/admin/rules/simulate", `{"candidate": {"rules": [{"rule": "RetailerRule", "multiplier": 2}]}}`)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("EvaluateRuleOk", func(t *testing.T) {
+		response := serve("POST", "/admin/rules/evaluate", `{"expression": "len(items) * 5", "receipt": `+morningReceipt+`}`)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("EvaluateRuleBadRequest", func(t *testing.T) {
+		response := serve("POST", "/admin/rules/evaluate", `{"expression": "len(items) *", "receipt": `+morningReceipt+`}`)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("CreateCampaignOk", func(t *testing.T) {
+		response := serve("POST", "/admin/campaigns", `{"name": "Gatorade bonus", "itemDescription": "Gatorade", "bonus": 100}`)
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+	})
+
+	t.Run("ListCampaignsOk", func(t *testing.T) {
+		response := serve("GET", "/admin/campaigns", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetCampaignNotFound", func(t *testing.T) {
+		response := serve("GET", "/admin/campaigns/"+uuid.New().String(), "")
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	var account struct {
+		Id string `json:"id"`
+	}
+	t.Run("CreateAccountOk", func(t *testing.T) {
+		response := serve("POST", "/accounts", `{"name": "Jane Doe"}`)
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&account))
+	})
+
+	t.Run("ProcessReceiptForAccountOk", func(t *testing.T) {
+		response := serve("POST", "/receipts/process?accountId="+account.Id, validReceipt)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetAccountOk", func(t *testing.T) {
+		response := serve("GET", "/accounts/"+account.Id, "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetBalanceOk", func(t *testing.T) {
+		response := serve("GET", "/accounts/"+account.Id+"/balance", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("AdjustBalanceOk", func(t *testing.T) {
+		response := serve("POST", "/admin/accounts/"+account.Id+"/adjustments", `{"points": -5, "reason": "Returned items"}`)
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+	})
+
+	t.Run("GetLedgerOk", func(t *testing.T) {
+		response := serve("GET", "/accounts/"+account.Id+"/ledger", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	var reward struct {
+		Id string `json:"id"`
+	}
+	t.Run("CreateRewardOk", func(t *testing.T) {
+		response := serve("POST", "/admin/rewards", `{"name": "Sticker", "cost": 1, "inventory": 5}`)
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&reward))
+	})
+
+	t.Run("ListRewardsOk", func(t *testing.T) {
+		response := serve("GET", "/rewards", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	var redemption struct {
+		Id string `json:"id"`
+	}
+	t.Run("RedeemRewardOk", func(t *testing.T) {
+		response := serve("POST", "/accounts/"+account.Id+"/redemptions", `{"rewardId": "`+reward.Id+`"}`)
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&redemption))
+	})
+
+	t.Run("RedeemRewardConflict", func(t *testing.T) {
+		response := serve("PUT", "/admin/rewards/"+reward.Id, `{"name": "Sticker", "cost": 1000}`)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		response = serve("POST", "/accounts/"+account.Id+"/redemptions", `{"rewardId": "`+reward.Id+`"}`)
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+	})
+
+	t.Run("CancelRedemptionOk", func(t *testing.T) {
+		response := serve("POST", "/accounts/"+account.Id+"/redemptions/"+redemption.Id+"/cancel", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("ListRedemptionsOk", func(t *testing.T) {
+		response := serve("GET", "/accounts/"+account.Id+"/redemptions", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetRewardNotFound", func(t *testing.T) {
+		response := serve("GET", "/rewards/"+uuid.New().String(), "")
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("GetExpiringPointsOk", func(t *testing.T) {
+		response := serve("GET", "/accounts/"+account.Id+"/expiring?days=7", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("ListExpiringPointsBadRequest", func(t *testing.T) {
+		response := serve("GET", "/admin/accounts/expiring?days=0", "")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("ExpirePointsOk", func(t *testing.T) {
+		response := serve("POST", "/admin/accounts/expire", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetTiersOk", func(t *testing.T) {
+		response := serve("GET", "/tiers", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("SetTiersOk", func(t *testing.T) {
+		response := serve("PUT", "/admin/tiers", `{"basis": "points", "tiers": [{"name": "Member", "threshold": 0, "multiplier": 1}, {"name": "Plus", "threshold": 1, "multiplier": 1.5}]}`)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("SetTiersBadRequest", func(t *testing.T) {
+		response := serve("PUT", "/admin/tiers", `{"basis": "visits", "tiers": []}`)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("RecalculateTiersOk", func(t *testing.T) {
+		response := serve("POST", "/admin/tiers/recalculate", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetAccountTierOk", func(t *testing.T) {
+		response := serve("GET", "/accounts/"+account.Id+"/tier", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetAccountTierNotFound", func(t *testing.T) {
+		response := serve("GET", "/accounts/"+uuid.New().String()+"/tier", "")
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("GetLeaderboardOk", func(t *testing.T) {
+		response := serve("GET", "/leaderboards/week?retailer=Target&limit=5", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetLeaderboardBadRequest", func(t *testing.T) {
+		response := serve("GET", "/leaderboards/year", "")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("GetLeaderboardRankOk", func(t *testing.T) {
+		response := serve("GET", "/leaderboards/month/accounts/"+account.Id, "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetLeaderboardRankNotFound", func(t *testing.T) {
+		response := serve("GET", "/leaderboards/day/accounts/"+uuid.New().String(), "")
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("DeleteReceiptNotFound", func(t *testing.T) {
+		response := serve("DELETE", "/admin/receipts/"+uuid.New().String(), "")
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("GetAnalyticsOk", func(t *testing.T) {
+		response := serve("GET", "/admin/analytics?groupBy=weekday&from=2022-01-01&to=2022-12-31", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetAnalyticsBadRequest", func(t *testing.T) {
+		response := serve("GET", "/admin/analytics?groupBy=year", "")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("GetTopProductsOk", func(t *testing.T) {
+		response := serve("GET", "/admin/products/top?by=spend&limit=5", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetTopProductsBadRequest", func(t *testing.T) {
+		response := serve("GET", "/admin/products/top?limit=0", "")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("ListRetailersOk", func(t *testing.T) {
+		response := serve("GET", "/retailers", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("MatchRetailerNotFound", func(t *testing.T) {
+		response := serve("GET", "/retailers/match?name=Nowhere", "")
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("RegisterRetailerOk", func(t *testing.T) {
+		response := serve("PUT", "/admin/retailers/corner-market", `{"name": "M&M Corner Market", "aliases": ["M and M Mkt"]}`)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("MatchRetailerOk", func(t *testing.T) {
+		response := serve("GET", "/retailers/match?name=M%20%26%20M%20Corner%20Mkt", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("RegisterRetailerConflict", func(t *testing.T) {
+		response := serve("PUT", "/admin/retailers/other", `{"name": "m and m mkt"}`)
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+	})
+
+	t.Run("GetLedgerNotFound", func(t *testing.T) {
+		response := serve("GET", "/accounts/"+uuid.New().String()+"/ledger", "")
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("GetOpenAPISpec", func(t *testing.T) {
+		response := serve("GET", "/openapi.json", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("GetDocs", func(t *testing.T) {
+		response := serve("GET", "/docs", "")
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	})
+}
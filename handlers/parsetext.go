@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"receipts/textparser"
+)
+
+// Response of ParseTextReceipt, the parse result along with the id the receipt was stored under
+type parsedTextReceipt struct {
+	Id string `json:"id"`
+	*textparser.Result
+}
+
+/*
+Takes a raw text receipt, as printed by a POS system, from the request body. The
+parsed receipt goes through the same validation as ProcessReceipt before being
+stored, and the response includes it along with the confidence in each field.
+As with ProcessReceipt, the accountId query parameter adds the points to an account.
+*/
+func (h *Handlers) ParseTextReceipt(w http.ResponseWriter, r *http.Request) {
+	accountId, err := h.queryAccount(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	text, err := io.ReadAll(h.limitBody(w, r))
+	if err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
+		return
+	}
+
+	result, err := h.textParser.Parse(string(text))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := result.Receipt.Validate(); err != nil {
+		http.Error(w, "parsed receipt is invalid: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.ingester.Ingest(result.Receipt, accountId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parsedTextReceipt{Id: id.String(), Result: result})
+}
+
+// Adds a per-retailer template used by ParseTextReceipt.
+func (h *Handlers) RegisterTextTemplate(template textparser.Template) {
+	h.textParser.Register(template)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/models"
+	"receipts/storage"
+	"receipts/textparser"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+Only worried about the http side of the endpoint here, parsing is tested
+in the textparser package.
+*/
+func TestParseTextReceipt(t *testing.T) {
+	h := NewHandlers(storage.NewReceiptStorage(), DefaultConfig())
+	router := NewRouter(h)
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/receipts/parse-text", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "text/plain")
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	t.Run("ValidText", func(t *testing.T) {
+		responseRecorder := serve(strings.Join([]string{
+			"M&M Corner Market",
+			"03/20/2022 2:33 PM",
+			"Gatorade 2.25",
+			"Gatorade 2.25",
+			"Gatorade 2.25",
+			"Gatorade 2.25",
+			"TOTAL 9.00",
+		}, "\n"))
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+
+		var response struct {
+			Id         string
+			Receipt    models.Receipt
+			Confidence map[string]float64
+			Template   string
+		}
+		require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+		assert.Equal(t, "M&M Corner Market", response.Receipt.Retailer)
+		assert.Equal(t, textparser.GenericTemplateName, response.Template)
+		assert.Equal(t, 1.0, response.Confidence[textparser.FieldItems])
+
+		// Parsed receipt is stored like any other
+		req, err := http.NewRequest("GET", "/receipts/"+response.Id+"/points", nil)
+		require.NoError(t, err)
+		responseRecorder = httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		assert.JSONEq(t, `{"points": 109}`, responseRecorder.Body.String())
+	})
+
+	t.Run("FailsValidation", func(t *testing.T) {
+		// No date or time can be found
+		responseRecorder := serve("Corner Store\nGatorade 2.25\nTotal 2.25")
+		assert.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+	})
+
+	t.Run("NotAReceipt", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve("hello").Code)
+	})
+
+	t.Run("RegisteredTemplate", func(t *testing.T) {
+		h.RegisterTextTemplate(textparser.Template{
+			Name:     "walgreens",
+			Match:    regexp.MustCompile(`(?i)walgreens`),
+			Retailer: "Walgreens",
+		})
+		responseRecorder := serve("*** WALGREENS #99 ***\n2022-01-02 08:13\nDasani 1.40\nTotal 1.40")
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var response struct {
+			Receipt  models.Receipt
+			Template string
+		}
+		require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&response))
+		assert.Equal(t, "walgreens", response.Template)
+		assert.Equal(t, "Walgreens", response.Receipt.Retailer)
+	})
+
+	t.Run("OverMaxBodyBytes", func(t *testing.T) {
+		router := NewRouter(NewHandlers(storage.NewReceiptStorage(), Config{MaxBodyBytes: 10}))
+		req, err := http.NewRequest("POST", "/receipts/parse-text", bytes.NewBufferString("Corner Store\nGatorade 2.25\nTotal 2.25"))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, responseRecorder.Code)
+	})
+}
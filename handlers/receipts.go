@@ -1,48 +1,138 @@
 package handlers
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"receipts/accounts"
+	"receipts/analytics"
+	"receipts/gql"
+	"receipts/ingest"
+	"receipts/leaderboards"
 	"receipts/models"
 	"receipts/points"
+	"receipts/promotions"
+	"receipts/rewards"
 	"receipts/storage"
+	"receipts/textparser"
+	"receipts/tiers"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql"
 )
 
 type Handlers struct {
-	storage *storage.ReceiptStorage
+	storage       *storage.ReceiptStorage
+	config        Config
+	campaigns     *promotions.Store
+	accounts      *accounts.Store
+	rewards       *rewards.Catalog
+	tiers         *tiers.Store
+	leaderboards  *leaderboards.Boards
+	analytics     *analytics.Aggregates
+	products      *analytics.Products
+	scorer        points.Scorer
+	ingester      *ingest.Ingester
+	graphQLSchema graphql.Schema
+	textParser    *textparser.Parser
 }
 
-func NewHandlers(storage *storage.ReceiptStorage) *Handlers {
+func NewHandlers(storage *storage.ReceiptStorage, config Config) *Handlers {
+	campaigns := promotions.NewStore()
+	accountStore := accounts.NewStore()
+	scorer := points.Scorer{Selection: config.RuleSelection, Bonuses: []points.Bonus{campaigns}}
+	tierStore, boards := tiers.NewStore(accountStore), leaderboards.NewBoards()
+	ingester := ingest.NewIngester(storage, accountStore, tierStore, boards, scorer)
+	aggregates, topProducts := analytics.NewAggregates(), analytics.NewProducts()
+	storage.Observe(aggregates)
+	storage.Observe(topProducts)
+
+	// The schema is built from static definitions, so an error here is a programming mistake
+	graphQLSchema, err := gql.NewSchema(storage, ingester)
+	if err != nil {
+		panic("invalid graphql schema: " + err.Error())
+	}
+
 	return &Handlers{
-		storage: storage,
+		storage:       storage,
+		config:        config,
+		campaigns:     campaigns,
+		accounts:      accountStore,
+		rewards:       rewards.NewCatalog(accountStore),
+		tiers:         tierStore,
+		leaderboards:  boards,
+		analytics:     aggregates,
+		products:      topProducts,
+		scorer:        scorer,
+		ingester:      ingester,
+		graphQLSchema: graphQLSchema,
+		textParser:    textparser.NewParser(),
 	}
 }
 
-// Takes receipt in json format from request body.
+/*
+Returns the ingester processed receipts are stored with, which applies the handlers'
+promotion campaigns and credits accounts. Used to process receipts the same way
+outside of http, such as in the gRPC server.
+*/
+func (h *Handlers) Ingester() *ingest.Ingester {
+	return h.ingester
+}
+
+/*
+Returns the store of loyalty accounts and their ledgers, used to expire points on
+a schedule outside of http.
+*/
+func (h *Handlers) Accounts() *accounts.Store {
+	return h.accounts
+}
+
+/*
+Returns the store of membership tiers, used to recalculate tiers on a schedule
+outside of http.
+*/
+func (h *Handlers) Tiers() *tiers.Store {
+	return h.tiers
+}
+
+/*
+Takes receipt from request body in the format given by its Content-Type, which
+can be json, xml, csv or form encoded. With the accountId query parameter, the
+receipt's points are added to that account's ledger.
+*/
 func (h *Handlers) ProcessReceipt(w http.ResponseWriter, r *http.Request) {
-	var receipt models.Receipt
-	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+	// Checked before storing so a receipt is never saved without its id being returned
+	if _, ok := negotiateMediaType(r); !ok {
+		http.Error(w, "response can only be application/json or application/xml", http.StatusNotAcceptable)
+		return
+	}
+	accountId, err := h.queryAccount(r)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	receipt, status, err := h.decodeReceipt(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
 	if err := receipt.Validate(); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id := uuid.New()
-	h.storage.SetReceipt(id, &receipt)
+	id, err := h.ingester.Ingest(receipt, accountId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.Id{Id: id.String()})
+	writeNegotiated(w, r, http.StatusOK, models.Id{Id: id.String()})
 }
 
-// Calculates points for an existing receipt and returns them in response
+// Returns the points cached for an existing receipt in response as json or xml, depending on Accept
 func (h *Handlers) GetPoints(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -52,12 +142,23 @@ func (h *Handlers) GetPoints(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	receipt := h.storage.GetReceipt(parsedId)
-	if receipt == nil {
+	stored, exists := h.storage.GetStoredReceipt(parsedId)
+	if !exists {
 		http.Error(w, "receipt with id "+id+" not found", http.StatusNotFound)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(models.Points{Points: points.CalculatePoints(receipt)})
+	writeNegotiated(w, r, http.StatusOK, models.Points{Points: stored.Score().Points})
+}
+
+// Returns the accountId query parameter, or an error when it names an account that doesn't exist
+func (h *Handlers) queryAccount(r *http.Request) (string, error) {
+	accountId := r.URL.Query().Get("accountId")
+	if accountId == "" {
+		return "", nil
+	}
+	if _, exists := h.accounts.GetAccount(accountId); !exists {
+		return "", fmt.Errorf("account with id %s not found", accountId)
+	}
+	return accountId, nil
 }
@@ -6,10 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"receipts/models"
+	"receipts/points"
+	"receipts/storage"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestProcessReceipt(t *testing.T) {
@@ -73,6 +77,37 @@ func TestProcessReceipt(t *testing.T) {
 				}`,
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			testName: "ExtendedReceipt",
+			inputReceipt: `{
+					"retailer": "Target",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "13:13",
+					"total": "2.58",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "2.50", "quantity": 2, "unitPrice": "1.25"}
+					],
+					"subtotal": "2.50",
+					"discounts": [{"code": "PEPSI", "amount": "0.10"}],
+					"taxes": [{"name": "Sales Tax", "amount": "0.18"}],
+					"paymentMethod": "mobile"
+				}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName: "ExtendedReceiptNotReconciled",
+			inputReceipt: `{
+					"retailer": "Target",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "13:13",
+					"total": "2.50",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "2.50", "quantity": 2, "unitPrice": "1.25"}
+					],
+					"taxes": [{"name": "Sales Tax", "amount": "0.18"}]
+				}`,
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, test := range tests {
@@ -197,3 +232,119 @@ func TestGetPoints(t *testing.T) {
 		assert.Equal(t, 28, responsePoints.Points)
 	})
 }
+
+func TestProcessReceiptBodyLimits(t *testing.T) {
+	validReceipt := `{
+					"retailer": "Target",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "13:13",
+					"total": "1.25",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}
+					]
+				}`
+
+	tests := []struct {
+		testName       string
+		config         Config
+		inputBody      string
+		expectedStatus int
+	}{
+		{
+			testName:       "UnderMaxBodyBytes",
+			config:         Config{MaxBodyBytes: int64(len(validReceipt))},
+			inputBody:      validReceipt,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "OverMaxBodyBytes",
+			config:         Config{MaxBodyBytes: int64(len(validReceipt) - 1)},
+			inputBody:      validReceipt,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			testName:       "NoMaxBodyBytes",
+			config:         Config{MaxBodyBytes: 0},
+			inputBody:      validReceipt,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "UnknownFieldNotStrict",
+			config:         Config{StrictDecoding: false},
+			inputBody:      strings.Replace(validReceipt, `"retailer"`, `"store": "x", "retailer"`, 1),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "UnknownFieldStrict",
+			config:         Config{StrictDecoding: true},
+			inputBody:      strings.Replace(validReceipt, `"retailer"`, `"store": "x", "retailer"`, 1),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testName:       "UnknownItemFieldStrict",
+			config:         Config{StrictDecoding: true},
+			inputBody:      strings.Replace(validReceipt, `"price": "1.25"}`, `"price": "1.25", "sku": "1"}`, 1),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testName:       "TrailingGarbageNotStrict",
+			config:         Config{StrictDecoding: false},
+			inputBody:      validReceipt + "garbage",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "TrailingGarbageStrict",
+			config:         Config{StrictDecoding: true},
+			inputBody:      validReceipt + "garbage",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testName:       "MultipleValuesStrict",
+			config:         Config{StrictDecoding: true},
+			inputBody:      validReceipt + validReceipt,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testName:       "TrailingWhitespaceStrict",
+			config:         Config{StrictDecoding: true},
+			inputBody:      validReceipt + "\n\n",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			router := CreateRouterWithConfig(test.config)
+			req, err := http.NewRequest("POST", "/receipts/process", bytes.NewBuffer([]byte(test.inputBody)))
+			assert.NoError(t, err)
+
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, req)
+
+			assert.Equal(t, test.expectedStatus, responseRecorder.Code)
+		})
+	}
+}
+
+// Testing that the rule set picked by the configured selection is recorded with the receipt
+func TestProcessReceiptRuleVersion(t *testing.T) {
+	for _, selection := range []points.Selection{points.SelectByPurchaseDate, points.SelectBySubmissionTime} {
+		t.Run(string(selection), func(t *testing.T) {
+			receiptStorage := storage.NewReceiptStorage()
+			config := DefaultConfig()
+			config.RuleSelection = selection
+			router := NewRouter(NewHandlers(receiptStorage, config))
+
+			id := processReceipt(t, router, morningReceipt)
+			stored, exists := receiptStorage.GetStoredReceipt(uuid.MustParse(id))
+			require.True(t, exists)
+			var expected points.RuleSet
+			if selection == points.SelectByPurchaseDate {
+				expected = points.EffectiveRuleSet(stored.Receipt.PurchaseDate.Date)
+			} else {
+				expected = points.EffectiveRuleSet(stored.Metadata.CreatedAt)
+			}
+			assert.Equal(t, expected.Version, stored.Metadata.RuleVersion)
+		})
+	}
+}
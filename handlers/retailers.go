@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"receipts/retailers"
+
+	"github.com/gorilla/mux"
+)
+
+// Returns every canonical retailer, registered and discovered from receipts, in order of id
+func (h *Handlers) ListRetailers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.storage.Retailers().List())
+}
+
+// Returns the canonical retailer the name query parameter matches and how it matched
+func (h *Handlers) MatchRetailer(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	match, exists := h.storage.Retailers().Match(name)
+	if !exists {
+		http.Error(w, "no retailer matches "+name, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, match)
+}
+
+/*
+Registers the retailer in the json request body under the id in the path, replacing
+the retailer with that id if there is one. Receipts processed from then on are matched
+to it, and stored receipts and leaderboard points of the discovered retailers it absorbs
+are moved to it.
+*/
+func (h *Handlers) RegisterRetailer(w http.ResponseWriter, r *http.Request) {
+	var retailer retailers.Retailer
+	if status, err := h.decodeJSONBody(w, r, &retailer); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	retailer.Id = mux.Vars(r)["id"]
+
+	registered, absorbed, err := h.storage.RegisterRetailer(retailer)
+	if errors.Is(err, retailers.ErrNameTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.leaderboards.MergeRetailers(absorbed, registered.Id)
+	writeJSON(w, http.StatusOK, registered)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/accounts"
+	"receipts/analytics"
+	"receipts/models"
+	"receipts/retailers"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetailers(t *testing.T) {
+	router := CreateRouter()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+	decode := func(responseRecorder *httptest.ResponseRecorder, v any) {
+		require.Less(t, responseRecorder.Code, 300, responseRecorder.Body.String())
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), v))
+	}
+	// Processes the morning receipt as if printed with the retailer, returning its points
+	process := func(retailer string) int {
+		var id models.Id
+		decode(serve("POST", "/receipts/process", strings.Replace(morningReceipt, "Walgreens", retailer, 1)), &id)
+		var points models.Points
+		decode(serve("GET", "/receipts/"+id.Id+"/points", ""), &points)
+		return points.Points
+	}
+
+	responseRecorder := serve("PUT", "/admin/retailers/m-and-m", `{"name": "M&M Corner Market", "aliases": ["MM Market"]}`)
+	var registered retailers.Retailer
+	decode(responseRecorder, &registered)
+	assert.Equal(t, retailers.Retailer{Id: "m-and-m", Name: "M&M Corner Market", Aliases: []string{"MM Market"}}, registered)
+	assert.Equal(t, http.StatusConflict, serve("PUT", "/admin/retailers/other", `{"name": "m & m corner market"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", "/admin/retailers/Not%20An%20Id", `{"name": "Target"}`).Code)
+
+	// A campaign at one spelling of the retailer applies to receipts printed with any other
+	require.Equal(t, http.StatusCreated, serve("POST", "/admin/campaigns", `{"name": "Corner bonus", "retailer": "M & M Corner Mkt", "bonus": 100}`).Code)
+	withBonus := process("m&m corner market")
+	assert.Greater(t, withBonus, 100)
+	// RetailerRule still counts the 14 and 16 characters of the retailer as printed
+	assert.Equal(t, withBonus+2, process("M and M Corner Markt"))
+	assert.Less(t, process("Walgreens"), 100)
+
+	var match retailers.Match
+	decode(serve("GET", "/retailers/match?name=M%26M%20Corner%20Mkt", ""), &match)
+	assert.Equal(t, "m-and-m", match.Retailer.Id)
+	assert.Equal(t, retailers.MatchExact, match.By)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/retailers/match?name=Costco", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/retailers/match", "").Code)
+
+	var listed []retailers.Retailer
+	decode(serve("GET", "/retailers", ""), &listed)
+	require.Len(t, listed, 2)
+	assert.Equal(t, "m-and-m", listed[0].Id)
+	assert.Equal(t, retailers.Retailer{Id: "walgreens", Name: "Walgreens", Discovered: true}, listed[1])
+
+	// Analytics count every spelling as one retailer
+	var report analytics.Report
+	decode(serve("GET", "/admin/analytics", ""), &report)
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, "M&M Corner Market", report.Groups[0].Key)
+	assert.Equal(t, 2, report.Groups[0].Receipts)
+
+	// Receipts of a discovered retailer count as the registered retailer absorbing it
+	var account accounts.Account
+	decode(serve("POST", "/accounts", `{"name": "Jane"}`), &account)
+	require.Equal(t, http.StatusOK, serve("POST", "/receipts/process?accountId="+account.Id, strings.Replace(morningReceipt, "Walgreens", "Walgreens Pharmacy", 1)).Code)
+	decode(serve("GET", "/admin/analytics", ""), &report)
+	require.Len(t, report.Groups, 3)
+
+	decode(serve("PUT", "/admin/retailers/walgreens", `{"name": "Walgreens", "aliases": ["Walgreens Pharmacy"]}`), &registered)
+	decode(serve("GET", "/admin/analytics", ""), &report)
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, "Walgreens", report.Groups[1].Key)
+	assert.Equal(t, 2, report.Groups[1].Receipts)
+	var leaderboard leaderboardResponse
+	decode(serve("GET", "/leaderboards/week?retailer=Walgreens", ""), &leaderboard)
+	require.Len(t, leaderboard.Standings, 1)
+	assert.Equal(t, account.Id, leaderboard.Standings[0].AccountId)
+}
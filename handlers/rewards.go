@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"receipts/accounts"
+	"receipts/rewards"
+
+	"github.com/gorilla/mux"
+)
+
+// Request body of RedeemReward
+type redemptionRequest struct {
+	RewardId string `json:"rewardId"`
+}
+
+// Response of ListRedemptions
+type redemptionsResponse struct {
+	AccountId   string               `json:"accountId"`
+	Redemptions []rewards.Redemption `json:"redemptions"`
+}
+
+// Returns every reward in the catalog in the order they were created
+func (h *Handlers) ListRewards(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.rewards.ListRewards())
+}
+
+func (h *Handlers) GetReward(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	reward, exists := h.rewards.GetReward(id)
+	if !exists {
+		http.Error(w, "reward with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, reward)
+}
+
+// Adds a reward from the json request body to the catalog
+func (h *Handlers) CreateReward(w http.ResponseWriter, r *http.Request) {
+	reward, ok := h.decodeReward(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusCreated, h.rewards.CreateReward(reward))
+}
+
+// Replaces a reward with the json request body, keeping its id
+func (h *Handlers) UpdateReward(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	reward, ok := h.decodeReward(w, r)
+	if !ok {
+		return
+	}
+	updated, exists := h.rewards.UpdateReward(id, reward)
+	if !exists {
+		http.Error(w, "reward with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Removes a reward from the catalog, its past redemptions can still be cancelled
+func (h *Handlers) DeleteReward(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !h.rewards.DeleteReward(id) {
+		http.Error(w, "reward with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+Spends the account's points on the reward in the json request body, debiting its
+ledger. Responds with 409 when the account can't afford it or it is out of stock.
+*/
+func (h *Handlers) RedeemReward(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["id"]
+	var request redemptionRequest
+	if status, err := h.decodeJSONBody(w, r, &request); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if request.RewardId == "" {
+		http.Error(w, "rewardId is required", http.StatusBadRequest)
+		return
+	}
+
+	redemption, err := h.rewards.Redeem(accountId, request.RewardId)
+	if err != nil {
+		http.Error(w, err.Error(), redemptionErrorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusCreated, redemption)
+}
+
+// Returns the account's redemptions, oldest first
+func (h *Handlers) ListRedemptions(w http.ResponseWriter, r *http.Request) {
+	accountId := mux.Vars(r)["id"]
+	if _, exists := h.accounts.GetAccount(accountId); !exists {
+		http.Error(w, "account with id "+accountId+" not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, redemptionsResponse{AccountId: accountId, Redemptions: h.rewards.Redemptions(accountId)})
+}
+
+// Cancels one of the account's redemptions, refunding its points to the account's ledger
+func (h *Handlers) CancelRedemption(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	redemption, err := h.rewards.Cancel(vars["id"], vars["redemptionId"])
+	if err != nil {
+		http.Error(w, err.Error(), redemptionErrorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, redemption)
+}
+
+// Status code for an error from redeeming or cancelling
+func redemptionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, accounts.ErrAccountNotFound), errors.Is(err, rewards.ErrRewardNotFound), errors.Is(err, rewards.ErrRedemptionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, accounts.ErrInsufficientBalance), errors.Is(err, rewards.ErrOutOfStock), errors.Is(err, rewards.ErrAlreadyCancelled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Decodes and validates a reward from the request body, responding with an error when it can't
+func (h *Handlers) decodeReward(w http.ResponseWriter, r *http.Request) (rewards.Reward, bool) {
+	var reward rewards.Reward
+	if status, err := h.decodeJSONBody(w, r, &reward); err != nil {
+		http.Error(w, err.Error(), status)
+		return reward, false
+	}
+	if err := reward.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return reward, false
+	}
+	return reward, true
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/accounts"
+	"receipts/rewards"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewards(t *testing.T) {
+	router := CreateRouter()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+	decode := func(responseRecorder *httptest.ResponseRecorder, v any) {
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), v))
+	}
+	getBalance := func(accountId string) int {
+		var balance balanceResponse
+		decode(serve("GET", "/accounts/"+accountId+"/balance", ""), &balance)
+		return balance.Balance
+	}
+
+	// Two morning receipts earn the account 30 points
+	var account accounts.Account
+	decode(serve("POST", "/accounts", `{"name": "Jane Doe"}`), &account)
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, serve("POST", "/receipts/process?accountId="+account.Id, morningReceipt).Code)
+	}
+
+	responseRecorder := serve("POST", "/admin/rewards", `{"name": "Sticker", "cost": 20, "inventory": 3}`)
+	require.Equal(t, http.StatusCreated, responseRecorder.Code)
+	var reward rewards.Reward
+	decode(responseRecorder, &reward)
+
+	t.Run("InvalidReward", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/admin/rewards", `{"name": "Sticker", "cost": 0}`).Code)
+		assert.Equal(t, http.StatusNotFound, serve("PUT", "/admin/rewards/unknown", `{"name": "Sticker", "cost": 1}`).Code)
+	})
+
+	var redemption rewards.Redemption
+	t.Run("Redeem", func(t *testing.T) {
+		responseRecorder := serve("POST", "/accounts/"+account.Id+"/redemptions", `{"rewardId": "`+reward.Id+`"}`)
+		require.Equal(t, http.StatusCreated, responseRecorder.Code)
+		decode(responseRecorder, &redemption)
+		assert.Equal(t, rewards.RedemptionCompleted, redemption.Status)
+		assert.Equal(t, 10, getBalance(account.Id))
+
+		var stored rewards.Reward
+		decode(serve("GET", "/rewards/"+reward.Id, ""), &stored)
+		assert.Equal(t, 2, *stored.Inventory)
+	})
+
+	t.Run("RedeemErrors", func(t *testing.T) {
+		body := `{"rewardId": "` + reward.Id + `"}`
+		assert.Equal(t, http.StatusConflict, serve("POST", "/accounts/"+account.Id+"/redemptions", body).Code, "overdraft")
+		assert.Equal(t, http.StatusNotFound, serve("POST", "/accounts/unknown/redemptions", body).Code)
+		assert.Equal(t, http.StatusNotFound, serve("POST", "/accounts/"+account.Id+"/redemptions", `{"rewardId": "unknown"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/accounts/"+account.Id+"/redemptions", `{}`).Code)
+		assert.Equal(t, 10, getBalance(account.Id))
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		target := "/accounts/" + account.Id + "/redemptions/" + redemption.Id + "/cancel"
+		responseRecorder := serve("POST", target, "")
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var cancelled rewards.Redemption
+		decode(responseRecorder, &cancelled)
+		assert.Equal(t, rewards.RedemptionCancelled, cancelled.Status)
+		assert.Equal(t, 30, getBalance(account.Id))
+
+		assert.Equal(t, http.StatusConflict, serve("POST", target, "").Code)
+		assert.Equal(t, http.StatusNotFound, serve("POST", "/accounts/"+account.Id+"/redemptions/unknown/cancel", "").Code)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		var history redemptionsResponse
+		decode(serve("GET", "/accounts/"+account.Id+"/redemptions", ""), &history)
+		require.Len(t, history.Redemptions, 1)
+		assert.Equal(t, rewards.RedemptionCancelled, history.Redemptions[0].Status)
+		assert.Equal(t, http.StatusNotFound, serve("GET", "/accounts/unknown/redemptions", "").Code)
+
+		var ledger ledgerResponse
+		decode(serve("GET", "/accounts/"+account.Id+"/ledger", ""), &ledger)
+		require.Len(t, ledger.Entries, 4)
+		assert.Equal(t, accounts.EntryRedeem, ledger.Entries[2].Type)
+		assert.Equal(t, accounts.EntryRefund, ledger.Entries[3].Type)
+	})
+
+	t.Run("ConcurrentRedeem", func(t *testing.T) {
+		// 30 points afford one 20 point sticker, however many requests race for it
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest("POST", "/accounts/"+account.Id+"/redemptions", bytes.NewBufferString(`{"rewardId": "`+reward.Id+`"}`))
+				router.ServeHTTP(httptest.NewRecorder(), req)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 10, getBalance(account.Id))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve("DELETE", "/admin/rewards/"+reward.Id, "").Code)
+		assert.Equal(t, http.StatusNotFound, serve("GET", "/rewards/"+reward.Id, "").Code)
+		var catalog []rewards.Reward
+		decode(serve("GET", "/rewards", ""), &catalog)
+		assert.Empty(t, catalog)
+	})
+}
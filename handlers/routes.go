@@ -13,10 +13,69 @@ Created this function here instead of main package so that handler test files ca
 router as well as main file when program is ran.
 */
 func CreateRouter() *mux.Router {
+	return CreateRouterWithConfig(DefaultConfig())
+}
+
+// Same as CreateRouter, but handlers use the given config instead of the default one.
+func CreateRouterWithConfig(config Config) *mux.Router {
 	receiptStorage := storage.NewReceiptStorage()
-	handlers := NewHandlers(receiptStorage)
+	return NewRouter(NewHandlers(receiptStorage, config))
+}
+
+/*
+Creates the mux Router for already constructed handlers. Used when the handlers'
+storage has to be shared with something else, such as the gRPC server.
+*/
+func NewRouter(handlers *Handlers) *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/receipts/process", handlers.ProcessReceipt).Methods("POST")
+	router.HandleFunc("/receipts/parse-text", handlers.ParseTextReceipt).Methods("POST")
 	router.HandleFunc("/receipts/{id}/points", handlers.GetPoints).Methods("GET")
+	router.HandleFunc("/accounts", handlers.CreateAccount).Methods("POST")
+	router.HandleFunc("/accounts/{id}", handlers.GetAccount).Methods("GET")
+	router.HandleFunc("/accounts/{id}/balance", handlers.GetBalance).Methods("GET")
+	router.HandleFunc("/accounts/{id}/ledger", handlers.GetLedger).Methods("GET")
+	router.HandleFunc("/accounts/{id}/expiring", handlers.GetExpiringPoints).Methods("GET")
+	router.HandleFunc("/accounts/{id}/tier", handlers.GetAccountTier).Methods("GET")
+	router.HandleFunc("/accounts/{id}/redemptions", handlers.RedeemReward).Methods("POST")
+	router.HandleFunc("/accounts/{id}/redemptions", handlers.ListRedemptions).Methods("GET")
+	router.HandleFunc("/accounts/{id}/redemptions/{redemptionId}/cancel", handlers.CancelRedemption).Methods("POST")
+	router.HandleFunc("/rewards", handlers.ListRewards).Methods("GET")
+	router.HandleFunc("/rewards/{id}", handlers.GetReward).Methods("GET")
+	router.HandleFunc("/tiers", handlers.GetTiers).Methods("GET")
+	router.HandleFunc("/retailers", handlers.ListRetailers).Methods("GET")
+	router.HandleFunc("/retailers/match", handlers.MatchRetailer).Methods("GET")
+	router.HandleFunc("/leaderboards/{period}", handlers.GetLeaderboard).Methods("GET")
+	router.HandleFunc("/leaderboards/{period}/accounts/{id}", handlers.GetLeaderboardRank).Methods("GET")
+	router.HandleFunc("/graphql", handlers.GraphQL).Methods("GET", "POST")
+	router.HandleFunc("/openapi.json", handlers.GetOpenAPISpec).Methods("GET")
+	router.HandleFunc("/docs", handlers.GetDocs).Methods("GET")
+
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(handlers.requireAdmin)
+	admin.HandleFunc("/receipts/export", handlers.ExportReceipts).Methods("GET")
+	admin.HandleFunc("/receipts/import", handlers.ImportReceipts).Methods("POST")
+	admin.HandleFunc("/receipts/rescore", handlers.RescoreReceipts).Methods("POST")
+	admin.HandleFunc("/receipts/{id}", handlers.DeleteReceipt).Methods("DELETE")
+	admin.HandleFunc("/analytics", handlers.GetAnalytics).Methods("GET")
+	admin.HandleFunc("/products/top", handlers.GetTopProducts).Methods("GET")
+	admin.HandleFunc("/rules", handlers.GetRules).Methods("GET")
+	admin.HandleFunc("/rules/reload", handlers.ReloadRules).Methods("POST")
+	admin.HandleFunc("/rules/simulate", handlers.SimulateRules).Methods("POST")
+	admin.HandleFunc("/rules/evaluate", handlers.EvaluateRule).Methods("POST")
+	admin.HandleFunc("/accounts/expiring", handlers.ListExpiringPoints).Methods("GET")
+	admin.HandleFunc("/accounts/expire", handlers.ExpirePoints).Methods("POST")
+	admin.HandleFunc("/accounts/{id}/adjustments", handlers.AdjustBalance).Methods("POST")
+	admin.HandleFunc("/rewards", handlers.CreateReward).Methods("POST")
+	admin.HandleFunc("/rewards/{id}", handlers.UpdateReward).Methods("PUT")
+	admin.HandleFunc("/rewards/{id}", handlers.DeleteReward).Methods("DELETE")
+	admin.HandleFunc("/tiers", handlers.SetTiers).Methods("PUT")
+	admin.HandleFunc("/retailers/{id}", handlers.RegisterRetailer).Methods("PUT")
+	admin.HandleFunc("/tiers/recalculate", handlers.RecalculateTiers).Methods("POST")
+	admin.HandleFunc("/campaigns", handlers.ListCampaigns).Methods("GET")
+	admin.HandleFunc("/campaigns", handlers.CreateCampaign).Methods("POST")
+	admin.HandleFunc("/campaigns/{id}", handlers.GetCampaign).Methods("GET")
+	admin.HandleFunc("/campaigns/{id}", handlers.UpdateCampaign).Methods("PUT")
+	admin.HandleFunc("/campaigns/{id}", handlers.DeleteCampaign).Methods("DELETE")
 	return router
 }
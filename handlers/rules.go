@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"receipts/points"
+	"receipts/rulesfile"
+	"time"
+)
+
+// The active points rules, as returned by GetRules
+type rulesResponse struct {
+	// Version of the rule set in effect now
+	ActiveVersion string           `json:"activeVersion"`
+	RuleSets      []ruleSetSummary `json:"ruleSets"`
+	// Rules file the rule sets are loaded from, nil when the built in rules are used
+	File *rulesfile.Status `json:"file,omitempty"`
+}
+
+type ruleSetSummary struct {
+	Version       string    `json:"version"`
+	EffectiveFrom time.Time `json:"effectiveFrom"`
+	Stages        []string  `json:"stages"`
+}
+
+// Returns the active points rule set versions, their stages, and how the rules file last loaded
+func (h *Handlers) GetRules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.rulesResponse())
+}
+
+/*
+Reloads the rules file and makes its rule sets the active ones. When the file is
+invalid the previous rule sets stay active and the error is returned.
+*/
+func (h *Handlers) ReloadRules(w http.ResponseWriter, r *http.Request) {
+	if h.config.Rules == nil {
+		http.Error(w, "rules are built in and not loaded from a rules file", http.StatusConflict)
+		return
+	}
+	if err := h.config.Rules.Reload(); err != nil {
+		http.Error(w, "rules not reloaded: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.rulesResponse())
+}
+
+func (h *Handlers) rulesResponse() rulesResponse {
+	ruleSets := points.GetRuleSets()
+	response := rulesResponse{
+		ActiveVersion: points.EffectiveRuleSet(time.Now()).Version,
+		RuleSets:      make([]ruleSetSummary, 0, len(ruleSets)),
+	}
+	for _, ruleSet := range ruleSets {
+		summary := ruleSetSummary{Version: ruleSet.Version, EffectiveFrom: ruleSet.EffectiveFrom, Stages: make([]string, 0, len(ruleSet.Stages))}
+		for _, stage := range ruleSet.Stages {
+			summary.Stages = append(summary.Stages, stage.Name)
+		}
+		response.RuleSets = append(response.RuleSets, summary)
+	}
+	if h.config.Rules != nil {
+		status := h.config.Rules.Status()
+		response.File = &status
+	}
+	return response
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"receipts/points"
+	"receipts/rulesfile"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules(t *testing.T) {
+	t.Cleanup(func() { points.SetRuleSets(points.DefaultRuleSets()) })
+	path := filepath.Join(t.TempDir(), "rules.json")
+	write := func(rules string) {
+		require.NoError(t, os.WriteFile(path, []byte(rules), 0o600))
+	}
+
+	config := DefaultConfig()
+	config.Rules = rulesfile.NewReloader(path)
+	router := CreateRouterWithConfig(config)
+
+	serve := func(method string, target string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, nil)
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+	getRules := func() rulesResponse {
+		responseRecorder := serve("GET", "/admin/rules")
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var response rulesResponse
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("BuiltIn", func(t *testing.T) {
+		response := getRules()
+		assert.Equal(t, "v1", response.ActiveVersion)
+		assert.Equal(t, "RetailerRule", response.RuleSets[0].Stages[0])
+		require.NotNil(t, response.File)
+		assert.Nil(t, response.File.LoadedAt)
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		write(`{"ruleSets": [{"version": "flat", "stages": [{"type": "add", "name": "Flat", "expression": "10"}]}]}`)
+		assert.Equal(t, http.StatusOK, serve("POST", "/admin/rules/reload").Code)
+
+		response := getRules()
+		assert.Equal(t, "flat", response.ActiveVersion)
+		assert.Equal(t, []string{"Flat"}, response.RuleSets[0].Stages)
+		assert.NotNil(t, response.File.LoadedAt)
+
+		id := processReceipt(t, router, morningReceipt)
+		responseRecorder := serve("GET", "/receipts/"+id+"/points")
+		assert.Equal(t, `{"points":10}`+"\n", responseRecorder.Body.String())
+	})
+
+	t.Run("InvalidFileKeepsRules", func(t *testing.T) {
+		write(`{"ruleSets": [{"version": "broken", "stages": [{"type": "add", "name": "WeekendRule"}]}]}`)
+		responseRecorder := serve("POST", "/admin/rules/reload")
+		assert.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+		assert.Contains(t, responseRecorder.Body.String(), "unknown rule")
+
+		response := getRules()
+		assert.Equal(t, "flat", response.ActiveVersion)
+		assert.Contains(t, response.File.LastError, "unknown rule")
+	})
+
+	t.Run("NoRulesFile", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/admin/rules/reload", nil)
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		CreateRouter().ServeHTTP(responseRecorder, req)
+		assert.Equal(t, http.StatusConflict, responseRecorder.Code)
+	})
+}
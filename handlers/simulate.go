@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"receipts/points"
+	"receipts/simulation"
+	"time"
+)
+
+// Request body of SimulateRules
+type simulateRequest struct {
+	Candidate simulation.Candidate `json:"candidate"`
+	// Version of the rule set to compare against, the one in effect now when empty
+	Baseline string `json:"baseline"`
+	Sample   int    `json:"sample"`
+	Seed     int64  `json:"seed"`
+	Top      int    `json:"top"`
+}
+
+/*
+Scores stored receipts, or a random sample of them, with both a baseline and a
+candidate rule set and reports how points would change under the candidate.
+Nothing is saved.
+*/
+func (h *Handlers) SimulateRules(w http.ResponseWriter, r *http.Request) {
+	var request simulateRequest
+	if status, err := h.decodeJSONBody(w, r, &request); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if request.Sample < 0 || request.Top < 0 {
+		http.Error(w, "sample and top must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	candidate, err := request.Candidate.RuleSet()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	baseline := points.EffectiveRuleSet(time.Now())
+	if request.Baseline != "" {
+		var ok bool
+		if baseline, ok = points.RuleSetVersion(request.Baseline); !ok {
+			http.Error(w, "rule set version "+request.Baseline+" not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	stored := h.storage.ListReceipts()
+	inputs := make([]simulation.Input, 0, len(stored))
+	for _, receipt := range stored {
+		inputs = append(inputs, simulation.Input{Id: receipt.Id.String(), Receipt: receipt.Receipt})
+	}
+
+	options := simulation.Options{Sample: request.Sample, Seed: request.Seed, Top: request.Top}
+	writeJSON(w, http.StatusOK, simulation.Simulate(inputs, baseline, candidate, options))
+}
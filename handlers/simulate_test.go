@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/simulation"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateRules(t *testing.T) {
+	router := CreateRouter()
+	id := processReceipt(t, router, morningReceipt)
+	processReceipt(t, router, morningReceipt)
+
+	simulate := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/admin/rules/simulate", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	t.Run("DoubleRetailerPoints", func(t *testing.T) {
+		responseRecorder := simulate(`{
+			"candidate": {"rules": [{"rule": "RetailerRule", "multiplier": 2}]},
+			"top": 1
+		}`)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+
+		// The morning receipt earns 9 retailer points and 6 purchase day points under v1
+		var report simulation.Report
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &report))
+		assert.Equal(t, "v1", report.Baseline)
+		assert.Equal(t, simulation.CandidateVersion, report.Candidate)
+		assert.Equal(t, 2, report.Receipts)
+		assert.Equal(t, 30, report.BaselinePoints)
+		assert.Equal(t, 36, report.CandidatePoints)
+		assert.Equal(t, 2, report.Increased)
+		assert.Equal(t, []simulation.ReceiptDelta{
+			{Id: id, Retailer: "Walgreens", BaselinePoints: 15, CandidatePoints: 18, Delta: 3},
+		}, report.MostAffected)
+	})
+
+	t.Run("Sample", func(t *testing.T) {
+		responseRecorder := simulate(`{"candidate": {"version": "v1"}, "sample": 1, "seed": 3}`)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var report simulation.Report
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &report))
+		assert.Equal(t, 1, report.Receipts)
+		assert.Equal(t, 0, report.Delta)
+	})
+
+	t.Run("BadRequest", func(t *testing.T) {
+		for _, body := range []string{
+			`{}`,
+			`{"candidate": {"rules": [{"rule": "WeekendRule"}]}}`,
+			`{"candidate": {"version": "v1"}, "baseline": "v0"}`,
+			`{"candidate": {"version": "v1"}, "sample": -1}`,
+			`not json`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, simulate(body).Code, body)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"receipts/tiers"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Response of GetAccountTier
+type accountTierResponse struct {
+	AccountId string      `json:"accountId"`
+	Tier      tiers.Tier  `json:"tier"`
+	Basis     tiers.Basis `json:"basis"`
+	// Points or spend of the last QualifyingMonths, depending on Basis
+	Qualifying float64 `json:"qualifying"`
+	// Tier after the account's tier and how much more qualifying activity reaches it, nil in the highest tier
+	NextTier  *tiers.Tier    `json:"nextTier,omitempty"`
+	Remaining *float64       `json:"remaining,omitempty"`
+	History   []tiers.Change `json:"history"`
+}
+
+// Response of RecalculateTiers
+type recalculateResponse struct {
+	Changes []tiers.Change `json:"changes"`
+}
+
+// Returns the tier program, which tiers accounts can reach and the multiplier each earns
+func (h *Handlers) GetTiers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.tiers.Program())
+}
+
+/*
+Replaces the tier program with the one in the json request body. Accounts keep their
+tiers until tiers are next recalculated.
+*/
+func (h *Handlers) SetTiers(w http.ResponseWriter, r *http.Request) {
+	var program tiers.Program
+	if status, err := h.decodeJSONBody(w, r, &program); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err := h.tiers.SetProgram(program); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.tiers.Program())
+}
+
+// Returns the account's tier, its progress towards the next tier, and its tier history
+func (h *Handlers) GetAccountTier(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	now := time.Now().UTC()
+	qualifying, exists := h.tiers.Qualifying(id, now)
+	if !exists {
+		http.Error(w, "account with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+
+	program := h.tiers.Program()
+	tier := h.tiers.TierAt(id, now)
+	response := accountTierResponse{AccountId: id, Tier: tier, Basis: program.Basis, Qualifying: qualifying, History: h.tiers.History(id)}
+	if next, exists := program.NextTier(tier.Name); exists {
+		remaining := max(next.Threshold-qualifying, 0)
+		response.NextTier, response.Remaining = &next, &remaining
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Moves every account to the tier its qualifying activity reaches now, returning the accounts that changed tier
+func (h *Handlers) RecalculateTiers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, recalculateResponse{Changes: h.tiers.Recalculate(time.Now().UTC())})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"receipts/accounts"
+	"receipts/tiers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTiers(t *testing.T) {
+	router := CreateRouter()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+	decode := func(responseRecorder *httptest.ResponseRecorder, v any) {
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), v))
+	}
+
+	var program tiers.Program
+	decode(serve("GET", "/tiers", ""), &program)
+	assert.Equal(t, tiers.DefaultProgram(), program)
+
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", "/admin/tiers", `{"basis": "points", "tiers": []}`).Code)
+	responseRecorder := serve("PUT", "/admin/tiers", `{"basis": "points", "tiers": [{"name": "Member", "threshold": 0, "multiplier": 1}, {"name": "Plus", "threshold": 10, "multiplier": 2}]}`)
+	require.Equal(t, http.StatusOK, responseRecorder.Code)
+	decode(responseRecorder, &program)
+	assert.Equal(t, "Plus", program.Tiers[1].Name)
+
+	// A morning receipt earns 15 points in the first tier, enough to reach the second
+	var account accounts.Account
+	decode(serve("POST", "/accounts", `{"name": "Jane Doe"}`), &account)
+	require.Equal(t, http.StatusOK, serve("POST", "/receipts/process?accountId="+account.Id, morningReceipt).Code)
+
+	var tier accountTierResponse
+	decode(serve("GET", "/accounts/"+account.Id+"/tier", ""), &tier)
+	assert.Equal(t, "Member", tier.Tier.Name)
+	assert.Equal(t, 15.0, tier.Qualifying)
+	require.NotNil(t, tier.NextTier)
+	assert.Equal(t, "Plus", tier.NextTier.Name)
+	assert.Equal(t, 0.0, *tier.Remaining)
+	assert.Empty(t, tier.History)
+
+	var recalculated recalculateResponse
+	decode(serve("POST", "/admin/tiers/recalculate", ""), &recalculated)
+	require.Len(t, recalculated.Changes, 1)
+	assert.Equal(t, account.Id, recalculated.Changes[0].AccountId)
+	assert.Equal(t, "Member", recalculated.Changes[0].Previous)
+
+	var promoted accountTierResponse
+	decode(serve("GET", "/accounts/"+account.Id+"/tier", ""), &promoted)
+	assert.Equal(t, "Plus", promoted.Tier.Name)
+	assert.Nil(t, promoted.NextTier)
+	assert.Len(t, promoted.History, 1)
+
+	// The second receipt earns double, with the tier's points in its breakdown
+	require.Equal(t, http.StatusOK, serve("POST", "/receipts/process?accountId="+account.Id, morningReceipt).Code)
+	var ledger ledgerResponse
+	decode(serve("GET", "/accounts/"+account.Id+"/ledger", ""), &ledger)
+	require.Len(t, ledger.Entries, 2)
+	assert.Equal(t, 30, ledger.Entries[1].Points)
+	assert.Equal(t, 2.65, ledger.Entries[1].Spend)
+
+	var graphQLResult struct {
+		Data struct {
+			Receipt struct {
+				Breakdown []struct {
+					Rule   string
+					Points int
+					Tier   *string
+				}
+			}
+		}
+	}
+	query := `{"query": "{ receipt(id: \"` + ledger.Entries[1].ReceiptId + `\") { breakdown { rule points tier } } }"}`
+	decode(serve("POST", "/graphql", query), &graphQLResult)
+	breakdown := graphQLResult.Data.Receipt.Breakdown
+	require.NotEmpty(t, breakdown)
+	last := breakdown[len(breakdown)-1]
+	assert.Equal(t, "Plus tier", last.Rule)
+	assert.Equal(t, 15, last.Points)
+	require.NotNil(t, last.Tier)
+	assert.Equal(t, "Plus", *last.Tier)
+
+	// Rescoring keeps the tier each receipt was earned in, so nothing changes
+	var report rescoreReport
+	decode(serve("POST", "/admin/receipts/rescore?version=v1", ""), &report)
+	assert.Equal(t, 2, report.Receipts)
+	assert.Zero(t, report.Changed)
+
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/accounts/unknown/tier", "").Code)
+}
@@ -0,0 +1,75 @@
+package ingest
+
+import (
+	"encoding/json"
+	"receipts/accounts"
+	"receipts/leaderboards"
+	"receipts/models"
+	"receipts/points"
+	"receipts/storage"
+	"receipts/tiers"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Earns 15 points
+const morningReceipt = `{
+	"retailer": "Walgreens",
+	"purchaseDate": "2022-01-02",
+	"purchaseTime": "08:13",
+	"total": "2.65",
+	"items": [
+		{"shortDescription": "Pepsi - 12-oz", "price": "1.25"},
+		{"shortDescription": "Dasani", "price": "1.40"}
+	]
+}`
+
+func TestIngest(t *testing.T) {
+	receiptStorage, accountStore, boards := storage.NewReceiptStorage(), accounts.NewStore(), leaderboards.NewBoards()
+	scorer := points.Scorer{Selection: points.SelectByPurchaseDate}
+	ingester := NewIngester(receiptStorage, accountStore, tiers.NewStore(accountStore), boards, scorer)
+	account := accountStore.CreateAccount(accounts.Account{Name: "Jane Doe"})
+
+	tests := []struct {
+		testName        string
+		accountId       string
+		expectedErr     error
+		expectedBalance int
+		expectedCount   int
+	}{
+		{testName: "NoAccount", accountId: "", expectedBalance: 0, expectedCount: 1},
+		{testName: "Account", accountId: account.Id, expectedBalance: 15, expectedCount: 2},
+		{testName: "UnknownAccount", accountId: "unknown", expectedErr: accounts.ErrAccountNotFound, expectedBalance: 15, expectedCount: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			var receipt models.Receipt
+			require.NoError(t, json.Unmarshal([]byte(morningReceipt), &receipt))
+
+			id, err := ingester.Ingest(&receipt, test.accountId)
+			assert.ErrorIs(t, err, test.expectedErr)
+			balance, _ := accountStore.Balance(account.Id)
+			assert.Equal(t, test.expectedBalance, balance)
+			assert.Equal(t, test.expectedCount, receiptStorage.Count())
+			if err != nil {
+				assert.Equal(t, uuid.Nil, id)
+				return
+			}
+
+			stored, exists := receiptStorage.GetStoredReceipt(id)
+			require.True(t, exists)
+			assert.Equal(t, 15, stored.Score().Points)
+			receiptAccount, linked := accountStore.ReceiptAccount(id.String())
+			assert.Equal(t, test.accountId != "", linked)
+			assert.Equal(t, test.accountId, receiptAccount)
+		})
+	}
+
+	standings, _ := boards.Top(leaderboards.PeriodDay, time.Now(), "", 10)
+	assert.Equal(t, []leaderboards.Standing{{Rank: 1, AccountId: account.Id, Points: 15}}, standings)
+}
@@ -0,0 +1,70 @@
+package ingest
+
+import (
+	"receipts/accounts"
+	"receipts/leaderboards"
+	"receipts/models"
+	"receipts/points"
+	"receipts/storage"
+	"receipts/tiers"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/*
+Stores new receipts for every transport, so a receipt processed over http, GraphQL
+or gRPC is scored the same way and earns the same loyalty credit.
+*/
+type Ingester struct {
+	storage      *storage.ReceiptStorage
+	accounts     *accounts.Store
+	tiers        *tiers.Store
+	leaderboards *leaderboards.Boards
+	// Scores new receipts, before the multiplier of the account's tier is added
+	scorer points.Scorer
+}
+
+func NewIngester(storage *storage.ReceiptStorage, accounts *accounts.Store, tiers *tiers.Store, leaderboards *leaderboards.Boards, scorer points.Scorer) *Ingester {
+	return &Ingester{
+		storage:      storage,
+		accounts:     accounts,
+		tiers:        tiers,
+		leaderboards: leaderboards,
+		scorer:       scorer,
+	}
+}
+
+/*
+Matches a validated receipt's retailer to its canonical retailer, then scores the
+receipt and stores both, returning its id. When accountId isn't empty the points are
+earned by that account along with the multiplier of the account's tier, and count on
+the account's leaderboards.
+
+The earn entry linking the receipt to its account is appended before the receipt is
+stored, so rescoring or deleting a stored receipt always finds its account. Returns
+an error wrapping accounts.ErrAccountNotFound, having stored nothing, when the
+account doesn't exist.
+*/
+func (i *Ingester) Ingest(receipt *models.Receipt, accountId string) (uuid.UUID, error) {
+	id := uuid.New()
+	now := time.Now()
+	i.storage.Retailers().Canonicalize(receipt)
+	if accountId == "" {
+		i.storage.SetScoredReceipt(id, receipt, i.scorer.Score(receipt, now))
+		return id, nil
+	}
+
+	score := i.scorer.WithBonus(i.tiers.TierAt(accountId, now)).Score(receipt, now)
+	// Validated receipts have a valid total, and a parse failure only leaves the spend out of tiers
+	spend, _ := strconv.ParseFloat(receipt.Total, 64)
+	earned := max(score.Points, 0)
+	_, err := i.accounts.Append(accounts.Entry{AccountId: accountId, Type: accounts.EntryEarn, Points: earned, ReceiptId: id.String(), Spend: spend})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	i.leaderboards.Record(id.String(), accountId, receipt.RetailerId(), now, earned)
+	i.storage.SetScoredReceipt(id, receipt, score)
+	return id, nil
+}
@@ -0,0 +1,228 @@
+package leaderboards
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Points a receipt earned an account, counted on the leaderboards of the periods it was earned in
+type contribution struct {
+	accountId string
+	retailer  string
+	earnedAt  time.Time
+	points    int
+}
+
+// Identifies one leaderboard, the points earned in a period, at one retailer or at every retailer when empty
+type boardKey struct {
+	period   Period
+	start    time.Time
+	retailer string
+}
+
+// An account's place on a leaderboard
+type Standing struct {
+	// Accounts with equal points share a rank, and the account after them skips the ranks they share
+	Rank      int    `json:"rank"`
+	AccountId string `json:"accountId"`
+	Points    int    `json:"points"`
+}
+
+/*
+Thread safe leaderboards of the points accounts earn per day, week and month, overall
+and per retailer. Every receipt's points are kept, and each leaderboard's totals
+are updated as receipts are recorded, rescored and removed, so reads only have to
+sort one leaderboard's accounts.
+*/
+type Boards struct {
+	*sync.RWMutex
+	receiptToContribution map[string]contribution
+	// Points of every account with points on each leaderboard
+	boardToTotals map[boardKey]map[string]int
+	// Retailer each merged retailer's points are recorded under instead
+	mergedTo map[string]string
+}
+
+func NewBoards() *Boards {
+	return &Boards{
+		RWMutex:               &sync.RWMutex{},
+		receiptToContribution: make(map[string]contribution),
+		boardToTotals:         make(map[boardKey]map[string]int),
+		mergedTo:              make(map[string]string),
+	}
+}
+
+// Retailers are compared ignoring case and surrounding spaces, as campaigns do
+func normalizeRetailer(retailer string) string {
+	return strings.ToLower(strings.TrimSpace(retailer))
+}
+
+/*
+Counts the points a receipt earned the account at the retailer on the leaderboards
+of the periods containing earnedAt, after waiting for the read / write lock.
+Recording a receipt again replaces its previous points.
+*/
+func (b *Boards) Record(receiptId string, accountId string, retailer string, earnedAt time.Time, points int) {
+	b.Lock()
+	defer b.Unlock()
+	b.remove(receiptId)
+	retailer = normalizeRetailer(retailer)
+	if to, merged := b.mergedTo[retailer]; merged {
+		retailer = to
+	}
+	c := contribution{accountId: accountId, retailer: retailer, earnedAt: earnedAt.UTC(), points: points}
+	b.receiptToContribution[receiptId] = c
+	b.add(c, c.points)
+}
+
+/*
+Replaces the points of an already recorded receipt, such as when it is rescored, keeping
+the periods and retailer it counts for. Returns false if the receipt was never recorded.
+Waits for the read / write lock.
+*/
+func (b *Boards) Rescore(receiptId string, points int) bool {
+	b.Lock()
+	defer b.Unlock()
+	c, exists := b.receiptToContribution[receiptId]
+	if !exists {
+		return false
+	}
+	b.add(c, points-c.points)
+	c.points = points
+	b.receiptToContribution[receiptId] = c
+	return true
+}
+
+/*
+Moves the points recorded at each of the from retailers to the to retailer's leaderboards,
+such as when discovered retailers are absorbed into a registered one, after waiting for
+the read / write lock. Points recorded at them later count for the to retailer too.
+*/
+func (b *Boards) MergeRetailers(from []string, to string) {
+	b.Lock()
+	defer b.Unlock()
+	to = normalizeRetailer(to)
+	delete(b.mergedTo, to)
+	merging := make(map[string]bool)
+	for _, retailer := range from {
+		retailer = normalizeRetailer(retailer)
+		if retailer != to {
+			merging[retailer] = true
+			b.mergedTo[retailer] = to
+		}
+	}
+	for receiptId, c := range b.receiptToContribution {
+		if !merging[c.retailer] {
+			continue
+		}
+		b.add(c, -c.points)
+		c.retailer = to
+		b.add(c, c.points)
+		b.receiptToContribution[receiptId] = c
+	}
+}
+
+// Takes a receipt's points off the leaderboards, returning false if it was never recorded, after waiting for the read / write lock.
+func (b *Boards) Remove(receiptId string) bool {
+	b.Lock()
+	defer b.Unlock()
+	return b.remove(receiptId)
+}
+
+// Same as Remove, caller must hold the write lock
+func (b *Boards) remove(receiptId string) bool {
+	c, exists := b.receiptToContribution[receiptId]
+	if !exists {
+		return false
+	}
+	b.add(c, -c.points)
+	delete(b.receiptToContribution, receiptId)
+	return true
+}
+
+/*
+Adds points to the contribution's account on every leaderboard it counts for, dropping
+accounts left with no points so they aren't ranked. Caller must hold the write lock.
+*/
+func (b *Boards) add(c contribution, points int) {
+	if points == 0 {
+		return
+	}
+	retailers := []string{""}
+	if c.retailer != "" {
+		retailers = append(retailers, c.retailer)
+	}
+	for _, period := range Periods {
+		start := period.Start(c.earnedAt)
+		for _, retailer := range retailers {
+			key := boardKey{period: period, start: start, retailer: retailer}
+			totals := b.boardToTotals[key]
+			if totals == nil {
+				totals = make(map[string]int)
+				b.boardToTotals[key] = totals
+			}
+			totals[c.accountId] += points
+			if totals[c.accountId] == 0 {
+				delete(totals, c.accountId)
+			}
+			if len(totals) == 0 {
+				delete(b.boardToTotals, key)
+			}
+		}
+	}
+}
+
+// Returns every account on the leaderboard ranked highest points first, caller must hold the read lock
+func (b *Boards) standings(period Period, at time.Time, retailer string) []Standing {
+	totals := b.boardToTotals[boardKey{period: period, start: period.Start(at), retailer: normalizeRetailer(retailer)}]
+	standings := make([]Standing, 0, len(totals))
+	for accountId, points := range totals {
+		standings = append(standings, Standing{AccountId: accountId, Points: points})
+	}
+	// Ties are listed by account id so the order is stable between reads
+	slices.SortFunc(standings, func(a, b Standing) int {
+		if a.Points != b.Points {
+			return b.Points - a.Points
+		}
+		return strings.Compare(a.AccountId, b.AccountId)
+	})
+	for i := range standings {
+		if i > 0 && standings[i].Points == standings[i-1].Points {
+			standings[i].Rank = standings[i-1].Rank
+		} else {
+			standings[i].Rank = i + 1
+		}
+	}
+	return standings
+}
+
+/*
+Returns the limit highest ranked accounts of the period containing at, at the retailer
+or at every retailer when it is empty, and how many accounts are ranked in total.
+Waits for the read lock.
+*/
+func (b *Boards) Top(period Period, at time.Time, retailer string, limit int) ([]Standing, int) {
+	b.RLock()
+	defer b.RUnlock()
+	standings := b.standings(period, at, retailer)
+	return standings[:min(limit, len(standings))], len(standings)
+}
+
+/*
+Returns the account's standing in the period containing at, at the retailer or at
+every retailer when it is empty, and how many accounts are ranked in total. Returns
+false if the account earned no points in the period. Waits for the read lock.
+*/
+func (b *Boards) Rank(accountId string, period Period, at time.Time, retailer string) (Standing, int, bool) {
+	b.RLock()
+	defer b.RUnlock()
+	standings := b.standings(period, at, retailer)
+	for _, standing := range standings {
+		if standing.AccountId == accountId {
+			return standing, len(standings), true
+		}
+	}
+	return Standing{}, len(standings), false
+}
@@ -0,0 +1,105 @@
+package leaderboards
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriodStart(t *testing.T) {
+	// Wednesday, June 12 2024
+	at := time.Date(2024, time.June, 12, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		period        Period
+		at            time.Time
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{period: PeriodDay, at: at, expectedStart: time.Date(2024, time.June, 12, 0, 0, 0, 0, time.UTC), expectedEnd: time.Date(2024, time.June, 13, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodWeek, at: at, expectedStart: time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC), expectedEnd: time.Date(2024, time.June, 17, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodWeek, at: time.Date(2024, time.June, 16, 23, 0, 0, 0, time.UTC), expectedStart: time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC), expectedEnd: time.Date(2024, time.June, 17, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodMonth, at: at, expectedStart: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), expectedEnd: time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.period)+test.at.Format(time.DateOnly), func(t *testing.T) {
+			start := test.period.Start(test.at)
+			assert.Equal(t, test.expectedStart, start)
+			assert.Equal(t, test.expectedEnd, test.period.End(start))
+		})
+	}
+
+	_, err := ParsePeriod("year")
+	assert.Error(t, err)
+}
+
+func TestBoards(t *testing.T) {
+	boards := NewBoards()
+	monday := time.Date(2024, time.June, 10, 9, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+
+	boards.Record("r1", "alice", "Target", monday, 30)
+	boards.Record("r2", "bob", "Walgreens", monday, 50)
+	boards.Record("r3", "alice", " target ", tuesday, 25)
+	boards.Record("r4", "carol", "Walgreens", tuesday, 55)
+
+	top, ranked := boards.Top(PeriodWeek, tuesday, "", 10)
+	assert.Equal(t, 3, ranked)
+	assert.Equal(t, []Standing{{Rank: 1, AccountId: "alice", Points: 55}, {Rank: 1, AccountId: "carol", Points: 55}, {Rank: 3, AccountId: "bob", Points: 50}}, top)
+
+	top, _ = boards.Top(PeriodDay, monday, "", 1)
+	assert.Equal(t, []Standing{{Rank: 1, AccountId: "bob", Points: 50}}, top)
+
+	top, ranked = boards.Top(PeriodMonth, monday, "TARGET", 10)
+	assert.Equal(t, 1, ranked)
+	assert.Equal(t, []Standing{{Rank: 1, AccountId: "alice", Points: 55}}, top)
+
+	// Rescoring and removing receipts update every leaderboard they count on
+	assert.True(t, boards.Rescore("r3", 5))
+	standing, _, exists := boards.Rank("alice", PeriodWeek, monday, "")
+	require.True(t, exists)
+	assert.Equal(t, Standing{Rank: 3, AccountId: "alice", Points: 35}, standing)
+
+	assert.True(t, boards.Remove("r2"))
+	assert.False(t, boards.Remove("r2"))
+	_, ranked, exists = boards.Rank("bob", PeriodWeek, monday, "")
+	assert.False(t, exists)
+	assert.Equal(t, 2, ranked)
+	top, _ = boards.Top(PeriodDay, monday, "Walgreens", 10)
+	assert.Empty(t, top, "accounts left with no points aren't ranked")
+
+	// Recording a receipt again replaces its points
+	boards.Record("r1", "alice", "Target", monday, 100)
+	standing, _, _ = boards.Rank("alice", PeriodMonth, monday, "")
+	assert.Equal(t, Standing{Rank: 1, AccountId: "alice", Points: 105}, standing)
+	assert.False(t, boards.Rescore("unknown", 1))
+
+	top, ranked = boards.Top(PeriodWeek, monday.AddDate(0, 0, 7), "", 10)
+	assert.Empty(t, top)
+	assert.Zero(t, ranked)
+}
+
+func TestMergeRetailers(t *testing.T) {
+	boards := NewBoards()
+	monday := time.Date(2024, time.June, 10, 9, 0, 0, 0, time.UTC)
+	boards.Record("r1", "alice", "m-and-m-corner-market", monday, 30)
+	boards.Record("r2", "bob", "mm-market", monday, 50)
+	boards.Record("r3", "alice", "m-and-m", monday, 10)
+	boards.Record("r4", "carol", "target", monday, 20)
+
+	boards.MergeRetailers([]string{"m-and-m-corner-market", "mm-market"}, "m-and-m")
+	top, _ := boards.Top(PeriodDay, monday, "m-and-m", 10)
+	assert.Equal(t, []Standing{{Rank: 1, AccountId: "bob", Points: 50}, {Rank: 2, AccountId: "alice", Points: 40}}, top)
+	top, ranked := boards.Top(PeriodDay, monday, "mm-market", 10)
+	assert.Equal(t, 0, ranked)
+	assert.Empty(t, top)
+	top, _ = boards.Top(PeriodDay, monday, "", 10)
+	assert.Len(t, top, 3, "the overall leaderboard doesn't change")
+
+	// Receipts recorded at a merged retailer afterwards, such as ones matched before it was merged, count for the retailer merged into
+	boards.Record("r5", "carol", "mm-market", monday, 100)
+	top, _ = boards.Top(PeriodDay, monday, "m-and-m", 1)
+	assert.Equal(t, []Standing{{Rank: 1, AccountId: "carol", Points: 100}}, top)
+}
@@ -0,0 +1,58 @@
+package leaderboards
+
+import (
+	"fmt"
+	"time"
+)
+
+// Length of time a leaderboard ranks points earned over
+type Period string
+
+const (
+	// A UTC calendar day
+	PeriodDay Period = "day"
+	// A week starting on Monday, in UTC
+	PeriodWeek Period = "week"
+	// A UTC calendar month
+	PeriodMonth Period = "month"
+)
+
+// Every period, each of which has its own leaderboards
+var Periods = []Period{PeriodDay, PeriodWeek, PeriodMonth}
+
+// Parses a period name, returning an error for unknown names
+func ParsePeriod(name string) (Period, error) {
+	switch period := Period(name); period {
+	case PeriodDay, PeriodWeek, PeriodMonth:
+		return period, nil
+	default:
+		return "", fmt.Errorf("period must be one of day, week or month")
+	}
+}
+
+// Returns the start of the period containing the time, in UTC
+func (p Period) Start(at time.Time) time.Time {
+	at = at.UTC()
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case PeriodWeek:
+		// Weekday counts from Sunday, so Sunday is 6 days after the Monday starting its week
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case PeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// Returns the start of the period after the one starting at start
+func (p Period) End(start time.Time) time.Time {
+	switch p {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
@@ -1,15 +1,111 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"receipts/accounts"
 	"receipts/handlers"
+	"receipts/points"
+	"receipts/products"
+	"receipts/rpc"
+	"receipts/rulesfile"
+	"receipts/storage"
+	"syscall"
+	"time"
 )
 
-// Starts server listening on port 8080
+// Starts http server listening on port 8080 and gRPC server listening on port 50051
 func main() {
-	router := handlers.CreateRouter()
+	config := handlers.DefaultConfig()
+	flag.Int64Var(&config.MaxBodyBytes, "max-body-bytes", config.MaxBodyBytes, "maximum size in bytes of a request body, 0 for no limit")
+	flag.BoolVar(&config.StrictDecoding, "strict", config.StrictDecoding, "reject receipts with unknown fields or trailing data")
+	flag.Int64Var(&config.MaxImportBytes, "max-import-bytes", config.MaxImportBytes, "maximum size in bytes of a bulk import body, 0 for no limit")
+	flag.StringVar(&config.AdminToken, "admin-token", os.Getenv("RECEIPTS_ADMIN_TOKEN"), "bearer token required by /admin endpoints, defaults to $RECEIPTS_ADMIN_TOKEN, the server won't start without one")
+	flag.Func("rule-selection", "date that picks the points rule set of a new receipt, purchase-date or submission-time", func(name string) error {
+		selection, err := points.ParseSelection(name)
+		config.RuleSelection = selection
+		return err
+	})
+	rulesPath := flag.String("rules", "", "json file the points rules are loaded from, reloaded when it changes or on SIGHUP, built in rules when empty")
+	rulesPoll := flag.Duration("rules-poll", 2*time.Second, "how often the rules file is checked for changes, 0 to only reload on SIGHUP or /admin/rules/reload")
+	flag.Func("expiration", "when earned points expire, never, end-of-year, days:N or inactivity:N, defaults to never", func(value string) error {
+		policy, err := accounts.ParseExpirationPolicy(value)
+		config.Expiration = policy
+		return err
+	})
+	expirationInterval := flag.Duration("expiration-interval", time.Hour, "how often expired points are taken from accounts")
+	tierInterval := flag.Duration("tier-interval", 24*time.Hour, "how often accounts are moved to the tier their last 12 months of activity reaches, 0 to only recalculate on /admin/tiers/recalculate")
+	aliasesPath := flag.String("product-aliases", "", "json file of item name aliases to canonical product names, added to the built in aliases")
+	grpcPort := flag.Int("grpc-port", 50051, "port the gRPC server listens on, 0 to disable it")
+	flag.Parse()
+	// Admin endpoints change accounts' ledgers, so they are never served without a token
+	if config.AdminToken == "" {
+		log.Fatalf("an admin token is required, set it with -admin-token or $RECEIPTS_ADMIN_TOKEN")
+	}
+
+	if *rulesPath != "" {
+		config.Rules = rulesfile.NewReloader(*rulesPath)
+		if err := config.Rules.Reload(); err != nil {
+			log.Fatalf("failed to load rules: %v", err)
+		}
+		watchRules(config.Rules, *rulesPoll)
+	}
+
+	// Both servers share storage so receipts processed through one can be read through the other,
+	// and share the scorer so campaigns created over http apply to receipts processed over gRPC
+	receiptStorage := storage.NewReceiptStorage()
+	if *aliasesPath != "" {
+		aliases, err := products.LoadAliases(*aliasesPath)
+		if err != nil {
+			log.Fatalf("failed to load product aliases: %v", err)
+		}
+		receiptStorage.SetNormalizer(products.NewNormalizer(aliases))
+	}
+	receiptHandlers := handlers.NewHandlers(receiptStorage, config)
+	if config.Expiration.Kind != "" && config.Expiration.Kind != accounts.ExpireNever {
+		go receiptHandlers.Accounts().RunExpiration(config.Expiration, *expirationInterval, nil)
+	}
+	if *tierInterval > 0 {
+		go receiptHandlers.Tiers().RunRecalculation(*tierInterval, nil)
+	}
+
+	if *grpcPort != 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *grpcPort))
+		if err != nil {
+			log.Fatalf("failed to listen on gRPC port %d: %v", *grpcPort, err)
+		}
+		grpcServer := rpc.CreateGRPCServer(receiptStorage, receiptHandlers.Ingester())
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("gRPC server stopped: %v", err)
+			}
+		}()
+		fmt.Printf("Receipt Processor gRPC server is running on port %d\n", *grpcPort)
+	}
+
+	router := handlers.NewRouter(receiptHandlers)
 	http.Handle("/", router)
 	fmt.Println("Receipt Processor server is running on port 8080")
 	http.ListenAndServe(":8080", nil)
 }
+
+// Reloads the rules file whenever it changes and whenever the process receives SIGHUP
+func watchRules(reloader *rulesfile.Reloader, poll time.Duration) {
+	if poll > 0 {
+		go reloader.Watch(poll, nil)
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			// Errors are logged by the reloader, which keeps the previous rules
+			reloader.Reload()
+		}
+	}()
+}
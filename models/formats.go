@@ -0,0 +1,294 @@
+package models
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// This file contains decoding of receipts from formats other than json.
+
+// Columns of a receipt in csv format, one item per row
+var CSVHeader = []string{"retailer", "purchaseDate", "purchaseTime", "total", "shortDescription", "price"}
+
+/*
+Optional columns that may follow CSVHeader for extended receipts: the receipt's subtotal,
+taxes, discounts, tip and paymentMethod, repeated on every row like the other receipt
+columns, then the item's quantity and unitPrice. Taxes and discounts are json arrays of
+the same objects as in json receipts. Empty cells leave a field unset.
+*/
+var CSVExtendedHeader = []string{"subtotal", "taxes", "discounts", "tip", "paymentMethod", "quantity", "unitPrice"}
+
+// Number of receipt level columns at the start of CSVExtendedHeader, the rest are item columns
+const csvExtendedReceiptColumns = 5
+
+/*
+Checks the csv header row is the expected columns, optionally followed by
+CSVExtendedHeader, and returns whether it has the extended columns.
+*/
+func CheckCSVHeader(header []string, expected []string) (bool, error) {
+	extended := len(header) == len(expected)+len(CSVExtendedHeader)
+	if len(header) != len(expected) && !extended {
+		return false, fmt.Errorf("csv header must have %d columns, or %d with the extended receipt columns", len(expected), len(expected)+len(CSVExtendedHeader))
+	}
+	for i, column := range slices.Concat(expected, CSVExtendedHeader)[:len(header)] {
+		if header[i] != column {
+			return false, fmt.Errorf("csv header column %d must be %s", i+1, column)
+		}
+	}
+	return extended, nil
+}
+
+// Returns the values of the CSVExtendedHeader columns for the row of one of the receipt's items
+func (r *Receipt) CSVExtendedColumns(item Item) []string {
+	quantity := ""
+	if item.Quantity != 0 {
+		quantity = strconv.FormatFloat(item.Quantity, 'f', -1, 64)
+	}
+	return []string{r.Subtotal, jsonCell(r.Taxes), jsonCell(r.Discounts), r.Tip, string(r.PaymentMethod), quantity, item.UnitPrice}
+}
+
+// Encodes the values as a json array, or an empty cell when there are none
+func jsonCell[T any](values []T) string {
+	if len(values) == 0 {
+		return ""
+	}
+	// Slices of structs with only string fields always marshal
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// Sets the receipt level fields from the values of the CSVExtendedHeader columns
+func (r *Receipt) SetCSVExtendedColumns(values []string) error {
+	r.Subtotal = values[0]
+	if err := unmarshalJSONField("taxes", values[1], &r.Taxes); err != nil {
+		return err
+	}
+	if err := unmarshalJSONField("discounts", values[2], &r.Discounts); err != nil {
+		return err
+	}
+	r.Tip = values[3]
+	r.PaymentMethod = PaymentMethod(values[4])
+	return nil
+}
+
+// Sets the item's quantity and unit price from the values of the CSVExtendedHeader columns
+func (i *Item) SetCSVExtendedColumns(values []string) error {
+	quantity, err := parseQuantity(values[csvExtendedReceiptColumns])
+	if err != nil {
+		return err
+	}
+	i.Quantity = quantity
+	i.UnitPrice = values[csvExtendedReceiptColumns+1]
+	return nil
+}
+
+// Decodes a json array given as a csv cell or form value, leaving the field unset when it's empty
+func unmarshalJSONField[T any](name string, value string, field *[]T) error {
+	if value == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), field); err != nil {
+		return fmt.Errorf("%s must be a json array", name)
+	}
+	return nil
+}
+
+// Parses an item quantity given as text, 0 for an empty one
+func parseQuantity(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	quantity, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("quantity must be a number")
+	}
+	return quantity, nil
+}
+
+func (d *PurchaseDate) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var rawDate string
+	if err := decoder.DecodeElement(&rawDate, &start); err != nil {
+		return fmt.Errorf("purchaseDate field must be a string")
+	}
+
+	parsedDate, err := ParsePurchaseDate(rawDate)
+	if err != nil {
+		return err
+	}
+
+	*d = parsedDate
+	return nil
+}
+
+func (d PurchaseDate) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	return encoder.EncodeElement(d.String(), start)
+}
+
+func (t *PurchaseTime) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var rawTime string
+	if err := decoder.DecodeElement(&rawTime, &start); err != nil {
+		return fmt.Errorf("purchaseTime field must be a string")
+	}
+
+	parsedTime, err := ParsePurchaseTime(rawTime)
+	if err != nil {
+		return err
+	}
+
+	*t = parsedTime
+	return nil
+}
+
+func (t PurchaseTime) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	return encoder.EncodeElement(t.String(), start)
+}
+
+/*
+Reads a receipt in csv format. The first row must be CSVHeader, optionally followed by
+CSVExtendedHeader, and every row after it is one item. The retailer, purchaseDate,
+purchaseTime and total columns, and the receipt level extended columns, are repeated on
+every row and must be the same on all of them.
+*/
+func ReceiptFromCSV(reader io.Reader) (*Receipt, error) {
+	csvReader := csv.NewReader(reader)
+	// Every row must have as many columns as the header
+	csvReader.FieldsPerRecord = 0
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv must have a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	extended, err := CheckCSVHeader(header, CSVHeader)
+	if err != nil {
+		return nil, err
+	}
+	receiptColumns := []int{0, 1, 2, 3}
+	if extended {
+		for i := 0; i < csvExtendedReceiptColumns; i++ {
+			receiptColumns = append(receiptColumns, len(CSVHeader)+i)
+		}
+	}
+
+	var receipt *Receipt
+	var first []string
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if receipt == nil {
+			purchaseDate, err := ParsePurchaseDate(row[1])
+			if err != nil {
+				return nil, err
+			}
+			purchaseTime, err := ParsePurchaseTime(row[2])
+			if err != nil {
+				return nil, err
+			}
+			receipt = &Receipt{
+				Retailer:     row[0],
+				PurchaseDate: purchaseDate,
+				PurchaseTime: purchaseTime,
+				Total:        row[3],
+			}
+			if extended {
+				if err := receipt.SetCSVExtendedColumns(row[len(CSVHeader):]); err != nil {
+					return nil, err
+				}
+			}
+			first = row
+		} else {
+			for _, i := range receiptColumns {
+				if row[i] != first[i] {
+					return nil, fmt.Errorf("csv column %s must be the same on every row", header[i])
+				}
+			}
+		}
+
+		item := Item{ShortDescription: row[4], Price: row[5]}
+		if extended {
+			if err := item.SetCSVExtendedColumns(row[len(CSVHeader):]); err != nil {
+				return nil, err
+			}
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+
+	if receipt == nil {
+		return nil, fmt.Errorf("csv must have at least one item row")
+	}
+	return receipt, nil
+}
+
+/*
+Reads a receipt from form values. Items are given by repeating the shortDescription
+and price keys, pairing them up in the order they appear. Extended receipts may also
+have subtotal, tip and paymentMethod keys, taxes and discounts keys holding json arrays
+as in csv, and quantity and unitPrice keys repeated once for every item, empty for
+items without one.
+*/
+func ReceiptFromForm(form url.Values) (*Receipt, error) {
+	purchaseDate, err := ParsePurchaseDate(form.Get("purchaseDate"))
+	if err != nil {
+		return nil, err
+	}
+	purchaseTime, err := ParsePurchaseTime(form.Get("purchaseTime"))
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions := form["shortDescription"]
+	prices := form["price"]
+	if len(descriptions) != len(prices) {
+		return nil, fmt.Errorf("every item must have both a shortDescription and a price")
+	}
+
+	quantities := form["quantity"]
+	unitPrices := form["unitPrice"]
+	if (len(quantities) > 0 && len(quantities) != len(descriptions)) || (len(unitPrices) > 0 && len(unitPrices) != len(descriptions)) {
+		return nil, fmt.Errorf("quantity and unitPrice must be given for every item or for none")
+	}
+
+	receipt := &Receipt{
+		Retailer:      form.Get("retailer"),
+		PurchaseDate:  purchaseDate,
+		PurchaseTime:  purchaseTime,
+		Total:         form.Get("total"),
+		Subtotal:      form.Get("subtotal"),
+		Tip:           form.Get("tip"),
+		PaymentMethod: PaymentMethod(form.Get("paymentMethod")),
+	}
+	if err := unmarshalJSONField("taxes", form.Get("taxes"), &receipt.Taxes); err != nil {
+		return nil, err
+	}
+	if err := unmarshalJSONField("discounts", form.Get("discounts"), &receipt.Discounts); err != nil {
+		return nil, err
+	}
+	for i := range descriptions {
+		item := Item{ShortDescription: descriptions[i], Price: prices[i]}
+		if len(quantities) > 0 {
+			if item.Quantity, err = parseQuantity(quantities[i]); err != nil {
+				return nil, err
+			}
+		}
+		if len(unitPrices) > 0 {
+			item.UnitPrice = unitPrices[i]
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+	return receipt, nil
+}
@@ -0,0 +1,249 @@
+package models
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Same receipt as morning-receipt.json, used to compare against other formats
+func morningReceipt(t *testing.T) Receipt {
+	var receipt Receipt
+	assert.NoError(t, json.Unmarshal([]byte(`{
+					"retailer": "Walgreens",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "08:13",
+					"total": "2.65",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "1.25"},
+						{"shortDescription": "Dasani", "price": "1.40"}
+					]
+				}`), &receipt))
+	return receipt
+}
+
+// The morning receipt with every extended field set
+func extendedMorningReceipt(t *testing.T) Receipt {
+	receipt := morningReceipt(t)
+	receipt.Items[0].Quantity, receipt.Items[0].UnitPrice = 2, "0.625"
+	receipt.Subtotal, receipt.Tip, receipt.PaymentMethod = "2.65", "0.10", PaymentDebit
+	receipt.Taxes = []Tax{{Name: "Sales Tax, 5%", Amount: "0.10"}}
+	receipt.Discounts = []Discount{{Description: "Coupon", Code: "SAVE20", Amount: "0.20"}}
+	return receipt
+}
+
+func TestReceiptXML(t *testing.T) {
+	rawReceipt := `<receipt>
+			<retailer>Walgreens</retailer>
+			<purchaseDate>2022-01-02</purchaseDate>
+			<purchaseTime>08:13</purchaseTime>
+			<total>2.65</total>
+			<items>
+				<item><shortDescription>Pepsi - 12-oz</shortDescription><price>1.25</price></item>
+				<item><shortDescription>Dasani</shortDescription><price>1.40</price></item>
+			</items>
+		</receipt>`
+
+	t.Run("Unmarshal", func(t *testing.T) {
+		var receipt Receipt
+		assert.NoError(t, xml.Unmarshal([]byte(rawReceipt), &receipt))
+		receipt.XMLName = xml.Name{}
+		assert.Equal(t, morningReceipt(t), receipt)
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		expected := morningReceipt(t)
+		data, err := xml.Marshal(expected)
+		assert.NoError(t, err)
+		var receipt Receipt
+		assert.NoError(t, xml.Unmarshal(data, &receipt))
+		receipt.XMLName = xml.Name{}
+		assert.Equal(t, expected, receipt)
+	})
+
+	t.Run("ExtendedRoundTrip", func(t *testing.T) {
+		expected := morningReceipt(t)
+		expected.Items[0].Quantity, expected.Items[0].UnitPrice = 1, "1.25"
+		expected.Subtotal, expected.Tip, expected.PaymentMethod = "2.65", "0.35", PaymentDebit
+		expected.Taxes = []Tax{{Name: "Sales Tax", Amount: "0.10"}}
+		expected.Discounts = []Discount{{Code: "SAVE10", Amount: "0.10"}}
+		data, err := xml.Marshal(expected)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "<taxes><tax><name>Sales Tax</name><amount>0.10</amount></tax></taxes>")
+		var receipt Receipt
+		assert.NoError(t, xml.Unmarshal(data, &receipt))
+		receipt.XMLName = xml.Name{}
+		assert.Equal(t, expected, receipt)
+	})
+
+	t.Run("InvalidDate", func(t *testing.T) {
+		var receipt Receipt
+		assert.Error(t, xml.Unmarshal([]byte(strings.Replace(rawReceipt, "2022-01-02", "2022-01-2", 1)), &receipt))
+	})
+
+	t.Run("InvalidTime", func(t *testing.T) {
+		var receipt Receipt
+		assert.Error(t, xml.Unmarshal([]byte(strings.Replace(rawReceipt, "08:13", "8:13pm", 1)), &receipt))
+	})
+}
+
+func TestReceiptFromCSV(t *testing.T) {
+	tests := []struct {
+		testName    string
+		inputCSV    string
+		expectError bool
+	}{
+		{
+			testName: "ValidReceipt",
+			inputCSV: "retailer,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+				"Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25\n" +
+				"Walgreens,2022-01-02,08:13,2.65,Dasani,1.40\n",
+			expectError: false,
+		},
+		{
+			testName:    "Empty",
+			inputCSV:    "",
+			expectError: true,
+		},
+		{
+			testName:    "HeaderOnly",
+			inputCSV:    "retailer,purchaseDate,purchaseTime,total,shortDescription,price\n",
+			expectError: true,
+		},
+		{
+			testName: "WrongHeader",
+			inputCSV: "store,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+				"Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25\n",
+			expectError: true,
+		},
+		{
+			testName: "MissingColumn",
+			inputCSV: "retailer,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+				"Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz\n",
+			expectError: true,
+		},
+		{
+			testName: "InconsistentTotal",
+			inputCSV: "retailer,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+				"Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25\n" +
+				"Walgreens,2022-01-02,08:13,2.66,Dasani,1.40\n",
+			expectError: true,
+		},
+		{
+			testName: "InvalidDate",
+			inputCSV: "retailer,purchaseDate,purchaseTime,total,shortDescription,price\n" +
+				"Walgreens,2022-1-02,08:13,2.65,Pepsi - 12-oz,1.25\n",
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			receipt, err := ReceiptFromCSV(strings.NewReader(test.inputCSV))
+			if test.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, morningReceipt(t), *receipt)
+			}
+		})
+	}
+}
+
+func TestReceiptFromCSVExtended(t *testing.T) {
+	header := strings.Join(append(CSVHeader, CSVExtendedHeader...), ",") + "\n"
+	receiptColumns := `2.65,"[{""name"":""Sales Tax, 5%"",""amount"":""0.10""}]","[{""description"":""Coupon"",""code"":""SAVE20"",""amount"":""0.20""}]",0.10,debit`
+	validCSV := header +
+		"Walgreens,2022-01-02,08:13,2.65,Pepsi - 12-oz,1.25," + receiptColumns + ",2,0.625\n" +
+		"Walgreens,2022-01-02,08:13,2.65,Dasani,1.40," + receiptColumns + ",,\n"
+
+	receipt, err := ReceiptFromCSV(strings.NewReader(validCSV))
+	assert.NoError(t, err)
+	assert.Equal(t, extendedMorningReceipt(t), *receipt)
+
+	tests := []struct {
+		testName string
+		inputCSV string
+	}{
+		{testName: "InconsistentTip", inputCSV: strings.Replace(validCSV, ",0.10,debit,,", ",0.20,debit,,", 1)},
+		{testName: "InvalidTaxes", inputCSV: strings.Replace(validCSV, `"[{""name""`, `"{""name""`, 1)},
+		{testName: "InvalidQuantity", inputCSV: strings.Replace(validCSV, ",2,0.625", ",two,0.625", 1)},
+		{testName: "PartialExtendedHeader", inputCSV: strings.Replace(validCSV, ",quantity,unitPrice", "", 1)},
+		{testName: "MissingExtendedColumns", inputCSV: strings.Replace(validCSV, ",,\n", "\n", 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			_, err := ReceiptFromCSV(strings.NewReader(test.inputCSV))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestReceiptFromForm(t *testing.T) {
+	validForm := func() url.Values {
+		return url.Values{
+			"retailer":         {"Walgreens"},
+			"purchaseDate":     {"2022-01-02"},
+			"purchaseTime":     {"08:13"},
+			"total":            {"2.65"},
+			"shortDescription": {"Pepsi - 12-oz", "Dasani"},
+			"price":            {"1.25", "1.40"},
+		}
+	}
+
+	t.Run("ValidReceipt", func(t *testing.T) {
+		receipt, err := ReceiptFromForm(validForm())
+		assert.NoError(t, err)
+		assert.Equal(t, morningReceipt(t), *receipt)
+	})
+
+	t.Run("MismatchedItems", func(t *testing.T) {
+		form := validForm()
+		form["price"] = []string{"1.25"}
+		_, err := ReceiptFromForm(form)
+		assert.Error(t, err)
+	})
+
+	t.Run("MissingTime", func(t *testing.T) {
+		form := validForm()
+		form.Del("purchaseTime")
+		_, err := ReceiptFromForm(form)
+		assert.Error(t, err)
+	})
+
+	extendedForm := func() url.Values {
+		form := validForm()
+		form.Set("subtotal", "2.65")
+		form.Set("taxes", `[{"name": "Sales Tax, 5%", "amount": "0.10"}]`)
+		form.Set("discounts", `[{"description": "Coupon", "code": "SAVE20", "amount": "0.20"}]`)
+		form.Set("tip", "0.10")
+		form.Set("paymentMethod", "debit")
+		form["quantity"] = []string{"2", ""}
+		form["unitPrice"] = []string{"0.625", ""}
+		return form
+	}
+
+	t.Run("ExtendedReceipt", func(t *testing.T) {
+		receipt, err := ReceiptFromForm(extendedForm())
+		assert.NoError(t, err)
+		assert.Equal(t, extendedMorningReceipt(t), *receipt)
+	})
+
+	t.Run("QuantityForSomeItems", func(t *testing.T) {
+		form := extendedForm()
+		form["quantity"] = []string{"2"}
+		_, err := ReceiptFromForm(form)
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidDiscounts", func(t *testing.T) {
+		form := extendedForm()
+		form.Set("discounts", "SAVE20")
+		_, err := ReceiptFromForm(form)
+		assert.Error(t, err)
+	})
+}
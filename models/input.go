@@ -2,7 +2,9 @@ package models
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -32,16 +34,120 @@ type PurchaseTime struct {
 }
 
 type Receipt struct {
-	Retailer     string       `json:"retailer"`
-	PurchaseDate PurchaseDate `json:"purchaseDate"`
-	PurchaseTime PurchaseTime `json:"purchaseTime"`
-	Items        []Item       `json:"items"`
-	Total        string       `json:"total"`
+	XMLName      xml.Name     `json:"-" xml:"receipt"`
+	Retailer     string       `json:"retailer" xml:"retailer"`
+	PurchaseDate PurchaseDate `json:"purchaseDate" xml:"purchaseDate"`
+	PurchaseTime PurchaseTime `json:"purchaseTime" xml:"purchaseTime"`
+	Items        []Item       `json:"items" xml:"items>item"`
+	Total        string       `json:"total" xml:"total"`
+
+	// Optional breakdown of Total for receipts that print one, never used to score the receipt.
+	// Once any of Subtotal, Taxes, Discounts or Tip is given they must reconcile with Total.
+
+	// Sum of the items' prices, before or after discounts
+	Subtotal  string     `json:"subtotal,omitempty" xml:"subtotal,omitempty"`
+	Taxes     []Tax      `json:"taxes,omitempty" xml:"taxes>tax"`
+	Discounts []Discount `json:"discounts,omitempty" xml:"discounts>discount"`
+	Tip       string     `json:"tip,omitempty" xml:"tip,omitempty"`
+	// How the receipt was paid, one of PaymentMethods
+	PaymentMethod PaymentMethod `json:"paymentMethod,omitempty" xml:"paymentMethod,omitempty"`
+	// Set from Retailer when the receipt is ingested, replacing any canonical retailer given with the receipt
+	CanonicalRetailer *CanonicalRetailer `json:"canonicalRetailer,omitempty" xml:"canonicalRetailer,omitempty"`
+}
+
+/*
+Registered retailer a receipt's retailer was matched to, so receipts from one store
+spelled differently, such as "M&M Corner Market" and "M & M Corner Mkt", count as the
+same retailer.
+*/
+type CanonicalRetailer struct {
+	Id   string `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+// Returns the canonical retailer's id, or the retailer in lower case when it hasn't been matched to one
+func (r *Receipt) RetailerId() string {
+	if r.CanonicalRetailer != nil {
+		return r.CanonicalRetailer.Id
+	}
+	return strings.ToLower(strings.TrimSpace(r.Retailer))
+}
+
+// Returns the canonical retailer's name, or the retailer as given when it hasn't been matched to one
+func (r *Receipt) RetailerName() string {
+	if r.CanonicalRetailer != nil {
+		return r.CanonicalRetailer.Name
+	}
+	return strings.TrimSpace(r.Retailer)
 }
 
 type Item struct {
-	ShortDescription string `json:"shortDescription"`
-	Price            string `json:"price"`
+	ShortDescription string `json:"shortDescription" xml:"shortDescription"`
+	Price            string `json:"price" xml:"price"`
+	// Number of units bought, which can be fractional for items sold by weight. 0 when not printed.
+	Quantity float64 `json:"quantity,omitempty" xml:"quantity,omitempty"`
+	// Price of one unit. When both are given, Price must be Quantity times UnitPrice.
+	UnitPrice string `json:"unitPrice,omitempty" xml:"unitPrice,omitempty"`
+	// Set from ShortDescription when the receipt is stored, replacing any product given with the item
+	Product *Product `json:"product,omitempty" xml:"product,omitempty"`
+}
+
+/*
+Returns how many units the item is counted as, its quantity when that is a whole number
+of units, or 1 when no quantity is printed or the item is sold by weight.
+*/
+func (item Item) Units() int {
+	if item.Quantity >= 1 && item.Quantity == math.Trunc(item.Quantity) {
+		return int(item.Quantity)
+	}
+	return 1
+}
+
+// A tax line added to the receipt's total
+type Tax struct {
+	// Name of the tax as printed, such as "Sales Tax 5.5%"
+	Name   string `json:"name,omitempty" xml:"name,omitempty"`
+	Amount string `json:"amount" xml:"amount"`
+}
+
+// A discount or coupon taken off the receipt's total
+type Discount struct {
+	Description string `json:"description,omitempty" xml:"description,omitempty"`
+	// Code of the coupon the discount was given for, empty for other discounts
+	Code string `json:"code,omitempty" xml:"code,omitempty"`
+	// Amount taken off, as a positive price
+	Amount string `json:"amount" xml:"amount"`
+}
+
+// How a receipt was paid
+type PaymentMethod string
+
+const (
+	PaymentCash     PaymentMethod = "cash"
+	PaymentCredit   PaymentMethod = "credit"
+	PaymentDebit    PaymentMethod = "debit"
+	PaymentGiftCard PaymentMethod = "gift-card"
+	PaymentMobile   PaymentMethod = "mobile"
+	PaymentCheck    PaymentMethod = "check"
+	PaymentOther    PaymentMethod = "other"
+)
+
+// Every payment method a receipt can have
+var PaymentMethods = []PaymentMethod{PaymentCash, PaymentCredit, PaymentDebit, PaymentGiftCard, PaymentMobile, PaymentCheck, PaymentOther}
+
+/*
+Canonical product an item's free text description was normalized to, so items
+described differently, such as "Klarbrunn 12-PK 12 FL OZ" and "KLARBRUNN 12 PACK 12oz",
+count as the same product.
+*/
+type Product struct {
+	// Identifies the product, made of its lower case name, size and pack
+	Id   string `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+	// Size of one unit with its unit, such as "12 fl oz", empty when the description has none
+	Size string `json:"size,omitempty" xml:"size,omitempty"`
+	// Number of units in a pack, 0 when the description has none
+	Pack int `json:"pack,omitempty" xml:"pack,omitempty"`
 }
 
 /*
@@ -89,15 +195,28 @@ func (d *PurchaseDate) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("purchaseDate field must be a string")
 	}
 
-	parsedDate, err := time.Parse(DateLayout, rawDate)
+	parsedDate, err := ParsePurchaseDate(rawDate)
 	if err != nil {
-		return fmt.Errorf("purchaseDate must be in format YYYY-MM-DD")
+		return err
 	}
 
-	d.Date = parsedDate
+	*d = parsedDate
 	return nil
 }
 
+// Parses a purchaseDate string in format YYYY-MM-DD.
+func ParsePurchaseDate(rawDate string) (PurchaseDate, error) {
+	parsedDate, err := time.Parse(DateLayout, rawDate)
+	if err != nil {
+		return PurchaseDate{}, fmt.Errorf("purchaseDate must be in format YYYY-MM-DD")
+	}
+	return PurchaseDate{Date: parsedDate}, nil
+}
+
+func (d PurchaseDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
 func (d PurchaseDate) String() string {
 	return fmt.Sprint(d.Date.Format(DateLayout))
 }
@@ -109,15 +228,28 @@ func (t *PurchaseTime) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("purchaseTime field must be a string")
 	}
 
-	parsedTime, err := time.Parse(TimeLayout, rawTime)
+	parsedTime, err := ParsePurchaseTime(rawTime)
 	if err != nil {
-		return fmt.Errorf("purchaseTime must be in format HH:MM")
+		return err
 	}
 
-	t.Time = parsedTime
+	*t = parsedTime
 	return nil
 }
 
+// Parses a purchaseTime string in format HH:MM.
+func ParsePurchaseTime(rawTime string) (PurchaseTime, error) {
+	parsedTime, err := time.Parse(TimeLayout, rawTime)
+	if err != nil {
+		return PurchaseTime{}, fmt.Errorf("purchaseTime must be in format HH:MM")
+	}
+	return PurchaseTime{Time: parsedTime}, nil
+}
+
+func (p PurchaseTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
 func (p PurchaseTime) String() string {
 	return fmt.Sprint(p.Time.Format(TimeLayout))
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -56,6 +57,25 @@ func TestGetDollars(t *testing.T) {
 	}
 }
 
+func TestItemUnits(t *testing.T) {
+	tests := []struct {
+		testName      string
+		quantity      float64
+		expectedUnits int
+	}{
+		{testName: "NotPrinted", quantity: 0, expectedUnits: 1},
+		{testName: "WholeUnits", quantity: 3, expectedUnits: 3},
+		{testName: "SoldByWeight", quantity: 1.5, expectedUnits: 1},
+		{testName: "LessThanOne", quantity: 0.25, expectedUnits: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Equal(t, test.expectedUnits, Item{Quantity: test.quantity}.Units())
+		})
+	}
+}
+
 func TestGetCents(t *testing.T) {
 	tests := []struct {
 		testName      string
@@ -105,3 +125,18 @@ func TestGetCents(t *testing.T) {
 		})
 	}
 }
+
+// Testing that a marshalled receipt unmarshals back to the same receipt
+func TestReceiptJSONRoundTrip(t *testing.T) {
+	rawReceipt := `{"retailer":"Walgreens","purchaseDate":"2022-01-02","purchaseTime":"08:13","items":[{"shortDescription":"Pepsi - 12-oz","price":"1.25"},{"shortDescription":"Dasani","price":"1.40"}],"total":"2.65"}`
+	var receipt Receipt
+	assert.NoError(t, json.Unmarshal([]byte(rawReceipt), &receipt))
+
+	data, err := json.Marshal(receipt)
+	assert.NoError(t, err)
+	assert.JSONEq(t, rawReceipt, string(data))
+
+	var roundTripped Receipt
+	assert.NoError(t, json.Unmarshal(data, &roundTripped))
+	assert.Equal(t, receipt, roundTripped)
+}
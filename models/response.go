@@ -1,11 +1,15 @@
 package models
 
+import "encoding/xml"
+
 // This file contains structs used for api responses in handlers package.
 
 type Id struct {
-	Id string `json:"id"`
+	XMLName xml.Name `json:"-" xml:"id"`
+	Id      string   `json:"id" xml:",chardata"`
 }
 
 type Points struct {
-	Points int `json:"points"`
+	XMLName xml.Name `json:"-" xml:"points"`
+	Points  int      `json:"points" xml:",chardata"`
 }
@@ -2,13 +2,26 @@ package models
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
 )
 
 const (
 	RetailerRegex         string = "^[\\w\\s\\-&]+$"
 	ShortDescriptionRegex string = "^[\\w\\s\\-]+$"
 	PriceRegex            string = "^\\d+\\.\\d{2}$"
+
+	MaxItems                  int     = 1000
+	MaxRetailerLength         int     = 256
+	MaxShortDescriptionLength int     = 256
+	MaxPriceLength            int     = 32
+	MaxTaxes                  int     = 100
+	MaxDiscounts              int     = 100
+	MaxQuantity               float64 = 100000
+
+	// Cents the extended fields' arithmetic may be off by, such as from rounding each line, and still reconcile
+	ReconcileToleranceCents int = 2
 )
 
 /*
@@ -21,30 +34,156 @@ in case anyone in future calls this function on an instance of Receipt that
 wasn't created through json.Unmarshal
 */
 func (r *Receipt) Validate() error {
+	if len(r.Retailer) > MaxRetailerLength {
+		return fmt.Errorf("retailer must be at most %d characters", MaxRetailerLength)
+	}
 	if !regexp.MustCompile(RetailerRegex).MatchString(r.Retailer) {
 		return fmt.Errorf("invalid retailer format")
 	}
 	if r.PurchaseDate.Date.IsZero() {
 		return fmt.Errorf("invalid purchase date format")
 	}
-	if r.PurchaseDate.Date.IsZero() {
+	if r.PurchaseTime.Time.IsZero() {
 		return fmt.Errorf("invalid purchase time format")
 	}
-	if !regexp.MustCompile(PriceRegex).MatchString(r.Total) {
+	if len(r.Total) > MaxPriceLength || !regexp.MustCompile(PriceRegex).MatchString(r.Total) {
 		return fmt.Errorf("invalid total format")
 	}
 
 	if len(r.Items) == 0 {
 		return fmt.Errorf("there must be at least one item in receipt")
 	}
+	if len(r.Items) > MaxItems {
+		return fmt.Errorf("there must be at most %d items in receipt", MaxItems)
+	}
 	for _, item := range r.Items {
+		if len(item.ShortDescription) > MaxShortDescriptionLength {
+			return fmt.Errorf("item short description must be at most %d characters", MaxShortDescriptionLength)
+		}
 		if !regexp.MustCompile(ShortDescriptionRegex).MatchString(item.ShortDescription) {
 			return fmt.Errorf("invalid item short description format")
 		}
-		if !regexp.MustCompile(PriceRegex).MatchString(item.Price) {
+		if len(item.Price) > MaxPriceLength || !regexp.MustCompile(PriceRegex).MatchString(item.Price) {
 			return fmt.Errorf("invalid item price format")
 		}
+		if err := item.validateQuantity(); err != nil {
+			return err
+		}
+	}
+
+	return r.reconcile()
+}
+
+// Returns whether the price is a valid price no longer than MaxPriceLength
+func isPrice(price string) bool {
+	return len(price) <= MaxPriceLength && regexp.MustCompile(PriceRegex).MatchString(price)
+}
+
+// Returns a valid price in cents
+func priceCents(price string) int {
+	return GetDollars(price)*100 + GetCents(price)
+}
+
+// Formats cents as a price, such as "-1.05"
+func formatCents(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// Returns whether two amounts in cents are within ReconcileToleranceCents of each other
+func reconciles(a int, b int) bool {
+	return max(a-b, b-a) <= ReconcileToleranceCents
+}
+
+// Validates the item's optional quantity and unit price, and that they multiply to its price
+func (item Item) validateQuantity() error {
+	if item.Quantity < 0 || item.Quantity > MaxQuantity || math.IsNaN(item.Quantity) {
+		return fmt.Errorf("item quantity must be from 0 to %g", MaxQuantity)
+	}
+	if item.UnitPrice == "" {
+		return nil
+	}
+	if !isPrice(item.UnitPrice) {
+		return fmt.Errorf("invalid item unit price format")
+	}
+	if item.Quantity == 0 {
+		return nil
+	}
+	expected := int(math.Round(item.Quantity * float64(priceCents(item.UnitPrice))))
+	if !reconciles(expected, priceCents(item.Price)) {
+		return fmt.Errorf("item price %s must be quantity %g times unit price %s, %s", item.Price, item.Quantity, item.UnitPrice, formatCents(expected))
+	}
+	return nil
+}
+
+/*
+Validates the optional subtotal, taxes, discounts, tip and payment method, and once
+any of the first four is given, that they reconcile with the total: the items' prices,
+less discounts, plus taxes and tip. The subtotal may be printed before or after
+discounts. Receipts in the original format, with only items and a total, are not
+reconciled, so their items don't have to add up to their total.
+*/
+func (r *Receipt) reconcile() error {
+	if r.PaymentMethod != "" && !slices.Contains(PaymentMethods, r.PaymentMethod) {
+		return fmt.Errorf("payment method must be one of cash, credit, debit, gift-card, mobile, check or other")
+	}
+	if r.Subtotal != "" && !isPrice(r.Subtotal) {
+		return fmt.Errorf("invalid subtotal format")
+	}
+	if r.Tip != "" && !isPrice(r.Tip) {
+		return fmt.Errorf("invalid tip format")
+	}
+	if len(r.Taxes) > MaxTaxes {
+		return fmt.Errorf("there must be at most %d taxes in receipt", MaxTaxes)
+	}
+	if len(r.Discounts) > MaxDiscounts {
+		return fmt.Errorf("there must be at most %d discounts in receipt", MaxDiscounts)
+	}
+
+	taxes := 0
+	for _, tax := range r.Taxes {
+		if len(tax.Name) > MaxShortDescriptionLength {
+			return fmt.Errorf("tax name must be at most %d characters", MaxShortDescriptionLength)
+		}
+		if !isPrice(tax.Amount) {
+			return fmt.Errorf("invalid tax amount format")
+		}
+		taxes += priceCents(tax.Amount)
+	}
+	discounts := 0
+	for _, discount := range r.Discounts {
+		if len(discount.Description) > MaxShortDescriptionLength || len(discount.Code) > MaxShortDescriptionLength {
+			return fmt.Errorf("discount description and code must be at most %d characters", MaxShortDescriptionLength)
+		}
+		if !isPrice(discount.Amount) {
+			return fmt.Errorf("invalid discount amount format")
+		}
+		discounts += priceCents(discount.Amount)
 	}
 
+	if r.Subtotal == "" && r.Tip == "" && len(r.Taxes) == 0 && len(r.Discounts) == 0 {
+		return nil
+	}
+	items := 0
+	for _, item := range r.Items {
+		items += priceCents(item.Price)
+	}
+	if r.Subtotal != "" {
+		subtotal := priceCents(r.Subtotal)
+		if !reconciles(subtotal, items) && !reconciles(subtotal, items-discounts) {
+			return fmt.Errorf("subtotal %s must be the sum of item prices, %s, or that less discounts, %s", r.Subtotal, formatCents(items), formatCents(items-discounts))
+		}
+	}
+	tip := 0
+	if r.Tip != "" {
+		tip = priceCents(r.Tip)
+	}
+	expected := items - discounts + taxes + tip
+	if !reconciles(priceCents(r.Total), expected) {
+		return fmt.Errorf("total %s must be item prices less discounts plus taxes and tip, %s", r.Total, formatCents(expected))
+	}
 	return nil
 }
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -521,3 +522,112 @@ func TestValidateReceipt(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateReceiptLimits(t *testing.T) {
+	validReceipt := func() Receipt {
+		var receipt Receipt
+		err := json.Unmarshal([]byte(`{
+					"retailer": "Target",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "13:13",
+					"total": "1.25",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}
+					]
+				}`), &receipt)
+		assert.NoError(t, err)
+		return receipt
+	}
+
+	t.Run("RetailerAtLimit", func(t *testing.T) {
+		receipt := validReceipt()
+		receipt.Retailer = strings.Repeat("a", MaxRetailerLength)
+		assert.NoError(t, receipt.Validate())
+	})
+
+	t.Run("RetailerTooLong", func(t *testing.T) {
+		receipt := validReceipt()
+		receipt.Retailer = strings.Repeat("a", MaxRetailerLength+1)
+		assert.Error(t, receipt.Validate())
+	})
+
+	t.Run("ShortDescriptionTooLong", func(t *testing.T) {
+		receipt := validReceipt()
+		receipt.Items[0].ShortDescription = strings.Repeat("a", MaxShortDescriptionLength+1)
+		assert.Error(t, receipt.Validate())
+	})
+
+	t.Run("PriceTooLong", func(t *testing.T) {
+		receipt := validReceipt()
+		receipt.Items[0].Price = strings.Repeat("1", MaxPriceLength) + ".00"
+		assert.Error(t, receipt.Validate())
+	})
+
+	t.Run("ItemsAtLimit", func(t *testing.T) {
+		receipt := validReceipt()
+		for len(receipt.Items) < MaxItems {
+			receipt.Items = append(receipt.Items, receipt.Items[0])
+		}
+		assert.NoError(t, receipt.Validate())
+	})
+
+	t.Run("TooManyItems", func(t *testing.T) {
+		receipt := validReceipt()
+		for len(receipt.Items) <= MaxItems {
+			receipt.Items = append(receipt.Items, receipt.Items[0])
+		}
+		assert.Error(t, receipt.Validate())
+	})
+}
+
+func TestValidateExtendedReceipt(t *testing.T) {
+	// Items of 2 x 1.25 and 1.40 less a 0.40 coupon, 0.25 of tax and a 1.00 tip
+	const extendedReceipt = `{
+					"retailer": "Walgreens",
+					"purchaseDate": "2022-01-02",
+					"purchaseTime": "08:13",
+					"items": [
+						{"shortDescription": "Pepsi - 12-oz", "price": "2.50", "quantity": 2, "unitPrice": "1.25"},
+						{"shortDescription": "Bananas", "price": "1.40", "quantity": 2.8, "unitPrice": "0.50"}
+					],
+					"subtotal": "3.90",
+					"discounts": [{"description": "Pepsi coupon", "code": "PEPSI40", "amount": "0.40"}],
+					"taxes": [{"name": "Sales Tax", "amount": "0.25"}],
+					"tip": "1.00",
+					"paymentMethod": "credit",
+					"total": "4.75"
+				}`
+
+	tests := []struct {
+		testName      string
+		replacements  []string
+		expectedError string
+	}{
+		{testName: "Reconciles"},
+		{testName: "SubtotalAfterDiscounts", replacements: []string{`"subtotal": "3.90"`, `"subtotal": "3.50"`}},
+		{testName: "WithinTolerance", replacements: []string{`"total": "4.75"`, `"total": "4.77"`}},
+		{testName: "OnlyPaymentMethod", replacements: []string{`"subtotal": "3.90",`, ``, `"discounts": [{"description": "Pepsi coupon", "code": "PEPSI40", "amount": "0.40"}],`, ``, `"taxes": [{"name": "Sales Tax", "amount": "0.25"}],`, ``, `"tip": "1.00",`, ``, `"total": "4.75"`, `"total": "100.00"`}},
+		{testName: "TotalOff", replacements: []string{`"total": "4.75"`, `"total": "4.78"`}, expectedError: "total 4.78 must be item prices less discounts plus taxes and tip, 4.75"},
+		{testName: "SubtotalOff", replacements: []string{`"subtotal": "3.90"`, `"subtotal": "3.70"`}, expectedError: "subtotal 3.70 must be the sum of item prices, 3.90, or that less discounts, 3.50"},
+		{testName: "QuantityOff", replacements: []string{`"quantity": 2,`, `"quantity": 3,`}, expectedError: "item price 2.50 must be quantity 3 times unit price 1.25, 3.75"},
+		{testName: "NegativeQuantity", replacements: []string{`"quantity": 2,`, `"quantity": -2,`}, expectedError: "item quantity"},
+		{testName: "InvalidUnitPrice", replacements: []string{`"unitPrice": "1.25"`, `"unitPrice": "1.2"`}, expectedError: "invalid item unit price format"},
+		{testName: "InvalidTax", replacements: []string{`"amount": "0.25"`, `"amount": "-0.25"`}, expectedError: "invalid tax amount format"},
+		{testName: "InvalidDiscount", replacements: []string{`"amount": "0.40"`, `"amount": "40"`}, expectedError: "invalid discount amount format"},
+		{testName: "InvalidTip", replacements: []string{`"tip": "1.00"`, `"tip": "1"`}, expectedError: "invalid tip format"},
+		{testName: "InvalidPaymentMethod", replacements: []string{`"credit"`, `"barter"`}, expectedError: "payment method must be one of"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			var receipt Receipt
+			assert.NoError(t, json.Unmarshal([]byte(strings.NewReplacer(test.replacements...).Replace(extendedReceipt)), &receipt))
+			err := receipt.Validate()
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
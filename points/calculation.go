@@ -2,12 +2,25 @@ package points
 
 import "receipts/models"
 
-func CalculatePoints(receipt *models.Receipt) int {
-	points := 0
+// Points a single rule awarded a receipt
+type RulePoints struct {
+	Rule   string `json:"rule"`
+	Points int    `json:"points"`
+	// Id of the promotion campaign that awarded the points, empty for rules of a rule set
+	Campaign string `json:"campaign,omitempty"`
+	// Name of the membership tier whose multiplier awarded the points, empty for rules of a rule set
+	Tier string `json:"tier,omitempty"`
+}
 
-	for _, rule := range GetReceiptRules() {
-		points = points + rule(receipt)
-	}
+// Calculates points with the rule set in effect on the receipt's purchaseDate
+func CalculatePoints(receipt *models.Receipt) int {
+	return EffectiveRuleSet(receipt.PurchaseDate.Date).CalculatePoints(receipt)
+}
 
-	return points
+/*
+Returns the points each rule of the rule set in effect on the receipt's purchaseDate
+awards the receipt. The sum of the breakdown is always equal to CalculatePoints.
+*/
+func CalculateBreakdown(receipt *models.Receipt) []RulePoints {
+	return EffectiveRuleSet(receipt.PurchaseDate.Date).CalculateBreakdown(receipt)
 }
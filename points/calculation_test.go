@@ -94,3 +94,50 @@ func TestCalculatePoints(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateBreakdown(t *testing.T) {
+	rawReceipt := `{
+						"retailer": "M&M Corner Market",
+						"purchaseDate": "2022-03-20",
+						"purchaseTime": "14:33",
+						"items": [
+							{"shortDescription": "Gatorade", "price": "2.25"},
+							{"shortDescription": "Gatorade", "price": "2.25"},
+							{"shortDescription": "Gatorade", "price": "2.25"},
+							{"shortDescription": "Gatorade", "price": "2.25"}
+						],
+						"total": "9.00"
+					}`
+	var receipt models.Receipt
+	assert.NoError(t, json.Unmarshal([]byte(rawReceipt), &receipt))
+
+	breakdown := CalculateBreakdown(&receipt)
+	assert.Equal(t, []RulePoints{
+		{Rule: "RetailerRule", Points: 14},
+		{Rule: "TotalRoundRule", Points: 50},
+		{Rule: "TotalMultipleRule", Points: 25},
+		{Rule: "NumItemsRule", Points: 10},
+		{Rule: "ItemDescriptionRule", Points: 0},
+		{Rule: "PurchaseDayRule", Points: 0},
+		{Rule: "PurchaseTimeRule", Points: 10},
+	}, breakdown)
+
+	total := 0
+	for _, rulePoints := range breakdown {
+		total += rulePoints.Points
+	}
+	assert.Equal(t, CalculatePoints(&receipt), total)
+
+	// The extended fields breaking the total down don't change how the receipt is scored
+	extended := receipt
+	extended.Items = nil
+	for _, item := range receipt.Items {
+		item.Quantity, item.UnitPrice = 1, item.Price
+		extended.Items = append(extended.Items, item)
+	}
+	extended.Subtotal, extended.Tip, extended.PaymentMethod = "9.00", "1.00", models.PaymentCash
+	extended.Discounts = []models.Discount{{Description: "Coupon", Amount: "1.50"}}
+	extended.Taxes = []models.Tax{{Name: "Sales Tax", Amount: "0.50"}}
+	assert.NoError(t, extended.Validate())
+	assert.Equal(t, breakdown, CalculateBreakdown(&extended))
+}
@@ -0,0 +1,122 @@
+package points
+
+import (
+	"math"
+	"receipts/models"
+	"strings"
+)
+
+/*
+One step of a rule set. Stages run in order, each one given the receipt and the
+running total of the stages before it, and the points a stage reports in a breakdown
+are how much it changed the total. When Stop is true no later stage runs.
+*/
+type Stage struct {
+	Name  string
+	Apply func(receipt *models.Receipt, total int) (newTotal int, stop bool)
+}
+
+// Given a receipt, returns the factor to multiply points by
+type Multiplier func(*models.Receipt) float64
+
+// Given a receipt, returns whether it meets some condition
+type Condition func(*models.Receipt) bool
+
+// Adds the rule's points to the total
+func AddStage(namedRule NamedReceiptRule) Stage {
+	return Stage{
+		Name: namedRule.Name,
+		Apply: func(receipt *models.Receipt, total int) (int, bool) {
+			return total + namedRule.Rule(receipt), false
+		},
+	}
+}
+
+// Returns an additive stage for each rule, in order
+func AddStages(namedRules []NamedReceiptRule) []Stage {
+	stages := make([]Stage, 0, len(namedRules))
+	for _, namedRule := range namedRules {
+		stages = append(stages, AddStage(namedRule))
+	}
+	return stages
+}
+
+// Multiplies the total by the factor, rounded to the nearest point
+func MultiplyStage(name string, multiplier Multiplier) Stage {
+	return Stage{
+		Name: name,
+		Apply: func(receipt *models.Receipt, total int) (int, bool) {
+			return int(math.Round(float64(total) * multiplier(receipt))), false
+		},
+	}
+}
+
+// Lowers the total to max when it is above it
+func CapStage(name string, max int) Stage {
+	return Stage{
+		Name: name,
+		Apply: func(receipt *models.Receipt, total int) (int, bool) {
+			return min(total, max), false
+		},
+	}
+}
+
+// Raises the total to minimum when it is below it
+func MinimumStage(name string, minimum int) Stage {
+	return Stage{
+		Name: name,
+		Apply: func(receipt *models.Receipt, total int) (int, bool) {
+			return max(total, minimum), false
+		},
+	}
+}
+
+// When the condition holds, sets the total to points and skips every later stage
+func ShortCircuitStage(name string, condition Condition, points int) Stage {
+	return Stage{
+		Name: name,
+		Apply: func(receipt *models.Receipt, total int) (int, bool) {
+			if condition(receipt) {
+				return points, true
+			}
+			return total, false
+		},
+	}
+}
+
+// Returns a rule awarding at most max of the rule's points
+func CapRule(rule ReceiptRule, max int) ReceiptRule {
+	return func(receipt *models.Receipt) int {
+		return min(rule(receipt), max)
+	}
+}
+
+// Returns a rule awarding at least minimum, or the rule's points if they are more
+func MinimumRule(rule ReceiptRule, minimum int) ReceiptRule {
+	return func(receipt *models.Receipt) int {
+		return max(rule(receipt), minimum)
+	}
+}
+
+// Returns a multiplier that is always factor
+func ConstantMultiplier(factor float64) Multiplier {
+	return func(*models.Receipt) float64 {
+		return factor
+	}
+}
+
+/*
+Returns a condition that holds for receipts from any of the retailers, compared with
+the receipt's retailer and the name of its canonical retailer ignoring case and surrounding spaces
+*/
+func RetailerIs(retailers ...string) Condition {
+	return func(receipt *models.Receipt) bool {
+		for _, retailer := range retailers {
+			retailer = strings.TrimSpace(retailer)
+			if strings.EqualFold(retailer, strings.TrimSpace(receipt.Retailer)) || strings.EqualFold(retailer, receipt.RetailerName()) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,112 @@
+package points
+
+import (
+	"receipts/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposedRuleSet(t *testing.T) {
+	// RetailerRule awards 6 for Target and 9 for Walgreens
+	retailer := NamedReceiptRule{Name: "RetailerRule", Rule: RetailerRule}
+	flat := NamedReceiptRule{Name: "FlatRule", Rule: func(*models.Receipt) int { return 10 }}
+
+	tests := []struct {
+		testName          string
+		stages            []Stage
+		retailer          string
+		expectedBreakdown []RulePoints
+	}{
+		{
+			testName:          "Additive",
+			stages:            AddStages([]NamedReceiptRule{retailer, flat}),
+			retailer:          "Target",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 6}, {Rule: "FlatRule", Points: 10}},
+		},
+		{
+			testName:          "MultiplyRunningTotal",
+			stages:            []Stage{AddStage(retailer), MultiplyStage("Double", ConstantMultiplier(2)), AddStage(flat)},
+			retailer:          "Target",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 6}, {Rule: "Double", Points: 6}, {Rule: "FlatRule", Points: 10}},
+		},
+		{
+			testName:          "MultiplyRounds",
+			stages:            []Stage{AddStage(retailer), MultiplyStage("Half", ConstantMultiplier(0.25))},
+			retailer:          "Walgreens",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 9}, {Rule: "Half", Points: -7}},
+		},
+		{
+			testName:          "CapTotal",
+			stages:            []Stage{AddStage(retailer), AddStage(flat), CapStage("Cap", 12)},
+			retailer:          "Target",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 6}, {Rule: "FlatRule", Points: 10}, {Rule: "Cap", Points: -4}},
+		},
+		{
+			testName:          "CapNotReached",
+			stages:            []Stage{AddStage(retailer), CapStage("Cap", 12)},
+			retailer:          "Target",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 6}, {Rule: "Cap", Points: 0}},
+		},
+		{
+			testName:          "MinimumTotal",
+			stages:            []Stage{AddStage(retailer), MinimumStage("Minimum", 8)},
+			retailer:          "Target",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 6}, {Rule: "Minimum", Points: 2}},
+		},
+		{
+			testName:          "CapRule",
+			stages:            AddStages([]NamedReceiptRule{{Name: "RetailerRule", Rule: CapRule(RetailerRule, 7)}, flat}),
+			retailer:          "Walgreens",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 7}, {Rule: "FlatRule", Points: 10}},
+		},
+		{
+			testName:          "MinimumRule",
+			stages:            AddStages([]NamedReceiptRule{{Name: "RetailerRule", Rule: MinimumRule(RetailerRule, 8)}}),
+			retailer:          "Target",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 8}},
+		},
+		{
+			testName:          "ShortCircuitExcludedRetailer",
+			stages:            []Stage{ShortCircuitStage("Excluded", RetailerIs("walgreens", "CVS"), 0), AddStage(retailer), AddStage(flat)},
+			retailer:          " Walgreens ",
+			expectedBreakdown: []RulePoints{{Rule: "Excluded", Points: 0}},
+		},
+		{
+			testName:          "ShortCircuitNotTaken",
+			stages:            []Stage{ShortCircuitStage("Excluded", RetailerIs("Walgreens"), 0), AddStage(retailer)},
+			retailer:          "Target",
+			expectedBreakdown: []RulePoints{{Rule: "Excluded", Points: 0}, {Rule: "RetailerRule", Points: 6}},
+		},
+		{
+			testName:          "ShortCircuitAfterRules",
+			stages:            []Stage{AddStage(retailer), ShortCircuitStage("Flat", RetailerIs("Target"), 1), AddStage(flat)},
+			retailer:          "Target",
+			expectedBreakdown: []RulePoints{{Rule: "RetailerRule", Points: 6}, {Rule: "Flat", Points: -5}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			ruleSet := RuleSet{Version: "test", Stages: test.stages}
+			receipt := &models.Receipt{Retailer: test.retailer}
+
+			breakdown := ruleSet.CalculateBreakdown(receipt)
+			assert.Equal(t, test.expectedBreakdown, breakdown)
+			// The breakdown always adds up to the points
+			sum := 0
+			for _, rulePoints := range breakdown {
+				sum += rulePoints.Points
+			}
+			assert.Equal(t, sum, ruleSet.CalculatePoints(receipt))
+			assert.Equal(t, sum, ruleSet.Score(receipt).Points)
+		})
+	}
+}
+
+func TestRetailerIs(t *testing.T) {
+	receipt := &models.Receipt{Retailer: "M & M Corner Mkt", CanonicalRetailer: &models.CanonicalRetailer{Id: "m-and-m", Name: "M&M Corner Market"}}
+	assert.True(t, RetailerIs("m&m corner market")(receipt), "the canonical retailer's name matches")
+	assert.True(t, RetailerIs(" M & M Corner Mkt ")(receipt), "the retailer as printed matches")
+	assert.False(t, RetailerIs("m-and-m", "Target")(receipt))
+}
@@ -11,9 +11,33 @@ import (
 // Given a receipt, return number of points gained from this rule
 type ReceiptRule func(*models.Receipt) int
 
+// A ReceiptRule along with the name it is reported under in point breakdowns
+type NamedReceiptRule struct {
+	Name string
+	Rule ReceiptRule
+}
+
+// Returns a list of all current receipt point rules along with their names
+func GetNamedReceiptRules() []NamedReceiptRule {
+	return []NamedReceiptRule{
+		{Name: "RetailerRule", Rule: RetailerRule},
+		{Name: "TotalRoundRule", Rule: TotalRoundRule},
+		{Name: "TotalMultipleRule", Rule: TotalMultipleRule},
+		{Name: "NumItemsRule", Rule: NumItemsRule},
+		{Name: "ItemDescriptionRule", Rule: ItemDescriptionRule},
+		{Name: "PurchaseDayRule", Rule: PurchaseDayRule},
+		{Name: "PurchaseTimeRule", Rule: PurchaseTimeRule},
+	}
+}
+
 // Returns a list of all current receipt point rules
 func GetReceiptRules() []ReceiptRule {
-	return []ReceiptRule{RetailerRule, TotalRoundRule, TotalMultipleRule, NumItemsRule, ItemDescriptionRule, PurchaseDayRule, PurchaseTimeRule}
+	namedRules := GetNamedReceiptRules()
+	rules := make([]ReceiptRule, 0, len(namedRules))
+	for _, namedRule := range namedRules {
+		rules = append(rules, namedRule.Rule)
+	}
+	return rules
 }
 
 // Returns 1 point for each alphanumeric character in the retailer
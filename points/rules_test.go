@@ -47,6 +47,10 @@ func TestRetailerRule(t *testing.T) {
 			assert.Equal(t, test.expectedPoints, RetailerRule(&models.Receipt{Retailer: test.inputRetailer}))
 		})
 	}
+
+	// The retailer is counted as printed on the receipt, not as its canonical retailer's name
+	receipt := &models.Receipt{Retailer: "M & M Corner Mkt", CanonicalRetailer: &models.CanonicalRetailer{Id: "m-and-m", Name: "M&M Corner Market"}}
+	assert.Equal(t, 11, RetailerRule(receipt))
 }
 
 func TestTotalRoundRule(t *testing.T) {
@@ -0,0 +1,195 @@
+package points
+
+import (
+	"fmt"
+	"receipts/models"
+	"sync/atomic"
+	"time"
+)
+
+// A version of the rewards program, scoring receipts from EffectiveFrom until the next version takes effect
+type RuleSet struct {
+	Version       string
+	EffectiveFrom time.Time
+	// Run in order, see Stage
+	Stages []Stage
+}
+
+/*
+Returns the versions of the rewards program built into the service, ordered by EffectiveFrom.
+
+When the program changes, add a new RuleSet here instead of editing the rules of
+an existing one, so receipts scored under the old version keep their points.
+*/
+func DefaultRuleSets() []RuleSet {
+	return []RuleSet{
+		{Version: "v1", EffectiveFrom: time.Time{}, Stages: AddStages(GetNamedReceiptRules())},
+	}
+}
+
+// Rule sets replacing DefaultRuleSets, nil until SetRuleSets is called
+var activeRuleSets atomic.Pointer[[]RuleSet]
+
+// Returns every active version of the rewards program, ordered by EffectiveFrom
+func GetRuleSets() []RuleSet {
+	if ruleSets := activeRuleSets.Load(); ruleSets != nil {
+		return *ruleSets
+	}
+	return DefaultRuleSets()
+}
+
+/*
+Replaces the active rule sets, such as with ones loaded from a rules file. Receipts
+scored after this returns use the new rule sets, while a receipt already being scored
+finishes with the old ones. Invalid rule sets are rejected and the active ones kept.
+*/
+func SetRuleSets(ruleSets []RuleSet) error {
+	if err := ValidateRuleSets(ruleSets); err != nil {
+		return err
+	}
+	ruleSets = append([]RuleSet(nil), ruleSets...)
+	activeRuleSets.Store(&ruleSets)
+	return nil
+}
+
+/*
+Checks that there is at least one rule set, that versions are unique and not empty,
+that rule sets are ordered by EffectiveFrom, and that every stage can be applied.
+*/
+func ValidateRuleSets(ruleSets []RuleSet) error {
+	if len(ruleSets) == 0 {
+		return fmt.Errorf("there must be at least one rule set")
+	}
+	versions := make(map[string]bool)
+	for i, ruleSet := range ruleSets {
+		if ruleSet.Version == "" {
+			return fmt.Errorf("rule set %d must have a version", i+1)
+		}
+		if versions[ruleSet.Version] {
+			return fmt.Errorf("rule set version %s is defined more than once", ruleSet.Version)
+		}
+		versions[ruleSet.Version] = true
+		if i > 0 && ruleSet.EffectiveFrom.Before(ruleSets[i-1].EffectiveFrom) {
+			return fmt.Errorf("rule set %s must not take effect before %s", ruleSet.Version, ruleSets[i-1].Version)
+		}
+		for j, stage := range ruleSet.Stages {
+			if stage.Name == "" || stage.Apply == nil {
+				return fmt.Errorf("stage %d of rule set %s must have a name and be applicable", j+1, ruleSet.Version)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the latest rule set in effect at the given time, or the first one if none are in effect yet
+func EffectiveRuleSet(at time.Time) RuleSet {
+	return effectiveIn(GetRuleSets(), at)
+}
+
+// Same as EffectiveRuleSet over the given rule sets, which must be ordered by EffectiveFrom
+func effectiveIn(ruleSets []RuleSet, at time.Time) RuleSet {
+	effective := ruleSets[0]
+	for _, ruleSet := range ruleSets[1:] {
+		if ruleSet.EffectiveFrom.After(at) {
+			break
+		}
+		effective = ruleSet
+	}
+	return effective
+}
+
+// Returns the rule set with the version, or false if there is none
+func RuleSetVersion(version string) (RuleSet, bool) {
+	for _, ruleSet := range GetRuleSets() {
+		if ruleSet.Version == version {
+			return ruleSet, true
+		}
+	}
+	return RuleSet{}, false
+}
+
+func (rs RuleSet) CalculatePoints(receipt *models.Receipt) int {
+	total := 0
+	for _, stage := range rs.Stages {
+		var stop bool
+		total, stop = stage.Apply(receipt, total)
+		if stop {
+			break
+		}
+	}
+	return total
+}
+
+/*
+Returns how much each stage of the set changed the receipt's points, in the order
+of the set's stages. Stages skipped by a short circuit are left out.
+*/
+func (rs RuleSet) CalculateBreakdown(receipt *models.Receipt) []RulePoints {
+	breakdown := make([]RulePoints, 0, len(rs.Stages))
+	total := 0
+	for _, stage := range rs.Stages {
+		newTotal, stop := stage.Apply(receipt, total)
+		breakdown = append(breakdown, RulePoints{Rule: stage.Name, Points: newTotal - total})
+		total = newTotal
+		if stop {
+			break
+		}
+	}
+	return breakdown
+}
+
+// Points a rule set awarded a receipt, kept with a stored receipt so they aren't recalculated on every read
+type Score struct {
+	RuleVersion string       `json:"ruleVersion"`
+	Points      int          `json:"points"`
+	Breakdown   []RulePoints `json:"breakdown"`
+}
+
+// Scores the receipt with every stage of the set
+func (rs RuleSet) Score(receipt *models.Receipt) Score {
+	breakdown := rs.CalculateBreakdown(receipt)
+	points := 0
+	for _, rulePoints := range breakdown {
+		points = points + rulePoints.Points
+	}
+	return Score{RuleVersion: rs.Version, Points: points, Breakdown: breakdown}
+}
+
+// Which date picks the rule set a newly processed receipt is scored with
+type Selection string
+
+const (
+	// The receipt's purchaseDate, so receipts bought before a change keep earning under the old rules
+	SelectByPurchaseDate Selection = "purchase-date"
+	// The time the receipt was submitted to the service
+	SelectBySubmissionTime Selection = "submission-time"
+)
+
+// Parses a selection name, returning an error for unknown names
+func ParseSelection(name string) (Selection, error) {
+	switch selection := Selection(name); selection {
+	case SelectByPurchaseDate, SelectBySubmissionTime:
+		return selection, nil
+	default:
+		return "", fmt.Errorf("rule selection must be one of purchase-date or submission-time")
+	}
+}
+
+// Returns the rule set that scores a receipt submitted at submittedAt
+func SelectRuleSet(receipt *models.Receipt, submittedAt time.Time, selection Selection) RuleSet {
+	if selection == SelectBySubmissionTime {
+		return EffectiveRuleSet(submittedAt)
+	}
+	return EffectiveRuleSet(receipt.PurchaseDate.Date)
+}
+
+/*
+Returns the rule set a stored receipt was scored with. Receipts stored without a
+version, or with a version that no longer exists, fall back to their purchaseDate.
+*/
+func StoredRuleSet(receipt *models.Receipt, version string) RuleSet {
+	if ruleSet, ok := RuleSetVersion(version); ok {
+		return ruleSet
+	}
+	return EffectiveRuleSet(receipt.PurchaseDate.Date)
+}
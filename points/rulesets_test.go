@@ -0,0 +1,94 @@
+package points
+
+import (
+	"receipts/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveIn(t *testing.T) {
+	date := func(value string) time.Time {
+		parsed, _ := time.Parse(models.DateLayout, value)
+		return parsed
+	}
+	ruleSets := []RuleSet{
+		{Version: "v1", EffectiveFrom: date("2022-01-01")},
+		{Version: "v2", EffectiveFrom: date("2023-01-01")},
+		{Version: "v3", EffectiveFrom: date("2024-06-15")},
+	}
+
+	tests := []struct {
+		testName        string
+		at              time.Time
+		expectedVersion string
+	}{
+		{testName: "BeforeFirst", at: date("2021-12-31"), expectedVersion: "v1"},
+		{testName: "OnFirst", at: date("2022-01-01"), expectedVersion: "v1"},
+		{testName: "DayBeforeChange", at: date("2022-12-31"), expectedVersion: "v1"},
+		{testName: "OnChange", at: date("2023-01-01"), expectedVersion: "v2"},
+		{testName: "Latest", at: date("2030-01-01"), expectedVersion: "v3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Equal(t, test.expectedVersion, effectiveIn(ruleSets, test.at).Version)
+		})
+	}
+}
+
+func TestRuleSets(t *testing.T) {
+	ruleSets := GetRuleSets()
+	assert.NotEmpty(t, ruleSets)
+	for i := 1; i < len(ruleSets); i++ {
+		assert.True(t, ruleSets[i-1].EffectiveFrom.Before(ruleSets[i].EffectiveFrom), "rule sets must be ordered by EffectiveFrom")
+	}
+
+	receipt := &models.Receipt{Retailer: "Target", Total: "1.00"}
+	assert.Equal(t, ruleSets[0].Version, StoredRuleSet(receipt, "").Version)
+	assert.Equal(t, ruleSets[0].Version, StoredRuleSet(receipt, "unknown").Version)
+	ruleSet, ok := RuleSetVersion(ruleSets[0].Version)
+	assert.True(t, ok)
+	assert.Equal(t, ruleSet.CalculatePoints(receipt), CalculatePoints(receipt))
+	score := ruleSet.Score(receipt)
+	assert.Equal(t, ruleSet.Version, score.RuleVersion)
+	assert.Equal(t, CalculatePoints(receipt), score.Points)
+	assert.Equal(t, CalculateBreakdown(receipt), score.Breakdown)
+
+	_, err := ParseSelection("purchase-date")
+	assert.NoError(t, err)
+	_, err = ParseSelection("tomorrow")
+	assert.Error(t, err)
+}
+
+func TestSetRuleSets(t *testing.T) {
+	t.Cleanup(func() { SetRuleSets(DefaultRuleSets()) })
+	flat := AddStage(NamedReceiptRule{Name: "FlatRule", Rule: func(*models.Receipt) int { return 10 }})
+	later := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		testName string
+		ruleSets []RuleSet
+	}{
+		{testName: "Empty", ruleSets: nil},
+		{testName: "MissingVersion", ruleSets: []RuleSet{{Stages: []Stage{flat}}}},
+		{testName: "DuplicateVersion", ruleSets: []RuleSet{{Version: "v1"}, {Version: "v1", EffectiveFrom: later}}},
+		{testName: "Unordered", ruleSets: []RuleSet{{Version: "v1", EffectiveFrom: later}, {Version: "v2"}}},
+		{testName: "UnnamedStage", ruleSets: []RuleSet{{Version: "v1", Stages: []Stage{{Apply: flat.Apply}}}}},
+		{testName: "MissingApply", ruleSets: []RuleSet{{Version: "v1", Stages: []Stage{{Name: "FlatRule"}}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Error(t, SetRuleSets(test.ruleSets))
+			assert.Equal(t, "v1", EffectiveRuleSet(later).Version, "invalid rule sets must not replace the active ones")
+		})
+	}
+
+	receipt := &models.Receipt{Retailer: "Target", Total: "1.00"}
+	assert.NoError(t, SetRuleSets([]RuleSet{{Version: "v1", Stages: []Stage{flat}}, {Version: "v2", EffectiveFrom: later, Stages: []Stage{flat, flat}}}))
+	assert.Equal(t, 10, CalculatePoints(receipt))
+	assert.Equal(t, "v2", EffectiveRuleSet(later).Version)
+	_, ok := RuleSetVersion("v2")
+	assert.True(t, ok)
+}
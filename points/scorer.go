@@ -0,0 +1,49 @@
+package points
+
+import (
+	"receipts/models"
+	"time"
+)
+
+// Points awarded on top of a rule set's score, such as promotion campaigns
+type Bonus interface {
+	// Returns the score with any bonus points added to its points and breakdown
+	Apply(receipt *models.Receipt, score Score) Score
+}
+
+// Scores receipts with the rule set picked by Selection, then adds every bonus in order
+type Scorer struct {
+	Selection Selection
+	Bonuses   []Bonus
+}
+
+// Scores a new receipt submitted at submittedAt
+func (s Scorer) Score(receipt *models.Receipt, submittedAt time.Time) Score {
+	return s.ScoreWith(SelectRuleSet(receipt, submittedAt, s.Selection), receipt)
+}
+
+// Scores the receipt with the given rule set instead of the one Selection picks
+func (s Scorer) ScoreWith(ruleSet RuleSet, receipt *models.Receipt) Score {
+	score := ruleSet.Score(receipt)
+	for _, bonus := range s.Bonuses {
+		score = bonus.Apply(receipt, score)
+	}
+	return score
+}
+
+// Returns a copy of the scorer that adds the bonus after its other bonuses
+func (s Scorer) WithBonus(bonus Bonus) Scorer {
+	s.Bonuses = append(append([]Bonus(nil), s.Bonuses...), bonus)
+	return s
+}
+
+// Sum of the points the rule set awarded in the score, leaving out any bonus points
+func (s Score) RulePoints() int {
+	total := 0
+	for _, rulePoints := range s.Breakdown {
+		if rulePoints.Campaign == "" && rulePoints.Tier == "" {
+			total += rulePoints.Points
+		}
+	}
+	return total
+}
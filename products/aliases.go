@@ -0,0 +1,47 @@
+package products
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Returns the alias dictionary built into the service, mapping common abbreviations and misspellings to product names
+func DefaultAliases() map[string]string {
+	return map[string]string{
+		"mtn dew":           "Mountain Dew",
+		"mt dew":            "Mountain Dew",
+		"coca cola":         "Coca-Cola",
+		"coke":              "Coca-Cola",
+		"gatorade":          "Gatorade",
+		"gatorade original": "Gatorade",
+		"klarbrunn":         "Klarbrunn Sparkling Water",
+		"klarbrunn water":   "Klarbrunn Sparkling Water",
+		"dasani":            "Dasani Water",
+		"dasani water":      "Dasani Water",
+	}
+}
+
+/*
+Reads an alias dictionary from a json file holding an object of aliases to product names,
+and returns it merged over DefaultAliases, so the file only needs the aliases it adds or changes.
+*/
+func LoadAliases(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var loaded map[string]string
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("aliases file %s must hold an object of aliases to product names: %w", path, err)
+	}
+
+	aliases := DefaultAliases()
+	for alias, name := range loaded {
+		if name == "" {
+			return nil, fmt.Errorf("alias %q must have a product name", alias)
+		}
+		aliases[alias] = name
+	}
+	return aliases, nil
+}
@@ -0,0 +1,110 @@
+package products
+
+import (
+	"receipts/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// A count of units, such as "12-PK", "6 pack" or "24ct"
+	packRegex = regexp.MustCompile(`\b(\d+)\s*-?\s*(?:pk|pack|ct|count)\b`)
+	// A size with its unit, such as "12 FL OZ", "12-oz" or "1.5L"
+	sizeRegex = regexp.MustCompile(`\b(\d+(?:\.\d+)?)\s*-?\s*(fl\.?\s*oz|oz|ml|l|lbs?|g|kg|gal)\b`)
+	// Canonical spelling of each unit sizeRegex matches
+	units = map[string]string{"oz": "oz", "ml": "ml", "l": "l", "lb": "lb", "lbs": "lb", "g": "g", "kg": "kg", "gal": "gal"}
+	// Punctuation left around a name once its size and pack are taken out
+	separatorRegex = regexp.MustCompile(`[\s\-,/|.]+`)
+)
+
+/*
+Maps free text item descriptions to canonical products. Descriptions are trimmed and
+case folded, their pack count and unit size are taken out, and the name left is
+looked up in the alias dictionary, so that differently spelled names map to one
+product. Names without an alias are title cased.
+*/
+type Normalizer struct {
+	// Canonical names by case folded alias
+	aliases map[string]string
+}
+
+/*
+Creates a normalizer with the alias dictionary, mapping names as they are left after
+normalizing a description to canonical product names. Aliases are matched ignoring case.
+*/
+func NewNormalizer(aliases map[string]string) *Normalizer {
+	folded := make(map[string]string, len(aliases))
+	for alias, name := range aliases {
+		folded[foldName(alias)] = name
+	}
+	return &Normalizer{aliases: folded}
+}
+
+// Trims, case folds and collapses the whitespace and punctuation of a name
+func foldName(name string) string {
+	return strings.Trim(separatorRegex.ReplaceAllString(strings.ToLower(name), " "), " ")
+}
+
+// Returns the canonical product the item description normalizes to
+func (n *Normalizer) Product(description string) models.Product {
+	folded := strings.Join(strings.Fields(strings.ToLower(description)), " ")
+	product := models.Product{}
+
+	if match := packRegex.FindStringSubmatchIndex(folded); match != nil {
+		product.Pack, _ = strconv.Atoi(folded[match[2]:match[3]])
+		folded = folded[:match[0]] + " " + folded[match[1]:]
+	}
+	if match := sizeRegex.FindStringSubmatchIndex(folded); match != nil {
+		unit := strings.Join(strings.Fields(strings.ReplaceAll(folded[match[4]:match[5]], ".", "")), "")
+		if unit == "floz" {
+			unit = "fl oz"
+		} else {
+			unit = units[unit]
+		}
+		product.Size = folded[match[2]:match[3]] + " " + unit
+		folded = folded[:match[0]] + " " + folded[match[1]:]
+	}
+
+	name := foldName(folded)
+	if name == "" {
+		// Descriptions that are only a size or pack are taken as the product's name
+		name = foldName(description)
+		product.Size, product.Pack = "", 0
+	}
+	if alias, exists := n.aliases[name]; exists {
+		product.Name = alias
+	} else {
+		product.Name = titleCase(name)
+	}
+
+	id := []string{strings.ToLower(product.Name)}
+	if product.Size != "" {
+		id = append(id, product.Size)
+	}
+	if product.Pack != 0 {
+		id = append(id, strconv.Itoa(product.Pack)+" pk")
+	}
+	product.Id = strings.Join(id, " ")
+	return product
+}
+
+// Sets the product of every item of the receipt from the item's description
+func (n *Normalizer) Normalize(receipt *models.Receipt) {
+	for i := range receipt.Items {
+		product := n.Product(receipt.Items[i].ShortDescription)
+		receipt.Items[i].Product = &product
+	}
+}
+
+// Upper cases the first letter of every word
+func titleCase(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(first)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,70 @@
+package products
+
+import (
+	"os"
+	"path/filepath"
+	"receipts/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProduct(t *testing.T) {
+	normalizer := NewNormalizer(DefaultAliases())
+	tests := []struct {
+		description     string
+		expectedProduct models.Product
+	}{
+		{
+			description:     "   Klarbrunn 12-PK 12 FL OZ  ",
+			expectedProduct: models.Product{Id: "klarbrunn sparkling water 12 fl oz 12 pk", Name: "Klarbrunn Sparkling Water", Size: "12 fl oz", Pack: 12},
+		},
+		{
+			description:     "KLARBRUNN 12 pack 12fl.oz",
+			expectedProduct: models.Product{Id: "klarbrunn sparkling water 12 fl oz 12 pk", Name: "Klarbrunn Sparkling Water", Size: "12 fl oz", Pack: 12},
+		},
+		{description: "Gatorade", expectedProduct: models.Product{Id: "gatorade", Name: "Gatorade"}},
+		{description: "Pepsi - 12-oz", expectedProduct: models.Product{Id: "pepsi 12 oz", Name: "Pepsi", Size: "12 oz"}},
+		{description: "MTN DEW 1.5L", expectedProduct: models.Product{Id: "mountain dew 1.5 l", Name: "Mountain Dew", Size: "1.5 l"}},
+		{description: "Emils Cheese Pizza", expectedProduct: models.Product{Id: "emils cheese pizza", Name: "Emils Cheese Pizza"}},
+		{description: "Eggs 24ct", expectedProduct: models.Product{Id: "eggs 24 pk", Name: "Eggs", Pack: 24}},
+		{description: "12 lemons", expectedProduct: models.Product{Id: "12 lemons", Name: "12 Lemons"}},
+		{description: "16 oz", expectedProduct: models.Product{Id: "16 oz", Name: "16 Oz"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.expectedProduct, normalizer.Product(test.description))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	normalizer := NewNormalizer(nil)
+	receipt := &models.Receipt{Retailer: "Target", Items: []models.Item{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}}}
+
+	normalizer.Normalize(receipt)
+	require.NotNil(t, receipt.Items[0].Product)
+	assert.Equal(t, "mountain dew 12 pk", receipt.Items[0].Product.Id)
+	assert.Equal(t, "Mountain Dew 12PK", receipt.Items[0].ShortDescription)
+}
+
+func TestLoadAliases(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "aliases.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Doritos Nacho": "Doritos Nacho Cheese", "coke": "Coke"}`), 0o644))
+
+	aliases, err := LoadAliases(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Doritos Nacho Cheese", aliases["Doritos Nacho"])
+	assert.Equal(t, "Coke", aliases["coke"], "the file overrides built in aliases")
+	assert.Equal(t, "Mountain Dew", aliases["mtn dew"])
+	assert.Equal(t, "Doritos Nacho Cheese", NewNormalizer(aliases).Product("doritos  nacho").Name)
+
+	require.NoError(t, os.WriteFile(path, []byte(`["coke"]`), 0o644))
+	_, err = LoadAliases(path)
+	assert.Error(t, err)
+	_, err = LoadAliases(filepath.Join(directory, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,127 @@
+package promotions
+
+import (
+	"fmt"
+	"math"
+	"receipts/models"
+	"receipts/points"
+	"strings"
+	"time"
+)
+
+const MaxNameLength int = 256
+
+/*
+A bonus campaign, such as double points at one retailer for a weekend or extra
+points for buying a product. A receipt earns a campaign's points when it matches
+every scope the campaign sets, and empty scopes match every receipt.
+*/
+type Campaign struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Retailer the receipt must be from, compared ignoring case and surrounding spaces
+	Retailer string `json:"retailer,omitempty"`
+	// Id of the canonical retailer Retailer matches, set when the campaign is saved. Receipts
+	// matched to a canonical retailer are compared by id instead, whatever their spelling.
+	RetailerId string `json:"retailerId,omitempty"`
+	// Window the receipt's purchaseDate and purchaseTime must fall in, taken as UTC. Start is
+	// inclusive and End exclusive, and a nil time leaves that side of the window open.
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+	// Text an item's shortDescription must contain, ignoring case
+	ItemDescription string `json:"itemDescription,omitempty"`
+	// Points from the base rules are multiplied by this, so 2 doubles them. 0 leaves them as is.
+	Multiplier float64 `json:"multiplier,omitempty"`
+	// Points added once to every matching receipt
+	Bonus int `json:"bonus,omitempty"`
+}
+
+// Checks the campaign has a name, a valid window and awards some points
+func (c Campaign) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(c.Name) > MaxNameLength {
+		return fmt.Errorf("name must be at most %d characters", MaxNameLength)
+	}
+	if c.Start != nil && c.End != nil && !c.End.After(*c.Start) {
+		return fmt.Errorf("end must be after start")
+	}
+	if c.Multiplier < 0 {
+		return fmt.Errorf("multiplier must not be negative")
+	}
+	if c.Bonus < 0 {
+		return fmt.Errorf("bonus must not be negative")
+	}
+	if c.Bonus == 0 && (c.Multiplier == 0 || c.Multiplier == 1) {
+		return fmt.Errorf("campaign must have a bonus or a multiplier other than 1")
+	}
+	return nil
+}
+
+// True when the receipt is in every scope of the campaign
+func (c Campaign) Matches(receipt *models.Receipt) bool {
+	if c.Retailer != "" && !c.matchesRetailer(receipt) {
+		return false
+	}
+
+	purchasedAt := PurchasedAt(receipt)
+	if c.Start != nil && purchasedAt.Before(*c.Start) {
+		return false
+	}
+	if c.End != nil && !purchasedAt.Before(*c.End) {
+		return false
+	}
+
+	if c.ItemDescription == "" {
+		return true
+	}
+	description := strings.ToLower(c.ItemDescription)
+	for _, item := range receipt.Items {
+		if strings.Contains(strings.ToLower(item.ShortDescription), description) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Returns the points the campaign adds to a matching receipt that earned basePoints
+from the rules of its rule set.
+*/
+func (c Campaign) Points(basePoints int) int {
+	bonus := c.Bonus
+	if c.Multiplier != 0 {
+		bonus += int(math.Round(float64(basePoints) * (c.Multiplier - 1)))
+	}
+	return bonus
+}
+
+// Returns the receipt's purchaseDate and purchaseTime combined, as UTC
+func PurchasedAt(receipt *models.Receipt) time.Time {
+	date := receipt.PurchaseDate.Date
+	clock := receipt.PurchaseTime.Time
+	return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+}
+
+// Adds a breakdown entry for the campaign, attributed to it, when it awards the receipt any points
+func (c Campaign) apply(receipt *models.Receipt, basePoints int, score points.Score) points.Score {
+	if !c.Matches(receipt) {
+		return score
+	}
+	campaignPoints := c.Points(basePoints)
+	if campaignPoints == 0 {
+		return score
+	}
+	score.Points += campaignPoints
+	score.Breakdown = append(score.Breakdown, points.RulePoints{Rule: c.Name, Points: campaignPoints, Campaign: c.Id})
+	return score
+}
+
+// True when the receipt is from the campaign's retailer, by canonical id when both have one
+func (c Campaign) matchesRetailer(receipt *models.Receipt) bool {
+	if c.RetailerId != "" && receipt.CanonicalRetailer != nil {
+		return c.RetailerId == receipt.CanonicalRetailer.Id
+	}
+	return strings.EqualFold(strings.TrimSpace(c.Retailer), strings.TrimSpace(receipt.Retailer))
+}
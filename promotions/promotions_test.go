@@ -0,0 +1,124 @@
+package promotions
+
+import (
+	"encoding/json"
+	"receipts/models"
+	"receipts/points"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Saturday afternoon at Target with a Gatorade
+const targetReceipt = `{
+					"retailer": "Target",
+					"purchaseDate": "2024-06-01",
+					"purchaseTime": "14:30",
+					"total": "2.25",
+					"items": [
+						{"shortDescription": "Gatorade", "price": "2.25"}
+					]
+				}`
+
+func at(value string) *time.Time {
+	parsed, _ := time.Parse(time.RFC3339, value)
+	return &parsed
+}
+
+func TestCampaignMatches(t *testing.T) {
+	var receipt models.Receipt
+	require.NoError(t, json.Unmarshal([]byte(targetReceipt), &receipt))
+
+	tests := []struct {
+		testName string
+		campaign Campaign
+		expected bool
+	}{
+		{testName: "Unscoped", campaign: Campaign{}, expected: true},
+		{testName: "Retailer", campaign: Campaign{Retailer: " target "}, expected: true},
+		{testName: "OtherRetailer", campaign: Campaign{Retailer: "Walgreens"}, expected: false},
+		{testName: "InWindow", campaign: Campaign{Start: at("2024-06-01T00:00:00Z"), End: at("2024-06-03T00:00:00Z")}, expected: true},
+		{testName: "StartIsInclusive", campaign: Campaign{Start: at("2024-06-01T14:30:00Z")}, expected: true},
+		{testName: "EndIsExclusive", campaign: Campaign{End: at("2024-06-01T14:30:00Z")}, expected: false},
+		{testName: "BeforeWindow", campaign: Campaign{Start: at("2024-06-02T00:00:00Z")}, expected: false},
+		{testName: "Item", campaign: Campaign{ItemDescription: "gatorade"}, expected: true},
+		{testName: "MissingItem", campaign: Campaign{ItemDescription: "Pepsi"}, expected: false},
+		{testName: "AllScopes", campaign: Campaign{Retailer: "Target", Start: at("2024-06-01T00:00:00Z"), ItemDescription: "Gator"}, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.campaign.Matches(&receipt))
+		})
+	}
+
+	// Receipts matched to a canonical retailer are compared by its id, whatever their spelling
+	receipt.Retailer, receipt.CanonicalRetailer = "TGT Store", &models.CanonicalRetailer{Id: "target", Name: "Target"}
+	assert.True(t, Campaign{Retailer: "Target", RetailerId: "target"}.Matches(&receipt))
+	assert.False(t, Campaign{Retailer: "TGT Store", RetailerId: "target-2"}.Matches(&receipt))
+	assert.True(t, Campaign{Retailer: "tgt store"}.Matches(&receipt), "campaigns saved before retailers were canonical compare names")
+}
+
+func TestCampaignValidate(t *testing.T) {
+	tests := []struct {
+		testName      string
+		campaign      Campaign
+		expectedError bool
+	}{
+		{testName: "Multiplier", campaign: Campaign{Name: "Double points", Multiplier: 2}},
+		{testName: "Bonus", campaign: Campaign{Name: "Gatorade bonus", Bonus: 100}},
+		{testName: "MissingName", campaign: Campaign{Bonus: 100}, expectedError: true},
+		{testName: "NoPoints", campaign: Campaign{Name: "Nothing", Multiplier: 1}, expectedError: true},
+		{testName: "NegativeBonus", campaign: Campaign{Name: "Penalty", Bonus: -5}, expectedError: true},
+		{testName: "NegativeMultiplier", campaign: Campaign{Name: "Penalty", Multiplier: -1}, expectedError: true},
+		{testName: "EndBeforeStart", campaign: Campaign{Name: "Backwards", Bonus: 1, Start: at("2024-06-02T00:00:00Z"), End: at("2024-06-01T00:00:00Z")}, expectedError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			err := test.campaign.Validate()
+			if test.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	var receipt models.Receipt
+	require.NoError(t, json.Unmarshal([]byte(targetReceipt), &receipt))
+	store := NewStore()
+
+	double := store.CreateCampaign(Campaign{Name: "Double points at Target", Retailer: "Target", Multiplier: 2})
+	gatorade := store.CreateCampaign(Campaign{Name: "Gatorade bonus", ItemDescription: "Gatorade", Bonus: 100})
+	store.CreateCampaign(Campaign{Name: "Walgreens bonus", Retailer: "Walgreens", Bonus: 50})
+	assert.NotEmpty(t, double.Id)
+	assert.Len(t, store.ListCampaigns(), 3)
+
+	// Both matching campaigns are attributed, and the multiplier only applies to base points
+	base := points.Score{RuleVersion: "v1", Points: 40, Breakdown: []points.RulePoints{{Rule: "RetailerRule", Points: 40}}}
+	score := store.Apply(&receipt, base)
+	assert.Equal(t, 180, score.Points)
+	assert.Equal(t, []points.RulePoints{
+		{Rule: "RetailerRule", Points: 40},
+		{Rule: "Double points at Target", Points: 40, Campaign: double.Id},
+		{Rule: "Gatorade bonus", Points: 100, Campaign: gatorade.Id},
+	}, score.Breakdown)
+
+	updated, ok := store.UpdateCampaign(gatorade.Id, Campaign{Name: "Gatorade bonus", ItemDescription: "Gatorade", Bonus: 10})
+	assert.True(t, ok)
+	assert.Equal(t, gatorade.Id, updated.Id)
+	assert.True(t, store.DeleteCampaign(double.Id))
+	assert.False(t, store.DeleteCampaign(double.Id))
+	_, ok = store.UpdateCampaign(double.Id, Campaign{Name: "Gone", Bonus: 1})
+	assert.False(t, ok)
+
+	assert.Equal(t, 50, store.Apply(&receipt, base).Points)
+	campaign, ok := store.GetCampaign(gatorade.Id)
+	assert.True(t, ok)
+	assert.Equal(t, 10, campaign.Bonus)
+}
@@ -0,0 +1,98 @@
+package promotions
+
+import (
+	"receipts/models"
+	"receipts/points"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+/*
+Thread safe store of campaigns, which is also the points.Bonus that applies them.
+Campaigns are applied in the order they were created.
+*/
+type Store struct {
+	*sync.RWMutex
+	idToCampaign map[string]Campaign
+	ids          []string
+}
+
+func NewStore() *Store {
+	return &Store{
+		RWMutex:      &sync.RWMutex{},
+		idToCampaign: make(map[string]Campaign),
+	}
+}
+
+// Returns every campaign in the order they were created after waiting for the read lock.
+func (s *Store) ListCampaigns() []Campaign {
+	s.RLock()
+	defer s.RUnlock()
+	campaigns := make([]Campaign, 0, len(s.ids))
+	for _, id := range s.ids {
+		campaigns = append(campaigns, s.idToCampaign[id])
+	}
+	return campaigns
+}
+
+// Returns the campaign with the id, or false if there is none, after waiting for the read lock.
+func (s *Store) GetCampaign(id string) (Campaign, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	campaign, exists := s.idToCampaign[id]
+	return campaign, exists
+}
+
+/*
+Saves the campaign under a new id and returns it with the id set, after waiting
+for the read / write lock. The campaign must already be valid.
+*/
+func (s *Store) CreateCampaign(campaign Campaign) Campaign {
+	s.Lock()
+	defer s.Unlock()
+	campaign.Id = uuid.New().String()
+	s.idToCampaign[campaign.Id] = campaign
+	s.ids = append(s.ids, campaign.Id)
+	return campaign
+}
+
+/*
+Replaces the campaign with the id, keeping the id, and returns false if there is
+no such campaign. Waits for the read / write lock.
+*/
+func (s *Store) UpdateCampaign(id string, campaign Campaign) (Campaign, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := s.idToCampaign[id]; !exists {
+		return Campaign{}, false
+	}
+	campaign.Id = id
+	s.idToCampaign[id] = campaign
+	return campaign, true
+}
+
+// Removes the campaign, returning false if there was none, after waiting for the read / write lock.
+func (s *Store) DeleteCampaign(id string) bool {
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := s.idToCampaign[id]; !exists {
+		return false
+	}
+	delete(s.idToCampaign, id)
+	s.ids = slices.DeleteFunc(s.ids, func(existing string) bool { return existing == id })
+	return true
+}
+
+/*
+Adds the points of every campaign the receipt matches to the score. Multipliers
+apply to the points from the rule set only, so campaigns don't compound each other.
+*/
+func (s *Store) Apply(receipt *models.Receipt, score points.Score) points.Score {
+	basePoints := score.Points
+	for _, campaign := range s.ListCampaigns() {
+		score = campaign.apply(receipt, basePoints, score)
+	}
+	return score
+}
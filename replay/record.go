@@ -0,0 +1,70 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+/*
+One recorded request and the response it originally got. Recorded traffic is
+stored as one Record per line of a JSONL file.
+*/
+type Record struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	// Status code of the recorded response, 0 to not check the status
+	Status int `json:"status,omitempty"`
+	// Body of the recorded response, used to map receipt ids and compare points
+	Response string `json:"response,omitempty"`
+	// Line of the file ReadRecords read the record from, 0 for records built in code
+	Line int `json:"-"`
+}
+
+/*
+Reads records from JSONL. Blank lines are skipped, and lines without both a
+method and a path are rejected, so the wrong kind of JSONL file is caught early.
+*/
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if record.Method == "" || !strings.HasPrefix(record.Path, "/") {
+			return nil, fmt.Errorf("line %d: record must have a method and a path starting with /", lineNumber)
+		}
+		record.Line = lineNumber
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Builds the http request for the record against the target's base url
+func (r Record) newRequest(baseURL string, path string) (*http.Request, error) {
+	req, err := http.NewRequest(r.Method, strings.TrimSuffix(baseURL, "/")+path, strings.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
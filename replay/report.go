@@ -0,0 +1,90 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Summary of a replay
+type Report struct {
+	Total            int          `json:"total"`
+	Errors           int          `json:"errors"`
+	StatusMismatches []Result     `json:"statusMismatches"`
+	PointsDiffs      []Result     `json:"pointsDiffs"`
+	Latency          LatencyStats `json:"latency"`
+	// Wall clock time of the whole replay
+	Elapsed time.Duration `json:"elapsedNs"`
+}
+
+// Latency percentiles over requests that got a response
+type LatencyStats struct {
+	P50 time.Duration `json:"p50Ns"`
+	P90 time.Duration `json:"p90Ns"`
+	P99 time.Duration `json:"p99Ns"`
+	Max time.Duration `json:"maxNs"`
+}
+
+func NewReport(results []Result, elapsed time.Duration) Report {
+	report := Report{
+		Total:            len(results),
+		StatusMismatches: []Result{},
+		PointsDiffs:      []Result{},
+		Elapsed:          elapsed,
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			report.Errors++
+			continue
+		}
+		if result.StatusMismatch() {
+			report.StatusMismatches = append(report.StatusMismatches, result)
+		}
+		if result.PointsDiff() {
+			report.PointsDiffs = append(report.PointsDiffs, result)
+		}
+	}
+
+	latencies := sortedLatencies(results)
+	report.Latency = LatencyStats{
+		P50: percentile(latencies, 50),
+		P90: percentile(latencies, 90),
+		P99: percentile(latencies, 99),
+	}
+	if len(latencies) > 0 {
+		report.Latency.Max = latencies[len(latencies)-1]
+	}
+	return report
+}
+
+// Nearest rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// True when nothing differed from the recording
+func (r Report) Ok() bool {
+	return r.Errors == 0 && len(r.StatusMismatches) == 0 && len(r.PointsDiffs) == 0
+}
+
+// Writes the report in a human readable form
+func (r Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "requests: %d, errors: %d, status mismatches: %d, points diffs: %d, elapsed: %s\n",
+		r.Total, r.Errors, len(r.StatusMismatches), len(r.PointsDiffs), r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "latency p50: %s, p90: %s, p99: %s, max: %s\n", r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max)
+	for _, result := range r.StatusMismatches {
+		fmt.Fprintf(w, "line %d: %s %s: status %d, recorded %d\n", result.Line, result.Method, result.Path, result.Status, result.ExpectedStatus)
+	}
+	for _, result := range r.PointsDiffs {
+		actual := "none"
+		if result.Points != nil {
+			actual = fmt.Sprint(*result.Points)
+		}
+		fmt.Fprintf(w, "line %d: %s %s: points %s, recorded %d\n", result.Line, result.Method, result.Path, actual, *result.ExpectedPoints)
+	}
+}
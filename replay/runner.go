@@ -0,0 +1,285 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"receipts/models"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings for how fast records are replayed
+type Options struct {
+	// Number of requests in flight at once, values below 1 are treated as 1
+	Concurrency int
+	// Maximum requests started per second, 0 for no limit
+	Rate float64
+}
+
+// Outcome of replaying one record
+type Result struct {
+	// Line of the file the record was read from, or its position in records when it wasn't read from a file
+	Line           int           `json:"line"`
+	Method         string        `json:"method"`
+	Path           string        `json:"path"`
+	ExpectedStatus int           `json:"expectedStatus,omitempty"`
+	Status         int           `json:"status"`
+	Latency        time.Duration `json:"latencyNs"`
+	Error          string        `json:"error,omitempty"`
+	// Set for points requests whose recorded response had points
+	ExpectedPoints *int `json:"expectedPoints,omitempty"`
+	Points         *int `json:"points,omitempty"`
+}
+
+func (r Result) StatusMismatch() bool {
+	return r.ExpectedStatus != 0 && r.ExpectedStatus != r.Status
+}
+
+func (r Result) PointsDiff() bool {
+	return r.ExpectedPoints != nil && (r.Points == nil || *r.Points != *r.ExpectedPoints)
+}
+
+// Receipt ids in paths such as /receipts/{id}/points
+var receiptIdPattern = regexp.MustCompile(`^/receipts/([^/]+)/`)
+
+/*
+Replays records against the target and returns one result per record, in the
+same order as records.
+
+Records are started in order. Ids returned by /receipts/process are recorded, and
+later requests for the recorded id are rewritten to the id the target returned,
+waiting for that process request to finish first if it is still in flight. Requests
+recorded before the process request that returned their id are replayed as recorded.
+*/
+func Run(ctx context.Context, target Target, records []Record, options Options) []Result {
+	concurrency := max(options.Concurrency, 1)
+	ids := newIdMap()
+	results := make([]Result, len(records))
+
+	var ticker *time.Ticker
+	if options.Rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / options.Rate))
+		defer ticker.Stop()
+	}
+
+	// Ids that records will produce are registered up front so later records know to wait for them
+	for index, record := range records {
+		if id := recordedProcessId(record); id != "" {
+			ids.expect(id, index)
+		}
+	}
+
+	work := make(chan int)
+	// Set by the worker that replayed each record, read once every worker is done
+	replayed := make([]bool, len(records))
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range work {
+				results[index] = replayRecord(ctx, target, records[index], index, ids)
+				replayed[index] = true
+			}
+		}()
+	}
+
+dispatch:
+	for index := range records {
+		if ticker != nil {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		select {
+		case work <- index:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	// Records never started because the context ended
+	for index := range results {
+		if !replayed[index] {
+			results[index] = Result{Method: records[index].Method, Path: records[index].Path, Error: ctx.Err().Error()}
+		}
+		results[index].Line = records[index].Line
+		if results[index].Line == 0 {
+			results[index].Line = index + 1
+		}
+	}
+	return results
+}
+
+func replayRecord(ctx context.Context, target Target, record Record, index int, ids *idMap) Result {
+	result := Result{Method: record.Method, Path: record.Path, ExpectedStatus: record.Status}
+
+	path := record.Path
+	if match := receiptIdPattern.FindStringSubmatch(path); match != nil {
+		if mapped, ok := ids.wait(ctx, match[1], index); ok {
+			path = "/receipts/" + mapped + path[len(match[0])-1:]
+		}
+	}
+
+	req, err := record.newRequest(target.BaseURL(), path)
+	if err != nil {
+		result.Error = err.Error()
+		ids.resolve(recordedProcessId(record), "")
+		return result
+	}
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	response, err := target.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		ids.resolve(recordedProcessId(record), "")
+		return result
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	result.Status = response.StatusCode
+
+	if recordedId := recordedProcessId(record); recordedId != "" {
+		var id models.Id
+		if response.StatusCode == http.StatusOK && json.Unmarshal(body, &id) == nil {
+			ids.resolve(recordedId, id.Id)
+		} else {
+			ids.resolve(recordedId, "")
+		}
+	}
+
+	if expected, ok := pointsFromBody(record.Response); ok && isPointsRequest(record) {
+		result.ExpectedPoints = &expected
+		if actual, ok := pointsFromBody(string(body)); ok {
+			result.Points = &actual
+		}
+	}
+	return result
+}
+
+// Id in the recorded response of a successful process request, empty for any other record
+func recordedProcessId(record Record) string {
+	if record.Method != http.MethodPost || record.Path != "/receipts/process" || record.Response == "" {
+		return ""
+	}
+	var id models.Id
+	if err := json.Unmarshal([]byte(record.Response), &id); err != nil {
+		return ""
+	}
+	return id.Id
+}
+
+func isPointsRequest(record Record) bool {
+	return record.Method == http.MethodGet && receiptIdPattern.MatchString(record.Path) && strings.HasSuffix(record.Path, "/points")
+}
+
+func pointsFromBody(body string) (int, bool) {
+	var points struct {
+		Points *int `json:"points"`
+	}
+	if err := json.Unmarshal([]byte(body), &points); err != nil || points.Points == nil {
+		return 0, false
+	}
+	return *points.Points, true
+}
+
+// Recorded receipt ids and the ids the target gave the same receipts
+type idMap struct {
+	*sync.Mutex
+	mapped map[string]string
+	done   map[string]chan struct{}
+	// Index of the first record that resolves each recorded id
+	producer map[string]int
+}
+
+func newIdMap() *idMap {
+	return &idMap{
+		Mutex:    &sync.Mutex{},
+		mapped:   make(map[string]string),
+		done:     make(map[string]chan struct{}),
+		producer: make(map[string]int),
+	}
+}
+
+// Marks the recorded id as one the record at the index will resolve
+func (m *idMap) expect(recordedId string, index int) {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.done[recordedId]; !exists {
+		m.done[recordedId] = make(chan struct{})
+		m.producer[recordedId] = index
+	}
+}
+
+// Sets the target's id for the recorded id, empty if the target did not store the receipt
+func (m *idMap) resolve(recordedId string, id string) {
+	if recordedId == "" {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	done, exists := m.done[recordedId]
+	if !exists {
+		return
+	}
+	select {
+	case <-done:
+		// Already resolved by an earlier record with the same recorded id
+		return
+	default:
+	}
+	if id != "" {
+		m.mapped[recordedId] = id
+	}
+	close(done)
+}
+
+/*
+Returns the target's id for the recorded id, waiting for it to be resolved if a record
+before the one at the index is going to. Records are dispatched in order, so that record
+has already been started and waiting can't hold up the workers it needs. Returns false
+if the id should be used as recorded.
+*/
+func (m *idMap) wait(ctx context.Context, recordedId string, index int) (string, bool) {
+	m.Lock()
+	done, exists := m.done[recordedId]
+	producer := m.producer[recordedId]
+	m.Unlock()
+	if !exists || producer >= index {
+		return "", false
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return "", false
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	id, ok := m.mapped[recordedId]
+	return id, ok
+}
+
+// Sorts a copy of the latencies, for percentile calculations
+func sortedLatencies(results []Result) []time.Duration {
+	latencies := make([]time.Duration, 0, len(results))
+	for _, result := range results {
+		if result.Error == "" {
+			latencies = append(latencies, result.Latency)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies
+}
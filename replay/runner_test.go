@@ -0,0 +1,186 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"receipts/handlers"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recordedReceipt = `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25", "items": [{"shortDescription": "Pepsi - 12-oz", "price": "1.25"}]}`
+
+// Recorded traffic for one receipt: processing it, then reading its points
+func recordedSession(recordedId string, recordedPoints int) []Record {
+	return []Record{
+		{
+			Method:   "POST",
+			Path:     "/receipts/process",
+			Headers:  map[string]string{"Content-Type": "application/json"},
+			Body:     recordedReceipt,
+			Status:   200,
+			Response: `{"id": "` + recordedId + `"}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/receipts/" + recordedId + "/points",
+			Status:   200,
+			Response: fmt.Sprintf(`{"points": %d}`, recordedPoints),
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	target := HandlerTarget{Handler: handlers.CreateRouter()}
+
+	t.Run("MatchingRecording", func(t *testing.T) {
+		results := Run(context.Background(), target, recordedSession("recorded-1", 31), Options{Concurrency: 1})
+		require.Len(t, results, 2)
+		report := NewReport(results, time.Second)
+		assert.True(t, report.Ok())
+		assert.Equal(t, 2, report.Total)
+		assert.Equal(t, 31, *results[1].Points)
+	})
+
+	t.Run("PointsDiff", func(t *testing.T) {
+		results := Run(context.Background(), target, recordedSession("recorded-2", 40), Options{Concurrency: 1})
+		report := NewReport(results, time.Second)
+		assert.False(t, report.Ok())
+		require.Len(t, report.PointsDiffs, 1)
+		assert.Equal(t, 2, report.PointsDiffs[0].Line)
+		assert.Equal(t, 40, *report.PointsDiffs[0].ExpectedPoints)
+		assert.Equal(t, 31, *report.PointsDiffs[0].Points)
+	})
+
+	t.Run("StatusMismatch", func(t *testing.T) {
+		records := []Record{{Method: "GET", Path: "/receipts/unknown/points", Status: 200}}
+		report := NewReport(Run(context.Background(), target, records, Options{}), time.Second)
+		require.Len(t, report.StatusMismatches, 1)
+		assert.Equal(t, http.StatusNotFound, report.StatusMismatches[0].Status)
+	})
+
+	t.Run("ConcurrentKeepsIdsMapped", func(t *testing.T) {
+		var records []Record
+		for i := 0; i < 50; i++ {
+			records = append(records, recordedSession(fmt.Sprintf("recorded-concurrent-%d", i), 31)...)
+		}
+		results := Run(context.Background(), target, records, Options{Concurrency: 8})
+		report := NewReport(results, time.Second)
+		assert.True(t, report.Ok(), "%+v", report)
+		for i, result := range results {
+			assert.Equal(t, i+1, result.Line)
+		}
+	})
+
+	t.Run("PointsBeforeProcess", func(t *testing.T) {
+		// Each points request was recorded before the process request returning its id,
+		// so it's replayed as recorded instead of holding up the only worker
+		var records []Record
+		for i := 0; i < 3; i++ {
+			session := recordedSession(fmt.Sprintf("recorded-early-%d", i), 31)
+			records = append(records, session[1], session[0])
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		results := Run(ctx, target, records, Options{Concurrency: 1})
+		require.NoError(t, ctx.Err())
+		for i, result := range results {
+			assert.Empty(t, result.Error)
+			if i%2 == 0 {
+				assert.Equal(t, http.StatusNotFound, result.Status)
+			} else {
+				assert.Equal(t, http.StatusOK, result.Status)
+			}
+		}
+	})
+
+	t.Run("FileLines", func(t *testing.T) {
+		records, err := ReadRecords(strings.NewReader(`{"method": "GET", "path": "/receipts/unknown/points", "status": 200}
+
+{"method": "GET", "path": "/receipts/unknown/points", "status": 200}
+`))
+		require.NoError(t, err)
+		report := NewReport(Run(context.Background(), target, records, Options{}), time.Second)
+		require.Len(t, report.StatusMismatches, 2)
+		assert.Equal(t, 1, report.StatusMismatches[0].Line)
+		assert.Equal(t, 3, report.StatusMismatches[1].Line)
+	})
+
+	t.Run("Rate", func(t *testing.T) {
+		records := []Record{
+			{Method: "GET", Path: "/openapi.json"},
+			{Method: "GET", Path: "/openapi.json"},
+			{Method: "GET", Path: "/openapi.json"},
+		}
+		start := time.Now()
+		Run(context.Background(), target, records, Options{Concurrency: 3, Rate: 50})
+		// Three requests at 50 per second take at least 40ms after the first tick
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("CanceledContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		results := Run(ctx, target, recordedSession("recorded-canceled", 31), Options{Rate: 1})
+		report := NewReport(results, time.Second)
+		assert.Equal(t, 2, report.Errors)
+	})
+}
+
+func TestRunRemote(t *testing.T) {
+	server := httptest.NewServer(handlers.CreateRouter())
+	defer server.Close()
+
+	results := Run(context.Background(), RemoteTarget{URL: server.URL}, recordedSession("recorded-remote", 31), Options{Concurrency: 2})
+	assert.True(t, NewReport(results, time.Second).Ok())
+
+	results = Run(context.Background(), RemoteTarget{URL: "http://127.0.0.1:1"}, recordedSession("recorded-down", 31), Options{})
+	assert.Equal(t, 2, NewReport(results, time.Second).Errors)
+}
+
+func TestReadRecords(t *testing.T) {
+	records, err := ReadRecords(strings.NewReader(`{"method": "GET", "path": "/openapi.json", "status": 200}
+
+{"method": "POST", "path": "/receipts/process", "body": "{}"}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []Record{
+		{Method: "GET", Path: "/openapi.json", Status: 200, Line: 1},
+		{Method: "POST", Path: "/receipts/process", Body: "{}", Line: 3},
+	}, records)
+
+	_, err = ReadRecords(strings.NewReader(`{"method": "GET"`))
+	assert.Error(t, err)
+
+	// Lines that are json but not request records
+	_, err = ReadRecords(strings.NewReader(`{"request_id": "user-001", "title": "Something"}`))
+	assert.Error(t, err)
+}
+
+func TestNewReportLatency(t *testing.T) {
+	var results []Result
+	for i := 1; i <= 100; i++ {
+		results = append(results, Result{Latency: time.Duration(i) * time.Millisecond})
+	}
+	results = append(results, Result{Error: "failed", Latency: time.Hour})
+
+	report := NewReport(results, time.Second)
+	assert.Equal(t, 101, report.Total)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, LatencyStats{
+		P50: 50 * time.Millisecond,
+		P90: 90 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+		Max: 100 * time.Millisecond,
+	}, report.Latency)
+
+	var text strings.Builder
+	report.WriteText(&text)
+	assert.Contains(t, text.String(), "requests: 101, errors: 1")
+}
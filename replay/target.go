@@ -0,0 +1,59 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+// Something records are replayed against
+type Target interface {
+	// Base url prepended to each record's path
+	BaseURL() string
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Replays against an http.Handler in the same process, such as handlers.CreateRouter()
+type HandlerTarget struct {
+	Handler http.Handler
+}
+
+func (t HandlerTarget) BaseURL() string {
+	return "http://replay.local"
+}
+
+func (t HandlerTarget) Do(req *http.Request) (*http.Response, error) {
+	responseRecorder := httptest.NewRecorder()
+	t.Handler.ServeHTTP(responseRecorder, req)
+	return responseRecorder.Result(), nil
+}
+
+// Replays against a running server
+type RemoteTarget struct {
+	URL    string
+	Client *http.Client
+}
+
+func (t RemoteTarget) BaseURL() string {
+	return t.URL
+}
+
+func (t RemoteTarget) Do(req *http.Request) (*http.Response, error) {
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// Read the whole body so latency includes it and the connection can be reused
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
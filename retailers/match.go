@@ -0,0 +1,97 @@
+package retailers
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Least similarity, from 0 to 1, a name must have to a registered name or alias to fuzzy match it
+const FuzzyThreshold float64 = 0.85
+
+var (
+	// Anything other than letters and digits separates words
+	nonWordRegex = regexp.MustCompile(`[^a-z0-9]+`)
+	// Full words of abbreviations common on receipt headers
+	abbreviations = map[string]string{
+		"mkt":  "market",
+		"mrkt": "market",
+		"ctr":  "center",
+		"whse": "warehouse",
+		"dept": "department",
+		"intl": "international",
+	}
+	// Words that don't tell retailers apart
+	ignoredWords = map[string]bool{"inc": true, "llc": true}
+)
+
+// How a name was matched to a retailer
+type MatchKind string
+
+const (
+	// The name folds to the retailer's name
+	MatchExact MatchKind = "exact"
+	// The name folds to one of the retailer's aliases
+	MatchAlias MatchKind = "alias"
+	// The name is close enough to the retailer's name or one of its aliases
+	MatchFuzzy MatchKind = "fuzzy"
+)
+
+// A retailer a name was matched to and how
+type Match struct {
+	Retailer Retailer  `json:"retailer"`
+	By       MatchKind `json:"by"`
+	// 1 for exact and alias matches, otherwise how similar the name is to the closest name or alias
+	Similarity float64 `json:"similarity"`
+}
+
+/*
+Folds a retailer name to the key it is matched by: lower cased, with "&" spelled
+out, apostrophes dropped, other punctuation taken as spaces and abbreviations
+expanded, so "M & M Corner Mkt" and "m&m corner market" both fold to "m and m corner market".
+*/
+func fold(name string) string {
+	name = strings.ToLower(name)
+	name = strings.ReplaceAll(name, "&", " and ")
+	name = strings.ReplaceAll(name, "'", "")
+	words := []string{}
+	for _, word := range strings.Fields(nonWordRegex.ReplaceAllString(name, " ")) {
+		if ignoredWords[word] {
+			continue
+		}
+		if full, exists := abbreviations[word]; exists {
+			word = full
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " ")
+}
+
+// Returns how similar two keys are, from 0 for nothing in common to 1 for equal keys
+func similarity(a string, b string) float64 {
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// Returns the Levenshtein distance between the two strings, counting bytes
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
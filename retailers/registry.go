@@ -0,0 +1,294 @@
+package retailers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"receipts/models"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const MaxIdLength int = 64
+
+// Most retailers the registry discovers from receipts, beyond which unmatched names get no canonical retailer
+const MaxDiscovered int = 10000
+
+var (
+	ErrNameTaken = errors.New("name is already registered to another retailer")
+
+	idRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// A canonical retailer that receipts' retailer names are matched to
+type Retailer struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Other names receipts from the retailer are printed with, matched ignoring case, punctuation and common abbreviations
+	Aliases []string `json:"aliases,omitempty"`
+	// True for retailers added when a receipt from a retailer matching no other was ingested, rather than registered
+	Discovered bool `json:"discovered,omitempty"`
+}
+
+// Checks the retailer has a valid id and name, and aliases with at least one letter or digit
+func (r Retailer) Validate() error {
+	if len(r.Id) > MaxIdLength || !idRegex.MatchString(r.Id) {
+		return fmt.Errorf("id must be at most %d lower case letters, digits and single dashes", MaxIdLength)
+	}
+	if fold(r.Name) == "" {
+		return fmt.Errorf("name must have a letter or digit")
+	}
+	if len(r.Name) > models.MaxRetailerLength {
+		return fmt.Errorf("name must be at most %d characters", models.MaxRetailerLength)
+	}
+	for _, alias := range r.Aliases {
+		if fold(alias) == "" {
+			return fmt.Errorf("alias %q must have a letter or digit", alias)
+		}
+	}
+	return nil
+}
+
+// The keys the retailer is matched by, its folded name and aliases
+func (r Retailer) keys() []string {
+	keys := []string{fold(r.Name)}
+	for _, alias := range r.Aliases {
+		keys = append(keys, fold(alias))
+	}
+	return keys
+}
+
+/*
+Thread safe registry of canonical retailers. A retailer name is matched to the
+retailer whose name or alias it folds to, or failing that to the one whose name
+or alias it is most similar to, so spelling, case and punctuation differences
+between receipts from the same store don't split it into several retailers.
+*/
+type Registry struct {
+	*sync.RWMutex
+	idToRetailer map[string]*Retailer
+	// Id of the retailer each folded name and alias belongs to
+	keyToId map[string]string
+	// Keys by length, so fuzzy matches only compare keys of a length that can reach FuzzyThreshold
+	lengthToKeys map[int]map[string]bool
+	discovered   int
+	// Ids of discovered retailers absorbed into registered ones, never given to another retailer
+	// so receipts and leaderboards moved off them can't be confused with a new one
+	absorbed map[string]bool
+	// Changed by every add and remove, so Resolve can tell whether a match it made is still current
+	generation int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		RWMutex:      &sync.RWMutex{},
+		idToRetailer: make(map[string]*Retailer),
+		keyToId:      make(map[string]string),
+		lengthToKeys: make(map[int]map[string]bool),
+		absorbed:     make(map[string]bool),
+	}
+}
+
+/*
+Registers the retailer, replacing any retailer with the same id, after waiting for the
+read / write lock. Returns an error wrapping ErrNameTaken when its name or an alias
+belongs to another registered retailer. Discovered retailers with its name or an alias
+are absorbed into it, and their ids are returned so whatever was matched to them can be
+moved to it, as storage.ReceiptStorage.RegisterRetailer does for stored receipts.
+*/
+func (r *Registry) Register(retailer Retailer) (Retailer, []string, error) {
+	if err := retailer.Validate(); err != nil {
+		return Retailer{}, nil, err
+	}
+	retailer.Name = strings.Join(strings.Fields(retailer.Name), " ")
+	retailer.Aliases = slices.Clone(retailer.Aliases)
+	retailer.Discovered = false
+
+	r.Lock()
+	defer r.Unlock()
+	for _, key := range retailer.keys() {
+		ownerId, exists := r.keyToId[key]
+		if exists && ownerId != retailer.Id && !r.idToRetailer[ownerId].Discovered {
+			return Retailer{}, nil, fmt.Errorf("%w: %q is %s", ErrNameTaken, key, ownerId)
+		}
+	}
+
+	if previous, exists := r.idToRetailer[retailer.Id]; exists {
+		r.remove(previous)
+	}
+	var absorbed []string
+	for _, key := range retailer.keys() {
+		if ownerId, exists := r.keyToId[key]; exists {
+			r.remove(r.idToRetailer[ownerId])
+			r.absorbed[ownerId] = true
+			absorbed = append(absorbed, ownerId)
+		}
+	}
+	delete(r.absorbed, retailer.Id)
+	r.add(&retailer)
+	return copyRetailer(&retailer), absorbed, nil
+}
+
+// Adds the retailer and its keys, caller must hold the write lock
+func (r *Registry) add(retailer *Retailer) {
+	r.idToRetailer[retailer.Id] = retailer
+	for _, key := range retailer.keys() {
+		r.keyToId[key] = retailer.Id
+		if r.lengthToKeys[len(key)] == nil {
+			r.lengthToKeys[len(key)] = make(map[string]bool)
+		}
+		r.lengthToKeys[len(key)][key] = true
+	}
+	if retailer.Discovered {
+		r.discovered++
+	}
+	r.generation++
+}
+
+// Removes the retailer and its keys, caller must hold the write lock
+func (r *Registry) remove(retailer *Retailer) {
+	delete(r.idToRetailer, retailer.Id)
+	for _, key := range retailer.keys() {
+		if r.keyToId[key] == retailer.Id {
+			delete(r.keyToId, key)
+			delete(r.lengthToKeys[len(key)], key)
+		}
+	}
+	if retailer.Discovered {
+		r.discovered--
+	}
+	r.generation++
+}
+
+// Returns a copy of the retailer that doesn't share its aliases
+func copyRetailer(retailer *Retailer) Retailer {
+	copied := *retailer
+	copied.Aliases = slices.Clone(retailer.Aliases)
+	return copied
+}
+
+// If the retailer exists, returns it. Waits for the read lock.
+func (r *Registry) Get(id string) (Retailer, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	retailer, exists := r.idToRetailer[id]
+	if !exists {
+		return Retailer{}, false
+	}
+	return copyRetailer(retailer), true
+}
+
+// Returns every retailer, registered and discovered, in order of id. Waits for the read lock.
+func (r *Registry) List() []Retailer {
+	r.RLock()
+	defer r.RUnlock()
+	retailers := make([]Retailer, 0, len(r.idToRetailer))
+	for _, retailer := range r.idToRetailer {
+		retailers = append(retailers, copyRetailer(retailer))
+	}
+	slices.SortFunc(retailers, func(a, b Retailer) int { return strings.Compare(a.Id, b.Id) })
+	return retailers
+}
+
+// Returns the retailer the name matches, if any. Waits for the read lock.
+func (r *Registry) Match(name string) (Match, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.match(fold(name))
+}
+
+/*
+Returns the retailer whose name or alias the key equals, or otherwise the one with the
+name or alias most similar to it, as long as it reaches FuzzyThreshold. Equally similar
+retailers are told apart by id. Only keys whose length is close enough to the key's to
+reach FuzzyThreshold are compared. Caller must hold the read lock.
+*/
+func (r *Registry) match(key string) (Match, bool) {
+	if key == "" {
+		return Match{}, false
+	}
+	if id, exists := r.keyToId[key]; exists {
+		retailer := r.idToRetailer[id]
+		match := Match{Retailer: copyRetailer(retailer), By: MatchAlias, Similarity: 1}
+		if fold(retailer.Name) == key {
+			match.By = MatchExact
+		}
+		return match, true
+	}
+
+	// Keys differing in length by more than this share of the longer one can't reach FuzzyThreshold
+	shortest := int(math.Ceil(float64(len(key))*FuzzyThreshold - 1e-9))
+	longest := int(math.Floor(float64(len(key))/FuzzyThreshold + 1e-9))
+	bestId, best := "", 0.0
+	for length := shortest; length <= longest; length++ {
+		for candidate := range r.lengthToKeys[length] {
+			id := r.keyToId[candidate]
+			score := similarity(key, candidate)
+			if score > best || (score == best && id < bestId) {
+				bestId, best = id, score
+			}
+		}
+	}
+	if best < FuzzyThreshold {
+		return Match{}, false
+	}
+	return Match{Retailer: copyRetailer(r.idToRetailer[bestId]), By: MatchFuzzy, Similarity: best}, true
+}
+
+/*
+Returns the canonical retailer the name matches. A name matching no retailer is
+added as a discovered retailer, so later receipts spelling it differently match it.
+Returns nil for names without a letter or digit, and for names matching no retailer
+once MaxDiscovered retailers were discovered. Waits for the read / write lock.
+*/
+func (r *Registry) Resolve(name string) *models.CanonicalRetailer {
+	key := fold(name)
+	if key == "" {
+		return nil
+	}
+	r.RLock()
+	match, exists := r.match(key)
+	generation := r.generation
+	r.RUnlock()
+	if exists {
+		return &models.CanonicalRetailer{Id: match.Retailer.Id, Name: match.Retailer.Name}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	// Another receipt may have discovered the retailer while the lock was released
+	if r.generation != generation {
+		if match, exists := r.match(key); exists {
+			return &models.CanonicalRetailer{Id: match.Retailer.Id, Name: match.Retailer.Name}
+		}
+	}
+	if r.discovered >= MaxDiscovered {
+		return nil
+	}
+	retailer := &Retailer{Id: r.unusedId(key), Name: strings.Join(strings.Fields(name), " "), Discovered: true}
+	r.add(retailer)
+	return &models.CanonicalRetailer{Id: retailer.Id, Name: retailer.Name}
+}
+
+// Returns an id made from the key that no retailer has or had absorbed, caller must hold the read lock
+func (r *Registry) unusedId(key string) string {
+	id := strings.ReplaceAll(key, " ", "-")
+	if len(id) > MaxIdLength-4 {
+		id = strings.Trim(id[:MaxIdLength-4], "-")
+	}
+	candidate := id
+	for i := 2; ; i++ {
+		if _, exists := r.idToRetailer[candidate]; !exists && !r.absorbed[candidate] {
+			return candidate
+		}
+		candidate = id + "-" + strconv.Itoa(i)
+	}
+}
+
+// Sets the receipt's canonical retailer from its retailer
+func (r *Registry) Canonicalize(receipt *models.Receipt) {
+	receipt.CanonicalRetailer = r.Resolve(receipt.Retailer)
+}
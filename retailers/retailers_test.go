@@ -0,0 +1,154 @@
+package retailers
+
+import (
+	"receipts/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name        string
+		expectedKey string
+	}{
+		{name: "M&M Corner Market", expectedKey: "m and m corner market"},
+		{name: "  M & M Corner Mkt ", expectedKey: "m and m corner market"},
+		{name: "m&m corner market", expectedKey: "m and m corner market"},
+		{name: "Trader Joe's", expectedKey: "trader joes"},
+		{name: "Costco Whse, Inc.", expectedKey: "costco warehouse"},
+		{name: "- & -", expectedKey: "and"},
+		{name: " -- ", expectedKey: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expectedKey, fold(test.name))
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	registry := NewRegistry()
+	_, _, err := registry.Register(Retailer{Id: "m-and-m", Name: "M&M Corner Market", Aliases: []string{"MM Market"}})
+	require.NoError(t, err)
+	_, _, err = registry.Register(Retailer{Id: "target", Name: "Target"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		expectedId string
+		expectedBy MatchKind
+	}{
+		{name: "m & m corner mkt", expectedId: "m-and-m", expectedBy: MatchExact},
+		{name: "MM MKT", expectedId: "m-and-m", expectedBy: MatchAlias},
+		{name: "M&M Corner Markt", expectedId: "m-and-m", expectedBy: MatchFuzzy},
+		{name: "TARGET", expectedId: "target", expectedBy: MatchExact},
+		// One edit in a short name is too large a share of it to be a typo
+		{name: "Targe"},
+		{name: "Walgreens"},
+		{name: "&&"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, exists := registry.Match(test.name)
+			assert.Equal(t, test.expectedId != "", exists)
+			assert.Equal(t, test.expectedId, match.Retailer.Id)
+			assert.Equal(t, test.expectedBy, match.By)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	registry := NewRegistry()
+
+	first := registry.Resolve("  M&M   Corner Market")
+	assert.Equal(t, &models.CanonicalRetailer{Id: "m-and-m-corner-market", Name: "M&M Corner Market"}, first)
+	assert.Equal(t, first, registry.Resolve("M & M Corner Mkt"), "later spellings match the discovered retailer")
+	assert.Nil(t, registry.Resolve(" - "))
+
+	retailers := registry.List()
+	require.Len(t, retailers, 1)
+	assert.True(t, retailers[0].Discovered)
+
+	// Registering a retailer with a discovered retailer's name absorbs it
+	registered, absorbed, err := registry.Register(Retailer{Id: "mm", Name: "M and M", Aliases: []string{"M&M Corner Market"}})
+	require.NoError(t, err)
+	assert.False(t, registered.Discovered)
+	assert.Equal(t, []string{"m-and-m-corner-market"}, absorbed)
+	assert.Equal(t, &models.CanonicalRetailer{Id: "mm", Name: "M and M"}, registry.Resolve("m&m corner mkt"))
+	_, exists := registry.Get("m-and-m-corner-market")
+	assert.False(t, exists)
+
+	receipt := &models.Receipt{Retailer: "M and M"}
+	registry.Canonicalize(receipt)
+	assert.Equal(t, "M and M", receipt.RetailerName())
+
+	// Absorbed ids aren't given to retailers discovered later
+	_, _, err = registry.Register(Retailer{Id: "mm", Name: "M and M"})
+	require.NoError(t, err)
+	assert.Equal(t, "m-and-m-corner-market-2", registry.Resolve("M&M Corner Market").Id)
+}
+
+func TestResolveBounds(t *testing.T) {
+	registry := NewRegistry()
+	_, _, err := registry.Register(Retailer{Id: "alphabet", Name: "abcdefghijklmnopqrst"})
+	require.NoError(t, err)
+
+	// Keys 3 shorter than a 20 character name are exactly FuzzyThreshold similar to it
+	match, exists := registry.Match("abcdefghijklmnopq")
+	require.True(t, exists)
+	assert.Equal(t, MatchFuzzy, match.By)
+	_, exists = registry.Match("abcdefghijklmnop")
+	assert.False(t, exists)
+
+	registry.discovered = MaxDiscovered
+	assert.Nil(t, registry.Resolve("Costco"), "no more retailers are discovered past MaxDiscovered")
+	assert.Equal(t, "alphabet", registry.Resolve("ABCDEFGHIJKLMNOPQRST").Id, "names still match known retailers")
+	_, _, err = registry.Register(Retailer{Id: "costco", Name: "Costco"})
+	require.NoError(t, err)
+	assert.Equal(t, "costco", registry.Resolve("Costco").Id)
+}
+
+func TestRegister(t *testing.T) {
+	registry := NewRegistry()
+	_, _, err := registry.Register(Retailer{Id: "target", Name: "Target", Aliases: []string{"Target Store"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		testName      string
+		retailer      Retailer
+		expectedError string
+	}{
+		{testName: "InvalidId", retailer: Retailer{Id: "Target!", Name: "Target"}, expectedError: "id must be"},
+		{testName: "EmptyName", retailer: Retailer{Id: "empty", Name: " - "}, expectedError: "name must have"},
+		{testName: "EmptyAlias", retailer: Retailer{Id: "walgreens", Name: "Walgreens", Aliases: []string{"--"}}, expectedError: "alias"},
+		{testName: "NameTaken", retailer: Retailer{Id: "target-2", Name: "TARGET"}, expectedError: ErrNameTaken.Error()},
+		{testName: "AliasTaken", retailer: Retailer{Id: "walgreens", Name: "Walgreens", Aliases: []string{"target store"}}, expectedError: ErrNameTaken.Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			_, _, err := registry.Register(test.retailer)
+			assert.ErrorContains(t, err, test.expectedError)
+		})
+	}
+
+	// Registering an existing id replaces its name and aliases
+	_, _, err = registry.Register(Retailer{Id: "target", Name: "Target Corporation"})
+	require.NoError(t, err)
+	_, exists := registry.Match("Target Store")
+	assert.False(t, exists)
+	retailer, exists := registry.Get("target")
+	require.True(t, exists)
+	assert.Equal(t, "Target Corporation", retailer.Name)
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, similarity("target", "target"))
+	assert.Equal(t, 0.0, similarity("abc", "xyz"))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+	assert.InDelta(t, 0.95, similarity("m and m corner market", "m and m corner markt"), 0.01)
+}
@@ -0,0 +1,205 @@
+package rewards
+
+import (
+	"errors"
+	"fmt"
+	"receipts/accounts"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRewardNotFound     = errors.New("reward not found")
+	ErrOutOfStock         = errors.New("reward is out of stock")
+	ErrRedemptionNotFound = errors.New("redemption not found")
+	ErrAlreadyCancelled   = errors.New("redemption is already cancelled")
+)
+
+/*
+Thread safe rewards catalog and history of redemptions, debiting and refunding
+points through the accounts' ledgers.
+
+Redeeming and cancelling hold the catalog's write lock while appending to the
+ledger, so inventory and the ledger always change together, and the ledger refuses
+any redeem entry that would take an account's balance below zero.
+*/
+type Catalog struct {
+	*sync.RWMutex
+	ledger         *accounts.Store
+	idToReward     map[string]Reward
+	rewardIds      []string
+	idToRedemption map[string]Redemption
+	// Redemption ids of each account, oldest first
+	accountToRedemptions map[string][]string
+}
+
+func NewCatalog(ledger *accounts.Store) *Catalog {
+	return &Catalog{
+		RWMutex:              &sync.RWMutex{},
+		ledger:               ledger,
+		idToReward:           make(map[string]Reward),
+		idToRedemption:       make(map[string]Redemption),
+		accountToRedemptions: make(map[string][]string),
+	}
+}
+
+// Returns every reward in the order they were created after waiting for the read lock.
+func (c *Catalog) ListRewards() []Reward {
+	c.RLock()
+	defer c.RUnlock()
+	rewards := make([]Reward, 0, len(c.rewardIds))
+	for _, id := range c.rewardIds {
+		rewards = append(rewards, c.idToReward[id])
+	}
+	return rewards
+}
+
+// Returns the reward with the id, or false if there is none, after waiting for the read lock.
+func (c *Catalog) GetReward(id string) (Reward, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	reward, exists := c.idToReward[id]
+	return reward, exists
+}
+
+/*
+Saves the reward under a new id and returns it with the id set, after waiting
+for the read / write lock. The reward must already be valid.
+*/
+func (c *Catalog) CreateReward(reward Reward) Reward {
+	c.Lock()
+	defer c.Unlock()
+	reward.Id = uuid.New().String()
+	c.idToReward[reward.Id] = reward
+	c.rewardIds = append(c.rewardIds, reward.Id)
+	return reward
+}
+
+/*
+Replaces the reward with the id, keeping the id, and returns false if there is
+no such reward. Past redemptions keep the name and cost they were made with.
+Waits for the read / write lock.
+*/
+func (c *Catalog) UpdateReward(id string, reward Reward) (Reward, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if _, exists := c.idToReward[id]; !exists {
+		return Reward{}, false
+	}
+	reward.Id = id
+	c.idToReward[id] = reward
+	return reward, true
+}
+
+// Removes the reward, returning false if there was none, after waiting for the read / write lock.
+func (c *Catalog) DeleteReward(id string) bool {
+	c.Lock()
+	defer c.Unlock()
+	if _, exists := c.idToReward[id]; !exists {
+		return false
+	}
+	delete(c.idToReward, id)
+	c.rewardIds = slices.DeleteFunc(c.rewardIds, func(existing string) bool { return existing == id })
+	return true
+}
+
+/*
+Spends the account's points on the reward, appending a redeem entry to its ledger
+and taking one from the reward's inventory, after waiting for the read / write lock.
+Returns ErrRewardNotFound, ErrOutOfStock, accounts.ErrAccountNotFound or
+accounts.ErrInsufficientBalance without changing anything when it can't.
+*/
+func (c *Catalog) Redeem(accountId string, rewardId string) (Redemption, error) {
+	c.Lock()
+	defer c.Unlock()
+	reward, exists := c.idToReward[rewardId]
+	if !exists {
+		return Redemption{}, fmt.Errorf("%w: %s", ErrRewardNotFound, rewardId)
+	}
+	if reward.Inventory != nil && *reward.Inventory == 0 {
+		return Redemption{}, fmt.Errorf("%w: %s", ErrOutOfStock, reward.Name)
+	}
+
+	redemption := Redemption{
+		Id:         uuid.New().String(),
+		AccountId:  accountId,
+		RewardId:   reward.Id,
+		RewardName: reward.Name,
+		Cost:       reward.Cost,
+		Status:     RedemptionCompleted,
+		CreatedAt:  time.Now().UTC(),
+	}
+	_, err := c.ledger.Append(accounts.Entry{
+		AccountId:    accountId,
+		Type:         accounts.EntryRedeem,
+		Points:       -reward.Cost,
+		RedemptionId: redemption.Id,
+		Reason:       "redeemed " + reward.Name,
+	})
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	if reward.Inventory != nil {
+		remaining := *reward.Inventory - 1
+		reward.Inventory = &remaining
+		c.idToReward[reward.Id] = reward
+	}
+	c.idToRedemption[redemption.Id] = redemption
+	c.accountToRedemptions[accountId] = append(c.accountToRedemptions[accountId], redemption.Id)
+	return redemption, nil
+}
+
+/*
+Cancels one of the account's redemptions, refunding its cost to the account's ledger
+and returning the reward to inventory if it is still in the catalog, after waiting
+for the read / write lock. Returns ErrRedemptionNotFound when the account has no such
+redemption, and ErrAlreadyCancelled when it was cancelled before.
+*/
+func (c *Catalog) Cancel(accountId string, redemptionId string) (Redemption, error) {
+	c.Lock()
+	defer c.Unlock()
+	redemption, exists := c.idToRedemption[redemptionId]
+	if !exists || redemption.AccountId != accountId {
+		return Redemption{}, fmt.Errorf("%w: %s", ErrRedemptionNotFound, redemptionId)
+	}
+	if redemption.Status == RedemptionCancelled {
+		return Redemption{}, fmt.Errorf("%w: %s", ErrAlreadyCancelled, redemptionId)
+	}
+
+	_, err := c.ledger.Append(accounts.Entry{
+		AccountId:    accountId,
+		Type:         accounts.EntryRefund,
+		Points:       redemption.Cost,
+		RedemptionId: redemption.Id,
+		Reason:       "cancelled " + redemption.RewardName,
+	})
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	if reward, exists := c.idToReward[redemption.RewardId]; exists && reward.Inventory != nil {
+		restocked := *reward.Inventory + 1
+		reward.Inventory = &restocked
+		c.idToReward[reward.Id] = reward
+	}
+	cancelledAt := time.Now().UTC()
+	redemption.Status = RedemptionCancelled
+	redemption.CancelledAt = &cancelledAt
+	c.idToRedemption[redemption.Id] = redemption
+	return redemption, nil
+}
+
+// Returns the account's redemptions, oldest first, after waiting for the read lock.
+func (c *Catalog) Redemptions(accountId string) []Redemption {
+	c.RLock()
+	defer c.RUnlock()
+	redemptions := make([]Redemption, 0, len(c.accountToRedemptions[accountId]))
+	for _, id := range c.accountToRedemptions[accountId] {
+		redemptions = append(redemptions, c.idToRedemption[id])
+	}
+	return redemptions
+}
@@ -0,0 +1,61 @@
+package rewards
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const MaxNameLength int = 256
+
+// Something in the rewards catalog that accounts can spend points on
+type Reward struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Points debited from an account for each redemption
+	Cost int `json:"cost"`
+	// Number of redemptions left, unlimited when nil
+	Inventory *int `json:"inventory,omitempty"`
+}
+
+// Checks the reward has a name, a positive cost and no negative inventory
+func (r Reward) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Name) > MaxNameLength {
+		return fmt.Errorf("name must be at most %d characters", MaxNameLength)
+	}
+	if r.Cost <= 0 {
+		return fmt.Errorf("cost must be more than 0")
+	}
+	if r.Inventory != nil && *r.Inventory < 0 {
+		return fmt.Errorf("inventory must not be negative")
+	}
+	return nil
+}
+
+// Whether a redemption still holds its points or has been cancelled and refunded
+type RedemptionStatus string
+
+const (
+	RedemptionCompleted RedemptionStatus = "completed"
+	RedemptionCancelled RedemptionStatus = "cancelled"
+)
+
+/*
+An account spending points on a reward. The reward's name and cost are copied so
+history stays accurate after the reward is changed or removed from the catalog.
+*/
+type Redemption struct {
+	Id         string           `json:"id"`
+	AccountId  string           `json:"accountId"`
+	RewardId   string           `json:"rewardId"`
+	RewardName string           `json:"rewardName"`
+	Cost       int              `json:"cost"`
+	Status     RedemptionStatus `json:"status"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	// Set when the redemption was cancelled and its points refunded
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
+}
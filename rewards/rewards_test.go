@@ -0,0 +1,110 @@
+package rewards
+
+import (
+	"receipts/accounts"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func inventory(count int) *int {
+	return &count
+}
+
+func TestRewardValidate(t *testing.T) {
+	tests := []struct {
+		testName    string
+		reward      Reward
+		expectedErr bool
+	}{
+		{testName: "Valid", reward: Reward{Name: "Gift card", Cost: 500}},
+		{testName: "WithInventory", reward: Reward{Name: "Gift card", Cost: 500, Inventory: inventory(0)}},
+		{testName: "MissingName", reward: Reward{Name: " ", Cost: 500}, expectedErr: true},
+		{testName: "Free", reward: Reward{Name: "Gift card"}, expectedErr: true},
+		{testName: "NegativeInventory", reward: Reward{Name: "Gift card", Cost: 500, Inventory: inventory(-1)}, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Equal(t, test.expectedErr, test.reward.Validate() != nil)
+		})
+	}
+}
+
+// Returns a catalog along with an account holding balance points
+func setup(t *testing.T, balance int) (*Catalog, *accounts.Store, string) {
+	ledger := accounts.NewStore()
+	account := ledger.CreateAccount(accounts.Account{Name: "Jane"})
+	_, err := ledger.Append(accounts.Entry{AccountId: account.Id, Type: accounts.EntryEarn, Points: balance})
+	require.NoError(t, err)
+	return NewCatalog(ledger), ledger, account.Id
+}
+
+func TestRedeemAndCancel(t *testing.T) {
+	catalog, ledger, accountId := setup(t, 100)
+	reward := catalog.CreateReward(Reward{Name: "Gift card", Cost: 60, Inventory: inventory(1)})
+
+	redemption, err := catalog.Redeem(accountId, reward.Id)
+	require.NoError(t, err)
+	assert.Equal(t, RedemptionCompleted, redemption.Status)
+	assert.Equal(t, "Gift card", redemption.RewardName)
+	balance, _ := ledger.Balance(accountId)
+	assert.Equal(t, 40, balance)
+	stored, _ := catalog.GetReward(reward.Id)
+	assert.Equal(t, 0, *stored.Inventory)
+
+	_, err = catalog.Redeem(accountId, reward.Id)
+	assert.ErrorIs(t, err, ErrOutOfStock)
+	unlimited := catalog.CreateReward(Reward{Name: "Sticker", Cost: 50})
+	_, err = catalog.Redeem(accountId, unlimited.Id)
+	assert.ErrorIs(t, err, accounts.ErrInsufficientBalance)
+	_, err = catalog.Redeem(accountId, "unknown")
+	assert.ErrorIs(t, err, ErrRewardNotFound)
+	_, err = catalog.Redeem("unknown", unlimited.Id)
+	assert.ErrorIs(t, err, accounts.ErrAccountNotFound)
+
+	_, err = catalog.Cancel("another account", redemption.Id)
+	assert.ErrorIs(t, err, ErrRedemptionNotFound)
+	cancelled, err := catalog.Cancel(accountId, redemption.Id)
+	require.NoError(t, err)
+	assert.Equal(t, RedemptionCancelled, cancelled.Status)
+	assert.NotNil(t, cancelled.CancelledAt)
+	_, err = catalog.Cancel(accountId, redemption.Id)
+	assert.ErrorIs(t, err, ErrAlreadyCancelled)
+
+	balance, _ = ledger.Balance(accountId)
+	assert.Equal(t, 100, balance)
+	stored, _ = catalog.GetReward(reward.Id)
+	assert.Equal(t, 1, *stored.Inventory)
+	assert.Equal(t, []Redemption{cancelled}, catalog.Redemptions(accountId))
+
+	entries, _ := ledger.Ledger(accountId)
+	require.Len(t, entries, 3)
+	assert.Equal(t, accounts.EntryRedeem, entries[1].Type)
+	assert.Equal(t, accounts.EntryRefund, entries[2].Type)
+	assert.Equal(t, redemption.Id, entries[2].RedemptionId)
+}
+
+func TestRedeemConcurrently(t *testing.T) {
+	// Enough points for 10 redemptions and enough stock for 15, with 50 attempts
+	catalog, ledger, accountId := setup(t, 100)
+	reward := catalog.CreateReward(Reward{Name: "Gift card", Cost: 10, Inventory: inventory(15)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			catalog.Redeem(accountId, reward.Id)
+		}()
+	}
+	wg.Wait()
+
+	balance, _ := ledger.Balance(accountId)
+	assert.Equal(t, 0, balance)
+	assert.Len(t, catalog.Redemptions(accountId), 10)
+	stored, _ := catalog.GetReward(reward.Id)
+	assert.Equal(t, 5, *stored.Inventory)
+}
@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"fmt"
+	"receipts/models"
+	"receipts/rpc/receiptspb"
+)
+
+/*
+Converts a protobuf receipt into models.Receipt. Only the date and time are
+checked here, the rest of the validation is left to Receipt.Validate.
+*/
+func FromProto(protoReceipt *receiptspb.Receipt) (*models.Receipt, error) {
+	if protoReceipt == nil {
+		return nil, fmt.Errorf("receipt is required")
+	}
+
+	purchaseDate, err := models.ParsePurchaseDate(protoReceipt.GetPurchaseDate())
+	if err != nil {
+		return nil, err
+	}
+	purchaseTime, err := models.ParsePurchaseTime(protoReceipt.GetPurchaseTime())
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &models.Receipt{
+		Retailer:      protoReceipt.GetRetailer(),
+		PurchaseDate:  purchaseDate,
+		PurchaseTime:  purchaseTime,
+		Total:         protoReceipt.GetTotal(),
+		Subtotal:      protoReceipt.GetSubtotal(),
+		Tip:           protoReceipt.GetTip(),
+		PaymentMethod: models.PaymentMethod(protoReceipt.GetPaymentMethod()),
+	}
+	for _, item := range protoReceipt.GetItems() {
+		receipt.Items = append(receipt.Items, models.Item{
+			ShortDescription: item.GetShortDescription(),
+			Price:            item.GetPrice(),
+			Quantity:         item.GetQuantity(),
+			UnitPrice:        item.GetUnitPrice(),
+		})
+	}
+	for _, tax := range protoReceipt.GetTaxes() {
+		receipt.Taxes = append(receipt.Taxes, models.Tax{Name: tax.GetName(), Amount: tax.GetAmount()})
+	}
+	for _, discount := range protoReceipt.GetDiscounts() {
+		receipt.Discounts = append(receipt.Discounts, models.Discount{
+			Description: discount.GetDescription(),
+			Code:        discount.GetCode(),
+			Amount:      discount.GetAmount(),
+		})
+	}
+	return receipt, nil
+}
+
+// Converts models.Receipt into its protobuf equivalent.
+func ToProto(receipt *models.Receipt) *receiptspb.Receipt {
+	protoReceipt := &receiptspb.Receipt{
+		Retailer:      receipt.Retailer,
+		PurchaseDate:  receipt.PurchaseDate.String(),
+		PurchaseTime:  receipt.PurchaseTime.String(),
+		Total:         receipt.Total,
+		Subtotal:      receipt.Subtotal,
+		Tip:           receipt.Tip,
+		PaymentMethod: string(receipt.PaymentMethod),
+	}
+	for _, item := range receipt.Items {
+		protoReceipt.Items = append(protoReceipt.Items, &receiptspb.Item{
+			ShortDescription: item.ShortDescription,
+			Price:            item.Price,
+			Quantity:         item.Quantity,
+			UnitPrice:        item.UnitPrice,
+		})
+	}
+	for _, tax := range receipt.Taxes {
+		protoReceipt.Taxes = append(protoReceipt.Taxes, &receiptspb.Tax{Name: tax.Name, Amount: tax.Amount})
+	}
+	for _, discount := range receipt.Discounts {
+		protoReceipt.Discounts = append(protoReceipt.Discounts, &receiptspb.Discount{
+			Description: discount.Description,
+			Code:        discount.Code,
+			Amount:      discount.Amount,
+		})
+	}
+	return protoReceipt
+}
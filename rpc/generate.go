@@ -0,0 +1,4 @@
+package rpc
+
+// Regenerate receiptspb after changing receipts.proto (requires protoc, protoc-gen-go and protoc-gen-go-grpc)
+//go:generate protoc --go_out=. --go_opt=module=receipts/rpc --go-grpc_out=. --go-grpc_opt=module=receipts/rpc receipts.proto
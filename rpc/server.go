@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"receipts/accounts"
+	"receipts/ingest"
+	"receipts/rpc/receiptspb"
+	"receipts/storage"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Implements receiptspb.ReceiptServiceServer over the same storage and points packages as the http handlers.
+type Server struct {
+	receiptspb.UnimplementedReceiptServiceServer
+	storage *storage.ReceiptStorage
+	// Stores processed receipts, the same way as the http handlers
+	ingester *ingest.Ingester
+}
+
+func NewServer(storage *storage.ReceiptStorage, ingester *ingest.Ingester) *Server {
+	return &Server{
+		storage:  storage,
+		ingester: ingester,
+	}
+}
+
+/*
+Creates a grpc.Server with the ReceiptService registered on it.
+
+Created this function here instead of main package so that tests can serve
+the same service as main does.
+*/
+func CreateGRPCServer(storage *storage.ReceiptStorage, ingester *ingest.Ingester, opts ...grpc.ServerOption) *grpc.Server {
+	grpcServer := grpc.NewServer(opts...)
+	receiptspb.RegisterReceiptServiceServer(grpcServer, NewServer(storage, ingester))
+	return grpcServer
+}
+
+func (s *Server) ProcessReceipt(ctx context.Context, req *receiptspb.ProcessReceiptRequest) (*receiptspb.ProcessReceiptResponse, error) {
+	id, err := s.processReceipt(req.GetReceipt(), req.GetAccountId())
+	if errors.Is(err, accounts.ErrAccountNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &receiptspb.ProcessReceiptResponse{Id: id.String()}, nil
+}
+
+func (s *Server) GetPoints(ctx context.Context, req *receiptspb.GetPointsRequest) (*receiptspb.GetPointsResponse, error) {
+	stored, err := s.getStoredReceipt(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return &receiptspb.GetPointsResponse{Points: int64(stored.Score().Points)}, nil
+}
+
+func (s *Server) GetReceipt(ctx context.Context, req *receiptspb.GetReceiptRequest) (*receiptspb.GetReceiptResponse, error) {
+	stored, err := s.getStoredReceipt(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return &receiptspb.GetReceiptResponse{Receipt: ToProto(stored.Receipt)}, nil
+}
+
+/*
+Reads receipts until the client closes its side of the stream. An invalid receipt
+does not end the stream, its error is sent back in place of an id instead.
+*/
+func (s *Server) ProcessReceipts(stream receiptspb.ReceiptService_ProcessReceiptsServer) error {
+	for index := int64(0); ; index++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		response := &receiptspb.ProcessReceiptsResponse{Index: index}
+		id, err := s.processReceipt(req.GetReceipt(), req.GetAccountId())
+		if err != nil {
+			response.Error = err.Error()
+		} else {
+			response.Id = id.String()
+		}
+
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+}
+
+/*
+Validates, scores and stores the receipt, returning the id it was stored under. When
+accountId isn't empty the receipt's points are earned by that account.
+*/
+func (s *Server) processReceipt(protoReceipt *receiptspb.Receipt, accountId string) (uuid.UUID, error) {
+	receipt, err := FromProto(protoReceipt)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := receipt.Validate(); err != nil {
+		return uuid.Nil, err
+	}
+
+	return s.ingester.Ingest(receipt, accountId)
+}
+
+// Returns the stored receipt, or a NotFound status error if there isn't one
+func (s *Server) getStoredReceipt(id string) (storage.StoredReceipt, error) {
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		return storage.StoredReceipt{}, status.Error(codes.NotFound, "receipt with id "+id+" not found")
+	}
+
+	stored, exists := s.storage.GetStoredReceipt(parsedId)
+	if !exists {
+		return storage.StoredReceipt{}, status.Error(codes.NotFound, "receipt with id "+id+" not found")
+	}
+	return stored, nil
+}
@@ -0,0 +1,266 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"receipts/accounts"
+	"receipts/ingest"
+	"receipts/leaderboards"
+	"receipts/points"
+	"receipts/rpc/receiptspb"
+	"receipts/storage"
+	"receipts/tiers"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+/*
+Starts the service on an in-process listener and returns a client connected to it,
+along with the accounts receipts are credited to.
+*/
+func newTestClient(t *testing.T) (receiptspb.ReceiptServiceClient, *accounts.Store) {
+	listener := bufconn.Listen(1024 * 1024)
+	receiptStorage, accountStore := storage.NewReceiptStorage(), accounts.NewStore()
+	scorer := points.Scorer{Selection: points.SelectByPurchaseDate}
+	ingester := ingest.NewIngester(receiptStorage, accountStore, tiers.NewStore(accountStore), leaderboards.NewBoards(), scorer)
+	grpcServer := CreateGRPCServer(receiptStorage, ingester)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return receiptspb.NewReceiptServiceClient(conn), accountStore
+}
+
+func exampleReceipt() *receiptspb.Receipt {
+	return &receiptspb.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []*receiptspb.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+			{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: "12.00"},
+		},
+		Total: "35.35",
+	}
+}
+
+// The example receipt with every optional field set
+func extendedExampleReceipt() *receiptspb.Receipt {
+	receipt := exampleReceipt()
+	receipt.Items[0].Quantity, receipt.Items[0].UnitPrice = 1, "6.49"
+	receipt.Subtotal = "35.35"
+	receipt.Taxes = []*receiptspb.Tax{{Name: "Sales Tax", Amount: "2.50"}}
+	receipt.Discounts = []*receiptspb.Discount{{Description: "Coupon", Code: "SAVE3", Amount: "3.00"}}
+	receipt.Tip = "0.50"
+	receipt.PaymentMethod = "credit"
+	return receipt
+}
+
+func TestProcessReceipt(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		testName     string
+		inputReceipt *receiptspb.Receipt
+		expectedCode codes.Code
+	}{
+		{
+			testName:     "ValidReceipt",
+			inputReceipt: exampleReceipt(),
+			expectedCode: codes.OK,
+		},
+		{
+			testName:     "MissingReceipt",
+			inputReceipt: nil,
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			testName: "InvalidDate",
+			inputReceipt: func() *receiptspb.Receipt {
+				receipt := exampleReceipt()
+				receipt.PurchaseDate = "2022-01-1"
+				return receipt
+			}(),
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			testName: "InvalidTotal",
+			inputReceipt: func() *receiptspb.Receipt {
+				receipt := exampleReceipt()
+				receipt.Total = "35.353"
+				return receipt
+			}(),
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			testName:     "ExtendedReceipt",
+			inputReceipt: extendedExampleReceipt(),
+			expectedCode: codes.OK,
+		},
+		{
+			testName: "InvalidPaymentMethod",
+			inputReceipt: func() *receiptspb.Receipt {
+				receipt := extendedExampleReceipt()
+				receipt.PaymentMethod = "bitcoin"
+				return receipt
+			}(),
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			testName: "UnreconciledTax",
+			inputReceipt: func() *receiptspb.Receipt {
+				receipt := extendedExampleReceipt()
+				receipt.Taxes[0].Amount = "5.00"
+				return receipt
+			}(),
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			response, err := client.ProcessReceipt(ctx, &receiptspb.ProcessReceiptRequest{Receipt: test.inputReceipt})
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			if test.expectedCode == codes.OK {
+				_, err = uuid.Parse(response.GetId())
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetPointsAndReceipt(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	t.Run("InvalidIdFormat", func(t *testing.T) {
+		_, err := client.GetPoints(ctx, &receiptspb.GetPointsRequest{Id: "1234"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("NonExistentReceipt", func(t *testing.T) {
+		_, err := client.GetPoints(ctx, &receiptspb.GetPointsRequest{Id: uuid.New().String()})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = client.GetReceipt(ctx, &receiptspb.GetReceiptRequest{Id: uuid.New().String()})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("ExistingReceipt", func(t *testing.T) {
+		processed, err := client.ProcessReceipt(ctx, &receiptspb.ProcessReceiptRequest{Receipt: exampleReceipt()})
+		require.NoError(t, err)
+
+		pointsResponse, err := client.GetPoints(ctx, &receiptspb.GetPointsRequest{Id: processed.GetId()})
+		require.NoError(t, err)
+		assert.Equal(t, int64(28), pointsResponse.GetPoints())
+
+		receiptResponse, err := client.GetReceipt(ctx, &receiptspb.GetReceiptRequest{Id: processed.GetId()})
+		require.NoError(t, err)
+		assert.Equal(t, exampleReceipt().String(), receiptResponse.GetReceipt().String())
+	})
+
+	t.Run("ExtendedReceipt", func(t *testing.T) {
+		processed, err := client.ProcessReceipt(ctx, &receiptspb.ProcessReceiptRequest{Receipt: extendedExampleReceipt()})
+		require.NoError(t, err)
+
+		// The optional fields don't change the points
+		pointsResponse, err := client.GetPoints(ctx, &receiptspb.GetPointsRequest{Id: processed.GetId()})
+		require.NoError(t, err)
+		assert.Equal(t, int64(28), pointsResponse.GetPoints())
+
+		receiptResponse, err := client.GetReceipt(ctx, &receiptspb.GetReceiptRequest{Id: processed.GetId()})
+		require.NoError(t, err)
+		assert.Equal(t, extendedExampleReceipt().String(), receiptResponse.GetReceipt().String())
+	})
+}
+
+func TestProcessReceipts(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	invalidReceipt := exampleReceipt()
+	invalidReceipt.Retailer = "Target!"
+	requests := []*receiptspb.Receipt{exampleReceipt(), invalidReceipt, exampleReceipt()}
+
+	stream, err := client.ProcessReceipts(ctx)
+	require.NoError(t, err)
+	for _, receipt := range requests {
+		require.NoError(t, stream.Send(&receiptspb.ProcessReceiptRequest{Receipt: receipt}))
+	}
+	require.NoError(t, stream.CloseSend())
+
+	var responses []*receiptspb.ProcessReceiptsResponse
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		responses = append(responses, response)
+	}
+
+	require.Len(t, responses, len(requests))
+	for i, response := range responses {
+		assert.Equal(t, int64(i), response.GetIndex())
+	}
+	assert.NotEmpty(t, responses[0].GetId())
+	assert.Empty(t, responses[0].GetError())
+	assert.Empty(t, responses[1].GetId())
+	assert.NotEmpty(t, responses[1].GetError())
+	assert.NotEmpty(t, responses[2].GetId())
+
+	// Receipts from the stream are readable through the unary rpcs
+	pointsResponse, err := client.GetPoints(ctx, &receiptspb.GetPointsRequest{Id: responses[2].GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, int64(28), pointsResponse.GetPoints())
+}
+
+func TestProcessReceiptForAccount(t *testing.T) {
+	client, accountStore := newTestClient(t)
+	ctx := context.Background()
+	account := accountStore.CreateAccount(accounts.Account{Name: "Jane"})
+
+	t.Run("Unary", func(t *testing.T) {
+		response, err := client.ProcessReceipt(ctx, &receiptspb.ProcessReceiptRequest{Receipt: exampleReceipt(), AccountId: account.Id})
+		require.NoError(t, err)
+		receiptAccount, _ := accountStore.ReceiptAccount(response.GetId())
+		assert.Equal(t, account.Id, receiptAccount)
+		balance, _ := accountStore.Balance(account.Id)
+		assert.Equal(t, 28, balance)
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		stream, err := client.ProcessReceipts(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&receiptspb.ProcessReceiptRequest{Receipt: exampleReceipt(), AccountId: account.Id}))
+		require.NoError(t, stream.CloseSend())
+		response, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Empty(t, response.GetError())
+		balance, _ := accountStore.Balance(account.Id)
+		assert.Equal(t, 56, balance)
+	})
+
+	t.Run("UnknownAccount", func(t *testing.T) {
+		_, err := client.ProcessReceipt(ctx, &receiptspb.ProcessReceiptRequest{Receipt: exampleReceipt(), AccountId: "missing"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
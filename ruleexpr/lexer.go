@@ -0,0 +1,117 @@
+package ruleexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// Value of number and string tokens
+	number float64
+	str    string
+	// Byte offset in the source, reported in errors
+	pos int
+}
+
+// Operators, longest first so two character operators win over their prefixes
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "!", "?", ":", "(", ")", ","}
+
+// Splits the source into tokens, ending with a tokenEOF
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(source); {
+		char := rune(source[pos])
+		switch {
+		case unicode.IsSpace(char):
+			pos++
+
+		case unicode.IsDigit(char) || (char == '.' && pos+1 < len(source) && unicode.IsDigit(rune(source[pos+1]))):
+			end := pos
+			for end < len(source) && (unicode.IsDigit(rune(source[end])) || source[end] == '.') {
+				end++
+			}
+			number, err := strconv.ParseFloat(source[pos:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", source[pos:end], pos)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[pos:end], number: number, pos: pos})
+			pos = end
+
+		case char == '"':
+			str, end, err := lexString(source, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: source[pos:end], str: str, pos: pos})
+			pos = end
+
+		case unicode.IsLetter(char) || char == '_':
+			end := pos
+			for end < len(source) && (unicode.IsLetter(rune(source[end])) || unicode.IsDigit(rune(source[end])) || source[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[pos:end], pos: pos})
+			pos = end
+
+		default:
+			operator := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(source[pos:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", char, pos)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: pos})
+			pos += len(operator)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// Reads the double quoted string starting at start, returning its value and the offset after the closing quote
+func lexString(source string, start int) (string, int, error) {
+	var builder strings.Builder
+	for pos := start + 1; pos < len(source); pos++ {
+		switch source[pos] {
+		case '"':
+			return builder.String(), pos + 1, nil
+		case '\\':
+			pos++
+			if pos == len(source) {
+				break
+			}
+			switch source[pos] {
+			case '"', '\\':
+				builder.WriteByte(source[pos])
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			default:
+				// Kept as is so regular expression escapes such as \d work without doubling
+				builder.WriteByte('\\')
+				builder.WriteByte(source[pos])
+			}
+		default:
+			builder.WriteByte(source[pos])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at %d", start)
+}
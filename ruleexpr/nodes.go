@@ -0,0 +1,350 @@
+package ruleexpr
+
+import (
+	"fmt"
+	"math"
+	"receipts/models"
+	"regexp"
+	"strings"
+)
+
+// Static type of an expression, checked when it is compiled
+type valueType int
+
+const (
+	typeNumber valueType = iota
+	typeString
+	typeBool
+	typeItems
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeNumber:
+		return "number"
+	case typeString:
+		return "string"
+	case typeBool:
+		return "bool"
+	default:
+		return "items"
+	}
+}
+
+// State of one evaluation, counting steps so no expression runs unbounded
+type evaluation struct {
+	receipt *models.Receipt
+	// Item the body of an item aggregate is evaluated for, nil outside of one
+	item     *models.Item
+	steps    int
+	maxSteps int
+}
+
+func (e *evaluation) step() error {
+	e.steps++
+	if e.steps > e.maxSteps {
+		return fmt.Errorf("expression took more than %d steps", e.maxSteps)
+	}
+	return nil
+}
+
+// Values are float64, string, bool or []models.Item, matching the node's valueType
+type node interface {
+	valueType() valueType
+	eval(e *evaluation) (any, error)
+}
+
+type literal struct {
+	typ   valueType
+	value any
+}
+
+func (n literal) valueType() valueType { return n.typ }
+
+func (n literal) eval(e *evaluation) (any, error) {
+	return n.value, e.step()
+}
+
+// A receipt or item field
+type field struct {
+	typ valueType
+	get func(e *evaluation) any
+}
+
+func (n field) valueType() valueType { return n.typ }
+
+func (n field) eval(e *evaluation) (any, error) {
+	return n.get(e), e.step()
+}
+
+type unary struct {
+	operator string
+	operand  node
+}
+
+func (n unary) valueType() valueType { return n.operand.valueType() }
+
+func (n unary) eval(e *evaluation) (any, error) {
+	if err := e.step(); err != nil {
+		return nil, err
+	}
+	value, err := n.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if n.operator == "!" {
+		return !value.(bool), nil
+	}
+	return -value.(float64), nil
+}
+
+type binary struct {
+	operator string
+	typ      valueType
+	left     node
+	right    node
+}
+
+func (n binary) valueType() valueType { return n.typ }
+
+func (n binary) eval(e *evaluation) (any, error) {
+	if err := e.step(); err != nil {
+		return nil, err
+	}
+	left, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators only evaluate the right side when it decides the result
+	switch n.operator {
+	case "&&":
+		if !left.(bool) {
+			return false, nil
+		}
+		return n.right.eval(e)
+	case "||":
+		if left.(bool) {
+			return true, nil
+		}
+		return n.right.eval(e)
+	}
+
+	right, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch n.operator {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	}
+
+	if leftString, ok := left.(string); ok {
+		rightString := right.(string)
+		switch n.operator {
+		case "+":
+			return leftString + rightString, nil
+		case "<":
+			return leftString < rightString, nil
+		case "<=":
+			return leftString <= rightString, nil
+		case ">":
+			return leftString > rightString, nil
+		default:
+			return leftString >= rightString, nil
+		}
+	}
+
+	leftNumber, rightNumber := left.(float64), right.(float64)
+	switch n.operator {
+	case "+":
+		return leftNumber + rightNumber, nil
+	case "-":
+		return leftNumber - rightNumber, nil
+	case "*":
+		return leftNumber * rightNumber, nil
+	case "/":
+		if rightNumber == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return leftNumber / rightNumber, nil
+	case "%":
+		if rightNumber == 0 {
+			return nil, fmt.Errorf("modulo by zero")
+		}
+		return math.Mod(leftNumber, rightNumber), nil
+	case "<":
+		return leftNumber < rightNumber, nil
+	case "<=":
+		return leftNumber <= rightNumber, nil
+	case ">":
+		return leftNumber > rightNumber, nil
+	default:
+		return leftNumber >= rightNumber, nil
+	}
+}
+
+// A string matched against a regular expression compiled with the expression
+type match struct {
+	operand node
+	pattern *regexp.Regexp
+}
+
+func (n match) valueType() valueType { return typeBool }
+
+func (n match) eval(e *evaluation) (any, error) {
+	if err := e.step(); err != nil {
+		return nil, err
+	}
+	value, err := n.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return n.pattern.MatchString(value.(string)), nil
+}
+
+type conditional struct {
+	condition node
+	then      node
+	otherwise node
+}
+
+func (n conditional) valueType() valueType { return n.then.valueType() }
+
+func (n conditional) eval(e *evaluation) (any, error) {
+	if err := e.step(); err != nil {
+		return nil, err
+	}
+	condition, err := n.condition.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if condition.(bool) {
+		return n.then.eval(e)
+	}
+	return n.otherwise.eval(e)
+}
+
+// A call of a builtin function over plain values
+type call struct {
+	function builtin
+	args     []node
+}
+
+func (n call) valueType() valueType { return n.function.result }
+
+func (n call) eval(e *evaluation) (any, error) {
+	if err := e.step(); err != nil {
+		return nil, err
+	}
+	args := make([]any, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	return n.function.call(args), nil
+}
+
+/*
+An aggregate over the receipt's items, evaluating body once per item with the item's
+fields in scope. Items are limited by validation, so this is the only iteration and
+it is always bounded.
+*/
+type aggregate struct {
+	name  string
+	items node
+	body  node
+}
+
+func (n aggregate) valueType() valueType {
+	if n.name == "any" || n.name == "all" {
+		return typeBool
+	}
+	return typeNumber
+}
+
+func (n aggregate) eval(e *evaluation) (any, error) {
+	if err := e.step(); err != nil {
+		return nil, err
+	}
+	value, err := n.items.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	items := value.([]models.Item)
+	defer func() { e.item = nil }()
+
+	sum := 0.0
+	count := 0.0
+	for i := range items {
+		e.item = &items[i]
+		result, err := n.body.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		switch n.name {
+		case "sum":
+			sum += result.(float64)
+		case "count":
+			if result.(bool) {
+				count++
+			}
+		case "any":
+			if result.(bool) {
+				return true, nil
+			}
+		case "all":
+			if !result.(bool) {
+				return false, nil
+			}
+		}
+	}
+
+	switch n.name {
+	case "sum":
+		return sum, nil
+	case "count":
+		return count, nil
+	case "any":
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+// A function of plain values with fixed parameter types
+type builtin struct {
+	params []valueType
+	result valueType
+	call   func(args []any) any
+}
+
+var builtins = map[string]builtin{
+	"lower": {params: []valueType{typeString}, result: typeString, call: func(args []any) any { return strings.ToLower(args[0].(string)) }},
+	"upper": {params: []valueType{typeString}, result: typeString, call: func(args []any) any { return strings.ToUpper(args[0].(string)) }},
+	"trim":  {params: []valueType{typeString}, result: typeString, call: func(args []any) any { return strings.TrimSpace(args[0].(string)) }},
+	"contains": {params: []valueType{typeString, typeString}, result: typeBool, call: func(args []any) any {
+		return strings.Contains(args[0].(string), args[1].(string))
+	}},
+	"startsWith": {params: []valueType{typeString, typeString}, result: typeBool, call: func(args []any) any {
+		return strings.HasPrefix(args[0].(string), args[1].(string))
+	}},
+	"endsWith": {params: []valueType{typeString, typeString}, result: typeBool, call: func(args []any) any {
+		return strings.HasSuffix(args[0].(string), args[1].(string))
+	}},
+	"abs":   {params: []valueType{typeNumber}, result: typeNumber, call: func(args []any) any { return math.Abs(args[0].(float64)) }},
+	"floor": {params: []valueType{typeNumber}, result: typeNumber, call: func(args []any) any { return math.Floor(args[0].(float64)) }},
+	"ceil":  {params: []valueType{typeNumber}, result: typeNumber, call: func(args []any) any { return math.Ceil(args[0].(float64)) }},
+	"round": {params: []valueType{typeNumber}, result: typeNumber, call: func(args []any) any { return math.Round(args[0].(float64)) }},
+	"min": {params: []valueType{typeNumber, typeNumber}, result: typeNumber, call: func(args []any) any {
+		return math.Min(args[0].(float64), args[1].(float64))
+	}},
+	"max": {params: []valueType{typeNumber, typeNumber}, result: typeNumber, call: func(args []any) any {
+		return math.Max(args[0].(float64), args[1].(float64))
+	}},
+}
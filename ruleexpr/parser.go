@@ -0,0 +1,390 @@
+package ruleexpr
+
+import (
+	"fmt"
+	"receipts/models"
+	"regexp"
+	"strconv"
+)
+
+// Fields of the receipt, available anywhere in an expression
+var receiptFields = map[string]field{
+	"retailer":     {typ: typeString, get: func(e *evaluation) any { return e.receipt.Retailer }},
+	"retailerName": {typ: typeString, get: func(e *evaluation) any { return e.receipt.RetailerName() }},
+	"total":        {typ: typeNumber, get: func(e *evaluation) any { return parseNumber(e.receipt.Total) }},
+	"purchaseDate": {typ: typeString, get: func(e *evaluation) any { return e.receipt.PurchaseDate.String() }},
+	"purchaseTime": {typ: typeString, get: func(e *evaluation) any { return e.receipt.PurchaseTime.String() }},
+	"year":         {typ: typeNumber, get: func(e *evaluation) any { return float64(e.receipt.PurchaseDate.Date.Year()) }},
+	"month":        {typ: typeNumber, get: func(e *evaluation) any { return float64(e.receipt.PurchaseDate.Date.Month()) }},
+	"day":          {typ: typeNumber, get: func(e *evaluation) any { return float64(e.receipt.PurchaseDate.Date.Day()) }},
+	"weekday":      {typ: typeString, get: func(e *evaluation) any { return e.receipt.PurchaseDate.Date.Weekday().String() }},
+	"hour":         {typ: typeNumber, get: func(e *evaluation) any { return float64(e.receipt.PurchaseTime.Time.Hour()) }},
+	"minute":       {typ: typeNumber, get: func(e *evaluation) any { return float64(e.receipt.PurchaseTime.Time.Minute()) }},
+	"items":        {typ: typeItems, get: func(e *evaluation) any { return e.receipt.Items }},
+}
+
+// Fields of the current item, only available in the body of an item aggregate
+var itemFields = map[string]field{
+	"description": {typ: typeString, get: func(e *evaluation) any { return e.item.ShortDescription }},
+	"price":       {typ: typeNumber, get: func(e *evaluation) any { return parseNumber(e.item.Price) }},
+}
+
+// Receipts are validated before they are scored, so an unparsable amount only happens in tests
+func parseNumber(value string) float64 {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+/*
+Recursive descent parser that type checks while it parses. From lowest to highest
+precedence: ?:, ||, &&, == !=, < <= > >= matches, + -, * / %, unary ! -.
+*/
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+	// True while parsing the body of an item aggregate
+	inItem bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	current := p.tokens[p.pos]
+	if current.kind != tokenEOF {
+		p.pos++
+	}
+	return current
+}
+
+// Consumes the next token if it is the operator or keyword
+func (p *parser) accept(text string) bool {
+	current := p.peek()
+	if (current.kind == tokenOperator || current.kind == tokenIdent) && current.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q", text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	current := p.peek()
+	found := "end of expression"
+	if current.kind != tokenEOF {
+		found = strconv.Quote(current.text)
+	}
+	return fmt.Errorf("%s at %d, found %s", fmt.Sprintf(format, args...), current.pos, found)
+}
+
+func (p *parser) parseExpression() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxDepth {
+		return nil, p.errorf("expression is nested more than %d deep", MaxDepth)
+	}
+
+	condition, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return condition, nil
+	}
+	if condition.valueType() != typeBool {
+		return nil, fmt.Errorf("condition of ?: must be bool, not %s", condition.valueType())
+	}
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if then.valueType() != otherwise.valueType() {
+		return nil, fmt.Errorf("both sides of ?: must have the same type, not %s and %s", then.valueType(), otherwise.valueType())
+	}
+	return conditional{condition: condition, then: then, otherwise: otherwise}, nil
+}
+
+// Binary operators by precedence level, lowest first
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">=", "matches"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(precedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := ""
+		for _, candidate := range precedence[level] {
+			if p.accept(candidate) {
+				operator = candidate
+				break
+			}
+		}
+		if operator == "" {
+			return left, nil
+		}
+
+		if operator == "matches" {
+			left, err = p.parseMatch(left)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left, err = newBinary(operator, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// The pattern must be a string literal, so it is compiled and checked once with the expression
+func (p *parser) parseMatch(operand node) (node, error) {
+	if operand.valueType() != typeString {
+		return nil, fmt.Errorf("left side of matches must be string, not %s", operand.valueType())
+	}
+	patternToken := p.next()
+	if patternToken.kind != tokenString {
+		return nil, fmt.Errorf("right side of matches must be a string literal at %d", patternToken.pos)
+	}
+	pattern, err := regexp.Compile(patternToken.str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern at %d: %w", patternToken.pos, err)
+	}
+	return match{operand: operand, pattern: pattern}, nil
+}
+
+// Type checks the operands of a binary operator
+func newBinary(operator string, left node, right node) (node, error) {
+	leftType, rightType := left.valueType(), right.valueType()
+	mismatch := fmt.Errorf("operator %s can't be used on %s and %s", operator, leftType, rightType)
+	if leftType != rightType || leftType == typeItems {
+		return nil, mismatch
+	}
+
+	resultType := typeBool
+	switch operator {
+	case "&&", "||":
+		if leftType != typeBool {
+			return nil, mismatch
+		}
+	case "==", "!=":
+	case "<", "<=", ">", ">=":
+		if leftType == typeBool {
+			return nil, mismatch
+		}
+	case "+":
+		if leftType == typeBool {
+			return nil, mismatch
+		}
+		resultType = leftType
+	default:
+		if leftType != typeNumber {
+			return nil, mismatch
+		}
+		resultType = typeNumber
+	}
+	return binary{operator: operator, typ: resultType, left: left, right: right}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	for _, operator := range []string{"!", "-"} {
+		if !p.accept(operator) {
+			continue
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > MaxDepth {
+			return nil, p.errorf("expression is nested more than %d deep", MaxDepth)
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if (operator == "!" && operand.valueType() != typeBool) || (operator == "-" && operand.valueType() != typeNumber) {
+			return nil, fmt.Errorf("operator %s can't be used on %s", operator, operand.valueType())
+		}
+		return unary{operator: operator, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	current := p.peek()
+	switch current.kind {
+	case tokenNumber:
+		p.next()
+		return literal{typ: typeNumber, value: current.number}, nil
+	case tokenString:
+		p.next()
+		return literal{typ: typeString, value: current.str}, nil
+	case tokenIdent:
+		p.next()
+		if p.accept("(") {
+			return p.parseCall(current)
+		}
+		return p.parseIdent(current)
+	}
+
+	if p.accept("(") {
+		inner, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return nil, p.errorf("expected a value")
+}
+
+func (p *parser) parseIdent(ident token) (node, error) {
+	switch ident.text {
+	case "true":
+		return literal{typ: typeBool, value: true}, nil
+	case "false":
+		return literal{typ: typeBool, value: false}, nil
+	}
+	if receiptField, ok := receiptFields[ident.text]; ok {
+		return receiptField, nil
+	}
+	if itemField, ok := itemFields[ident.text]; ok {
+		if !p.inItem {
+			return nil, fmt.Errorf("%s at %d is only available inside sum, count, any or all", ident.text, ident.pos)
+		}
+		return itemField, nil
+	}
+	return nil, fmt.Errorf("unknown name %s at %d", ident.text, ident.pos)
+}
+
+// Parses the arguments of a call after its opening parenthesis
+func (p *parser) parseCall(name token) (node, error) {
+	switch name.text {
+	case "sum", "count", "any", "all":
+		return p.parseAggregate(name)
+	}
+
+	var args []node
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	if name.text == "len" {
+		if len(args) != 1 || (args[0].valueType() != typeString && args[0].valueType() != typeItems) {
+			return nil, fmt.Errorf("len at %d takes one string or items", name.pos)
+		}
+		return length{operand: args[0]}, nil
+	}
+
+	function, ok := builtins[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at %d", name.text, name.pos)
+	}
+	if len(args) != len(function.params) {
+		return nil, fmt.Errorf("%s at %d takes %d arguments, not %d", name.text, name.pos, len(function.params), len(args))
+	}
+	for i, arg := range args {
+		if arg.valueType() != function.params[i] {
+			return nil, fmt.Errorf("argument %d of %s at %d must be %s, not %s", i+1, name.text, name.pos, function.params[i], arg.valueType())
+		}
+	}
+	return call{function: function, args: args}, nil
+}
+
+// Parses sum(items, number), count(items, bool), any(items, bool) or all(items, bool)
+func (p *parser) parseAggregate(name token) (node, error) {
+	if p.inItem {
+		return nil, fmt.Errorf("%s at %d can't be used inside another item aggregate", name.text, name.pos)
+	}
+	items, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if items.valueType() != typeItems {
+		return nil, fmt.Errorf("first argument of %s at %d must be items", name.text, name.pos)
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+
+	p.inItem = true
+	body, err := p.parseExpression()
+	p.inItem = false
+	if err != nil {
+		return nil, err
+	}
+	expectedType := typeBool
+	if name.text == "sum" {
+		expectedType = typeNumber
+	}
+	if body.valueType() != expectedType {
+		return nil, fmt.Errorf("second argument of %s at %d must be %s, not %s", name.text, name.pos, expectedType, body.valueType())
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return aggregate{name: name.text, items: items, body: body}, nil
+}
+
+// Length of a string or number of items
+type length struct {
+	operand node
+}
+
+func (n length) valueType() valueType { return typeNumber }
+
+func (n length) eval(e *evaluation) (any, error) {
+	if err := e.step(); err != nil {
+		return nil, err
+	}
+	value, err := n.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if items, ok := value.([]models.Item); ok {
+		return float64(len(items)), nil
+	}
+	return float64(len([]rune(value.(string)))), nil
+}
@@ -0,0 +1,87 @@
+/*
+A small expression language for points rules, such as
+
+	len(items) >= 5 && retailer matches "^Target" ? 20 : 0
+
+Expressions can read the receipt's fields (retailer, retailerName for the name of its
+canonical retailer, total, purchaseDate, purchaseTime, year, month, day, weekday, hour,
+minute and items) and aggregate its items with
+sum(items, price), count(items, bool), any(items, bool) and all(items, bool), inside
+which description and price refer to the current item. There are no loops other than
+those aggregates, they can't be nested, and every evaluation has a step limit.
+*/
+package ruleexpr
+
+import (
+	"fmt"
+	"math"
+	"receipts/models"
+	"receipts/points"
+)
+
+const (
+	// Longest source accepted by Compile
+	MaxSourceLength int = 4096
+	// Deepest nesting of parentheses, operators and calls accepted by Compile
+	MaxDepth int = 64
+	// Most nodes evaluated for one receipt before evaluation stops with an error
+	MaxSteps int = 100000
+)
+
+// A compiled expression that evaluates to points
+type Program struct {
+	source string
+	root   node
+}
+
+// Parses and type checks the source, which must evaluate to a number
+func Compile(source string) (*Program, error) {
+	if len(source) > MaxSourceLength {
+		return nil, fmt.Errorf("expression must be at most %d characters", MaxSourceLength)
+	}
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("expected end of expression")
+	}
+	if root.valueType() != typeNumber {
+		return nil, fmt.Errorf("expression must evaluate to a number, not %s", root.valueType())
+	}
+	return &Program{source: source, root: root}, nil
+}
+
+func (p *Program) String() string {
+	return p.source
+}
+
+// Evaluates the expression for the receipt, rounding the result to the nearest point
+func (p *Program) Evaluate(receipt *models.Receipt) (int, error) {
+	value, err := p.root.eval(&evaluation{receipt: receipt, maxSteps: MaxSteps})
+	if err != nil {
+		return 0, err
+	}
+	number := value.(float64)
+	if math.IsNaN(number) || math.IsInf(number, 0) || math.Abs(number) > math.MaxInt32 {
+		return 0, fmt.Errorf("expression evaluated to %v, which is not a valid number of points", number)
+	}
+	return int(math.Round(number)), nil
+}
+
+// Returns the expression as a rule, which awards 0 points when evaluation fails
+func (p *Program) Rule() points.ReceiptRule {
+	return func(receipt *models.Receipt) int {
+		result, err := p.Evaluate(receipt)
+		if err != nil {
+			return 0
+		}
+		return result
+	}
+}
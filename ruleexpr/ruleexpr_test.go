@@ -0,0 +1,152 @@
+package ruleexpr
+
+import (
+	"receipts/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReceipt(t *testing.T) *models.Receipt {
+	purchaseDate, err := models.ParsePurchaseDate("2022-01-01")
+	require.NoError(t, err)
+	purchaseTime, err := models.ParsePurchaseTime("13:01")
+	require.NoError(t, err)
+	return &models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: purchaseDate,
+		PurchaseTime: purchaseTime,
+		Total:        "35.35",
+		Items: []models.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+			{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: "12.00"},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		testName       string
+		expression     string
+		expectedPoints int
+	}{
+		{"Literal", "42", 42},
+		{"Arithmetic", "1 + 2 * 3 - 8 / 4 % 3", 5},
+		{"Parentheses", "(1 + 2) * 3", 9},
+		{"Negation", "-(2 - 5)", 3},
+		{"Rounding", "7 / 2", 4},
+		{"Total", "total * 2", 71},
+		{"Ternary", `len(items) >= 5 && retailer matches "^Target" ? 20 : 0`, 20},
+		{"TernaryFalse", `len(items) > 5 || retailer == "Walmart" ? 20 : 0`, 0},
+		{"NestedTernary", `hour < 12 ? 1 : hour < 14 ? 2 : 3`, 2},
+		{"Not", `!(weekday == "Saturday") ? 1 : 0`, 0},
+		{"DateFields", "year + month + day", 2024},
+		{"TimeFields", "hour * 100 + minute", 1301},
+		{"RetailerName", `retailerName == "Target" ? 1 : 0`, 1},
+		{"StringConcatenation", `len(retailer + " " + purchaseDate) == 17 ? 1 : 0`, 1},
+		{"StringComparison", `purchaseTime >= "12:00" ? 1 : 0`, 1},
+		{"Sum", "sum(items, price)", 35},
+		{"Count", `count(items, contains(lower(description), "cheese"))`, 2},
+		{"Any", `any(items, price > 12) ? 5 : 0`, 5},
+		{"All", `all(items, price > 2) ? 5 : 0`, 0},
+		{"TrimmedLength", `count(items, len(trim(description)) % 3 == 0)`, 2},
+		{"Functions", `max(floor(total), 10) + min(abs(-2), ceil(0.5)) + round(2.5)`, 39},
+		{"StringFunctions", `startsWith(upper(retailer), "TAR") && endsWith(retailer, "get") ? 1 : 0`, 1},
+		{"EscapedString", `len("a\"b\\c") == 5 ? 1 : 0`, 1},
+		{"RegexEscape", `any(items, description matches "\d+PK") ? 1 : 0`, 1},
+		{"ShortCircuit", `false && 1 / 0 > 0 ? 1 : 0`, 0},
+	}
+
+	receipt := testReceipt(t)
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			program, err := Compile(test.expression)
+			require.NoError(t, err)
+			points, err := program.Evaluate(receipt)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPoints, points)
+			assert.Equal(t, test.expectedPoints, program.Rule()(receipt))
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		testName      string
+		expression    string
+		expectedError string
+	}{
+		{"Empty", "", "expected a value"},
+		{"NotNumber", `retailer == "Target"`, "must evaluate to a number"},
+		{"UnknownName", "points", "unknown name points"},
+		{"UnknownFunction", "exec(1)", "unknown function exec"},
+		{"TypeMismatch", `retailer + 1`, "can't be used on string and number"},
+		{"BoolArithmetic", `true * 2`, "can't be used on bool and number"},
+		{"ConditionNotBool", "1 ? 2 : 3", "condition of ?: must be bool"},
+		{"BranchTypes", `true ? 1 : "a"`, "same type"},
+		{"ItemFieldOutsideAggregate", "price", "only available inside"},
+		{"NestedAggregate", "sum(items, count(items, true))", "inside another item aggregate"},
+		{"AggregateBody", "count(items, price)", "must be bool"},
+		{"ArgumentCount", "abs(1, 2)", "takes 1 arguments"},
+		{"ArgumentType", `abs("a")`, "must be number"},
+		{"LenType", "len(1)", "len at 0 takes one string or items"},
+		{"PatternNotLiteral", `retailer matches retailer`, "must be a string literal"},
+		{"InvalidPattern", `retailer matches "("`, "invalid pattern"},
+		{"Unterminated", `"abc`, "unterminated"},
+		{"TrailingTokens", "1 2", "expected end of expression"},
+		{"UnexpectedCharacter", "1 # 2", "unexpected character"},
+		{"MissingParenthesis", "(1 + 2", `expected ")"`},
+		{"TooDeep", strings.Repeat("(", MaxDepth+1) + "1" + strings.Repeat(")", MaxDepth+1), "nested more than"},
+		{"TooLong", strings.Repeat("1+", MaxSourceLength) + "1", "at most"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			_, err := Compile(test.expression)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		testName      string
+		expression    string
+		expectedError string
+	}{
+		{"DivisionByZero", "total / (day - 1)", "division by zero"},
+		{"ModuloByZero", "total % 0", "modulo by zero"},
+		{"TooLarge", "total * 1000000000000", "not a valid number of points"},
+	}
+
+	receipt := testReceipt(t)
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			program, err := Compile(test.expression)
+			require.NoError(t, err)
+			_, err = program.Evaluate(receipt)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+			assert.Equal(t, 0, program.Rule()(receipt))
+		})
+	}
+}
+
+func TestEvaluateStepLimit(t *testing.T) {
+	receipt := testReceipt(t)
+	for len(receipt.Items) < MaxSteps {
+		receipt.Items = append(receipt.Items, receipt.Items...)
+	}
+
+	program, err := Compile("sum(items, price)")
+	require.NoError(t, err)
+	_, err = program.Evaluate(receipt)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "steps")
+}
@@ -0,0 +1,103 @@
+package rulesfile
+
+import (
+	"log"
+	"os"
+	"receipts/points"
+	"sync"
+	"time"
+)
+
+/*
+Loads the rules file at a path into the active points rule sets, again whenever it
+is asked to or the file changes. A file that fails to load leaves the previously
+active rule sets in place.
+*/
+type Reloader struct {
+	*sync.RWMutex
+	path   string
+	status Status
+	// Modification time and size of the file when it was last read, to notice changes
+	modTime time.Time
+	size    int64
+}
+
+// Outcome of the most recent loads of a rules file
+type Status struct {
+	Path string `json:"path"`
+	// When the active rule sets were loaded from the file, nil if they never were
+	LoadedAt *time.Time `json:"loadedAt,omitempty"`
+	// Error of the most recent load when it failed, empty when it succeeded
+	LastError string `json:"lastError,omitempty"`
+}
+
+func NewReloader(path string) *Reloader {
+	return &Reloader{RWMutex: &sync.RWMutex{}, path: path, status: Status{Path: path}}
+}
+
+// Returns the outcome of the most recent loads after waiting for the read lock.
+func (r *Reloader) Status() Status {
+	r.RLock()
+	defer r.RUnlock()
+	return r.status
+}
+
+/*
+Loads the rules file and swaps it in as the active rule sets, logging the version
+now in effect. On error the active rule sets are kept, and the error is logged,
+returned and reported by Status until a load succeeds. Waits for the read / write lock.
+*/
+func (r *Reloader) Reload() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime, r.size = info.ModTime(), info.Size()
+	}
+	ruleSets, err := Load(r.path)
+	if err == nil {
+		err = points.SetRuleSets(ruleSets)
+	}
+	if err != nil {
+		r.status.LastError = err.Error()
+		log.Printf("failed to load rules from %s, keeping version %s: %v", r.path, points.EffectiveRuleSet(time.Now()).Version, err)
+		return err
+	}
+
+	loadedAt := time.Now().UTC()
+	r.status.LoadedAt = &loadedAt
+	r.status.LastError = ""
+	log.Printf("loaded %d rule sets from %s, version %s is in effect", len(ruleSets), r.path, points.EffectiveRuleSet(time.Now()).Version)
+	return nil
+}
+
+/*
+Checks the rules file every interval and reloads it when its modification time or
+size changed since it was last read, until stop is closed. Blocks, so it is usually
+run in its own goroutine.
+*/
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if r.changed() {
+				// Errors are logged and kept in the status, and the next change is tried again
+				r.Reload()
+			}
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+	r.RLock()
+	defer r.RUnlock()
+	return !info.ModTime().Equal(r.modTime) || info.Size() != r.size
+}
@@ -0,0 +1,176 @@
+/*
+Reads points rule sets from a json file, so the rewards program can change without
+a new build, and reloads them while the server is running.
+
+A rules file lists rule sets in the order they take effect, each made of stages:
+
+	{"ruleSets": [{"version": "v2", "effectiveFrom": "2025-01-01T00:00:00Z", "stages": [
+		{"type": "add", "name": "RetailerRule"},
+		{"type": "add", "name": "BigBasket", "expression": "len(items) >= 5 ? 20 : 0"},
+		{"type": "multiply", "name": "Double", "factor": 2},
+		{"type": "cap", "name": "Cap", "points": 500}
+	]}]}
+*/
+package rulesfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"receipts/models"
+	"receipts/points"
+	"receipts/ruleexpr"
+	"time"
+)
+
+// Types of stage a rules file can define, see the points package stage of the same name
+const (
+	StageAdd          = "add"
+	StageMultiply     = "multiply"
+	StageCap          = "cap"
+	StageMinimum      = "minimum"
+	StageShortCircuit = "shortCircuit"
+)
+
+type File struct {
+	RuleSets []RuleSetDefinition `json:"ruleSets"`
+}
+
+type RuleSetDefinition struct {
+	Version string `json:"version"`
+	// The zero time when missing, so the first rule set covers every receipt before the next one
+	EffectiveFrom time.Time         `json:"effectiveFrom"`
+	Stages        []StageDefinition `json:"stages"`
+}
+
+type StageDefinition struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	/*
+		For add stages, the rule expression awarding the stage's points, and when empty the
+		name must be one of points.GetNamedReceiptRules. For shortCircuit stages, the rule
+		expression deciding whether the stage applies, which it does when not 0.
+	*/
+	Expression string `json:"expression,omitempty"`
+	// Factor of multiply stages
+	Factor *float64 `json:"factor,omitempty"`
+	// Limit of cap and minimum stages, and the total of shortCircuit stages, which defaults to 0
+	Points *int `json:"points,omitempty"`
+	// Retailers shortCircuit stages apply to, when there is no expression
+	Retailers []string `json:"retailers,omitempty"`
+}
+
+// Reads and builds the rule sets of the rules file at path
+func Load(path string) ([]points.RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+/*
+Decodes a rules file and builds its rule sets, returning an error for unknown fields,
+rules or stage types, expressions that don't compile, and rule sets that points.ValidateRuleSets rejects.
+*/
+func Parse(data []byte) ([]points.RuleSet, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file File
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid rules file: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid rules file: unexpected data after the rules")
+	}
+
+	namedRules := make(map[string]points.ReceiptRule)
+	for _, namedRule := range points.GetNamedReceiptRules() {
+		namedRules[namedRule.Name] = namedRule.Rule
+	}
+
+	ruleSets := make([]points.RuleSet, 0, len(file.RuleSets))
+	for _, definition := range file.RuleSets {
+		ruleSet := points.RuleSet{Version: definition.Version, EffectiveFrom: definition.EffectiveFrom}
+		for i, stageDefinition := range definition.Stages {
+			stage, err := stageDefinition.stage(namedRules)
+			if err != nil {
+				return nil, fmt.Errorf("stage %d of rule set %s: %w", i+1, definition.Version, err)
+			}
+			ruleSet.Stages = append(ruleSet.Stages, stage)
+		}
+		ruleSets = append(ruleSets, ruleSet)
+	}
+
+	if err := points.ValidateRuleSets(ruleSets); err != nil {
+		return nil, err
+	}
+	return ruleSets, nil
+}
+
+func (d StageDefinition) stage(namedRules map[string]points.ReceiptRule) (points.Stage, error) {
+	if d.Name == "" {
+		return points.Stage{}, fmt.Errorf("stage must have a name")
+	}
+
+	switch d.Type {
+	case StageAdd:
+		if d.Expression != "" {
+			program, err := ruleexpr.Compile(d.Expression)
+			if err != nil {
+				return points.Stage{}, fmt.Errorf("expression of %s: %w", d.Name, err)
+			}
+			return points.AddStage(points.NamedReceiptRule{Name: d.Name, Rule: program.Rule()}), nil
+		}
+		rule, ok := namedRules[d.Name]
+		if !ok {
+			return points.Stage{}, fmt.Errorf("unknown rule %q", d.Name)
+		}
+		return points.AddStage(points.NamedReceiptRule{Name: d.Name, Rule: rule}), nil
+	case StageMultiply:
+		if d.Factor == nil || *d.Factor < 0 {
+			return points.Stage{}, fmt.Errorf("multiply stage %s must have a factor that is not negative", d.Name)
+		}
+		return points.MultiplyStage(d.Name, points.ConstantMultiplier(*d.Factor)), nil
+	case StageCap, StageMinimum:
+		if d.Points == nil {
+			return points.Stage{}, fmt.Errorf("%s stage %s must have points", d.Type, d.Name)
+		}
+		if d.Type == StageCap {
+			return points.CapStage(d.Name, *d.Points), nil
+		}
+		return points.MinimumStage(d.Name, *d.Points), nil
+	case StageShortCircuit:
+		total := 0
+		if d.Points != nil {
+			total = *d.Points
+		}
+		condition, err := d.condition()
+		if err != nil {
+			return points.Stage{}, err
+		}
+		return points.ShortCircuitStage(d.Name, condition, total), nil
+	default:
+		return points.Stage{}, fmt.Errorf("stage type must be one of add, multiply, cap, minimum or shortCircuit")
+	}
+}
+
+// Condition of a shortCircuit stage, from either its expression or its retailers
+func (d StageDefinition) condition() (points.Condition, error) {
+	if (d.Expression == "") == (len(d.Retailers) == 0) {
+		return nil, fmt.Errorf("shortCircuit stage %s must have either an expression or retailers", d.Name)
+	}
+	if len(d.Retailers) > 0 {
+		return points.RetailerIs(d.Retailers...), nil
+	}
+	program, err := ruleexpr.Compile(d.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("expression of %s: %w", d.Name, err)
+	}
+	rule := program.Rule()
+	return func(receipt *models.Receipt) bool {
+		return rule(receipt) != 0
+	}, nil
+}
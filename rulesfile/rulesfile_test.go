@@ -0,0 +1,154 @@
+package rulesfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"receipts/models"
+	"receipts/points"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readReceipt(t *testing.T, path string) *models.Receipt {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var receipt models.Receipt
+	require.NoError(t, json.Unmarshal(data, &receipt))
+	return &receipt
+}
+
+func TestExampleRulesMatchDefault(t *testing.T) {
+	ruleSets, err := Load("../example-rules.json")
+	require.NoError(t, err)
+	defaults := points.DefaultRuleSets()
+	require.Len(t, ruleSets, len(defaults))
+
+	for _, path := range []string{"../example-receipts/simple-receipt.json", "../example-receipts/morning-receipt.json"} {
+		receipt := readReceipt(t, path)
+		for i := range defaults {
+			assert.Equal(t, defaults[i].Score(receipt), ruleSets[i].Score(receipt), path)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	receipt := readReceipt(t, "../example-receipts/simple-receipt.json")
+
+	tests := []struct {
+		testName          string
+		rules             string
+		expectedBreakdown []points.RulePoints
+	}{
+		{
+			testName:          "NamedRule",
+			rules:             `{"ruleSets": [{"version": "v1", "stages": [{"type": "add", "name": "RetailerRule"}]}]}`,
+			expectedBreakdown: []points.RulePoints{{Rule: "RetailerRule", Points: 6}},
+		},
+		{
+			testName: "Composed",
+			rules: `{"ruleSets": [{"version": "v1", "stages": [
+				{"type": "add", "name": "RetailerRule"},
+				{"type": "add", "name": "PerItem", "expression": "len(items) * 10"},
+				{"type": "multiply", "name": "Double", "factor": 2},
+				{"type": "cap", "name": "Cap", "points": 30},
+				{"type": "minimum", "name": "Minimum", "points": 5}
+			]}]}`,
+			expectedBreakdown: []points.RulePoints{{Rule: "RetailerRule", Points: 6}, {Rule: "PerItem", Points: 10}, {Rule: "Double", Points: 16}, {Rule: "Cap", Points: -2}, {Rule: "Minimum", Points: 0}},
+		},
+		{
+			testName: "ShortCircuitRetailers",
+			rules: `{"ruleSets": [{"version": "v1", "stages": [
+				{"type": "shortCircuit", "name": "Excluded", "retailers": ["target"]},
+				{"type": "add", "name": "RetailerRule"}
+			]}]}`,
+			expectedBreakdown: []points.RulePoints{{Rule: "Excluded", Points: 0}},
+		},
+		{
+			testName: "ShortCircuitExpression",
+			rules: `{"ruleSets": [{"version": "v1", "stages": [
+				{"type": "shortCircuit", "name": "SmallBasket", "expression": "len(items) < 2 ? 1 : 0", "points": 3},
+				{"type": "add", "name": "RetailerRule"}
+			]}]}`,
+			expectedBreakdown: []points.RulePoints{{Rule: "SmallBasket", Points: 3}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			ruleSets, err := Parse([]byte(test.rules))
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedBreakdown, ruleSets[0].CalculateBreakdown(receipt))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		testName string
+		rules    string
+	}{
+		{testName: "NotJSON", rules: `ruleSets: []`},
+		{testName: "UnknownField", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "add", "name": "RetailerRule", "multiplier": 2}]}]}`},
+		{testName: "TrailingData", rules: `{"ruleSets": [{"version": "v1", "stages": []}]} {}`},
+		{testName: "NoRuleSets", rules: `{"ruleSets": []}`},
+		{testName: "UnknownRule", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "add", "name": "WeekendRule"}]}]}`},
+		{testName: "UnknownType", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "divide", "name": "Half"}]}]}`},
+		{testName: "UnnamedStage", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "add", "expression": "1"}]}]}`},
+		{testName: "InvalidExpression", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "add", "name": "Bad", "expression": "1 +"}]}]}`},
+		{testName: "MissingFactor", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "multiply", "name": "Double"}]}]}`},
+		{testName: "NegativeFactor", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "multiply", "name": "Double", "factor": -1}]}]}`},
+		{testName: "MissingCapPoints", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "cap", "name": "Cap"}]}]}`},
+		{testName: "ShortCircuitWithoutCondition", rules: `{"ruleSets": [{"version": "v1", "stages": [{"type": "shortCircuit", "name": "Excluded"}]}]}`},
+		{testName: "DuplicateVersion", rules: `{"ruleSets": [{"version": "v1", "stages": []}, {"version": "v1", "stages": []}]}`},
+		{testName: "Unordered", rules: `{"ruleSets": [{"version": "v1", "effectiveFrom": "2025-01-01T00:00:00Z"}, {"version": "v2"}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			_, err := Parse([]byte(test.rules))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestReloader(t *testing.T) {
+	t.Cleanup(func() { points.SetRuleSets(points.DefaultRuleSets()) })
+	path := filepath.Join(t.TempDir(), "rules.json")
+	write := func(rules string) {
+		require.NoError(t, os.WriteFile(path, []byte(rules), 0o600))
+	}
+	receipt := readReceipt(t, "../example-receipts/simple-receipt.json")
+
+	reloader := NewReloader(path)
+	assert.Error(t, reloader.Reload(), "missing file")
+	assert.NotEmpty(t, reloader.Status().LastError)
+	assert.Nil(t, reloader.Status().LoadedAt)
+
+	write(`{"ruleSets": [{"version": "flat", "stages": [{"type": "add", "name": "Flat", "expression": "10"}]}]}`)
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, "flat", points.EffectiveRuleSet(time.Now()).Version)
+	assert.Equal(t, 10, points.CalculatePoints(receipt))
+	assert.Empty(t, reloader.Status().LastError)
+	assert.NotNil(t, reloader.Status().LoadedAt)
+
+	write(`{"ruleSets": [{"version": "broken", "stages": [{"type": "add", "name": "Flat", "expression": "10 +"}]}]}`)
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, "flat", points.EffectiveRuleSet(time.Now()).Version, "a failed reload must keep the previous rule sets")
+	assert.Contains(t, reloader.Status().LastError, "expression of Flat")
+
+	t.Run("Watch", func(t *testing.T) {
+		stop := make(chan struct{})
+		defer close(stop)
+		go reloader.Watch(10*time.Millisecond, stop)
+
+		write(`{"ruleSets": [{"version": "watched", "stages": [{"type": "add", "name": "Flat", "expression": "20"}]}]}`)
+		assert.Eventually(t, func() bool {
+			return points.EffectiveRuleSet(time.Now()).Version == "watched"
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, 20, points.CalculatePoints(receipt))
+	})
+}
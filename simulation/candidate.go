@@ -0,0 +1,87 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"receipts/models"
+	"receipts/points"
+	"receipts/ruleexpr"
+)
+
+// Version reported for candidates built from rules rather than taken from an existing version
+const CandidateVersion = "candidate"
+
+/*
+A rule of a candidate rule set, named after one of the rules in points.GetNamedReceiptRules,
+or any name along with an expression in the ruleexpr language that awards the rule's points.
+*/
+type CandidateRule struct {
+	Rule       string `json:"rule"`
+	Expression string `json:"expression,omitempty"`
+	// Points the rule awards are multiplied by this and rounded, 0 is treated as 1
+	Multiplier float64 `json:"multiplier,omitempty"`
+}
+
+/*
+A proposed rule set, either an existing rule set version or a list of rules.
+An empty candidate is rejected rather than treated as a rule set that awards nothing.
+*/
+type Candidate struct {
+	Version string          `json:"version,omitempty"`
+	Rules   []CandidateRule `json:"rules,omitempty"`
+}
+
+// Builds the rule set the candidate describes, returning an error for unknown versions and rules
+func (c Candidate) RuleSet() (points.RuleSet, error) {
+	if c.Version != "" && len(c.Rules) > 0 {
+		return points.RuleSet{}, fmt.Errorf("candidate must have either a version or rules, not both")
+	}
+	if c.Version != "" {
+		ruleSet, ok := points.RuleSetVersion(c.Version)
+		if !ok {
+			return points.RuleSet{}, fmt.Errorf("rule set version %s not found", c.Version)
+		}
+		return ruleSet, nil
+	}
+	if len(c.Rules) == 0 {
+		return points.RuleSet{}, fmt.Errorf("candidate must have a version or at least one rule")
+	}
+
+	namedRules := make(map[string]points.ReceiptRule)
+	for _, namedRule := range points.GetNamedReceiptRules() {
+		namedRules[namedRule.Name] = namedRule.Rule
+	}
+
+	ruleSet := points.RuleSet{Version: CandidateVersion}
+	for _, candidateRule := range c.Rules {
+		rule, ok := namedRules[candidateRule.Rule]
+		if candidateRule.Expression != "" {
+			if candidateRule.Rule == "" {
+				return points.RuleSet{}, fmt.Errorf("rules with an expression must have a name")
+			}
+			program, err := ruleexpr.Compile(candidateRule.Expression)
+			if err != nil {
+				return points.RuleSet{}, fmt.Errorf("expression of %s: %w", candidateRule.Rule, err)
+			}
+			rule, ok = program.Rule(), true
+		}
+		if !ok {
+			return points.RuleSet{}, fmt.Errorf("unknown rule %q", candidateRule.Rule)
+		}
+		if candidateRule.Multiplier < 0 {
+			return points.RuleSet{}, fmt.Errorf("multiplier of %s must not be negative", candidateRule.Rule)
+		}
+		if candidateRule.Multiplier != 0 && candidateRule.Multiplier != 1 {
+			rule = multiply(rule, candidateRule.Multiplier)
+		}
+		ruleSet.Stages = append(ruleSet.Stages, points.AddStage(points.NamedReceiptRule{Name: candidateRule.Rule, Rule: rule}))
+	}
+	return ruleSet, nil
+}
+
+// Returns a rule awarding the rule's points multiplied by multiplier, rounded to the nearest point
+func multiply(rule points.ReceiptRule, multiplier float64) points.ReceiptRule {
+	return func(receipt *models.Receipt) int {
+		return int(math.Round(float64(rule(receipt)) * multiplier))
+	}
+}
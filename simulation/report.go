@@ -0,0 +1,133 @@
+package simulation
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+)
+
+// How points of the simulated receipts change from the baseline to the candidate rule set
+type Report struct {
+	Baseline        string `json:"baseline"`
+	Candidate       string `json:"candidate"`
+	Receipts        int    `json:"receipts"`
+	BaselinePoints  int    `json:"baselinePoints"`
+	CandidatePoints int    `json:"candidatePoints"`
+	Delta           int    `json:"delta"`
+	Increased       int    `json:"increased"`
+	Decreased       int    `json:"decreased"`
+	Unchanged       int    `json:"unchanged"`
+	// Points each rule awards in total, for every rule in either set
+	Rules []RuleDelta `json:"rules"`
+	// Number of receipts in each points range under both rule sets
+	Distribution Distribution `json:"distribution"`
+	// Receipts whose points change the most, largest change first
+	MostAffected []ReceiptDelta `json:"mostAffected"`
+}
+
+type RuleDelta struct {
+	Rule            string `json:"rule"`
+	BaselinePoints  int    `json:"baselinePoints"`
+	CandidatePoints int    `json:"candidatePoints"`
+	Delta           int    `json:"delta"`
+}
+
+type ReceiptDelta struct {
+	Id              string `json:"id"`
+	Retailer        string `json:"retailer"`
+	BaselinePoints  int    `json:"baselinePoints"`
+	CandidatePoints int    `json:"candidatePoints"`
+	Delta           int    `json:"delta"`
+}
+
+// Receipt counts per points range, ranges are ordered and together cover every number of points
+type Distribution []Bucket
+
+// Receipts with at least Min points and less than Max points, a Max of 0 means no upper bound
+type Bucket struct {
+	Min       int `json:"min"`
+	Max       int `json:"max,omitempty"`
+	Baseline  int `json:"baseline"`
+	Candidate int `json:"candidate"`
+}
+
+// Lower bounds of the distribution buckets, the first bucket also holds anything below 0
+var bucketBounds = []int{0, 25, 50, 75, 100, 150, 200, 300}
+
+func newDistribution() Distribution {
+	distribution := make(Distribution, 0, len(bucketBounds))
+	for i, bound := range bucketBounds {
+		bucket := Bucket{Min: bound}
+		if i+1 < len(bucketBounds) {
+			bucket.Max = bucketBounds[i+1]
+		}
+		distribution = append(distribution, bucket)
+	}
+	return distribution
+}
+
+func (d Distribution) add(baselinePoints int, candidatePoints int) {
+	d[d.bucket(baselinePoints)].Baseline++
+	d[d.bucket(candidatePoints)].Candidate++
+}
+
+// Index of the bucket holding the points
+func (d Distribution) bucket(points int) int {
+	for i := len(d) - 1; i > 0; i-- {
+		if points >= d[i].Min {
+			return i
+		}
+	}
+	return 0
+}
+
+// Label of the bucket's range, such as 25-49 or 300+
+func (b Bucket) Range() string {
+	if b.Max == 0 {
+		return fmt.Sprintf("%d+", b.Min)
+	}
+	return fmt.Sprintf("%d-%d", b.Min, b.Max-1)
+}
+
+// Writes the report in a human readable form
+func (r Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%d receipts, %s -> %s\n", r.Receipts, r.Baseline, r.Candidate)
+	fmt.Fprintf(w, "points: %d -> %d (%+d, %s)\n", r.BaselinePoints, r.CandidatePoints, r.Delta, percentChange(r.BaselinePoints, r.CandidatePoints))
+	fmt.Fprintf(w, "receipts increased: %d, decreased: %d, unchanged: %d\n", r.Increased, r.Decreased, r.Unchanged)
+
+	fmt.Fprintln(w)
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "RULE\tBASELINE\tCANDIDATE\tDELTA")
+	for _, rule := range r.Rules {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%+d\n", rule.Rule, rule.BaselinePoints, rule.CandidatePoints, rule.Delta)
+	}
+	table.Flush()
+
+	fmt.Fprintln(w)
+	table = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "POINTS\tBASELINE\tCANDIDATE")
+	for _, bucket := range r.Distribution {
+		fmt.Fprintf(table, "%s\t%d\t%d\n", bucket.Range(), bucket.Baseline, bucket.Candidate)
+	}
+	table.Flush()
+
+	if len(r.MostAffected) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	table = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "RECEIPT\tRETAILER\tBASELINE\tCANDIDATE\tDELTA")
+	for _, receipt := range r.MostAffected {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%+d\n", receipt.Id, receipt.Retailer, receipt.BaselinePoints, receipt.CandidatePoints, receipt.Delta)
+	}
+	table.Flush()
+}
+
+// Formats the change from before to after as a percentage of before
+func percentChange(before int, after int) string {
+	if before == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", math.Round(float64(after-before)/float64(before)*1000)/10)
+}
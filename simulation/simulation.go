@@ -0,0 +1,132 @@
+package simulation
+
+import (
+	"math/rand"
+	"receipts/models"
+	"receipts/points"
+	"sort"
+)
+
+// Default number of most affected receipts in a report
+const DefaultTop int = 10
+
+// A receipt to simulate along with the id it is reported under
+type Input struct {
+	Id      string
+	Receipt *models.Receipt
+}
+
+type Options struct {
+	// When above 0 and below the number of inputs, only a random sample of this many inputs is scored
+	Sample int
+	// Seed of the random sample, so a simulation can be repeated
+	Seed int64
+	// Number of most affected receipts reported, DefaultTop when 0
+	Top int
+}
+
+/*
+Scores the inputs with both the baseline and candidate rule sets and reports how
+points would change if the candidate replaced the baseline.
+*/
+func Simulate(inputs []Input, baseline points.RuleSet, candidate points.RuleSet, options Options) Report {
+	inputs = sample(inputs, options.Sample, options.Seed)
+	top := options.Top
+	if top == 0 {
+		top = DefaultTop
+	}
+
+	report := Report{
+		Baseline:     baseline.Version,
+		Candidate:    candidate.Version,
+		Receipts:     len(inputs),
+		Rules:        ruleDeltas(baseline, candidate),
+		Distribution: newDistribution(),
+		MostAffected: []ReceiptDelta{},
+	}
+	ruleIndex := make(map[string]int)
+	for i, rule := range report.Rules {
+		ruleIndex[rule.Rule] = i
+	}
+
+	var changed []ReceiptDelta
+	for _, in := range inputs {
+		baselineScore := baseline.Score(in.Receipt)
+		candidateScore := candidate.Score(in.Receipt)
+		report.BaselinePoints += baselineScore.Points
+		report.CandidatePoints += candidateScore.Points
+		for _, rulePoints := range baselineScore.Breakdown {
+			report.Rules[ruleIndex[rulePoints.Rule]].BaselinePoints += rulePoints.Points
+		}
+		for _, rulePoints := range candidateScore.Breakdown {
+			report.Rules[ruleIndex[rulePoints.Rule]].CandidatePoints += rulePoints.Points
+		}
+		report.Distribution.add(baselineScore.Points, candidateScore.Points)
+
+		delta := candidateScore.Points - baselineScore.Points
+		switch {
+		case delta > 0:
+			report.Increased++
+		case delta < 0:
+			report.Decreased++
+		default:
+			report.Unchanged++
+			continue
+		}
+		changed = append(changed, ReceiptDelta{
+			Id:              in.Id,
+			Retailer:        in.Receipt.Retailer,
+			BaselinePoints:  baselineScore.Points,
+			CandidatePoints: candidateScore.Points,
+			Delta:           delta,
+		})
+	}
+
+	report.Delta = report.CandidatePoints - report.BaselinePoints
+	for i := range report.Rules {
+		report.Rules[i].Delta = report.Rules[i].CandidatePoints - report.Rules[i].BaselinePoints
+	}
+
+	// Stable so receipts with the same change stay in input order
+	sort.SliceStable(changed, func(i, j int) bool {
+		return abs(changed[i].Delta) > abs(changed[j].Delta)
+	})
+	report.MostAffected = append(report.MostAffected, changed[:min(top, len(changed))]...)
+	return report
+}
+
+// Returns a random sample of size inputs in their original order, or every input when size doesn't limit them
+func sample(inputs []Input, size int, seed int64) []Input {
+	if size <= 0 || size >= len(inputs) {
+		return inputs
+	}
+	picked := rand.New(rand.NewSource(seed)).Perm(len(inputs))[:size]
+	sort.Ints(picked)
+	sampled := make([]Input, 0, size)
+	for _, i := range picked {
+		sampled = append(sampled, inputs[i])
+	}
+	return sampled
+}
+
+// Returns an empty delta for every stage of either set, baseline stages first
+func ruleDeltas(baseline points.RuleSet, candidate points.RuleSet) []RuleDelta {
+	var rules []RuleDelta
+	seen := make(map[string]bool)
+	for _, ruleSet := range []points.RuleSet{baseline, candidate} {
+		for _, stage := range ruleSet.Stages {
+			if !seen[stage.Name] {
+				seen[stage.Name] = true
+				rules = append(rules, RuleDelta{Rule: stage.Name})
+			}
+		}
+	}
+	return rules
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
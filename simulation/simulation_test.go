@@ -0,0 +1,118 @@
+package simulation
+
+import (
+	"bytes"
+	"fmt"
+	"receipts/models"
+	"receipts/points"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Receipts with a round total and retailers of 1 to n alphanumeric characters
+func testInputs(n int) []Input {
+	var inputs []Input
+	for i := 1; i <= n; i++ {
+		retailer := ""
+		for j := 0; j < i; j++ {
+			retailer += "a"
+		}
+		inputs = append(inputs, Input{Id: fmt.Sprint(i), Receipt: &models.Receipt{Retailer: retailer, Total: "1.00"}})
+	}
+	return inputs
+}
+
+func TestCandidateRuleSet(t *testing.T) {
+	tests := []struct {
+		testName      string
+		candidate     Candidate
+		expectedRules []string
+		expectedError bool
+	}{
+		{testName: "Version", candidate: Candidate{Version: "v1"}, expectedRules: []string{"RetailerRule", "TotalRoundRule", "TotalMultipleRule", "NumItemsRule", "ItemDescriptionRule", "PurchaseDayRule", "PurchaseTimeRule"}},
+		{testName: "Rules", candidate: Candidate{Rules: []CandidateRule{{Rule: "RetailerRule", Multiplier: 2}, {Rule: "TotalRoundRule"}}}, expectedRules: []string{"RetailerRule", "TotalRoundRule"}},
+		{testName: "Empty", candidate: Candidate{}, expectedError: true},
+		{testName: "Both", candidate: Candidate{Version: "v1", Rules: []CandidateRule{{Rule: "RetailerRule"}}}, expectedError: true},
+		{testName: "UnknownVersion", candidate: Candidate{Version: "v0"}, expectedError: true},
+		{testName: "UnknownRule", candidate: Candidate{Rules: []CandidateRule{{Rule: "WeekendRule"}}}, expectedError: true},
+		{testName: "Expression", candidate: Candidate{Rules: []CandidateRule{{Rule: "BigBasket", Expression: "len(items) >= 5 ? 20 : 0"}, {Rule: "RetailerRule"}}}, expectedRules: []string{"BigBasket", "RetailerRule"}},
+		{testName: "InvalidExpression", candidate: Candidate{Rules: []CandidateRule{{Rule: "BigBasket", Expression: "len(items) >="}}}, expectedError: true},
+		{testName: "UnnamedExpression", candidate: Candidate{Rules: []CandidateRule{{Expression: "1"}}}, expectedError: true},
+		{testName: "NegativeMultiplier", candidate: Candidate{Rules: []CandidateRule{{Rule: "RetailerRule", Multiplier: -1}}}, expectedError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			ruleSet, err := test.candidate.RuleSet()
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, stage := range ruleSet.Stages {
+				names = append(names, stage.Name)
+			}
+			assert.Equal(t, test.expectedRules, names)
+		})
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	baseline, err := Candidate{Rules: []CandidateRule{{Rule: "RetailerRule"}, {Rule: "TotalRoundRule"}}}.RuleSet()
+	require.NoError(t, err)
+	candidate, err := Candidate{Rules: []CandidateRule{{Rule: "RetailerRule", Multiplier: 2}}}.RuleSet()
+	require.NoError(t, err)
+
+	// Baseline awards i + 50, candidate 2i, so receipts above 50 characters gain and the rest lose
+	report := Simulate(testInputs(60), baseline, candidate, Options{Top: 3})
+
+	assert.Equal(t, 60, report.Receipts)
+	assert.Equal(t, 1830+3000, report.BaselinePoints)
+	assert.Equal(t, 3660, report.CandidatePoints)
+	assert.Equal(t, 3660-4830, report.Delta)
+	assert.Equal(t, 10, report.Increased)
+	assert.Equal(t, 49, report.Decreased)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, []RuleDelta{
+		{Rule: "RetailerRule", BaselinePoints: 1830, CandidatePoints: 3660, Delta: 1830},
+		{Rule: "TotalRoundRule", BaselinePoints: 3000, CandidatePoints: 0, Delta: -3000},
+	}, report.Rules)
+	assert.Equal(t, []ReceiptDelta{
+		{Id: "1", Retailer: "a", BaselinePoints: 51, CandidatePoints: 2, Delta: -49},
+		{Id: "2", Retailer: "aa", BaselinePoints: 52, CandidatePoints: 4, Delta: -48},
+		{Id: "3", Retailer: "aaa", BaselinePoints: 53, CandidatePoints: 6, Delta: -47},
+	}, report.MostAffected)
+
+	// Every receipt is counted once per rule set
+	baselineCount, candidateCount := 0, 0
+	for _, bucket := range report.Distribution {
+		baselineCount += bucket.Baseline
+		candidateCount += bucket.Candidate
+	}
+	assert.Equal(t, 60, baselineCount)
+	assert.Equal(t, 60, candidateCount)
+	assert.Equal(t, Bucket{Min: 50, Max: 75, Baseline: 24, Candidate: 13}, report.Distribution[2])
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	assert.Contains(t, text.String(), "points: 4830 -> 3660 (-1170, -24.2%)")
+	assert.Contains(t, text.String(), "300+")
+}
+
+func TestSimulateSample(t *testing.T) {
+	ruleSet, ok := points.RuleSetVersion("v1")
+	require.True(t, ok)
+	inputs := testInputs(100)
+
+	first := Simulate(inputs, ruleSet, ruleSet, Options{Sample: 10, Seed: 7})
+	second := Simulate(inputs, ruleSet, ruleSet, Options{Sample: 10, Seed: 7})
+	assert.Equal(t, 10, first.Receipts)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 0, first.Delta)
+	assert.Empty(t, first.MostAffected)
+
+	assert.Equal(t, 100, Simulate(inputs, ruleSet, ruleSet, Options{Sample: 500}).Receipts)
+}
@@ -1,24 +1,135 @@
 package storage
 
 import (
+	"fmt"
 	"receipts/models"
+	"receipts/points"
+	"receipts/products"
+	"receipts/retailers"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type ReceiptStorage struct {
 	*sync.RWMutex
-	idToReceipt map[uuid.UUID]*models.Receipt
+	idToReceipt  map[uuid.UUID]*models.Receipt
+	idToMetadata map[uuid.UUID]Metadata
+	idToScore    map[uuid.UUID]points.Score
+	// Ids in the order they were first set, so listing is stable for pagination
+	ids       []uuid.UUID
+	observers []Observer
+	// Sets the canonical product of every item of a receipt when it is saved
+	normalizer *products.Normalizer
+	// Sets the canonical retailer of a receipt when it is saved
+	retailers *retailers.Registry
+}
+
+/*
+Notified of every change to the stored receipts, such as to keep aggregates over them
+up to date. Called while the storage's write lock is held, so changes arrive in the
+order they were made, and must not call back into the storage. Previous is nil for a
+newly stored receipt and current is nil for a deleted one.
+*/
+type Observer interface {
+	ReceiptChanged(previous *StoredReceipt, current *StoredReceipt)
+}
+
+// Information about a stored receipt that isn't part of the receipt itself
+type Metadata struct {
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Version of the points rule set the receipt is scored with, empty if it was never recorded
+	RuleVersion string `json:"ruleVersion,omitempty"`
+}
+
+// A receipt along with the id it is stored under
+type StoredReceipt struct {
+	Id       uuid.UUID
+	Receipt  *models.Receipt
+	Metadata Metadata
+	// Points calculated when the receipt was stored, nil if they never were
+	CachedScore *points.Score
+}
+
+/*
+Returns the cached score, or calculates one with the rule set the receipt was stored
+with when there is no cached score.
+*/
+func (s StoredReceipt) Score() points.Score {
+	if s.CachedScore != nil {
+		return *s.CachedScore
+	}
+	return points.StoredRuleSet(s.Receipt, s.Metadata.RuleVersion).Score(s.Receipt)
 }
 
 func NewReceiptStorage() *ReceiptStorage {
 	return &ReceiptStorage{
-		RWMutex:     &sync.RWMutex{},
-		idToReceipt: make(map[uuid.UUID]*models.Receipt),
+		RWMutex:      &sync.RWMutex{},
+		idToReceipt:  make(map[uuid.UUID]*models.Receipt),
+		idToMetadata: make(map[uuid.UUID]Metadata),
+		idToScore:    make(map[uuid.UUID]points.Score),
+		normalizer:   products.NewNormalizer(products.DefaultAliases()),
+		retailers:    retailers.NewRegistry(),
 	}
 }
 
+/*
+Returns the registry receipts' canonical retailers are matched from. Receipts should
+be canonicalized with it before they are scored, so retailer scoped rules see their
+canonical retailer; those that aren't are canonicalized when saved.
+*/
+func (rs *ReceiptStorage) Retailers() *retailers.Registry {
+	return rs.retailers
+}
+
+/*
+Registers the retailer with the registry, then moves stored receipts matched to a
+discovered retailer it absorbed, or to the retailer it replaced, to it and notifies
+observers, so every receipt from the retailer counts as one. Holds the read / write
+lock throughout, so receipts saved meanwhile are matched to the registered retailer.
+Returns the registered retailer along with the ids of the retailers it absorbed.
+*/
+func (rs *ReceiptStorage) RegisterRetailer(retailer retailers.Retailer) (retailers.Retailer, []string, error) {
+	rs.Lock()
+	defer rs.Unlock()
+	registered, absorbed, err := rs.retailers.Register(retailer)
+	if err != nil {
+		return retailers.Retailer{}, nil, err
+	}
+
+	canonical := &models.CanonicalRetailer{Id: registered.Id, Name: registered.Name}
+	for _, id := range rs.ids {
+		receipt := rs.idToReceipt[id]
+		if receipt.CanonicalRetailer == nil || (receipt.CanonicalRetailer.Id != registered.Id && !slices.Contains(absorbed, receipt.CanonicalRetailer.Id)) {
+			continue
+		}
+		// Readers may be holding the stored receipt, so it is replaced by a copy
+		previous := rs.get(id)
+		moved := *receipt
+		moved.CanonicalRetailer = canonical
+		rs.idToReceipt[id] = &moved
+		current := rs.get(id)
+		for _, observer := range rs.observers {
+			observer.ReceiptChanged(&previous, &current)
+		}
+	}
+	return registered, absorbed, nil
+}
+
+/*
+Replaces the normalizer that sets the products of items, such as with one using aliases
+loaded from a file, after waiting for the read / write lock. Receipts already stored
+keep their products until they are replaced.
+*/
+func (rs *ReceiptStorage) SetNormalizer(normalizer *products.Normalizer) {
+	rs.Lock()
+	defer rs.Unlock()
+	rs.normalizer = normalizer
+}
+
 /*
 If receipt exists, returns the receipt, otherwise returns nil.
 
@@ -30,11 +141,269 @@ func (rs *ReceiptStorage) GetReceipt(id uuid.UUID) *models.Receipt {
 	return rs.idToReceipt[id]
 }
 
+/*
+If receipt exists, returns it along with its id, metadata and cached score.
+
+Waits for read lock.
+*/
+func (rs *ReceiptStorage) GetStoredReceipt(id uuid.UUID) (StoredReceipt, bool) {
+	rs.RLock()
+	defer rs.RUnlock()
+	if _, exists := rs.idToReceipt[id]; !exists {
+		return StoredReceipt{}, false
+	}
+	return rs.get(id), true
+}
+
+// Returns the stored receipt with its metadata and cached score, caller must hold the read lock
+func (rs *ReceiptStorage) get(id uuid.UUID) StoredReceipt {
+	stored := StoredReceipt{Id: id, Receipt: rs.idToReceipt[id], Metadata: rs.idToMetadata[id]}
+	if score, exists := rs.idToScore[id]; exists {
+		stored.CachedScore = &score
+	}
+	return stored
+}
+
 /*
 Saves the id to receipt mapping after waiting for the read / write lock.
+An already recorded rule version is kept, but any cached score is dropped
+since it was calculated for the previous receipt.
 */
 func (rs *ReceiptStorage) SetReceipt(id uuid.UUID, receipt *models.Receipt) {
 	rs.Lock()
 	defer rs.Unlock()
-	rs.idToReceipt[id] = receipt
+	metadata := rs.touch(id)
+	rs.set(StoredReceipt{Id: id, Receipt: receipt, Metadata: metadata})
+}
+
+/*
+Saves the id to receipt mapping along with the score calculated for the receipt,
+recording the score's rule version, after waiting for the read / write lock.
+*/
+func (rs *ReceiptStorage) SetScoredReceipt(id uuid.UUID, receipt *models.Receipt, score points.Score) {
+	rs.Lock()
+	defer rs.Unlock()
+	metadata := rs.touch(id)
+	metadata.RuleVersion = score.RuleVersion
+	rs.set(StoredReceipt{Id: id, Receipt: receipt, Metadata: metadata, CachedScore: &score})
+}
+
+// Returns the id's metadata with its timestamps updated to now, caller must hold the write lock
+func (rs *ReceiptStorage) touch(id uuid.UUID) Metadata {
+	now := time.Now().UTC()
+	metadata, exists := rs.idToMetadata[id]
+	if !exists {
+		metadata.CreatedAt = now
+	}
+	metadata.UpdatedAt = now
+	return metadata
+}
+
+/*
+Sets the products of a new receipt's items, saves it and notifies observers, caller must
+hold the write lock. A receipt already stored, such as one being rescored, isn't normalized
+again, since readers may be holding it.
+*/
+func (rs *ReceiptStorage) set(stored StoredReceipt) {
+	if rs.idToReceipt[stored.Id] != stored.Receipt {
+		rs.normalizer.Normalize(stored.Receipt)
+		rs.retailers.Canonicalize(stored.Receipt)
+	}
+	var previous *StoredReceipt
+	if _, exists := rs.idToReceipt[stored.Id]; exists {
+		existing := rs.get(stored.Id)
+		previous = &existing
+	} else {
+		rs.ids = append(rs.ids, stored.Id)
+	}
+	for _, observer := range rs.observers {
+		observer.ReceiptChanged(previous, &stored)
+	}
+	rs.idToReceipt[stored.Id] = stored.Receipt
+	rs.idToMetadata[stored.Id] = stored.Metadata
+	if stored.CachedScore != nil {
+		rs.idToScore[stored.Id] = *stored.CachedScore
+	} else {
+		delete(rs.idToScore, stored.Id)
+	}
+}
+
+/*
+Removes the receipt and returns it as it was stored, or false if there was no such
+receipt, after waiting for the read / write lock.
+*/
+func (rs *ReceiptStorage) DeleteReceipt(id uuid.UUID) (StoredReceipt, bool) {
+	stored, exists, _ := rs.DeleteReceiptIf(id, func(StoredReceipt) error { return nil })
+	return stored, exists
+}
+
+/*
+Same as DeleteReceipt, but first calls remove with the receipt while holding the read
+/ write lock, and only removes the receipt when remove returns nil. Otherwise the
+receipt is left as it was and remove's error is returned, so changes made elsewhere
+for the deletion either all happen or none do.
+*/
+func (rs *ReceiptStorage) DeleteReceiptIf(id uuid.UUID, remove func(StoredReceipt) error) (StoredReceipt, bool, error) {
+	rs.Lock()
+	defer rs.Unlock()
+	if _, exists := rs.idToReceipt[id]; !exists {
+		return StoredReceipt{}, false, nil
+	}
+	stored := rs.get(id)
+	if err := remove(stored); err != nil {
+		return stored, true, err
+	}
+	delete(rs.idToReceipt, id)
+	delete(rs.idToMetadata, id)
+	delete(rs.idToScore, id)
+	rs.ids = slices.DeleteFunc(rs.ids, func(existing uuid.UUID) bool { return existing == id })
+	for _, observer := range rs.observers {
+		observer.ReceiptChanged(&stored, nil)
+	}
+	return stored, true, nil
+}
+
+/*
+Adds an observer notified of every later change, after first notifying it of every
+receipt already stored as newly stored. Waits for the read / write lock.
+*/
+func (rs *ReceiptStorage) Observe(observer Observer) {
+	rs.Lock()
+	defer rs.Unlock()
+	for _, id := range rs.ids {
+		stored := rs.get(id)
+		observer.ReceiptChanged(nil, &stored)
+	}
+	rs.observers = append(rs.observers, observer)
+}
+
+/*
+Returns every stored receipt in the order they were first saved.
+
+Waits for read lock.
+*/
+func (rs *ReceiptStorage) ListReceipts() []StoredReceipt {
+	rs.RLock()
+	defer rs.RUnlock()
+	receipts := make([]StoredReceipt, 0, len(rs.ids))
+	for _, id := range rs.ids {
+		receipts = append(receipts, rs.get(id))
+	}
+	return receipts
+}
+
+// Returns the number of stored receipts after waiting for the read lock.
+func (rs *ReceiptStorage) Count() int {
+	rs.RLock()
+	defer rs.RUnlock()
+	return len(rs.ids)
+}
+
+// A receipt's score before and after it was rescored
+type ScoreUpdate struct {
+	Id       uuid.UUID
+	Previous points.Score
+	Current  points.Score
+}
+
+/*
+Scores every stored receipt with the score function, in the order they were first
+saved, and returns each receipt's previous and new score. Unless dryRun is true, the
+new scores are cached and their rule version is recorded on every receipt.
+
+Holds the read / write lock for the whole rescore, or only the read lock for a dry run.
+*/
+func (rs *ReceiptStorage) RescoreReceipts(score func(StoredReceipt) points.Score, dryRun bool) []ScoreUpdate {
+	if dryRun {
+		rs.RLock()
+		defer rs.RUnlock()
+	} else {
+		rs.Lock()
+		defer rs.Unlock()
+	}
+
+	updates := make([]ScoreUpdate, 0, len(rs.ids))
+	for _, id := range rs.ids {
+		stored := rs.get(id)
+		update := ScoreUpdate{Id: id, Previous: stored.Score(), Current: score(stored)}
+		updates = append(updates, update)
+		if !dryRun {
+			stored.Metadata.RuleVersion = update.Current.RuleVersion
+			stored.CachedScore = &update.Current
+			rs.set(stored)
+		}
+	}
+	return updates
+}
+
+// What to do when an imported receipt's id is already stored
+type ConflictPolicy string
+
+const (
+	// Keep the stored receipt and ignore the imported one
+	ConflictSkip ConflictPolicy = "skip"
+	// Replace the stored receipt with the imported one
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// Reject the whole import without saving anything
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// Parses a conflict policy name, returning an error for unknown names
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(name); policy {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("conflict policy must be one of skip, overwrite or fail")
+	}
+}
+
+// Counts of what happened to each receipt in an import
+type ImportSummary struct {
+	Imported    int `json:"imported"`
+	Skipped     int `json:"skipped"`
+	Overwritten int `json:"overwritten"`
+}
+
+/*
+Saves receipts keeping their ids, metadata and cached scores, resolving ids that are
+already stored with the policy. Receipts with a zero CreatedAt get the current time.
+
+Holds the read / write lock for the whole import, so with ConflictFail either
+every receipt is saved or none are.
+*/
+func (rs *ReceiptStorage) ImportReceipts(receipts []StoredReceipt, policy ConflictPolicy) (ImportSummary, error) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	var summary ImportSummary
+	if policy == ConflictFail {
+		for _, stored := range receipts {
+			if _, exists := rs.idToReceipt[stored.Id]; exists {
+				return summary, fmt.Errorf("receipt with id %s already exists", stored.Id)
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, stored := range receipts {
+		if _, exists := rs.idToReceipt[stored.Id]; exists {
+			if policy == ConflictSkip {
+				summary.Skipped++
+				continue
+			}
+			summary.Overwritten++
+		} else {
+			summary.Imported++
+		}
+
+		if stored.Metadata.CreatedAt.IsZero() {
+			stored.Metadata.CreatedAt = now
+		}
+		if stored.Metadata.UpdatedAt.IsZero() {
+			stored.Metadata.UpdatedAt = stored.Metadata.CreatedAt
+		}
+		rs.set(stored)
+	}
+	return summary, nil
 }
@@ -2,13 +2,19 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"receipts/models"
+	"receipts/points"
+	"receipts/products"
+	"receipts/retailers"
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetReceipt(t *testing.T) {
@@ -157,3 +163,319 @@ func TestSetGetConcurrent(t *testing.T) {
 
 	wg.Wait()
 }
+
+// Testing that listing returns receipts in the order they were first set
+func TestListReceipts(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	assert.Empty(t, receiptStorage.ListReceipts())
+	assert.Equal(t, 0, receiptStorage.Count())
+
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	receipts := []*models.Receipt{{Retailer: "Target"}, {Retailer: "Walgreens"}, {Retailer: "Costco"}}
+	for i := range ids {
+		receiptStorage.SetReceipt(ids[i], receipts[i])
+	}
+
+	// Updating an existing receipt keeps its position
+	updated := &models.Receipt{Retailer: "Madison Fresh Market"}
+	receiptStorage.SetReceipt(ids[0], updated)
+
+	listed := receiptStorage.ListReceipts()
+	assert.Len(t, listed, 3)
+	expected := []*models.Receipt{updated, receipts[1], receipts[2]}
+	for i := range listed {
+		assert.Equal(t, ids[i], listed[i].Id)
+		assert.Equal(t, expected[i], listed[i].Receipt)
+	}
+	assert.Equal(t, 3, receiptStorage.Count())
+}
+
+// Testing that items get the canonical product of their description when saved
+func TestSetReceiptProducts(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	receiptStorage.SetNormalizer(products.NewNormalizer(map[string]string{"klarbrunn": "Klarbrunn Sparkling Water"}))
+	id := uuid.New()
+	receiptStorage.SetReceipt(id, &models.Receipt{Retailer: "Target", Items: []models.Item{
+		{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: "12.00"},
+		{ShortDescription: "Gatorade", Price: "2.25", Product: &models.Product{Id: "sent by the client"}},
+	}})
+
+	items := receiptStorage.GetReceipt(id).Items
+	require.NotNil(t, items[0].Product)
+	assert.Equal(t, models.Product{Id: "klarbrunn sparkling water 12 fl oz 12 pk", Name: "Klarbrunn Sparkling Water", Size: "12 fl oz", Pack: 12}, *items[0].Product)
+	assert.Equal(t, "gatorade", items[1].Product.Id, "products given with items are replaced")
+	assert.Equal(t, "   Klarbrunn 12-PK 12 FL OZ  ", items[0].ShortDescription, "descriptions are kept as they were")
+}
+
+func TestSetReceiptCanonicalRetailer(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	receiptStorage.SetReceipt(ids[0], &models.Receipt{Retailer: "M&M Corner Market"})
+	receiptStorage.SetReceipt(ids[1], &models.Receipt{Retailer: "M & M Corner Mkt", CanonicalRetailer: &models.CanonicalRetailer{Id: "sent by the client"}})
+
+	expected := &models.CanonicalRetailer{Id: "m-and-m-corner-market", Name: "M&M Corner Market"}
+	assert.Equal(t, expected, receiptStorage.GetReceipt(ids[0]).CanonicalRetailer)
+	assert.Equal(t, expected, receiptStorage.GetReceipt(ids[1]).CanonicalRetailer, "canonical retailers given with receipts are replaced")
+	assert.Equal(t, "M & M Corner Mkt", receiptStorage.GetReceipt(ids[1]).Retailer, "retailers are kept as they were")
+	assert.Len(t, receiptStorage.Retailers().List(), 1)
+}
+
+// Testing that receipts matched to absorbed discovered retailers are moved to the registered one
+func TestRegisterRetailer(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	receiptStorage.SetReceipt(ids[0], &models.Receipt{Retailer: "M&M Corner Market"})
+	receiptStorage.SetReceipt(ids[1], &models.Receipt{Retailer: "MM Market"})
+	receiptStorage.SetReceipt(ids[2], &models.Receipt{Retailer: "Target"})
+	held := receiptStorage.GetReceipt(ids[0])
+	observer := &recordingObserver{}
+	receiptStorage.Observe(observer)
+	observer.changes = nil
+
+	registered, absorbed, err := receiptStorage.RegisterRetailer(retailers.Retailer{Id: "m-and-m", Name: "M and M", Aliases: []string{"M&M Corner Market", "MM Market"}})
+	require.NoError(t, err)
+	assert.Equal(t, "m-and-m", registered.Id)
+	assert.ElementsMatch(t, []string{"m-and-m-corner-market", "mm-market"}, absorbed)
+
+	expected := &models.CanonicalRetailer{Id: "m-and-m", Name: "M and M"}
+	assert.Equal(t, expected, receiptStorage.GetReceipt(ids[0]).CanonicalRetailer)
+	assert.Equal(t, expected, receiptStorage.GetReceipt(ids[1]).CanonicalRetailer)
+	assert.Equal(t, "target", receiptStorage.GetReceipt(ids[2]).RetailerId())
+	assert.Equal(t, "m-and-m-corner-market", held.RetailerId(), "receipts readers hold aren't changed")
+	assert.Len(t, observer.changes, 2)
+
+	// Replacing the retailer renames it on the receipts matched to it
+	_, _, err = receiptStorage.RegisterRetailer(retailers.Retailer{Id: "m-and-m", Name: "M&M", Aliases: []string{"M&M Corner Market", "MM Market"}})
+	require.NoError(t, err)
+	assert.Equal(t, "M&M", receiptStorage.GetReceipt(ids[1]).RetailerName())
+
+	_, _, err = receiptStorage.RegisterRetailer(retailers.Retailer{Id: "other", Name: "M&M"})
+	assert.ErrorIs(t, err, retailers.ErrNameTaken)
+}
+
+// Testing that a deleted receipt is returned as it was stored and no longer listed
+func TestDeleteReceipt(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	score := points.Score{RuleVersion: "v1", Points: 6}
+	receiptStorage.SetScoredReceipt(ids[0], &models.Receipt{Retailer: "Target"}, score)
+	receiptStorage.SetReceipt(ids[1], &models.Receipt{Retailer: "Walgreens"})
+
+	deleted, exists := receiptStorage.DeleteReceipt(ids[0])
+	assert.True(t, exists)
+	assert.Equal(t, "Target", deleted.Receipt.Retailer)
+	assert.Equal(t, &score, deleted.CachedScore)
+
+	_, exists = receiptStorage.DeleteReceipt(ids[0])
+	assert.False(t, exists)
+	assert.Nil(t, receiptStorage.GetReceipt(ids[0]))
+	listed := receiptStorage.ListReceipts()
+	assert.Len(t, listed, 1)
+	assert.Equal(t, ids[1], listed[0].Id)
+}
+
+func TestDeleteReceiptIf(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	id := uuid.New()
+	receiptStorage.SetReceipt(id, &models.Receipt{Retailer: "Target"})
+	failure := errors.New("failure")
+
+	_, exists, err := receiptStorage.DeleteReceiptIf(id, func(stored StoredReceipt) error {
+		assert.Equal(t, "Target", stored.Receipt.Retailer)
+		return failure
+	})
+	assert.True(t, exists)
+	assert.ErrorIs(t, err, failure)
+	assert.NotNil(t, receiptStorage.GetReceipt(id), "a failed removal must leave the receipt stored")
+
+	_, exists, err = receiptStorage.DeleteReceiptIf(id, func(StoredReceipt) error { return nil })
+	assert.True(t, exists)
+	assert.NoError(t, err)
+	assert.Nil(t, receiptStorage.GetReceipt(id))
+
+	called := false
+	_, exists, err = receiptStorage.DeleteReceiptIf(id, func(StoredReceipt) error { called = true; return nil })
+	assert.False(t, exists)
+	assert.NoError(t, err)
+	assert.False(t, called, "remove is only called for stored receipts")
+}
+
+// Records the retailers of every change it is notified of, empty for a missing receipt
+type recordingObserver struct {
+	changes [][2]string
+}
+
+func (o *recordingObserver) ReceiptChanged(previous *StoredReceipt, current *StoredReceipt) {
+	var change [2]string
+	if previous != nil {
+		change[0] = previous.Receipt.Retailer
+	}
+	if current != nil {
+		change[1] = current.Receipt.Retailer
+	}
+	o.changes = append(o.changes, change)
+}
+
+// Testing that observers are notified of existing receipts and every later change
+func TestObserve(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	id := uuid.New()
+	receiptStorage.SetReceipt(id, &models.Receipt{Retailer: "Target"})
+
+	observer := &recordingObserver{}
+	receiptStorage.Observe(observer)
+	receiptStorage.SetScoredReceipt(id, &models.Receipt{Retailer: "Walgreens"}, points.Score{RuleVersion: "v1"})
+	receiptStorage.RescoreReceipts(func(stored StoredReceipt) points.Score { return points.Score{RuleVersion: "v2"} }, true)
+	receiptStorage.RescoreReceipts(func(stored StoredReceipt) points.Score { return points.Score{RuleVersion: "v2"} }, false)
+	receiptStorage.DeleteReceipt(id)
+
+	expected := [][2]string{{"", "Target"}, {"Target", "Walgreens"}, {"Walgreens", "Walgreens"}, {"Walgreens", ""}}
+	assert.Equal(t, expected, observer.changes, "dry runs change nothing, so observers aren't notified")
+}
+
+// Testing that metadata keeps the first save time and tracks the latest update
+func TestGetStoredReceipt(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	id := uuid.New()
+
+	_, exists := receiptStorage.GetStoredReceipt(id)
+	assert.False(t, exists)
+
+	receiptStorage.SetReceipt(id, &models.Receipt{Retailer: "Target"})
+	first, exists := receiptStorage.GetStoredReceipt(id)
+	assert.True(t, exists)
+	assert.Equal(t, id, first.Id)
+	assert.False(t, first.Metadata.CreatedAt.IsZero())
+	assert.Equal(t, first.Metadata.CreatedAt, first.Metadata.UpdatedAt)
+
+	receiptStorage.SetReceipt(id, &models.Receipt{Retailer: "Walgreens"})
+	second, _ := receiptStorage.GetStoredReceipt(id)
+	assert.Equal(t, "Walgreens", second.Receipt.Retailer)
+	assert.Equal(t, first.Metadata.CreatedAt, second.Metadata.CreatedAt)
+	assert.False(t, second.Metadata.UpdatedAt.Before(first.Metadata.UpdatedAt))
+}
+
+// Testing that the score and its rule version are recorded, and that saving a new receipt drops the score
+func TestSetScoredReceipt(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	id := uuid.New()
+
+	score := points.Score{RuleVersion: "v1", Points: 6, Breakdown: []points.RulePoints{{Rule: "RetailerRule", Points: 6}}}
+	receiptStorage.SetScoredReceipt(id, &models.Receipt{Retailer: "Target"}, score)
+	stored, _ := receiptStorage.GetStoredReceipt(id)
+	assert.Equal(t, "v1", stored.Metadata.RuleVersion)
+	assert.Equal(t, &score, stored.CachedScore)
+	assert.Equal(t, score, stored.Score())
+
+	updated := &models.Receipt{Retailer: "Walgreens"}
+	receiptStorage.SetReceipt(id, updated)
+	stored, _ = receiptStorage.GetStoredReceipt(id)
+	assert.Equal(t, "v1", stored.Metadata.RuleVersion)
+	assert.Nil(t, stored.CachedScore)
+	assert.Equal(t, points.CalculatePoints(updated), stored.Score().Points)
+}
+
+func TestRescoreReceipts(t *testing.T) {
+	receiptStorage := NewReceiptStorage()
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	receiptStorage.SetScoredReceipt(ids[0], &models.Receipt{Retailer: "Target"}, points.Score{RuleVersion: "v0", Points: 100})
+	receiptStorage.SetReceipt(ids[1], &models.Receipt{Retailer: "Walgreens"})
+
+	// Awards one point per alphanumeric retailer character only
+	ruleSet := points.RuleSet{Version: "test", Stages: points.AddStages([]points.NamedReceiptRule{{Name: "RetailerRule", Rule: points.RetailerRule}})}
+	score := func(stored StoredReceipt) points.Score {
+		return ruleSet.Score(stored.Receipt)
+	}
+
+	updates := receiptStorage.RescoreReceipts(score, true)
+	assert.Len(t, updates, 2)
+	assert.Equal(t, ids[0], updates[0].Id)
+	assert.Equal(t, 100, updates[0].Previous.Points)
+	assert.Equal(t, 6, updates[0].Current.Points)
+	assert.Equal(t, "test", updates[1].Current.RuleVersion)
+
+	// A dry run saves nothing
+	stored, _ := receiptStorage.GetStoredReceipt(ids[0])
+	assert.Equal(t, 100, stored.Score().Points)
+
+	receiptStorage.RescoreReceipts(score, false)
+	for i, expectedPoints := range []int{6, 9} {
+		stored, _ := receiptStorage.GetStoredReceipt(ids[i])
+		assert.Equal(t, "test", stored.Metadata.RuleVersion)
+		assert.Equal(t, expectedPoints, stored.CachedScore.Points)
+	}
+}
+
+func TestImportReceipts(t *testing.T) {
+	createdAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	existingId := uuid.New()
+	newId := uuid.New()
+
+	// Storage with one receipt, and an import of that id plus a new one
+	setup := func() (*ReceiptStorage, []StoredReceipt) {
+		receiptStorage := NewReceiptStorage()
+		receiptStorage.SetReceipt(existingId, &models.Receipt{Retailer: "Target"})
+		return receiptStorage, []StoredReceipt{
+			{Id: existingId, Receipt: &models.Receipt{Retailer: "Imported Target"}, Metadata: Metadata{CreatedAt: createdAt}},
+			{Id: newId, Receipt: &models.Receipt{Retailer: "Walgreens"}, Metadata: Metadata{CreatedAt: createdAt}},
+		}
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		receiptStorage, imported := setup()
+		summary, err := receiptStorage.ImportReceipts(imported, ConflictSkip)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportSummary{Imported: 1, Skipped: 1}, summary)
+		assert.Equal(t, "Target", receiptStorage.GetReceipt(existingId).Retailer)
+
+		// Metadata of imported receipts is preserved
+		stored, exists := receiptStorage.GetStoredReceipt(newId)
+		assert.True(t, exists)
+		assert.Equal(t, "Walgreens", stored.Receipt.Retailer)
+		assert.Equal(t, createdAt, stored.Metadata.CreatedAt)
+		assert.Equal(t, createdAt, stored.Metadata.UpdatedAt)
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		receiptStorage, imported := setup()
+		summary, err := receiptStorage.ImportReceipts(imported, ConflictOverwrite)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportSummary{Imported: 1, Overwritten: 1}, summary)
+		assert.Equal(t, "Imported Target", receiptStorage.GetReceipt(existingId).Retailer)
+		assert.Equal(t, 2, receiptStorage.Count())
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		receiptStorage, imported := setup()
+		_, err := receiptStorage.ImportReceipts(imported, ConflictFail)
+		assert.Error(t, err)
+		// Nothing from the import was saved
+		assert.Nil(t, receiptStorage.GetReceipt(newId))
+		assert.Equal(t, "Target", receiptStorage.GetReceipt(existingId).Retailer)
+	})
+
+	t.Run("FailWithoutConflict", func(t *testing.T) {
+		receiptStorage, imported := setup()
+		summary, err := receiptStorage.ImportReceipts(imported[1:], ConflictFail)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportSummary{Imported: 1}, summary)
+	})
+
+	t.Run("MissingCreatedAt", func(t *testing.T) {
+		receiptStorage := NewReceiptStorage()
+		_, err := receiptStorage.ImportReceipts([]StoredReceipt{{Id: newId, Receipt: &models.Receipt{}}}, ConflictFail)
+		assert.NoError(t, err)
+		stored, _ := receiptStorage.GetStoredReceipt(newId)
+		assert.False(t, stored.Metadata.CreatedAt.IsZero())
+	})
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	for _, name := range []string{"skip", "overwrite", "fail"} {
+		policy, err := ParseConflictPolicy(name)
+		assert.NoError(t, err)
+		assert.Equal(t, ConflictPolicy(name), policy)
+	}
+	_, err := ParseConflictPolicy("replace")
+	assert.Error(t, err)
+}
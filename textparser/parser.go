@@ -0,0 +1,378 @@
+package textparser
+
+import (
+	"fmt"
+	"math"
+	"receipts/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the fields confidence is reported for, matching the json names of models.Receipt
+const (
+	FieldRetailer     string = "retailer"
+	FieldPurchaseDate string = "purchaseDate"
+	FieldPurchaseTime string = "purchaseTime"
+	FieldItems        string = "items"
+	FieldTotal        string = "total"
+)
+
+// Name reported when no registered template matched the text
+const GenericTemplateName string = "generic"
+
+// Output of parsing text into a receipt
+type Result struct {
+	Receipt *models.Receipt `json:"receipt"`
+	// Confidence between 0 and 1 per field, 0 meaning the field could not be found
+	Confidence map[string]float64 `json:"confidence"`
+	// Name of the template used, GenericTemplateName if none matched
+	Template string `json:"template"`
+}
+
+/*
+Parses raw receipt text printed by POS systems. Text is checked against each
+registered Template in the order they were registered, and the first match
+supplies overrides for the generic layout heuristics.
+*/
+type Parser struct {
+	*sync.RWMutex
+	templates []Template
+}
+
+func NewParser(templates ...Template) *Parser {
+	return &Parser{
+		RWMutex:   &sync.RWMutex{},
+		templates: templates,
+	}
+}
+
+// Adds a template after waiting for the read / write lock.
+func (p *Parser) Register(template Template) {
+	p.Lock()
+	defer p.Unlock()
+	p.templates = append(p.templates, template)
+}
+
+// Returns the first registered template matching the text, or nil if none do
+func (p *Parser) findTemplate(text string) *Template {
+	p.RLock()
+	defer p.RUnlock()
+	for i := range p.templates {
+		if p.templates[i].Match != nil && p.templates[i].Match.MatchString(text) {
+			template := p.templates[i]
+			return &template
+		}
+	}
+	return nil
+}
+
+/*
+Extracts a receipt from text. Fields that could not be found are left empty with
+a confidence of 0, so the returned receipt should still be checked with Validate.
+An error is only returned when nothing resembling a receipt was found.
+*/
+func (p *Parser) Parse(text string) (*Result, error) {
+	lines := splitLines(text)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("receipt text is empty")
+	}
+
+	template := p.findTemplate(text)
+	result := &Result{
+		Receipt:    &models.Receipt{},
+		Confidence: map[string]float64{},
+		Template:   GenericTemplateName,
+	}
+	if template != nil {
+		result.Template = template.Name
+	}
+
+	p.parseRetailer(lines, template, result)
+	parseDateTime(lines, result)
+	total, totalLine := parseTotal(lines, template, result)
+	parseItems(lines, template, totalLine, total, result)
+
+	if result.Receipt.Total == "" && len(result.Receipt.Items) == 0 {
+		return nil, fmt.Errorf("no items or total found in receipt text")
+	}
+	return result, nil
+}
+
+// Trimmed non empty lines of the text
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+var (
+	disallowedRetailerChars    = regexp.MustCompile(`[^\w\s\-&]+`)
+	disallowedDescriptionChars = regexp.MustCompile(`[^\w\s\-]+`)
+	repeatedSpaces             = regexp.MustCompile(`\s+`)
+	containsLetter             = regexp.MustCompile(`[A-Za-z]`)
+	// Lines near the top of a receipt that are not the store name
+	notRetailerLine = regexp.MustCompile(`(?i)(^\d+\s+\w+.*\b(st|street|ave|avenue|rd|road|blvd|dr|drive|hwy|ln|way)\b|\(\d{3}\)|\d{3}[-.]\d{3}[-.]\d{4}|^(store|st#|tel|phone|www\.|http)|welcome|receipt)`)
+)
+
+// Removes characters that models.RetailerRegex or models.ShortDescriptionRegex does not allow
+func sanitize(value string, disallowed *regexp.Regexp) string {
+	value = disallowed.ReplaceAllString(value, " ")
+	return strings.TrimSpace(repeatedSpaces.ReplaceAllString(value, " "))
+}
+
+/*
+The template's retailer is used when there is one. Otherwise the store name is
+assumed to be the first line near the top that isn't an address, phone number
+or greeting, which is how almost every thermal receipt is laid out.
+*/
+func (p *Parser) parseRetailer(lines []string, template *Template, result *Result) {
+	if template != nil && template.Retailer != "" {
+		result.Receipt.Retailer = template.Retailer
+		result.Confidence[FieldRetailer] = 1
+		return
+	}
+
+	for i, line := range lines {
+		if i >= 5 {
+			break
+		}
+		if !containsLetter.MatchString(line) || notRetailerLine.MatchString(line) {
+			continue
+		}
+		retailer := sanitize(line, disallowedRetailerChars)
+		if retailer == "" {
+			continue
+		}
+
+		result.Receipt.Retailer = retailer
+		result.Confidence[FieldRetailer] = 0.7
+		if retailer != line {
+			result.Confidence[FieldRetailer] = 0.5
+		}
+		// Further down the page it is less likely to be the header
+		result.Confidence[FieldRetailer] -= 0.1 * float64(i)
+		return
+	}
+}
+
+// Date formats commonly printed on receipts, most confident first
+var dateFormats = []struct {
+	pattern    *regexp.Regexp
+	layouts    []string
+	confidence float64
+}{
+	{regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}\b`), []string{"2006-01-02"}, 1},
+	{regexp.MustCompile(`\b\d{1,2}/\d{1,2}/\d{4}\b`), []string{"1/2/2006", "01/02/2006"}, 0.9},
+	{regexp.MustCompile(`\b(?i:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.? \d{1,2},? \d{4}\b`), []string{"Jan 2, 2006", "Jan 2 2006", "January 2, 2006", "January 2 2006"}, 0.9},
+	{regexp.MustCompile(`\b\d{1,2}/\d{1,2}/\d{2}\b`), []string{"1/2/06", "01/02/06"}, 0.8},
+}
+
+var timePattern = regexp.MustCompile(`(?i)\b(\d{1,2}):(\d{2})(?::\d{2})?\s*([ap]\.?m\.?)?`)
+
+// Finds the first date on the receipt, and the first time, preferring one on the same line as the date
+func parseDateTime(lines []string, result *Result) {
+	dateLine := -1
+	for i, line := range lines {
+		for _, format := range dateFormats {
+			match := format.pattern.FindString(line)
+			if match == "" {
+				continue
+			}
+			for _, layout := range format.layouts {
+				parsed, err := time.Parse(layout, normalizeMonth(match))
+				if err != nil {
+					continue
+				}
+				result.Receipt.PurchaseDate = models.PurchaseDate{Date: parsed}
+				result.Confidence[FieldPurchaseDate] = format.confidence
+				dateLine = i
+				break
+			}
+			if dateLine != -1 {
+				break
+			}
+		}
+		if dateLine != -1 {
+			break
+		}
+	}
+
+	candidates := lines
+	if dateLine != -1 {
+		// Look at the date's line first, then the rest of the receipt
+		candidates = append([]string{lines[dateLine]}, lines...)
+	}
+	for i, line := range candidates {
+		purchaseTime, ok := parseTime(line)
+		if !ok {
+			continue
+		}
+		result.Receipt.PurchaseTime = purchaseTime
+		result.Confidence[FieldPurchaseTime] = 0.7
+		if dateLine != -1 && i == 0 {
+			result.Confidence[FieldPurchaseTime] = 0.9
+		}
+		return
+	}
+}
+
+// Month names are title cased so time.Parse accepts "JAN" as well as "Jan"
+func normalizeMonth(date string) string {
+	if len(date) > 0 && containsLetter.MatchString(date[:1]) {
+		parts := strings.SplitN(date, " ", 2)
+		parts[0] = strings.ToUpper(parts[0][:1]) + strings.ToLower(strings.TrimSuffix(parts[0][1:], "."))
+		return strings.Join(parts, " ")
+	}
+	return date
+}
+
+// Parses 24 hour or am/pm times into a models.PurchaseTime
+func parseTime(line string) (models.PurchaseTime, bool) {
+	match := timePattern.FindStringSubmatch(line)
+	if match == nil {
+		return models.PurchaseTime{}, false
+	}
+
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+	meridiem := strings.ToLower(strings.ReplaceAll(match[3], ".", ""))
+	if meridiem != "" {
+		if hour < 1 || hour > 12 {
+			return models.PurchaseTime{}, false
+		}
+		hour = hour % 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return models.PurchaseTime{}, false
+	}
+
+	purchaseTime, err := models.ParsePurchaseTime(fmt.Sprintf("%02d:%02d", hour, minute))
+	return purchaseTime, err == nil
+}
+
+var (
+	amountPattern = regexp.MustCompile(`\$?\s?(\d+\.\d{2})\b`)
+	// Total lines, SUBTOTAL is excluded with nonTotalPattern
+	totalPattern    = regexp.MustCompile(`(?i)^(total|grand total|amount due|balance due|total due)\b`)
+	nonTotalPattern = regexp.MustCompile(`(?i)(sub\s*-?\s*total|total\s+(savings|items|discount|tax))`)
+)
+
+/*
+Uses the last amount on the first line labelled as a total. Returns the total in
+cents and the line it was found on, or -1 for both when no total was found.
+*/
+func parseTotal(lines []string, template *Template, result *Result) (int, int) {
+	pattern := totalPattern
+	if template != nil && template.TotalPattern != nil {
+		pattern = template.TotalPattern
+	}
+
+	for i, line := range lines {
+		if !pattern.MatchString(line) || nonTotalPattern.MatchString(line) {
+			continue
+		}
+		amounts := amountPattern.FindAllStringSubmatch(line, -1)
+		if len(amounts) == 0 {
+			continue
+		}
+		total := amounts[len(amounts)-1][1]
+		result.Receipt.Total = total
+		result.Confidence[FieldTotal] = 0.9
+		return toCents(total), i
+	}
+	return -1, -1
+}
+
+var (
+	// Description followed by an amount at the end of the line, optionally with a tax flag letter
+	itemPattern = regexp.MustCompile(`^(.*?[A-Za-z].*?)\s+\$?(\d+\.\d{2})(\s+[A-Z]{1,2})?$`)
+	// Lines with an amount that are not purchased items
+	nonItemPattern = regexp.MustCompile(`(?i)\b(total|subtotal|sub total|tax|change|cash|visa|mastercard|amex|discover|credit|debit|tender|balance|savings|you saved|coupon|discount|payment|tip|gratuity|rounding|auth)\b`)
+	// Leading SKU or UPC numbers printed before item descriptions
+	skuPattern = regexp.MustCompile(`^\d{5,}\s+`)
+	// Quantity noise such as "2 @ 1.25" left in descriptions
+	quantityPattern = regexp.MustCompile(`\s+\d+\s*@\s*\d+\.\d{2}.*$`)
+)
+
+/*
+Every line above the total that ends in an amount and is not a subtotal, tax or
+payment line is treated as an item. Confidence is highest when the item prices
+add up to the total.
+*/
+func parseItems(lines []string, template *Template, totalLine int, total int, result *Result) {
+	pattern := itemPattern
+	if template != nil && template.ItemPattern != nil {
+		pattern = template.ItemPattern
+	}
+	descriptionGroup, priceGroup := 1, 2
+	if index := pattern.SubexpIndex("description"); index != -1 {
+		descriptionGroup = index
+	}
+	if index := pattern.SubexpIndex("price"); index != -1 {
+		priceGroup = index
+	}
+
+	end := len(lines)
+	if totalLine != -1 {
+		end = totalLine
+	}
+
+	sum := 0
+	for _, line := range lines[:end] {
+		if nonItemPattern.MatchString(line) {
+			continue
+		}
+		if template != nil && template.SkipPattern != nil && template.SkipPattern.MatchString(line) {
+			continue
+		}
+		match := pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		price := match[priceGroup]
+		description := skuPattern.ReplaceAllString(match[descriptionGroup], "")
+		description = quantityPattern.ReplaceAllString(description, "")
+		description = sanitize(description, disallowedDescriptionChars)
+		if description == "" {
+			continue
+		}
+		result.Receipt.Items = append(result.Receipt.Items, models.Item{ShortDescription: description, Price: price})
+		sum += toCents(price)
+	}
+
+	if len(result.Receipt.Items) == 0 {
+		return
+	}
+	switch {
+	case total == -1:
+		result.Confidence[FieldItems] = 0.5
+	case sum == total:
+		// Items adding up exactly also confirms the total
+		result.Confidence[FieldItems] = 1
+		result.Confidence[FieldTotal] = 1
+	case sum < total:
+		// Tax is usually the difference, so this is still a reasonable read
+		result.Confidence[FieldItems] = 0.7
+	default:
+		result.Confidence[FieldItems] = 0.4
+	}
+}
+
+func toCents(amount string) int {
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(value * 100))
+}
@@ -0,0 +1,150 @@
+package textparser
+
+import (
+	"receipts/models"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const targetText = `
+          TARGET
+   1234 Main Street, Madison WI
+       (608) 555-0100
+
+01/01/2022  01:01 PM
+
+MOUNTAIN DEW 12PK          6.49 T
+EMILS CHEESE PIZZA        12.25 F
+KNORR CREAMY CHICKEN       1.26 F
+DORITOS NACHO CHEESE       3.35 F
+KLARBRUNN 12-PK 12 FL OZ  12.00 T
+
+SUBTOTAL                  35.35
+TAX                        0.00
+TOTAL                     35.35
+VISA                      35.35
+CHANGE DUE                 0.00
+`
+
+const walgreensText = `Walgreens #1234
+Store 1234 - Madison
+2022-01-02 08:13:45
+   012345678901 Pepsi - 12-oz     $1.25
+   012345678902 Dasani            $1.40
+Subtotal $2.65
+Total    $2.65
+Thank you for shopping`
+
+func TestParse(t *testing.T) {
+	parser := NewParser()
+
+	t.Run("Target", func(t *testing.T) {
+		result, err := parser.Parse(targetText)
+		require.NoError(t, err)
+		assert.Equal(t, GenericTemplateName, result.Template)
+		assert.Equal(t, "TARGET", result.Receipt.Retailer)
+		assert.Equal(t, "2022-01-01", result.Receipt.PurchaseDate.String())
+		assert.Equal(t, "13:01", result.Receipt.PurchaseTime.String())
+		assert.Equal(t, "35.35", result.Receipt.Total)
+		assert.Equal(t, []models.Item{
+			{ShortDescription: "MOUNTAIN DEW 12PK", Price: "6.49"},
+			{ShortDescription: "EMILS CHEESE PIZZA", Price: "12.25"},
+			{ShortDescription: "KNORR CREAMY CHICKEN", Price: "1.26"},
+			{ShortDescription: "DORITOS NACHO CHEESE", Price: "3.35"},
+			{ShortDescription: "KLARBRUNN 12-PK 12 FL OZ", Price: "12.00"},
+		}, result.Receipt.Items)
+		assert.Equal(t, 1.0, result.Confidence[FieldItems])
+		assert.Equal(t, 1.0, result.Confidence[FieldTotal])
+		assert.Equal(t, 0.9, result.Confidence[FieldPurchaseDate])
+		assert.Equal(t, 0.9, result.Confidence[FieldPurchaseTime])
+		assert.NoError(t, result.Receipt.Validate())
+	})
+
+	t.Run("WalgreensWithSkusAndDollarSigns", func(t *testing.T) {
+		result, err := parser.Parse(walgreensText)
+		require.NoError(t, err)
+		assert.Equal(t, "Walgreens 1234", result.Receipt.Retailer)
+		assert.Equal(t, 0.5, result.Confidence[FieldRetailer])
+		assert.Equal(t, "2022-01-02", result.Receipt.PurchaseDate.String())
+		assert.Equal(t, 1.0, result.Confidence[FieldPurchaseDate])
+		assert.Equal(t, "08:13", result.Receipt.PurchaseTime.String())
+		assert.Equal(t, "2.65", result.Receipt.Total)
+		assert.Equal(t, []models.Item{
+			{ShortDescription: "Pepsi - 12-oz", Price: "1.25"},
+			{ShortDescription: "Dasani", Price: "1.40"},
+		}, result.Receipt.Items)
+		assert.NoError(t, result.Receipt.Validate())
+	})
+
+	t.Run("ItemsDoNotAddUp", func(t *testing.T) {
+		result, err := parser.Parse("Corner Store\nMar 20, 2022 2:33pm\nGatorade 2.25\nTax 0.20\nTotal 2.45")
+		require.NoError(t, err)
+		assert.Equal(t, "2022-03-20", result.Receipt.PurchaseDate.String())
+		assert.Equal(t, "14:33", result.Receipt.PurchaseTime.String())
+		assert.Equal(t, []models.Item{{ShortDescription: "Gatorade", Price: "2.25"}}, result.Receipt.Items)
+		assert.Equal(t, 0.7, result.Confidence[FieldItems])
+		assert.Equal(t, 0.9, result.Confidence[FieldTotal])
+	})
+
+	t.Run("MissingFieldsHaveNoConfidence", func(t *testing.T) {
+		result, err := parser.Parse("Corner Store\nGatorade 2.25")
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, result.Confidence[FieldPurchaseDate])
+		assert.Equal(t, 0.0, result.Confidence[FieldPurchaseTime])
+		assert.Equal(t, 0.0, result.Confidence[FieldTotal])
+		assert.Equal(t, 0.5, result.Confidence[FieldItems])
+		assert.Error(t, result.Receipt.Validate())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, err := parser.Parse("  \n\n ")
+		assert.Error(t, err)
+	})
+
+	t.Run("NoItemsOrTotal", func(t *testing.T) {
+		_, err := parser.Parse("Hello\nWorld")
+		assert.Error(t, err)
+	})
+}
+
+func TestParseWithTemplate(t *testing.T) {
+	// Store whose item lines put the price first, which the generic layout can't read
+	parser := NewParser()
+	parser.Register(Template{
+		Name:         "corner-market",
+		Match:        regexp.MustCompile(`(?i)m&m corner`),
+		Retailer:     "M&M Corner Market",
+		ItemPattern:  regexp.MustCompile(`^\$(?P<price>\d+\.\d{2})\s+(?P<description>.+)$`),
+		TotalPattern: regexp.MustCompile(`(?i)^amt`),
+		SkipPattern:  regexp.MustCompile(`(?i)member`),
+	})
+
+	text := `*** M&M CORNER MKT ***
+03/20/2022 14:33
+$2.25 Gatorade
+$2.25 Gatorade
+$0.00 MEMBER CARD
+AMT 4.50`
+
+	result, err := parser.Parse(text)
+	require.NoError(t, err)
+	assert.Equal(t, "corner-market", result.Template)
+	assert.Equal(t, "M&M Corner Market", result.Receipt.Retailer)
+	assert.Equal(t, 1.0, result.Confidence[FieldRetailer])
+	assert.Equal(t, "4.50", result.Receipt.Total)
+
+	assert.Equal(t, []models.Item{
+		{ShortDescription: "Gatorade", Price: "2.25"},
+		{ShortDescription: "Gatorade", Price: "2.25"},
+	}, result.Receipt.Items)
+	assert.Equal(t, 1.0, result.Confidence[FieldItems])
+	assert.NoError(t, result.Receipt.Validate())
+
+	// Text not matching the template still uses the generic layout
+	result, err = parser.Parse(walgreensText)
+	require.NoError(t, err)
+	assert.Equal(t, GenericTemplateName, result.Template)
+}
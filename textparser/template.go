@@ -0,0 +1,25 @@
+package textparser
+
+import "regexp"
+
+/*
+Per-retailer overrides for receipts whose layout the generic heuristics get wrong.
+Only Match is required, any other field left empty falls back to the generic behavior.
+*/
+type Template struct {
+	// Name reported in Result.Template
+	Name string
+	// Applied to the whole receipt text to decide if this template is used
+	Match *regexp.Regexp
+	// Retailer name to use instead of guessing it from the header
+	Retailer string
+	/*
+		Item lines. Groups named description and price are used if present,
+		otherwise the first group must be the description and the second the price.
+	*/
+	ItemPattern *regexp.Regexp
+	// Line holding the total, the last amount on that line is used
+	TotalPattern *regexp.Regexp
+	// Lines that look like items but are not, in addition to the generic ones
+	SkipPattern *regexp.Regexp
+}
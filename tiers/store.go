@@ -0,0 +1,158 @@
+package tiers
+
+import (
+	"log"
+	"receipts/accounts"
+	"sync"
+	"time"
+)
+
+// An account moving to a tier, recorded when tiers are recalculated
+type Change struct {
+	AccountId string `json:"accountId"`
+	Tier      Tier   `json:"tier"`
+	// Name of the tier the account was in before, the lowest tier for accounts never recalculated
+	Previous string `json:"previous"`
+	// Qualifying points or spend the account had when it moved
+	Qualifying float64   `json:"qualifying"`
+	From       time.Time `json:"from"`
+}
+
+/*
+Thread safe store of the tier program and every account's tier history, reading
+qualifying activity from the accounts' ledgers. Accounts only move between tiers
+when tiers are recalculated, so a receipt earns with the tier the account was in
+when it was submitted. Accounts that never moved are in the lowest tier.
+*/
+type Store struct {
+	*sync.RWMutex
+	ledger  *accounts.Store
+	program Program
+	// Tier changes of each account, oldest first
+	idToHistory map[string][]Change
+}
+
+func NewStore(ledger *accounts.Store) *Store {
+	return &Store{
+		RWMutex:     &sync.RWMutex{},
+		ledger:      ledger,
+		program:     DefaultProgram(),
+		idToHistory: make(map[string][]Change),
+	}
+}
+
+// Returns the tier program after waiting for the read lock.
+func (s *Store) Program() Program {
+	s.RLock()
+	defer s.RUnlock()
+	return s.program
+}
+
+/*
+Replaces the tier program, returning an error and keeping the current one if it
+is invalid, after waiting for the read / write lock. Accounts keep their tiers
+until they are next recalculated.
+*/
+func (s *Store) SetProgram(program Program) error {
+	if err := program.Validate(); err != nil {
+		return err
+	}
+	program.Tiers = append([]Tier(nil), program.Tiers...)
+
+	s.Lock()
+	defer s.Unlock()
+	s.program = program
+	return nil
+}
+
+// Returns the account's tier changes oldest first after waiting for the read lock.
+func (s *Store) History(accountId string) []Change {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]Change{}, s.idToHistory[accountId]...)
+}
+
+// Returns the tier the account was in at the given time after waiting for the read lock.
+func (s *Store) TierAt(accountId string, at time.Time) Tier {
+	s.RLock()
+	defer s.RUnlock()
+	return s.tierAt(accountId, at)
+}
+
+// Same as TierAt, caller must hold the read lock
+func (s *Store) tierAt(accountId string, at time.Time) Tier {
+	tier := s.program.Tiers[0]
+	for _, change := range s.idToHistory[accountId] {
+		if change.From.After(at) {
+			break
+		}
+		tier = change.Tier
+	}
+	return tier
+}
+
+/*
+Returns the points or spend, depending on the program's basis, the account earned in
+the QualifyingMonths before now, or false if there is no such account. Waits for the read lock.
+*/
+func (s *Store) Qualifying(accountId string, now time.Time) (float64, bool) {
+	return s.qualifying(s.Program(), accountId, now)
+}
+
+// Same as Qualifying under the given program
+func (s *Store) qualifying(program Program, accountId string, now time.Time) (float64, bool) {
+	points, spend, exists := s.ledger.Activity(accountId, now.AddDate(0, -QualifyingMonths, 0))
+	if !exists {
+		return 0, false
+	}
+	if program.Basis == BasisSpend {
+		return spend, true
+	}
+	return float64(points), true
+}
+
+/*
+Moves every account to the tier its qualifying activity reaches now, and returns the
+changes for the accounts whose tier changed. Holds the read / write lock for the whole
+run, so receipts submitted meanwhile earn with the tier from before or after it.
+*/
+func (s *Store) Recalculate(now time.Time) []Change {
+	s.Lock()
+	defer s.Unlock()
+	changes := []Change{}
+	for _, id := range s.ledger.AccountIds() {
+		qualifying, exists := s.qualifying(s.program, id, now)
+		if !exists {
+			continue
+		}
+		current := s.tierAt(id, now)
+		tier := s.program.TierFor(qualifying)
+		if tier == current {
+			continue
+		}
+		change := Change{AccountId: id, Tier: tier, Previous: current.Name, Qualifying: qualifying, From: now}
+		s.idToHistory[id] = append(s.idToHistory[id], change)
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+/*
+Recalculates tiers every interval until stop is closed, logging how many accounts
+changed tier. Blocks, so it is usually run in its own goroutine.
+*/
+func (s *Store) RunRecalculation(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			changes := s.Recalculate(now.UTC())
+			if len(changes) > 0 {
+				log.Printf("recalculated tiers, %d accounts changed tier", len(changes))
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package tiers
+
+import (
+	"fmt"
+	"math"
+	"receipts/models"
+	"receipts/points"
+	"strings"
+)
+
+// Longest name a tier can have
+const MaxNameLength int = 64
+
+// Months of activity an account's tier is based on, ending at the time the tier is calculated
+const QualifyingMonths int = 12
+
+// What an account qualifies for a tier with
+type Basis string
+
+const (
+	// Points earned from receipts
+	BasisPoints Basis = "points"
+	// Total of the receipts points were earned for, in dollars
+	BasisSpend Basis = "spend"
+)
+
+// A membership level, reached once an account's qualifying activity is at least Threshold
+type Tier struct {
+	Name      string  `json:"name"`
+	Threshold float64 `json:"threshold"`
+	// Points from the rule set are multiplied by this, so 1.5 adds half of them again. 1 adds nothing.
+	Multiplier float64 `json:"multiplier"`
+}
+
+/*
+Adds the tier's share of the points the rule set awarded to the score. Like campaign
+multipliers, it applies to the rule set's points only, so it doesn't compound campaigns.
+*/
+func (t Tier) Apply(receipt *models.Receipt, score points.Score) points.Score {
+	tierPoints := int(math.Round(float64(score.RulePoints()) * (t.Multiplier - 1)))
+	if tierPoints == 0 {
+		return score
+	}
+	score.Points += tierPoints
+	score.Breakdown = append(score.Breakdown, points.RulePoints{Rule: t.Name + " tier", Points: tierPoints, Tier: t.Name})
+	return score
+}
+
+// The tiers accounts can reach, ordered from lowest to highest threshold
+type Program struct {
+	Basis Basis  `json:"basis"`
+	Tiers []Tier `json:"tiers"`
+}
+
+// Returns the program accounts start with, Bronze, Silver at 1000 points and Gold at 5000 points
+func DefaultProgram() Program {
+	return Program{
+		Basis: BasisPoints,
+		Tiers: []Tier{
+			{Name: "Bronze", Threshold: 0, Multiplier: 1},
+			{Name: "Silver", Threshold: 1000, Multiplier: 1.25},
+			{Name: "Gold", Threshold: 5000, Multiplier: 1.5},
+		},
+	}
+}
+
+/*
+Checks the program has a known basis and at least one tier, that the first tier has a
+threshold of 0 so every account has a tier, that thresholds strictly increase, and that
+every tier has a unique name and a multiplier of at least 1.
+*/
+func (p Program) Validate() error {
+	if p.Basis != BasisPoints && p.Basis != BasisSpend {
+		return fmt.Errorf("basis must be one of points or spend")
+	}
+	if len(p.Tiers) == 0 {
+		return fmt.Errorf("there must be at least one tier")
+	}
+	if p.Tiers[0].Threshold != 0 {
+		return fmt.Errorf("first tier must have a threshold of 0")
+	}
+	names := make(map[string]bool)
+	for i, tier := range p.Tiers {
+		if strings.TrimSpace(tier.Name) == "" {
+			return fmt.Errorf("tier %d must have a name", i+1)
+		}
+		if len(tier.Name) > MaxNameLength {
+			return fmt.Errorf("name of tier %d must be at most %d characters", i+1, MaxNameLength)
+		}
+		if names[tier.Name] {
+			return fmt.Errorf("tier %s is defined more than once", tier.Name)
+		}
+		names[tier.Name] = true
+		if tier.Multiplier < 1 {
+			return fmt.Errorf("multiplier of tier %s must be at least 1", tier.Name)
+		}
+		if i > 0 && tier.Threshold <= p.Tiers[i-1].Threshold {
+			return fmt.Errorf("threshold of tier %s must be above that of %s", tier.Name, p.Tiers[i-1].Name)
+		}
+	}
+	return nil
+}
+
+// Returns the highest tier whose threshold the qualifying activity reaches
+func (p Program) TierFor(qualifying float64) Tier {
+	reached := p.Tiers[0]
+	for _, tier := range p.Tiers[1:] {
+		if qualifying < tier.Threshold {
+			break
+		}
+		reached = tier
+	}
+	return reached
+}
+
+// Returns the tier after the given one, or false if it is the highest or no longer in the program
+func (p Program) NextTier(name string) (Tier, bool) {
+	for i, tier := range p.Tiers[:len(p.Tiers)-1] {
+		if tier.Name == name {
+			return p.Tiers[i+1], true
+		}
+	}
+	return Tier{}, false
+}
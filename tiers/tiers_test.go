@@ -0,0 +1,148 @@
+package tiers
+
+import (
+	"receipts/accounts"
+	"receipts/points"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramValidate(t *testing.T) {
+	tests := []struct {
+		testName    string
+		program     Program
+		expectedErr bool
+	}{
+		{testName: "Default", program: DefaultProgram()},
+		{testName: "Spend", program: Program{Basis: BasisSpend, Tiers: []Tier{{Name: "Member", Multiplier: 1}}}},
+		{testName: "UnknownBasis", program: Program{Basis: "visits", Tiers: []Tier{{Name: "Member", Multiplier: 1}}}, expectedErr: true},
+		{testName: "NoTiers", program: Program{Basis: BasisPoints}, expectedErr: true},
+		{testName: "FirstThresholdAboveZero", program: Program{Basis: BasisPoints, Tiers: []Tier{{Name: "Member", Threshold: 10, Multiplier: 1}}}, expectedErr: true},
+		{testName: "EmptyName", program: Program{Basis: BasisPoints, Tiers: []Tier{{Name: " ", Multiplier: 1}}}, expectedErr: true},
+		{testName: "DuplicateName", program: Program{Basis: BasisPoints, Tiers: []Tier{{Name: "A", Multiplier: 1}, {Name: "A", Threshold: 5, Multiplier: 2}}}, expectedErr: true},
+		{testName: "MultiplierBelowOne", program: Program{Basis: BasisPoints, Tiers: []Tier{{Name: "A", Multiplier: 0.5}}}, expectedErr: true},
+		{testName: "ThresholdsNotIncreasing", program: Program{Basis: BasisPoints, Tiers: []Tier{{Name: "A", Multiplier: 1}, {Name: "B", Threshold: 0, Multiplier: 2}}}, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			assert.Equal(t, test.expectedErr, test.program.Validate() != nil)
+		})
+	}
+}
+
+func TestTierFor(t *testing.T) {
+	program := DefaultProgram()
+	tests := []struct {
+		qualifying   float64
+		expectedTier string
+		expectedNext string
+	}{
+		{qualifying: 0, expectedTier: "Bronze", expectedNext: "Silver"},
+		{qualifying: 999, expectedTier: "Bronze", expectedNext: "Silver"},
+		{qualifying: 1000, expectedTier: "Silver", expectedNext: "Gold"},
+		{qualifying: 10000, expectedTier: "Gold"},
+	}
+
+	for _, test := range tests {
+		tier := program.TierFor(test.qualifying)
+		assert.Equal(t, test.expectedTier, tier.Name)
+		next, exists := program.NextTier(tier.Name)
+		assert.Equal(t, test.expectedNext != "", exists)
+		assert.Equal(t, test.expectedNext, next.Name)
+	}
+}
+
+func TestTierApply(t *testing.T) {
+	score := points.Score{
+		RuleVersion: "v1",
+		Points:      30,
+		Breakdown: []points.RulePoints{
+			{Rule: "RetailerRule", Points: 10},
+			{Rule: "Double points", Points: 10, Campaign: "campaign"},
+			{Rule: "ItemCountRule", Points: 10},
+		},
+	}
+
+	gold := Tier{Name: "Gold", Threshold: 5000, Multiplier: 1.5}
+	scored := gold.Apply(nil, score)
+	assert.Equal(t, 40, scored.Points, "only points from the rule set are multiplied")
+	assert.Equal(t, points.RulePoints{Rule: "Gold tier", Points: 10, Tier: "Gold"}, scored.Breakdown[3])
+	assert.Len(t, score.Breakdown, 3)
+
+	bronze := Tier{Name: "Bronze", Multiplier: 1}
+	assert.Equal(t, score, bronze.Apply(nil, score), "a multiplier of 1 adds nothing to the breakdown")
+}
+
+func TestStoreRecalculate(t *testing.T) {
+	ledger := accounts.NewStore()
+	store := NewStore(ledger)
+	gold := ledger.CreateAccount(accounts.Account{Name: "Gold"})
+	bronze := ledger.CreateAccount(accounts.Account{Name: "Bronze"})
+	_, err := ledger.Append(accounts.Entry{AccountId: gold.Id, Type: accounts.EntryEarn, Points: 6000, Spend: 40})
+	require.NoError(t, err)
+	_, err = ledger.Append(accounts.Entry{AccountId: bronze.Id, Type: accounts.EntryEarn, Points: 10, Spend: 400})
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Add(time.Minute)
+	assert.Equal(t, "Bronze", store.TierAt(gold.Id, now).Name)
+	changes := store.Recalculate(now)
+	require.Len(t, changes, 1)
+	assert.Equal(t, Change{AccountId: gold.Id, Tier: DefaultProgram().Tiers[2], Previous: "Bronze", Qualifying: 6000, From: now}, changes[0])
+	assert.Equal(t, "Gold", store.TierAt(gold.Id, now).Name)
+	assert.Equal(t, "Bronze", store.TierAt(gold.Id, now.Add(-time.Second)).Name, "tiers apply from when they were recalculated")
+	assert.Empty(t, store.Recalculate(now.Add(time.Second)), "unchanged tiers aren't recorded again")
+
+	// Spend of 400 reaches the second tier, while 40 falls back to the first
+	require.NoError(t, store.SetProgram(Program{Basis: BasisSpend, Tiers: []Tier{{Name: "Member", Multiplier: 1}, {Name: "Plus", Threshold: 100, Multiplier: 2}}}))
+	later := now.Add(time.Hour)
+	changes = store.Recalculate(later)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "Member", store.TierAt(gold.Id, later).Name)
+	assert.Equal(t, "Plus", store.TierAt(bronze.Id, later).Name)
+	assert.Len(t, store.History(gold.Id), 2)
+	assert.Empty(t, store.History("unknown"))
+
+	// Activity more than QualifyingMonths old no longer counts
+	changes = store.Recalculate(later.AddDate(0, QualifyingMonths, 1))
+	require.Len(t, changes, 1)
+	assert.Equal(t, bronze.Id, changes[0].AccountId)
+	assert.Equal(t, "Member", changes[0].Tier.Name)
+
+	assert.Error(t, store.SetProgram(Program{Basis: BasisSpend}))
+	assert.Equal(t, BasisSpend, store.Program().Basis, "invalid programs are rejected")
+}
+
+func TestStoreRecalculateDeletedReceipt(t *testing.T) {
+	ledger := accounts.NewStore()
+	store := NewStore(ledger)
+	account := ledger.CreateAccount(accounts.Account{Name: "Deleted"})
+	_, err := ledger.Append(accounts.Entry{AccountId: account.Id, Type: accounts.EntryEarn, Points: 600, ReceiptId: "kept", Spend: 60})
+	require.NoError(t, err)
+	_, err = ledger.Append(accounts.Entry{AccountId: account.Id, Type: accounts.EntryEarn, Points: 900, ReceiptId: "deleted", Spend: 90})
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Add(time.Minute)
+	changes := store.Recalculate(now)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "Silver", changes[0].Tier.Name)
+
+	// Deleting the receipt takes back its points and spend, so the account falls back to Bronze
+	_, err = ledger.Append(accounts.Entry{AccountId: account.Id, Type: accounts.EntryAdjust, Points: -900, ReceiptId: "deleted", Spend: -90, Reason: "receipt deleted"})
+	require.NoError(t, err)
+	// Adjustments without a receipt, such as manual corrections, aren't activity
+	_, err = ledger.Append(accounts.Entry{AccountId: account.Id, Type: accounts.EntryAdjust, Points: 1000, Reason: "goodwill"})
+	require.NoError(t, err)
+	later := now.Add(time.Minute)
+	changes = store.Recalculate(later)
+	require.Len(t, changes, 1)
+	assert.Equal(t, Change{AccountId: account.Id, Tier: DefaultProgram().Tiers[0], Previous: "Silver", Qualifying: 600, From: later}, changes[0])
+
+	require.NoError(t, store.SetProgram(Program{Basis: BasisSpend, Tiers: []Tier{{Name: "Member", Multiplier: 1}, {Name: "Plus", Threshold: 100, Multiplier: 2}}}))
+	qualifying, exists := store.Qualifying(account.Id, later)
+	require.True(t, exists)
+	assert.Equal(t, 60.0, qualifying)
+}
@@ -0,0 +1,141 @@
+package transfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"receipts/storage"
+	"strings"
+	"time"
+)
+
+const (
+	manifestName    string = "manifest.json"
+	receiptsDir     string = "receipts/"
+	archiveVersion  int    = 1
+	maxArchiveEntry int64  = 16 * 1024 * 1024
+	// Limits on the unpacked archive, since a small gzipped body can unpack to far more
+	maxArchiveBytes   int64 = 512 * 1024 * 1024
+	maxArchiveEntries int   = 1 << 20
+)
+
+// First entry of an archive, describing what it holds
+type manifest struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Count      int       `json:"count"`
+}
+
+func exportArchive(w io.Writer, receipts []storage.StoredReceipt) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	now := time.Now().UTC()
+
+	writeEntry := func(name string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = tarWriter.Write(data)
+		return err
+	}
+
+	if err := writeEntry(manifestName, manifest{Version: archiveVersion, ExportedAt: now, Count: len(receipts)}); err != nil {
+		return err
+	}
+	for _, stored := range receipts {
+		if err := writeEntry(receiptsDir+stored.Id.String()+".json", toRecord(stored)); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func importArchive(r io.Reader) ([]storage.StoredReceipt, error) {
+	return readArchive(r, maxArchiveBytes, maxArchiveEntries)
+}
+
+/*
+Reads an archive, rejecting it once its entries hold more than maxBytes unpacked or
+there are more than maxEntries of them, before reading any further.
+*/
+func readArchive(r io.Reader, maxBytes int64, maxEntries int) ([]storage.StoredReceipt, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("archive must be gzipped: %w", err)
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+
+	var receipts []storage.StoredReceipt
+	var archiveManifest *manifest
+	var entries int
+	var unpacked int64
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entries++; entries > maxEntries {
+			return nil, fmt.Errorf("archive has more than %d entries", maxEntries)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxArchiveEntry {
+			return nil, fmt.Errorf("%s is larger than %d bytes", header.Name, maxArchiveEntry)
+		}
+		if unpacked += header.Size; unpacked > maxBytes {
+			return nil, fmt.Errorf("archive unpacks to more than %d bytes", maxBytes)
+		}
+
+		var data bytes.Buffer
+		if _, err := io.Copy(&data, tarReader); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case header.Name == manifestName:
+			archiveManifest = &manifest{}
+			if err := json.Unmarshal(data.Bytes(), archiveManifest); err != nil {
+				return nil, fmt.Errorf("%s: %w", manifestName, err)
+			}
+			if archiveManifest.Version != archiveVersion {
+				return nil, fmt.Errorf("archive version %d is not supported", archiveManifest.Version)
+			}
+		case strings.HasPrefix(header.Name, receiptsDir) && path.Ext(header.Name) == ".json":
+			var record Record
+			if err := json.Unmarshal(data.Bytes(), &record); err != nil {
+				return nil, fmt.Errorf("%s: %w", header.Name, err)
+			}
+			stored, err := record.toStoredReceipt()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", header.Name, err)
+			}
+			receipts = append(receipts, stored)
+		}
+	}
+
+	if archiveManifest == nil {
+		return nil, fmt.Errorf("archive is missing %s", manifestName)
+	}
+	if archiveManifest.Count != len(receipts) {
+		return nil, fmt.Errorf("archive manifest lists %d receipts but %d were found", archiveManifest.Count, len(receipts))
+	}
+	return receipts, nil
+}
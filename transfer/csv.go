@@ -0,0 +1,152 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"receipts/models"
+	"receipts/storage"
+	"slices"
+	"time"
+)
+
+// Columns of a csv export, the metadata columns followed by models.CSVHeader
+var csvHeader = append([]string{"id", "createdAt", "updatedAt", "ruleVersion"}, models.CSVHeader...)
+
+// Exports every receipt with the extended receipt columns, so no field of a receipt is lost
+func exportCSV(w io.Writer, receipts []storage.StoredReceipt) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(slices.Concat(csvHeader, models.CSVExtendedHeader)); err != nil {
+		return err
+	}
+
+	for _, stored := range receipts {
+		receipt := stored.Receipt
+		for _, item := range receipt.Items {
+			row := []string{
+				stored.Id.String(),
+				stored.Metadata.CreatedAt.Format(time.RFC3339Nano),
+				stored.Metadata.UpdatedAt.Format(time.RFC3339Nano),
+				stored.Metadata.RuleVersion,
+				receipt.Retailer,
+				receipt.PurchaseDate.String(),
+				receipt.PurchaseTime.String(),
+				receipt.Total,
+				item.ShortDescription,
+				item.Price,
+			}
+			row = append(row, receipt.CSVExtendedColumns(item)...)
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+/*
+Rows of the same receipt must be next to each other, as they are in an export.
+Receipt level columns are taken from the first row of each receipt. Exports from
+before the extended receipt columns, which end at the price column, can be imported too.
+*/
+func importCSV(r io.Reader) ([]storage.StoredReceipt, error) {
+	csvReader := csv.NewReader(r)
+	// Every row must have as many columns as the header
+	csvReader.FieldsPerRecord = 0
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	extended, err := models.CheckCSVHeader(header, csvHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	seen := make(map[string]bool)
+	for rowNumber := 2; ; rowNumber++ {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(records) == 0 || records[len(records)-1].Id != row[0] {
+			if seen[row[0]] {
+				return nil, fmt.Errorf("row %d: rows of receipt %s must be next to each other", rowNumber, row[0])
+			}
+			seen[row[0]] = true
+
+			record, err := recordFromCSVRow(row)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", rowNumber, err)
+			}
+			if extended {
+				if err := record.Receipt.SetCSVExtendedColumns(row[len(csvHeader):]); err != nil {
+					return nil, fmt.Errorf("row %d: %w", rowNumber, err)
+				}
+			}
+			records = append(records, record)
+		}
+
+		receipt := records[len(records)-1].Receipt
+		item := models.Item{ShortDescription: row[8], Price: row[9]}
+		if extended {
+			if err := item.SetCSVExtendedColumns(row[len(csvHeader):]); err != nil {
+				return nil, fmt.Errorf("row %d: %w", rowNumber, err)
+			}
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+
+	receipts := make([]storage.StoredReceipt, 0, len(records))
+	for _, record := range records {
+		stored, err := record.toStoredReceipt()
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, stored)
+	}
+	return receipts, nil
+}
+
+// Builds a record without items from the first csv row of a receipt
+func recordFromCSVRow(row []string) (Record, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, row[1])
+	if err != nil {
+		return Record{}, fmt.Errorf("createdAt must be an RFC 3339 timestamp")
+	}
+	updatedAt, err := time.Parse(time.RFC3339Nano, row[2])
+	if err != nil {
+		return Record{}, fmt.Errorf("updatedAt must be an RFC 3339 timestamp")
+	}
+	purchaseDate, err := models.ParsePurchaseDate(row[5])
+	if err != nil {
+		return Record{}, err
+	}
+	purchaseTime, err := models.ParsePurchaseTime(row[6])
+	if err != nil {
+		return Record{}, err
+	}
+
+	return Record{
+		Id:          row[0],
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+		RuleVersion: row[3],
+		Receipt: &models.Receipt{
+			Retailer:     row[4],
+			PurchaseDate: purchaseDate,
+			PurchaseTime: purchaseTime,
+			Total:        row[7],
+		},
+	}, nil
+}
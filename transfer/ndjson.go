@@ -0,0 +1,49 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"receipts/storage"
+)
+
+func exportNDJSON(w io.Writer, receipts []storage.StoredReceipt) error {
+	encoder := json.NewEncoder(w)
+	for _, stored := range receipts {
+		if err := encoder.Encode(toRecord(stored)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importNDJSON(r io.Reader) ([]storage.StoredReceipt, error) {
+	var receipts []storage.StoredReceipt
+	scanner := bufio.NewScanner(r)
+	// Receipts can have up to models.MaxItems items, which is longer than the default line limit
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		stored, err := record.toStoredReceipt()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		receipts = append(receipts, stored)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
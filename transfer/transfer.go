@@ -0,0 +1,127 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"receipts/models"
+	"receipts/storage"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Supported file formats for moving receipts in and out of storage
+type Format string
+
+const (
+	// One json Record per line
+	FormatNDJSON Format = "ndjson"
+	// One row per item, with the receipt's id and metadata repeated on each of its rows
+	FormatCSV Format = "csv"
+	// Gzipped tar with a manifest and one json Record file per receipt
+	FormatArchive Format = "archive"
+)
+
+// Parses a format name, returning an error for unknown names
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatNDJSON, FormatCSV, FormatArchive:
+		return format, nil
+	default:
+		return "", fmt.Errorf("format must be one of ndjson, csv or archive")
+	}
+}
+
+// Content-Type used when serving an export in the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv"
+	case FormatArchive:
+		return "application/gzip"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// File extension for exports in the format
+func (f Format) Extension() string {
+	switch f {
+	case FormatCSV:
+		return ".csv"
+	case FormatArchive:
+		return ".tar.gz"
+	default:
+		return ".ndjson"
+	}
+}
+
+// A stored receipt as written to ndjson and archive exports
+type Record struct {
+	Id        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Version of the points rule set the receipt is scored with, empty if it was never recorded
+	RuleVersion string          `json:"ruleVersion,omitempty"`
+	Receipt     *models.Receipt `json:"receipt"`
+}
+
+func toRecord(stored storage.StoredReceipt) Record {
+	return Record{
+		Id:          stored.Id.String(),
+		CreatedAt:   stored.Metadata.CreatedAt,
+		UpdatedAt:   stored.Metadata.UpdatedAt,
+		RuleVersion: stored.Metadata.RuleVersion,
+		Receipt:     stored.Receipt,
+	}
+}
+
+// Checks the record's id and receipt the same way receipts are checked when first processed
+func (r Record) toStoredReceipt() (storage.StoredReceipt, error) {
+	id, err := uuid.Parse(r.Id)
+	if err != nil {
+		return storage.StoredReceipt{}, fmt.Errorf("invalid id %q", r.Id)
+	}
+	if r.Receipt == nil {
+		return storage.StoredReceipt{}, fmt.Errorf("receipt %s is missing", r.Id)
+	}
+	if err := r.Receipt.Validate(); err != nil {
+		return storage.StoredReceipt{}, fmt.Errorf("receipt %s: %w", r.Id, err)
+	}
+	return storage.StoredReceipt{
+		Id:       id,
+		Receipt:  r.Receipt,
+		Metadata: storage.Metadata{CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt, RuleVersion: r.RuleVersion},
+	}, nil
+}
+
+// Writes the receipts to w in the format.
+func Export(w io.Writer, format Format, receipts []storage.StoredReceipt) error {
+	switch format {
+	case FormatNDJSON:
+		return exportNDJSON(w, receipts)
+	case FormatCSV:
+		return exportCSV(w, receipts)
+	case FormatArchive:
+		return exportArchive(w, receipts)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+/*
+Reads receipts in the format from r. Every receipt is validated, and an error
+naming the first bad record is returned if any fail, so nothing partial is imported.
+*/
+func Import(r io.Reader, format Format) ([]storage.StoredReceipt, error) {
+	switch format {
+	case FormatNDJSON:
+		return importNDJSON(r)
+	case FormatCSV:
+		return importCSV(r)
+	case FormatArchive:
+		return importArchive(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
@@ -0,0 +1,258 @@
+package transfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"receipts/models"
+	"receipts/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReceipts(t *testing.T) []storage.StoredReceipt {
+	rawReceipts := []string{
+		`{
+			"retailer": "Walgreens",
+			"purchaseDate": "2022-01-02",
+			"purchaseTime": "08:13",
+			"total": "2.65",
+			"items": [
+				{"shortDescription": "Pepsi - 12-oz", "price": "1.25"},
+				{"shortDescription": "Dasani", "price": "1.40"}
+			]
+		}`,
+		`{
+			"retailer": "M&M Corner Market",
+			"purchaseDate": "2022-03-20",
+			"purchaseTime": "14:33",
+			"total": "2.25",
+			"items": [
+				{"shortDescription": "Gatorade", "price": "2.25"}
+			]
+		}`,
+	}
+
+	var receipts []storage.StoredReceipt
+	for i, rawReceipt := range rawReceipts {
+		var receipt models.Receipt
+		require.NoError(t, json.Unmarshal([]byte(rawReceipt), &receipt))
+		createdAt := time.Date(2022, 4, 1+i, 10, 30, 0, 123, time.UTC)
+		receipts = append(receipts, storage.StoredReceipt{
+			Id:       uuid.New(),
+			Receipt:  &receipt,
+			Metadata: storage.Metadata{CreatedAt: createdAt, UpdatedAt: createdAt.Add(time.Hour), RuleVersion: "v1"},
+		})
+	}
+	return receipts
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatNDJSON, FormatCSV, FormatArchive} {
+		t.Run(string(format), func(t *testing.T) {
+			receipts := testReceipts(t)
+			var buffer bytes.Buffer
+			require.NoError(t, Export(&buffer, format, receipts))
+
+			imported, err := Import(&buffer, format)
+			require.NoError(t, err)
+			require.Len(t, imported, len(receipts))
+			for i := range receipts {
+				assert.Equal(t, receipts[i].Id, imported[i].Id)
+				assert.Equal(t, *receipts[i].Receipt, *imported[i].Receipt)
+				assert.True(t, receipts[i].Metadata.CreatedAt.Equal(imported[i].Metadata.CreatedAt))
+				assert.True(t, receipts[i].Metadata.UpdatedAt.Equal(imported[i].Metadata.UpdatedAt))
+			}
+		})
+	}
+}
+
+func TestRoundTripExtended(t *testing.T) {
+	for _, format := range []Format{FormatNDJSON, FormatCSV, FormatArchive} {
+		t.Run(string(format), func(t *testing.T) {
+			receipts := testReceipts(t)
+			receipt := receipts[0].Receipt
+			receipt.Subtotal = "2.65"
+			receipt.Taxes = []models.Tax{{Name: "Sales Tax, 5%", Amount: "0.13"}, {Amount: "0.02"}}
+			receipt.Discounts = []models.Discount{{Description: "Coupon", Code: "SAVE", Amount: "0.25"}}
+			receipt.Tip = "0.10"
+			receipt.PaymentMethod = models.PaymentGiftCard
+			receipt.Items[0].Quantity, receipt.Items[0].UnitPrice = 2.5, "0.50"
+			receipt.Items[1].Quantity = 1
+			require.NoError(t, receipt.Validate())
+
+			var buffer bytes.Buffer
+			require.NoError(t, Export(&buffer, format, receipts))
+			imported, err := Import(&buffer, format)
+			require.NoError(t, err)
+			require.Len(t, imported, len(receipts))
+			for i := range receipts {
+				assert.Equal(t, *receipts[i].Receipt, *imported[i].Receipt)
+			}
+		})
+	}
+}
+
+func TestRoundTripEmpty(t *testing.T) {
+	for _, format := range []Format{FormatNDJSON, FormatCSV, FormatArchive} {
+		t.Run(string(format), func(t *testing.T) {
+			var buffer bytes.Buffer
+			require.NoError(t, Export(&buffer, format, nil))
+			imported, err := Import(&buffer, format)
+			require.NoError(t, err)
+			assert.Empty(t, imported)
+		})
+	}
+}
+
+func TestImportNDJSONErrors(t *testing.T) {
+	id := uuid.New().String()
+	tests := []struct {
+		testName string
+		input    string
+	}{
+		{
+			testName: "InvalidJson",
+			input:    `{"id": "` + id + `"`,
+		},
+		{
+			testName: "InvalidId",
+			input:    `{"id": "1234", "receipt": {"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25", "items": [{"shortDescription": "Pepsi", "price": "1.25"}]}}`,
+		},
+		{
+			testName: "MissingReceipt",
+			input:    `{"id": "` + id + `"}`,
+		},
+		{
+			testName: "InvalidReceipt",
+			input:    `{"id": "` + id + `", "receipt": {"retailer": "Target!", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25", "items": [{"shortDescription": "Pepsi", "price": "1.25"}]}}`,
+		},
+		{
+			testName: "UnknownField",
+			input:    `{"id": "` + id + `", "points": 5, "receipt": {"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25", "items": [{"shortDescription": "Pepsi", "price": "1.25"}]}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			_, err := Import(strings.NewReader(test.input), FormatNDJSON)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	header := "id,createdAt,updatedAt,ruleVersion,retailer,purchaseDate,purchaseTime,total,shortDescription,price\n"
+	idA := uuid.New().String()
+	idB := uuid.New().String()
+	row := func(id string, description string) string {
+		return id + ",2022-04-01T10:30:00Z,2022-04-01T10:30:00Z,v1,Target,2022-01-02,13:13,1.25," + description + ",1.25\n"
+	}
+
+	tests := []struct {
+		testName string
+		input    string
+	}{
+		{
+			testName: "WrongHeader",
+			input:    "retailer,purchaseDate,purchaseTime,total,shortDescription,price\n",
+		},
+		{
+			testName: "SplitReceipt",
+			input:    header + row(idA, "Pepsi") + row(idB, "Pepsi") + row(idA, "Dasani"),
+		},
+		{
+			testName: "InvalidCreatedAt",
+			input:    header + strings.Replace(row(idA, "Pepsi"), "2022-04-01T10:30:00Z", "yesterday", 1),
+		},
+		{
+			testName: "InvalidReceipt",
+			input:    header + row(idA, "Pepsi!"),
+		},
+		{
+			testName: "InvalidQuantity",
+			input: strings.Replace(header, "price\n", "price,subtotal,taxes,discounts,tip,paymentMethod,quantity,unitPrice\n", 1) +
+				strings.Replace(row(idA, "Pepsi"), "\n", ",,,,,,lots,\n", 1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			_, err := Import(strings.NewReader(test.input), FormatCSV)
+			assert.Error(t, err)
+		})
+	}
+
+	// Exports from before the extended columns still import
+	imported, err := Import(strings.NewReader(header+row(idA, "Pepsi")), FormatCSV)
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	assert.Equal(t, []models.Item{{ShortDescription: "Pepsi", Price: "1.25"}}, imported[0].Receipt.Items)
+}
+
+func TestImportArchiveErrors(t *testing.T) {
+	// Builds a gzipped tar holding the given files
+	archive := func(files map[string]string) *bytes.Buffer {
+		var buffer bytes.Buffer
+		gzipWriter := gzip.NewWriter(&buffer)
+		tarWriter := tar.NewWriter(gzipWriter)
+		for name, content := range files {
+			require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+			_, err := tarWriter.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tarWriter.Close())
+		require.NoError(t, gzipWriter.Close())
+		return &buffer
+	}
+
+	t.Run("NotGzipped", func(t *testing.T) {
+		_, err := Import(strings.NewReader("not an archive"), FormatArchive)
+		assert.Error(t, err)
+	})
+
+	t.Run("MissingManifest", func(t *testing.T) {
+		_, err := Import(archive(map[string]string{}), FormatArchive)
+		assert.Error(t, err)
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		_, err := Import(archive(map[string]string{manifestName: `{"version": 2, "count": 0}`}), FormatArchive)
+		assert.Error(t, err)
+	})
+
+	t.Run("CountMismatch", func(t *testing.T) {
+		_, err := Import(archive(map[string]string{manifestName: `{"version": 1, "count": 3}`}), FormatArchive)
+		assert.Error(t, err)
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		var exported bytes.Buffer
+		require.NoError(t, Export(&exported, FormatArchive, testReceipts(t)))
+		data := exported.Bytes()
+
+		receipts, err := readArchive(bytes.NewReader(data), 1<<20, 3)
+		require.NoError(t, err)
+		assert.Len(t, receipts, 2)
+		_, err = readArchive(bytes.NewReader(data), 1<<20, 2)
+		assert.ErrorContains(t, err, "more than 2 entries")
+		_, err = readArchive(bytes.NewReader(data), 500, 3)
+		assert.ErrorContains(t, err, "more than 500 bytes")
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"ndjson", "csv", "archive"} {
+		format, err := ParseFormat(name)
+		assert.NoError(t, err)
+		assert.Equal(t, Format(name), format)
+	}
+	_, err := ParseFormat("xml")
+	assert.Error(t, err)
+}